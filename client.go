@@ -4,11 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
-	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/http/httputil"
 	"time"
 )
 
@@ -36,7 +34,7 @@ func NewClient(clientID string, secret string, APIBase string) (*Client, error)
 // Endpoint: POST /v1/oauth2/token
 func (c *Client) GetAccessToken() (*TokenResponse, error) {
 	buf := bytes.NewBuffer([]byte("grant_type=client_credentials"))
-	req, err := http.NewRequest("POST", "https://api.trustar.co/oauth/token", buf)
+	req, err := c.newRequest("GetAccessToken", "POST", "https://api.trustar.co/oauth/token", buf)
 	if err != nil {
 		return &TokenResponse{}, err
 	}
@@ -69,7 +67,8 @@ func (c *Client) SetAccessToken(token string) {
 }
 
 // SetLog will set/change the output destination.
-// If log file is set paypalsdk will log all requests and responses to this Writer
+// If log file is set the client will dump all requests and responses to this Writer.
+// The dump is taken inside every middleware, so requests are logged as sent, once per retry attempt.
 func (c *Client) SetLog(log io.Writer) {
 	c.Log = log
 }
@@ -84,22 +83,7 @@ func (c *Client) Send(req *http.Request, v interface{}) error {
 		data []byte
 	)
 
-	// add headers from https://docs.trustar.co/api/index.html#headers to play nice
-	req.Header.Set("Client-Type", "API")
-	req.Header.Set("Client-Version", "v0.1.0")
-	req.Header.Set("Client-Metatag", "github.com/jakewarren/trustar-golang")
-
-	// Set default headers
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Accept-Language", "en_US")
-
-	// Default values for headers
-	if req.Header.Get("Content-type") == "" {
-		req.Header.Set("Content-type", "application/json")
-	}
-
-	resp, err = c.Client.Do(req)
-	c.log(req, resp)
+	resp, err = c.do(req)
 
 	if err != nil {
 		return err
@@ -141,7 +125,10 @@ func (c *Client) SendWithAuth(req *http.Request, v interface{}) error {
 	if c.Token != nil {
 		if !c.tokenExpiresAt.IsZero() && time.Until(c.tokenExpiresAt) < RequestNewTokenBeforeExpiresIn {
 			// c.Token will be updated in GetAccessToken call
-			if _, err := c.GetAccessToken(); err != nil {
+			_, span := c.startSpan(req.Context(), "trustar.TokenRefresh", Attr("trustar.operation", Operation(req)))
+			_, err := c.GetAccessToken()
+			endSpan(span, nil, err)
+			if err != nil {
 				c.Unlock()
 				return err
 			}
@@ -156,28 +143,15 @@ func (c *Client) SendWithAuth(req *http.Request, v interface{}) error {
 	return c.Send(req, v)
 }
 
-// SendWithBasicAuth makes a request to the API using clientID:secret basic auth
+// SendWithBasicAuth makes a request to the API using clientID:secret basic auth.
+// The credentials come from c.Credentials when it is set.
 func (c *Client) SendWithBasicAuth(req *http.Request, v interface{}) error {
-	req.SetBasicAuth(c.ClientID, c.Secret)
-
-	return c.Send(req, v)
-}
-
-// log will dump request and response to the log file
-func (c *Client) log(r *http.Request, resp *http.Response) {
-	if c.Log != nil {
-		var (
-			reqDump  []byte
-			respDump []byte
-		)
+	clientID, secret, err := c.credentials()
+	if err != nil {
+		return err
+	}
 
-		if r != nil {
-			reqDump, _ = httputil.DumpRequest(r, true)
-		}
-		if resp != nil {
-			respDump, _ = httputil.DumpResponse(resp, true)
-		}
+	req.SetBasicAuth(clientID, secret)
 
-		_, _ = c.Log.Write([]byte(fmt.Sprintf("Request: %s\nResponse: %s\n", string(reqDump), string(respDump))))
-	}
+	return c.Send(req, v)
 }
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestDryRun(t *testing.T) {
+	var writes []string
+	res := cli(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			writes = append(writes, r.Method+" "+r.URL.Path)
+		}
+		http.NotFound(w, r)
+	}, "-dry-run", "reports", "delete", "guid-1")
+
+	if res.code != exitOK {
+		t.Fatalf("got exit code %d\n%s", res.code, res.stderr)
+	}
+	if len(writes) != 0 {
+		t.Errorf("got write requests %v, want none in dry-run mode", writes)
+	}
+	if !strings.HasPrefix(res.stderr, "DRY RUN DeleteReport: DELETE ") || !strings.Contains(res.stderr, "/reports/guid-1") {
+		t.Errorf("got stderr %q, want the dry-run request", res.stderr)
+	}
+	if strings.Contains(res.stderr, "Authorization") {
+		t.Errorf("got stderr %q, want no credentials", res.stderr)
+	}
+}
+
+func TestDryRunIndicatorsSubmit(t *testing.T) {
+	var writes []string
+	res := cli(t, func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method != "GET":
+			writes = append(writes, r.Method+" "+r.URL.Path)
+			http.NotFound(w, r)
+		case r.URL.Path == "/enclaves":
+			fmt.Fprint(w, `[{"id":"e1","name":"Ops","read":true,"create":true,"update":true}]`)
+		case r.URL.Path == "/request-quotas":
+			fmt.Fprint(w, `[{"maxRequests":100}]`)
+		default:
+			http.NotFound(w, r)
+		}
+	}, "-dry-run", "indicators", "submit", "-tags", "seen", "1.2.3.4", "evil.com")
+
+	if res.code != exitOK {
+		t.Fatalf("got exit code %d\n%s", res.code, res.stderr)
+	}
+	if len(writes) != 0 {
+		t.Errorf("got write requests %v, want none in dry-run mode", writes)
+	}
+	if res.stdout != "submitted 2 indicators, 0 failed\n" {
+		t.Errorf("got stdout %q", res.stdout)
+	}
+	for _, want := range []string{"DRY RUN SubmitIndicators: POST ", `"value": "1.2.3.4"`, `"value": "evil.com"`, `"name": "seen"`} {
+		if !strings.Contains(res.stderr, want) {
+			t.Errorf("got stderr %q, want it to contain %q", res.stderr, want)
+		}
+	}
+}
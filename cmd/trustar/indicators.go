@@ -0,0 +1,281 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	trustar "github.com/jakewarren/trustar-golang"
+	"github.com/jakewarren/trustar-golang/importer"
+)
+
+var indicatorColumns = []string{"value", "indicatorType", "priorityLevel", "whitelisted"}
+
+func runIndicatorsSearch(a *app, args []string) error {
+	fs := a.newFlagSet("indicators search", "<term>")
+	out := addOutputFlags(fs)
+	query := addQueryFlags(fs)
+	types := fs.String("types", "", "comma separated indicator types to return, such as IP,URL")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("indicators search requires a single search term")
+	}
+
+	v, err := query.values()
+	if err != nil {
+		return err
+	}
+	v.Set("searchTerm", fs.Arg(0))
+	setList(v, "indicatorTypes", *types)
+
+	return a.printIndicators(out, query.all, v, func(c *trustar.Client, v url.Values) ([]trustar.Indicator, error) {
+		sir, err := c.SearchIndicators(v)
+		return sir.Items, err
+	}, func(c *trustar.Client, v url.Values, fn func(trustar.Indicator) error) error {
+		return c.ForEachIndicator(v, fn)
+	})
+}
+
+func runIndicatorsRelated(a *app, args []string) error {
+	fs := a.newFlagSet("indicators related", "<indicator>...")
+	out := addOutputFlags(fs)
+	query := addQueryFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("indicators related requires at least one indicator")
+	}
+
+	v, err := query.values()
+	if err != nil {
+		return err
+	}
+	for _, i := range fs.Args() {
+		v.Add("indicators", i)
+	}
+
+	return a.printIndicators(out, query.all, v, func(c *trustar.Client, v url.Values) ([]trustar.Indicator, error) {
+		rir, err := c.FindRelatedIndicators(v)
+		return rir.Items, err
+	}, func(c *trustar.Client, v url.Values, fn func(trustar.Indicator) error) error {
+		return c.ForEachRelatedIndicator(v, fn)
+	})
+}
+
+// printIndicators prints a single page of indicators, or every page when all is set
+func (a *app) printIndicators(out *outputFlags, all bool, v url.Values,
+	page func(*trustar.Client, url.Values) ([]trustar.Indicator, error),
+	each func(*trustar.Client, url.Values, func(trustar.Indicator) error) error) error {
+
+	sink, err := out.sink(a.stdout, indicatorColumns...)
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	if all {
+		err = each(c, v, func(i trustar.Indicator) error { return sink.Write(i) })
+	} else {
+		var items []trustar.Indicator
+		if items, err = page(c, v); err == nil {
+			for _, i := range items {
+				if err = sink.Write(i); err != nil {
+					break
+				}
+			}
+		}
+	}
+
+	if ferr := sink.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func runIndicatorsMetadata(a *app, args []string) error {
+	fs := a.newFlagSet("indicators metadata", "<indicator>...")
+	out := addOutputFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("indicators metadata requires at least one indicator")
+	}
+
+	sink, err := out.sink(a.stdout, "value", "indicatorType", "priorityLevel", "sightings", "firstSeen", "lastSeen", "tags")
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	indicators := make([]trustar.Indicator, fs.NArg())
+	for i, value := range fs.Args() {
+		indicators[i] = trustar.Indicator{Value: value}
+	}
+
+	// a failed chunk still leaves the metadata of the others to print before the error is returned
+	batch, err := c.GetIndicatorMetadataBatch(indicators, trustar.BatchOptions{})
+	if err != nil && len(batch.Metadata) == 0 {
+		return err
+	}
+
+	for _, ind := range indicators {
+		m, ok := batch.Metadata[ind.Value]
+		if !ok {
+			continue
+		}
+		if werr := sink.Write(m); werr != nil {
+			return werr
+		}
+	}
+	for _, value := range batch.Missing {
+		fmt.Fprintf(a.stderr, "no metadata for %s\n", value)
+	}
+	if ferr := sink.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func runIndicatorsTrending(a *app, args []string) error {
+	fs := a.newFlagSet("indicators trending", "")
+	out := addOutputFlags(fs)
+	indicatorType := fs.String("type", "", "only return indicators of this type")
+	days := fs.Int("days", 0, "number of days back to look for trends")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	sink, err := out.sink(a.stdout, "value", "indicatorType", "correlationCount")
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	v := url.Values{}
+	if *indicatorType != "" {
+		v.Set("type", *indicatorType)
+	}
+	if *days > 0 {
+		v.Set("daysBack", strconv.Itoa(*days))
+	}
+
+	ti, err := c.GetTrendingIndicators(v)
+	if err != nil {
+		return err
+	}
+
+	for _, i := range ti {
+		if err = sink.Write(i); err != nil {
+			return err
+		}
+	}
+	return sink.Flush()
+}
+
+func runIndicatorsSubmit(a *app, args []string) error {
+	fs := a.newFlagSet("indicators submit", "[<indicator>...]")
+	enclaves := fs.String("enclaves", "", "comma separated enclave IDs or names to submit to (default from the profile)")
+	tags := fs.String("tags", "", "comma separated tag names applied to every indicator")
+	file := fs.String("file", "", "CSV or JSONL file of indicators, - for stdin")
+	format := fs.String("format", "", "format of -file: csv or jsonl (default from the file extension)")
+	chunkSize := fs.Int("chunk-size", importer.DefaultChunkSize, "indicators per request")
+	concurrency := fs.Int("concurrency", importer.DefaultConcurrency, "concurrent requests")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	if (*file == "") == (fs.NArg() == 0) {
+		return usagef("indicators submit requires either -file or indicator arguments")
+	}
+
+	opts := importer.Options{
+		ChunkSize:   *chunkSize,
+		Concurrency: *concurrency,
+	}
+
+	var err error
+	if opts.EnclaveIDs, err = a.defaultEnclaves(*enclaves); err != nil {
+		return err
+	}
+	if len(opts.EnclaveIDs) == 0 {
+		return usagef("indicators submit requires -enclaves")
+	}
+	for _, t := range splitList(*tags) {
+		opts.Tags = append(opts.Tags, trustar.IndicatorTag{Name: t})
+	}
+
+	var (
+		rows    []importer.Row
+		invalid []importer.Result
+	)
+
+	if *file != "" {
+		data, err := a.readFile(*file)
+		if err != nil {
+			return err
+		}
+
+		if *format == "" {
+			*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(*file)), ".")
+		}
+
+		switch *format {
+		case "csv":
+			rows, invalid, err = importer.ReadCSV(bytes.NewReader(data))
+		case "jsonl", "ndjson":
+			rows, invalid, err = importer.ReadJSONL(bytes.NewReader(data))
+		default:
+			return usagef("cannot tell the format of %s, set -format", *file)
+		}
+		if err != nil {
+			return err
+		}
+	} else {
+		for i, value := range fs.Args() {
+			rows = append(rows, importer.Row{Row: i + 1, Content: trustar.IndicatorContent{Value: value}})
+		}
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+	opts.Enclaves = trustar.NewEnclaveRegistry(c)
+
+	report, err := importer.Submit(c, rows, opts)
+	if err != nil {
+		return err
+	}
+
+	for _, res := range invalid {
+		fmt.Fprintf(a.stderr, "row %d %s: %v\n", res.Row, res.Value, res.Err)
+	}
+	for _, res := range report.Errors() {
+		fmt.Fprintf(a.stderr, "row %d %s: %v\n", res.Row, res.Value, res.Err)
+	}
+
+	fmt.Fprintf(a.stdout, "submitted %d indicators, %d failed\n", report.Submitted, report.Failed+len(invalid))
+	if report.Failed+len(invalid) > 0 {
+		return fmt.Errorf("%d indicators could not be submitted", report.Failed+len(invalid))
+	}
+	return nil
+}
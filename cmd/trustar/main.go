@@ -0,0 +1,253 @@
+// Command trustar queries and updates TruSTAR from the command line.
+//
+// Credentials are read from a profile in the config file (~/.trustar/config by default), with the
+// TRUSTAR_CLIENT_ID, TRUSTAR_CLIENT_SECRET and TRUSTAR_API_BASE environment variables taking precedence.
+//
+// Exit codes: 0 success, 1 API or runtime error, 2 usage error, 3 configuration error, 4 not found.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	trustar "github.com/jakewarren/trustar-golang"
+	"github.com/jakewarren/trustar-golang/config"
+)
+
+const (
+	exitOK       = 0
+	exitError    = 1
+	exitUsage    = 2
+	exitConfig   = 3
+	exitNotFound = 4
+)
+
+// command is a single subcommand
+type command struct {
+	name  string
+	usage string
+	run   func(app *app, args []string) error
+}
+
+// usageError marks errors caused by invalid arguments
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, a ...interface{}) error {
+	return usageError{fmt.Sprintf(format, a...)}
+}
+
+// configError marks errors loading credentials
+type configError struct {
+	err error
+}
+
+func (e configError) Error() string { return e.err.Error() }
+
+// app holds the state shared by all subcommands
+type app struct {
+	configPath  string
+	profileName string
+	profile     *config.Profile
+	dryRun      bool
+	stdin       io.Reader
+	stdout      io.Writer
+	stderr      io.Writer
+	client      *trustar.Client
+}
+
+var groups = map[string][]command{
+	"reports": {
+		{"list", "list reports, newest first", runReportsList},
+		{"search", "search reports for a term", runReportsSearch},
+		{"get", "get the details of a report", runReportsGet},
+		{"submit", "submit a new report", runReportsSubmit},
+		{"update", "update an existing report", runReportsUpdate},
+		{"delete", "delete a report", runReportsDelete},
+		{"copy", "copy a report to other enclaves", runReportsCopy},
+		{"move", "move a report to other enclaves", runReportsMove},
+		{"correlated", "find reports containing any of the given indicators", runReportsCorrelated},
+		{"indicators", "list the indicators of a report", runReportsIndicators},
+	},
+	"indicators": {
+		{"search", "search indicators for a term", runIndicatorsSearch},
+		{"related", "find indicators correlated with the given indicators", runIndicatorsRelated},
+		{"metadata", "get the metadata of indicators", runIndicatorsMetadata},
+		{"trending", "list trending community indicators", runIndicatorsTrending},
+		{"submit", "submit indicators from arguments or a CSV/JSONL file", runIndicatorsSubmit},
+	},
+	"whitelist": {
+		{"get", "list whitelisted indicators", runWhitelistGet},
+		{"add", "whitelist indicator values", runWhitelistAdd},
+		{"delete", "remove an indicator from the whitelist", runWhitelistDelete},
+		{"sync", "make the whitelist match a file of values", runWhitelistSync},
+	},
+}
+
+var topLevel = []command{
+	{"enclaves", "list the enclaves the user has access to", runEnclaves},
+	{"quotas", "show the request quotas", runQuotas},
+	{"ping", "check that the API can be reached", runPing},
+	{"version", "show the current API version", runVersion},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	a := &app{stdin: stdin, stdout: stdout, stderr: stderr}
+
+	fs := flag.NewFlagSet("trustar", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&a.configPath, "config", "", "config file (default $TRUSTAR_CONFIG or ~/.trustar/config)")
+	fs.StringVar(&a.profileName, "profile", "", "config profile to use (default $TRUSTAR_PROFILE or default)")
+	fs.BoolVar(&a.dryRun, "dry-run", false, "print write requests to stderr instead of sending them")
+	fs.Usage = func() { printUsage(stderr) }
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	cmd, rest, err := lookup(fs.Args())
+	if err != nil {
+		fmt.Fprintf(stderr, "trustar: %v\n\n", err)
+		printUsage(stderr)
+		return exitUsage
+	}
+
+	return exitCode(stderr, cmd.run(a, rest))
+}
+
+// lookup finds the subcommand named by the leading arguments
+func lookup(args []string) (command, []string, error) {
+	if len(args) == 0 {
+		return command{}, nil, errors.New("no command given")
+	}
+
+	for _, c := range topLevel {
+		if c.name == args[0] {
+			return c, args[1:], nil
+		}
+	}
+
+	cmds, ok := groups[args[0]]
+	if !ok {
+		return command{}, nil, fmt.Errorf("unknown command %q", args[0])
+	}
+	if len(args) < 2 {
+		return command{}, nil, fmt.Errorf("%s requires a subcommand", args[0])
+	}
+	for _, c := range cmds {
+		if c.name == args[1] {
+			return c, args[2:], nil
+		}
+	}
+
+	return command{}, nil, fmt.Errorf("unknown command %q", strings.Join(args[:2], " "))
+}
+
+func exitCode(stderr io.Writer, err error) int {
+	if err == nil {
+		return exitOK
+	}
+
+	if err == flag.ErrHelp {
+		return exitOK
+	}
+
+	fmt.Fprintf(stderr, "trustar: %v\n", err)
+
+	switch err.(type) {
+	case usageError:
+		return exitUsage
+	case configError:
+		return exitConfig
+	}
+	if trustar.IsNotFound(err) {
+		return exitNotFound
+	}
+
+	return exitError
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: trustar [-config file] [-profile name] [-dry-run] <command> [subcommand] [flags] [args]")
+	fmt.Fprintln(w)
+	for _, group := range []string{"reports", "indicators", "whitelist"} {
+		for _, c := range groups[group] {
+			fmt.Fprintf(w, "  %-22s %s\n", group+" "+c.name, c.usage)
+		}
+	}
+	for _, c := range topLevel {
+		fmt.Fprintf(w, "  %-22s %s\n", c.name, c.usage)
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "Run a command with -h to see its flags.")
+}
+
+// Profile returns the selected credential profile, loading it on first use
+func (a *app) Profile() (*config.Profile, error) {
+	if a.profile != nil {
+		return a.profile, nil
+	}
+
+	p, err := config.LoadProfile(a.configPath, a.profileName)
+	if err != nil {
+		return nil, configError{err}
+	}
+
+	a.profile = p
+	return p, nil
+}
+
+// Client returns an authenticated client, creating it on first use
+func (a *app) Client() (*trustar.Client, error) {
+	if a.client != nil {
+		return a.client, nil
+	}
+
+	p, err := a.Profile()
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := p.NewClient()
+	if err != nil {
+		return nil, configError{err}
+	}
+
+	if _, err = c.GetAccessToken(); err != nil {
+		return nil, configError{fmt.Errorf("error while getting access token: %v", err)}
+	}
+
+	if a.dryRun {
+		c.SetDryRun(a.stderr)
+	}
+
+	a.client = c
+	return c, nil
+}
+
+// defaultEnclaves returns the enclaves given on the command line, or the profile's default enclaves
+func (a *app) defaultEnclaves(list string) ([]string, error) {
+	if ids := splitList(list); len(ids) > 0 {
+		return ids, nil
+	}
+
+	p, err := a.Profile()
+	if err != nil {
+		return nil, err
+	}
+	return p.EnclaveIDs, nil
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// redirectTransport sends requests for the TruSTAR token endpoint to the test server
+type redirectTransport struct {
+	target *url.URL
+	next   http.RoundTripper
+}
+
+func (t redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Host == "api.trustar.co" {
+		req = req.Clone(req.Context())
+		req.URL.Scheme = t.target.Scheme
+		req.URL.Host = t.target.Host
+		req.Host = t.target.Host
+	}
+	return t.next.RoundTrip(req)
+}
+
+// result is the outcome of one command line
+type result struct {
+	code   int
+	stdout string
+	stderr string
+}
+
+// cli runs the command line against handler, with a profile pointing at it. Token requests are answered
+// before they reach handler.
+func cli(t *testing.T, handler http.HandlerFunc, args ...string) result {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/token" {
+			fmt.Fprint(w, `{"access_token":"token","expires_in":3600}`)
+			return
+		}
+		handler(w, r)
+	}))
+	defer srv.Close()
+
+	target, _ := url.Parse(srv.URL)
+	defer func(rt http.RoundTripper) { http.DefaultTransport = rt }(http.DefaultTransport)
+	http.DefaultTransport = redirectTransport{target: target, next: http.DefaultTransport}
+
+	for _, env := range os.Environ() {
+		if strings.HasPrefix(env, "TRUSTAR_") {
+			os.Unsetenv(strings.SplitN(env, "=", 2)[0])
+		}
+	}
+
+	dir, err := ioutil.TempDir("", "trustar-cli")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config")
+	profile := fmt.Sprintf("[default]\nclient_id = id\nclient_secret = secret\napi_base = %s/\nenclave_ids = e1\n", srv.URL)
+	if err := ioutil.WriteFile(path, []byte(profile), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-config", path}, args...), strings.NewReader(""), &stdout, &stderr)
+	return result{code, stdout.String(), stderr.String()}
+}
+
+func TestExitCodes(t *testing.T) {
+	notFound := func(w http.ResponseWriter, r *http.Request) { http.NotFound(w, r) }
+	serverError := func(w http.ResponseWriter, r *http.Request) { http.Error(w, "boom", http.StatusInternalServerError) }
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		args    []string
+		code    int
+		stderr  string
+	}{
+		{name: "no command", args: nil, code: exitUsage, stderr: "no command given"},
+		{name: "unknown command", args: []string{"nope"}, code: exitUsage, stderr: `unknown command "nope"`},
+		{name: "missing subcommand", args: []string{"reports"}, code: exitUsage, stderr: "reports requires a subcommand"},
+		{name: "unknown subcommand", args: []string{"reports", "nope"}, code: exitUsage, stderr: `unknown command "reports nope"`},
+		{name: "missing argument", args: []string{"reports", "get"}, code: exitUsage, stderr: "reports get requires a report ID"},
+		{name: "unknown flag", args: []string{"reports", "get", "-nope", "x"}, code: exitUsage},
+		{name: "unknown output format", args: []string{"quotas", "-o", "xml"}, code: exitUsage, stderr: `unknown output format "xml"`},
+		{name: "help", args: []string{"reports", "get", "-h"}, code: exitOK, stderr: "usage: trustar reports get"},
+		{name: "not found", handler: notFound, args: []string{"reports", "get", "x"}, code: exitNotFound, stderr: "404"},
+		{name: "API error", handler: serverError, args: []string{"ping"}, code: exitError, stderr: "500"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := tt.handler
+			if handler == nil {
+				handler = func(w http.ResponseWriter, r *http.Request) {
+					t.Errorf("unexpected request %s %s", r.Method, r.URL)
+				}
+			}
+
+			res := cli(t, handler, tt.args...)
+			if res.code != tt.code {
+				t.Errorf("got exit code %d, want %d\n%s", res.code, tt.code, res.stderr)
+			}
+			if !strings.Contains(res.stderr, tt.stderr) {
+				t.Errorf("got stderr %q, want it to contain %q", res.stderr, tt.stderr)
+			}
+		})
+	}
+}
+
+func TestConfigError(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-config", filepath.Join("testdata", "missing"), "ping"}, strings.NewReader(""), &stdout, &stderr)
+	if code != exitConfig {
+		t.Errorf("got exit code %d, want %d\n%s", code, exitConfig, stderr.String())
+	}
+}
+
+func TestIndicatorsTrending(t *testing.T) {
+	var query url.Values
+	res := cli(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/indicators/community-trending" {
+			http.NotFound(w, r)
+			return
+		}
+		query = r.URL.Query()
+		fmt.Fprint(w, `[{"correlationCount":7,"value":"evil.com","indicatorType":"URL"}]`)
+	}, "indicators", "trending", "-type", "URL", "-days", "3")
+
+	if res.code != exitOK {
+		t.Fatalf("got exit code %d\n%s", res.code, res.stderr)
+	}
+	if query.Get("type") != "URL" || query.Get("daysBack") != "3" {
+		t.Errorf("got query %v, want type=URL and daysBack=3", query)
+	}
+
+	want := "value     indicatorType  correlationCount\nevil.com  URL            7\n"
+	if res.stdout != want {
+		t.Errorf("got\n%q\nwant\n%q", res.stdout, want)
+	}
+}
+
+func TestReportsGetExternal(t *testing.T) {
+	var uri string
+	res := cli(t, func(w http.ResponseWriter, r *http.Request) {
+		uri = r.URL.RequestURI()
+		fmt.Fprint(w, `{"id":"guid-1","externalId":"INC 1","title":"Phish"}`)
+	}, "reports", "get", "-external", "-o", "jsonl", "-columns", "id,title", "INC 1")
+
+	if res.code != exitOK {
+		t.Fatalf("got exit code %d\n%s", res.code, res.stderr)
+	}
+	if uri != "/reports/INC%201?idType=external" {
+		t.Errorf("got request %q", uri)
+	}
+	if want := `{"id":"guid-1","title":"Phish"}` + "\n"; res.stdout != want {
+		t.Errorf("got %q, want %q", res.stdout, want)
+	}
+}
+
+func TestWhitelistSyncMaxDelete(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trustar-whitelist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "whitelist.txt")
+	if err := ioutil.WriteFile(path, []byte("keep.com\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		maxDelete string
+		code      int
+		deletes   int
+	}{
+		{"0", exitError, 0},
+		{"0.5", exitOK, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.maxDelete, func(t *testing.T) {
+			deletes := 0
+			res := cli(t, func(w http.ResponseWriter, r *http.Request) {
+				switch r.Method {
+				case "GET":
+					fmt.Fprint(w, `{"items":[{"value":"keep.com","indicatorType":"DOMAIN"},{"value":"old.com","indicatorType":"DOMAIN"}]}`)
+				case "DELETE":
+					deletes++
+				}
+			}, "whitelist", "sync", "-max-delete", tt.maxDelete, path)
+
+			if res.code != tt.code || deletes != tt.deletes {
+				t.Errorf("got exit code %d and %d deletes, want %d and %d\n%s", res.code, deletes, tt.code, tt.deletes, res.stderr)
+			}
+			if tt.code != exitOK && !strings.Contains(res.stderr, "refusing to delete 1 of 2 whitelist entries, more than the allowed 0%") {
+				t.Errorf("got stderr %q", res.stderr)
+			}
+		})
+	}
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+func runEnclaves(a *app, args []string) error {
+	fs := a.newFlagSet("enclaves", "")
+	out := addOutputFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	sink, err := out.sink(a.stdout, "id", "name", "type", "read", "create", "update")
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	enclaves, err := c.GetEnclaves()
+	if err != nil {
+		return err
+	}
+
+	for _, e := range enclaves {
+		if err = sink.Write(e); err != nil {
+			return err
+		}
+	}
+	return sink.Flush()
+}
+
+func runQuotas(a *app, args []string) error {
+	fs := a.newFlagSet("quotas", "")
+	out := addOutputFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	sink, err := out.sink(a.stdout, "guid", "usedRequests", "maxRequests", "timeWindow", "nextResetTime")
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	quotas, err := c.RequestQuotas()
+	if err != nil {
+		return err
+	}
+
+	for _, q := range quotas {
+		if err = sink.Write(q); err != nil {
+			return err
+		}
+	}
+	return sink.Flush()
+}
+
+func runPing(a *app, args []string) error {
+	return a.printString("ping", args, func() (string, error) {
+		c, err := a.Client()
+		if err != nil {
+			return "", err
+		}
+		return c.Ping()
+	})
+}
+
+func runVersion(a *app, args []string) error {
+	return a.printString("version", args, func() (string, error) {
+		c, err := a.Client()
+		if err != nil {
+			return "", err
+		}
+		return c.Version()
+	})
+}
+
+// printString runs a command without flags that prints a single string
+func (a *app) printString(name string, args []string, get func() (string, error)) error {
+	fs := a.newFlagSet(name, "")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	s, err := get()
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(a.stdout, strings.TrimSpace(s))
+	return nil
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+	"github.com/jakewarren/trustar-golang/export"
+)
+
+// rowSink receives the rows printed by a command
+type rowSink interface {
+	Write(row interface{}) error
+	Flush() error
+}
+
+// outputFlags are the flags shared by every command that prints rows
+type outputFlags struct {
+	format    string
+	columns   string
+	humanTime bool
+}
+
+func addOutputFlags(fs *flag.FlagSet) *outputFlags {
+	o := &outputFlags{}
+	fs.StringVar(&o.format, "o", "table", "output format: table, json, jsonl or csv")
+	fs.StringVar(&o.columns, "columns", "", "comma separated list of columns to print (ignored for json)")
+	fs.BoolVar(&o.humanTime, "human-time", true, "print times as RFC 3339 instead of milliseconds since epoch")
+	return o
+}
+
+// sink returns the writer for the selected format. Tables print defaultColumns unless -columns is set.
+func (o *outputFlags) sink(w io.Writer, defaultColumns ...string) (rowSink, error) {
+	opts := export.Options{Columns: splitList(o.columns), HumanTime: o.humanTime}
+
+	switch o.format {
+	case "table":
+		if len(opts.Columns) == 0 {
+			opts.Columns = defaultColumns
+		}
+		return export.NewRowWriter(w, export.Table, opts), nil
+	case "csv":
+		return export.NewRowWriter(w, export.CSV, opts), nil
+	case "jsonl":
+		return export.NewRowWriter(w, export.JSONL, opts), nil
+	case "json":
+		return &jsonSink{w: w}, nil
+	}
+
+	return nil, usagef("unknown output format %q", o.format)
+}
+
+// jsonSink collects rows and prints them as an indented JSON array
+type jsonSink struct {
+	w    io.Writer
+	rows []interface{}
+}
+
+func (j *jsonSink) Write(row interface{}) error {
+	j.rows = append(j.rows, row)
+	return nil
+}
+
+func (j *jsonSink) Flush() error {
+	if j.rows == nil {
+		j.rows = []interface{}{}
+	}
+	data, err := json.MarshalIndent(j.rows, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(j.w, string(data))
+	return err
+}
+
+// queryFlags are the search filters shared by the report and indicator commands
+type queryFlags struct {
+	enclaves     string
+	tags         string
+	excludedTags string
+	from         string
+	to           string
+	pageSize     int
+	page         int
+	all          bool
+}
+
+func addQueryFlags(fs *flag.FlagSet) *queryFlags {
+	q := &queryFlags{}
+	fs.StringVar(&q.enclaves, "enclaves", "", "comma separated enclave IDs to search")
+	fs.StringVar(&q.tags, "tags", "", "comma separated tag names results must have")
+	fs.StringVar(&q.excludedTags, "excluded-tags", "", "comma separated tag names results must not have")
+	fs.StringVar(&q.from, "from", "", "start of the time window: epoch ms, RFC 3339, YYYY-MM-DD or a duration ago such as 24h or 7d")
+	fs.StringVar(&q.to, "to", "", "end of the time window, in the same formats as -from")
+	fs.IntVar(&q.pageSize, "page-size", 0, "number of results per page")
+	fs.IntVar(&q.page, "page", 0, "page number to fetch")
+	fs.BoolVar(&q.all, "all", false, "fetch every page of results")
+	return q
+}
+
+// values builds the query parameters shared by the search endpoints
+func (q *queryFlags) values() (url.Values, error) {
+	v := url.Values{}
+
+	setList(v, "enclaveIds", q.enclaves)
+	setList(v, "tags", q.tags)
+	setList(v, "excludedTags", q.excludedTags)
+
+	for name, s := range map[string]string{"from": q.from, "to": q.to} {
+		if s == "" {
+			continue
+		}
+		ms, err := parseTime(s)
+		if err != nil {
+			return nil, usagef("invalid -%s: %v", name, err)
+		}
+		v.Set(name, strconv.FormatInt(ms, 10))
+	}
+
+	if q.pageSize > 0 {
+		v.Set("pageSize", strconv.Itoa(q.pageSize))
+	}
+	if q.page > 0 {
+		v.Set("pageNumber", strconv.Itoa(q.page))
+	}
+
+	return v, nil
+}
+
+// parseTime accepts milliseconds since epoch, RFC 3339, YYYY-MM-DD or a duration before now
+func parseTime(s string) (int64, error) {
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return ms, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return trustar.TimeToMsEpoch(t), nil
+		}
+	}
+
+	if strings.HasSuffix(s, "d") {
+		if days, err := strconv.Atoi(strings.TrimSuffix(s, "d")); err == nil {
+			return trustar.TimeToMsEpoch(time.Now().AddDate(0, 0, -days)), nil
+		}
+	}
+
+	if d, err := time.ParseDuration(s); err == nil {
+		return trustar.TimeToMsEpoch(time.Now().Add(-d)), nil
+	}
+
+	return 0, fmt.Errorf("unrecognized time %q", s)
+}
+
+func setList(v url.Values, key, list string) {
+	if items := splitList(list); len(items) > 0 {
+		v.Set(key, strings.Join(items, ","))
+	}
+}
+
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// newFlagSet returns a flag set for a subcommand that reports errors instead of exiting
+func (a *app) newFlagSet(name, args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(a.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(a.stderr, "usage: trustar %s [flags] %s\n", name, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the flags of a subcommand, turning flag errors into usage errors
+func parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageError{err.Error()}
+	}
+	return nil
+}
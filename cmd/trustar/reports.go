@@ -0,0 +1,382 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+var reportColumns = []string{"id", "title", "updated", "enclaveIds"}
+
+func runReportsList(a *app, args []string) error {
+	fs := a.newFlagSet("reports list", "")
+	out := addOutputFlags(fs)
+	query := addQueryFlags(fs)
+	distribution := fs.String("distribution", "", "ENCLAVE or COMMUNITY")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	v, err := query.values()
+	if err != nil {
+		return err
+	}
+	if *distribution != "" {
+		v.Set("distributionType", *distribution)
+	}
+
+	return a.printReports(out, query.all, v, func(c *trustar.Client, v url.Values) (trustar.ReportResponse, error) {
+		return c.GetReports(v)
+	}, func(c *trustar.Client, v url.Values, fn func(trustar.ReportDetails) error) error {
+		return c.ForEachReport(v, fn)
+	})
+}
+
+func runReportsSearch(a *app, args []string) error {
+	fs := a.newFlagSet("reports search", "<term>")
+	out := addOutputFlags(fs)
+	query := addQueryFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("reports search requires a single search term")
+	}
+
+	v, err := query.values()
+	if err != nil {
+		return err
+	}
+	v.Set("searchTerm", fs.Arg(0))
+
+	return a.printReports(out, query.all, v, func(c *trustar.Client, v url.Values) (trustar.ReportResponse, error) {
+		return c.SearchReports(v)
+	}, func(c *trustar.Client, v url.Values, fn func(trustar.ReportDetails) error) error {
+		return c.ForEachSearchReport(v, fn)
+	})
+}
+
+func runReportsCorrelated(a *app, args []string) error {
+	fs := a.newFlagSet("reports correlated", "<indicator>...")
+	out := addOutputFlags(fs)
+	query := addQueryFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("reports correlated requires at least one indicator")
+	}
+
+	v, err := query.values()
+	if err != nil {
+		return err
+	}
+	for _, i := range fs.Args() {
+		v.Add("indicators", i)
+	}
+
+	return a.printReports(out, query.all, v, func(c *trustar.Client, v url.Values) (trustar.ReportResponse, error) {
+		crr, err := c.FindCorrelatedReports(v)
+		return trustar.ReportResponse{Reports: crr.Items, HasNext: crr.HasNext}, err
+	}, func(c *trustar.Client, v url.Values, fn func(trustar.ReportDetails) error) error {
+		return c.ForEachCorrelatedReport(v, fn)
+	})
+}
+
+// printReports prints a single page of reports, or every page when all is set
+func (a *app) printReports(out *outputFlags, all bool, v url.Values,
+	page func(*trustar.Client, url.Values) (trustar.ReportResponse, error),
+	each func(*trustar.Client, url.Values, func(trustar.ReportDetails) error) error) error {
+
+	sink, err := out.sink(a.stdout, reportColumns...)
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	if all {
+		err = each(c, v, func(r trustar.ReportDetails) error { return sink.Write(r) })
+	} else {
+		var rr trustar.ReportResponse
+		if rr, err = page(c, v); err == nil {
+			for _, r := range rr.Reports {
+				if err = sink.Write(r); err != nil {
+					break
+				}
+			}
+		}
+	}
+
+	if ferr := sink.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+func runReportsGet(a *app, args []string) error {
+	fs := a.newFlagSet("reports get", "<id>")
+	out := addOutputFlags(fs)
+	external := fs.Bool("external", false, "treat the ID as the external tracking ID")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("reports get requires a report ID")
+	}
+
+	sink, err := out.sink(a.stdout, "id", "title", "created", "updated", "externalId", "enclaveIds")
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	rd, err := c.GetReportDetails(fs.Arg(0), idType(*external))
+	if err != nil {
+		return err
+	}
+
+	if err = sink.Write(rd); err != nil {
+		return err
+	}
+	return sink.Flush()
+}
+
+func runReportsIndicators(a *app, args []string) error {
+	fs := a.newFlagSet("reports indicators", "<id>")
+	out := addOutputFlags(fs)
+	external := fs.Bool("external", false, "treat the ID as the external tracking ID")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("reports indicators requires a report ID")
+	}
+
+	sink, err := out.sink(a.stdout, indicatorColumns...)
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	err = c.ForEachReportIndicator(fs.Arg(0), url.Values{}, func(i trustar.Indicator) error {
+		return sink.Write(i)
+	}, idType(*external))
+
+	if ferr := sink.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
+
+// submissionFlags are the report fields accepted by submit and update
+type submissionFlags struct {
+	title        string
+	body         string
+	bodyFile     string
+	enclaves     string
+	distribution string
+	externalID   string
+	externalURL  string
+	timeBegan    string
+}
+
+func addSubmissionFlags(fs *flag.FlagSet) *submissionFlags {
+	s := &submissionFlags{}
+	fs.StringVar(&s.title, "title", "", "report title")
+	fs.StringVar(&s.body, "body", "", "report body")
+	fs.StringVar(&s.bodyFile, "body-file", "", "read the report body from a file, - for stdin")
+	fs.StringVar(&s.enclaves, "enclaves", "", "comma separated enclave IDs or names to submit to (default from the profile)")
+	fs.StringVar(&s.distribution, "distribution", "ENCLAVE", "ENCLAVE or COMMUNITY")
+	fs.StringVar(&s.externalID, "external-id", "", "external tracking ID, unique across the company's reports")
+	fs.StringVar(&s.externalURL, "external-url", "", "URL of the external report this originated from")
+	fs.StringVar(&s.timeBegan, "time-began", "", "ISO-8601 time the incident began")
+	return s
+}
+
+func (s *submissionFlags) submission(a *app) (trustar.ReportSubmission, error) {
+	report := trustar.ReportSubmission{
+		DistributionType:   s.distribution,
+		ExternalTrackingID: s.externalID,
+		ExternalURL:        s.externalURL,
+		ReportBody:         s.body,
+		TimeBegan:          s.timeBegan,
+		Title:              s.title,
+	}
+
+	var err error
+	if report.EnclaveIds, err = a.defaultEnclaves(s.enclaves); err != nil {
+		return report, err
+	}
+
+	if s.bodyFile != "" {
+		body, err := a.readFile(s.bodyFile)
+		if err != nil {
+			return report, err
+		}
+		report.ReportBody = string(body)
+	}
+
+	if report.Title == "" || report.ReportBody == "" {
+		return report, usagef("a report requires -title and -body or -body-file")
+	}
+	if report.DistributionType == "ENCLAVE" && len(report.EnclaveIds) == 0 {
+		return report, usagef("ENCLAVE distribution requires -enclaves")
+	}
+
+	return report, nil
+}
+
+func runReportsSubmit(a *app, args []string) error {
+	fs := a.newFlagSet("reports submit", "")
+	fields := addSubmissionFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	report, err := fields.submission(a)
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	if report, err = trustar.NewEnclaveRegistry(c).ValidateReport(report); err != nil {
+		return err
+	}
+
+	id, err := c.SubmitReport(report)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(a.stdout, id)
+	return nil
+}
+
+func runReportsUpdate(a *app, args []string) error {
+	fs := a.newFlagSet("reports update", "<id>")
+	fields := addSubmissionFlags(fs)
+	external := fs.Bool("external", false, "treat the ID as the external tracking ID")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("reports update requires a report ID")
+	}
+
+	report, err := fields.submission(a)
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	if report, err = trustar.NewEnclaveRegistry(c).ValidateReportUpdate(report); err != nil {
+		return err
+	}
+
+	return c.UpdateReport(fs.Arg(0), report, idType(*external))
+}
+
+func runReportsDelete(a *app, args []string) error {
+	fs := a.newFlagSet("reports delete", "<id>")
+	external := fs.Bool("external", false, "treat the ID as the external tracking ID")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("reports delete requires a report ID")
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	return c.DeleteReport(fs.Arg(0), idType(*external))
+}
+
+func runReportsCopy(a *app, args []string) error {
+	return a.copyReport("copy", args)
+}
+
+func runReportsMove(a *app, args []string) error {
+	return a.copyReport("move", args)
+}
+
+func (a *app) copyReport(name string, args []string) error {
+	fs := a.newFlagSet("reports "+name, "-enclaves <enclaves> <id>")
+	enclaves := fs.String("enclaves", "", "comma separated enclave IDs or names to "+name+" the report to")
+	distribution := fs.String("distribution", "ENCLAVE", "ENCLAVE or COMMUNITY")
+	external := fs.Bool("external", false, "treat the ID as the external tracking ID")
+	noTags := fs.Bool("no-tags", false, "do not carry the report's tags over")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("reports %s requires a report ID", name)
+	}
+	if *enclaves == "" && *distribution == "ENCLAVE" {
+		return usagef("reports %s requires -enclaves", name)
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	opts := trustar.CopyOptions{
+		EnclaveIDs:       splitList(*enclaves),
+		DistributionType: *distribution,
+		SourceIDType:     idType(*external),
+		SkipTags:         *noTags,
+		Enclaves:         trustar.NewEnclaveRegistry(c),
+	}
+
+	copyFn := c.CopyReport
+	if name == "move" {
+		copyFn = c.MoveReport
+	}
+
+	result, err := copyFn(fs.Arg(0), opts)
+	if result != nil {
+		fmt.Fprintln(a.stdout, result.ReportID)
+	}
+	return err
+}
+
+func idType(external bool) trustar.IDType {
+	if external {
+		return trustar.IDTypeExternal
+	}
+	return trustar.IDTypeInternal
+}
+
+// readFile reads a whole file, or stdin when the name is "-"
+func (a *app) readFile(name string) ([]byte, error) {
+	if name == "-" {
+		return ioutil.ReadAll(a.stdin)
+	}
+	return ioutil.ReadFile(name)
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"net/url"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+func runWhitelistGet(a *app, args []string) error {
+	fs := a.newFlagSet("whitelist get", "")
+	out := addOutputFlags(fs)
+	query := addQueryFlags(fs)
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+
+	v, err := query.values()
+	if err != nil {
+		return err
+	}
+
+	return a.printIndicators(out, query.all, v, func(c *trustar.Client, v url.Values) ([]trustar.Indicator, error) {
+		wir, err := c.GetWhitelist(v)
+		return wir.Items, err
+	}, func(c *trustar.Client, v url.Values, fn func(trustar.Indicator) error) error {
+		return c.ForEachWhitelistIndicator(v, fn)
+	})
+}
+
+func runWhitelistAdd(a *app, args []string) error {
+	fs := a.newFlagSet("whitelist add", "<indicator>...")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("whitelist add requires at least one indicator")
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	return c.WhitelistIndicators(fs.Args())
+}
+
+func runWhitelistDelete(a *app, args []string) error {
+	fs := a.newFlagSet("whitelist delete", "-type <type> <indicator>")
+	indicatorType := fs.String("type", "", "type of the indicator, such as IP or URL")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || *indicatorType == "" {
+		return usagef("whitelist delete requires -type and a single indicator")
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	v := url.Values{}
+	v.Set("indicatorType", *indicatorType)
+	v.Set("value", fs.Arg(0))
+
+	return c.DeleteFromWhitelist(v)
+}
+
+func runWhitelistSync(a *app, args []string) error {
+	fs := a.newFlagSet("whitelist sync", "[-dry-run] <file>")
+	dryRun := fs.Bool("dry-run", false, "print the changes without applying them")
+	maxDelete := fs.Float64("max-delete", trustar.DefaultMaxDeleteFraction, "largest fraction of the current whitelist that may be deleted, 0 to allow no deletes and 1 to allow emptying it")
+	if err := parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("whitelist sync requires a single file, or - for stdin")
+	}
+
+	data, err := a.readFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	desired, err := trustar.ParseWhitelist(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	c, err := a.Client()
+	if err != nil {
+		return err
+	}
+
+	plan, err := c.ReconcileWhitelist(desired, trustar.ReconcileOptions{DryRun: *dryRun, MaxDeleteFraction: maxDelete})
+	if plan != nil {
+		if _, werr := plan.WriteTo(a.stdout); werr != nil && err == nil {
+			err = werr
+		}
+	}
+	return err
+}
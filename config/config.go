@@ -0,0 +1,349 @@
+// Package config loads TruSTAR credentials from named profiles in a config file.
+//
+// The file uses the INI layout of the trustar.conf file read by TruSTAR's Python SDK:
+//
+//	[default]
+//	user_api_key = ...
+//	user_api_secret = ...
+//	api_endpoint = https://api.trustar.co/api/1.3
+//	enclave_ids = abc-123-def, ghi-456-jkl
+//	proxy = http://proxy.example.com:8080
+//	timeout = 90
+//
+// Instead of user_api_secret, the secret can be read from a file with user_api_secret_file, or
+// from the output of a helper program with user_api_secret_command. Both are read again when the
+// access token is refreshed, so rotated secrets are picked up without a restart.
+//
+// Environment variables override the values of the selected profile.
+package config
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// DefaultProfile is the profile used when none is selected
+const DefaultProfile = "default"
+
+// keys maps the accepted config file keys to the Profile field they set
+var keys = map[string]string{
+	"user_api_key":            "client_id",
+	"client_id":               "client_id",
+	"user_api_secret":         "secret",
+	"client_secret":           "secret",
+	"user_api_secret_file":    "secret_file",
+	"client_secret_file":      "secret_file",
+	"user_api_secret_command": "secret_command",
+	"client_secret_command":   "secret_command",
+	"api_endpoint":            "api_base",
+	"api_base":                "api_base",
+	"enclave_ids":             "enclave_ids",
+	"proxy":                   "proxy",
+	"https_proxy":             "proxy",
+	"http_proxy":              "proxy",
+	"timeout":                 "timeout",
+	"client_timeout":          "timeout",
+}
+
+// envVars lists the environment variables overriding each Profile field, in order of precedence
+var envVars = map[string][]string{
+	"client_id":      {"TRUSTAR_CLIENT_ID", "TRUSTAR_USER_API_KEY"},
+	"secret":         {"TRUSTAR_CLIENT_SECRET", "TRUSTAR_USER_API_SECRET"},
+	"secret_file":    {"TRUSTAR_CLIENT_SECRET_FILE"},
+	"secret_command": {"TRUSTAR_CLIENT_SECRET_COMMAND"},
+	"api_base":       {"TRUSTAR_API_BASE", "TRUSTAR_API_ENDPOINT"},
+	"enclave_ids":    {"TRUSTAR_ENCLAVE_IDS"},
+	"proxy":          {"TRUSTAR_PROXY"},
+	"timeout":        {"TRUSTAR_TIMEOUT"},
+}
+
+// Profile holds the settings needed to create a Client for one TruSTAR account
+type Profile struct {
+	Name          string
+	ClientID      string
+	Secret        string
+	SecretFile    string // file holding the secret, used instead of Secret
+	SecretCommand string // command printing the secret, used instead of Secret. It is split on spaces and run without a shell.
+	APIBase       string
+	EnclaveIDs    []string      // default enclaves for searches and submissions
+	Proxy         string        // URL of the proxy requests are sent through
+	Timeout       time.Duration // HTTP client timeout, zero keeps the Client default
+}
+
+// Config is a parsed config file
+type Config struct {
+	profiles map[string]map[string]string
+}
+
+// DefaultPath returns the config file path from TRUSTAR_CONFIG, or ~/.trustar/config
+func DefaultPath() string {
+	if p := os.Getenv("TRUSTAR_CONFIG"); p != "" {
+		return p
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".trustar", "config")
+}
+
+// Load reads and parses the config file at path
+func Load(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	cfg, err := Parse(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return cfg, nil
+}
+
+// Parse parses config data. Keys before the first section header belong to the default profile.
+func Parse(r io.Reader) (*Config, error) {
+	cfg := &Config{profiles: map[string]map[string]string{}}
+	section := DefaultProfile
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") {
+			if !strings.HasSuffix(line, "]") {
+				return nil, fmt.Errorf("line %d: invalid section header %q", n, line)
+			}
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+
+		i := strings.IndexAny(line, "=:")
+		if i <= 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+
+		key := strings.ToLower(strings.TrimSpace(line[:i]))
+		field, ok := keys[key]
+		if !ok {
+			// unrelated settings such as auth_endpoint and client_type are ignored
+			continue
+		}
+
+		if cfg.profiles[section] == nil {
+			cfg.profiles[section] = map[string]string{}
+		}
+		cfg.profiles[section][field] = unquote(strings.TrimSpace(line[i+1:]))
+	}
+
+	return cfg, scanner.Err()
+}
+
+// Profiles returns the names of the profiles in the config, sorted
+func (c *Config) Profiles() []string {
+	var names []string
+	for name := range c.profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the named profile with environment overrides applied.
+// An empty name selects TRUSTAR_PROFILE, or the default profile.
+func (c *Config) Profile(name string) (*Profile, error) {
+	if name == "" {
+		name = os.Getenv("TRUSTAR_PROFILE")
+	}
+	if name == "" {
+		name = DefaultProfile
+	}
+
+	values, ok := c.profiles[name]
+	if !ok && name != DefaultProfile {
+		return nil, fmt.Errorf("profile %q not found", name)
+	}
+
+	return newProfile(name, values, true)
+}
+
+// NewClientPool returns a ClientPool with a tenant for every profile, named after the profile.
+// Environment overrides are not applied, as they would give every tenant the same credentials.
+// Profiles without a client ID are skipped.
+func (c *Config) NewClientPool() (*trustar.ClientPool, error) {
+	pool := trustar.NewClientPool()
+
+	for _, name := range c.Profiles() {
+		p, err := newProfile(name, c.profiles[name], false)
+		if err != nil {
+			return nil, err
+		}
+		if p.ClientID == "" {
+			continue
+		}
+
+		client, err := p.NewClient()
+		if err != nil {
+			return nil, err
+		}
+		if _, err = pool.Add(name, client); err != nil {
+			return nil, err
+		}
+	}
+
+	return pool, nil
+}
+
+// LoadProfile loads the named profile from the config file at path, or DefaultPath if path is empty.
+// A missing default config file is not an error, so credentials can come from the environment alone.
+func LoadProfile(path, name string) (*Profile, error) {
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath()
+	}
+
+	cfg := &Config{}
+	if path != "" {
+		loaded, err := Load(path)
+		switch {
+		case err == nil:
+			cfg = loaded
+		case explicit || !os.IsNotExist(err):
+			return nil, err
+		}
+	}
+
+	return cfg.Profile(name)
+}
+
+// NewClient returns a Client configured from the profile
+func (p *Profile) NewClient() (*trustar.Client, error) {
+	var (
+		c   *trustar.Client
+		err error
+	)
+
+	switch {
+	case p.SecretFile != "":
+		c, err = trustar.NewClientWithCredentials(trustar.NewFileCredentials(p.ClientID, p.SecretFile), p.APIBase)
+	case p.SecretCommand != "":
+		args := strings.Fields(p.SecretCommand)
+		if len(args) == 0 {
+			return nil, fmt.Errorf("profile %s: secret_command is blank", p.Name)
+		}
+		c, err = trustar.NewClientWithCredentials(trustar.NewExecCredentials(p.ClientID, args[0], args[1:]...), p.APIBase)
+	default:
+		c, err = trustar.NewClient(p.ClientID, p.Secret, p.APIBase)
+	}
+	if err == nil && p.ClientID == "" {
+		err = errors.New("a client ID is required to create a Client")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %v", p.Name, err)
+	}
+
+	if p.Proxy == "" && p.Timeout == 0 {
+		return c, nil
+	}
+
+	if p.Timeout > 0 {
+		c.Client.Timeout = p.Timeout
+	}
+
+	if p.Proxy != "" {
+		proxy, err := url.Parse(p.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("profile %s: invalid proxy: %v", p.Name, err)
+		}
+
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxy)
+		c.Client.Transport = transport
+	}
+
+	return c, nil
+}
+
+func newProfile(name string, values map[string]string, env bool) (*Profile, error) {
+	merged := map[string]string{}
+	for k, v := range values {
+		merged[k] = v
+	}
+	if env {
+		fromEnv := map[string]bool{}
+		for field, vars := range envVars {
+			for i := len(vars) - 1; i >= 0; i-- {
+				if v := os.Getenv(vars[i]); v != "" {
+					merged[field] = v
+					fromEnv[field] = true
+				}
+			}
+		}
+
+		// a secret from the environment replaces every secret source in the file
+		if fromEnv["secret"] || fromEnv["secret_file"] || fromEnv["secret_command"] {
+			for _, field := range []string{"secret", "secret_file", "secret_command"} {
+				if !fromEnv[field] {
+					delete(merged, field)
+				}
+			}
+		}
+	}
+
+	p := &Profile{
+		Name:          name,
+		ClientID:      merged["client_id"],
+		Secret:        merged["secret"],
+		SecretFile:    merged["secret_file"],
+		SecretCommand: merged["secret_command"],
+		APIBase:       merged["api_base"],
+		Proxy:         merged["proxy"],
+	}
+
+	if p.APIBase == "" {
+		p.APIBase = trustar.APIBaseLive
+	}
+	if !strings.HasSuffix(p.APIBase, "/") {
+		p.APIBase += "/"
+	}
+
+	for _, id := range strings.Split(merged["enclave_ids"], ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			p.EnclaveIDs = append(p.EnclaveIDs, id)
+		}
+	}
+
+	if t := merged["timeout"]; t != "" {
+		if secs, err := strconv.Atoi(t); err == nil {
+			p.Timeout = time.Duration(secs) * time.Second
+		} else if p.Timeout, err = time.ParseDuration(t); err != nil {
+			return nil, fmt.Errorf("profile %s: invalid timeout %q", name, t)
+		}
+	}
+
+	return p, nil
+}
+
+func unquote(s string) string {
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		return s[1 : len(s)-1]
+	}
+	return s
+}
@@ -0,0 +1,345 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+const testConfig = `
+# keys before the first section belong to the default profile
+user_api_key = default-id
+user_api_secret = "default secret"
+
+[prod]
+client_id: prod-id
+client_secret = 'prod-secret'
+api_endpoint = https://api.trustar.co/api/1.3
+enclave_ids = abc, , def
+proxy = http://proxy.example.com:8080
+timeout = 30
+auth_endpoint = ignored
+
+[files]
+user_api_key = files-id
+user_api_secret = file-level
+user_api_secret_file = /run/secrets/trustar
+client_timeout = 1m30s
+`
+
+// setenv sets environment variables for a test, returning a function restoring them
+func setenv(vars map[string]string) func() {
+	old := map[string]*string{}
+	for k, v := range vars {
+		if prev, ok := os.LookupEnv(k); ok {
+			old[k] = &prev
+		} else {
+			old[k] = nil
+		}
+		os.Setenv(k, v)
+	}
+
+	return func() {
+		for k, v := range old {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				os.Setenv(k, *v)
+			}
+		}
+	}
+}
+
+// clearEnv unsets every variable read by the package for the duration of a test
+func clearEnv() func() {
+	vars := map[string]string{"TRUSTAR_PROFILE": "", "TRUSTAR_CONFIG": ""}
+	for _, names := range envVars {
+		for _, name := range names {
+			vars[name] = ""
+		}
+	}
+	restore := setenv(vars)
+	for name := range vars {
+		os.Unsetenv(name)
+	}
+	return restore
+}
+
+func parseConfig(t *testing.T) *Config {
+	t.Helper()
+
+	cfg, err := Parse(strings.NewReader(testConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestParse(t *testing.T) {
+	cfg := parseConfig(t)
+
+	if got, want := cfg.Profiles(), []string{"default", "files", "prod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got profiles %v, want %v", got, want)
+	}
+
+	errors := []struct {
+		data string
+		want string
+	}{
+		{"[prod\nclient_id = x\n", `line 1: invalid section header "[prod"`},
+		{"[prod]\nclient_id\n", "line 2: expected key = value"},
+		{"= value\n", "line 1: expected key = value"},
+	}
+	for _, tt := range errors {
+		if _, err := Parse(strings.NewReader(tt.data)); err == nil || err.Error() != tt.want {
+			t.Errorf("Parse(%q) got error %v, want %q", tt.data, err, tt.want)
+		}
+	}
+}
+
+func TestProfile(t *testing.T) {
+	defer clearEnv()()
+	cfg := parseConfig(t)
+
+	tests := []struct {
+		name string
+		want Profile
+	}{
+		{
+			name: "",
+			want: Profile{Name: "default", ClientID: "default-id", Secret: "default secret", APIBase: trustar.APIBaseLive},
+		},
+		{
+			name: "prod",
+			want: Profile{
+				Name:       "prod",
+				ClientID:   "prod-id",
+				Secret:     "prod-secret",
+				APIBase:    "https://api.trustar.co/api/1.3/",
+				EnclaveIDs: []string{"abc", "def"},
+				Proxy:      "http://proxy.example.com:8080",
+				Timeout:    30 * time.Second,
+			},
+		},
+		{
+			name: "files",
+			want: Profile{
+				Name:       "files",
+				ClientID:   "files-id",
+				Secret:     "file-level",
+				SecretFile: "/run/secrets/trustar",
+				APIBase:    trustar.APIBaseLive,
+				Timeout:    90 * time.Second,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := cfg.Profile(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(*p, tt.want) {
+				t.Errorf("got %+v\nwant %+v", *p, tt.want)
+			}
+		})
+	}
+
+	if _, err := cfg.Profile("staging"); err == nil || err.Error() != `profile "staging" not found` {
+		t.Errorf("got error %v, want a missing profile error", err)
+	}
+
+	bad, _ := Parse(strings.NewReader("timeout = soon\n"))
+	if _, err := bad.Profile(""); err == nil || !strings.Contains(err.Error(), `invalid timeout "soon"`) {
+		t.Errorf("got error %v, want an invalid timeout error", err)
+	}
+}
+
+func TestProfileEnv(t *testing.T) {
+	defer clearEnv()()
+	cfg := parseConfig(t)
+
+	tests := []struct {
+		name    string
+		profile string
+		env     map[string]string
+		check   func(p *Profile) bool
+	}{
+		{
+			name: "TRUSTAR_PROFILE selects the profile",
+			env:  map[string]string{"TRUSTAR_PROFILE": "prod"},
+			check: func(p *Profile) bool {
+				return p.Name == "prod" && p.ClientID == "prod-id"
+			},
+		},
+		{
+			name:    "environment overrides the profile",
+			profile: "prod",
+			env:     map[string]string{"TRUSTAR_CLIENT_ID": "env-id", "TRUSTAR_API_BASE": "http://localhost:8080", "TRUSTAR_ENCLAVE_IDS": "x,y"},
+			check: func(p *Profile) bool {
+				return p.ClientID == "env-id" && p.Secret == "prod-secret" && p.APIBase == "http://localhost:8080/" && reflect.DeepEqual(p.EnclaveIDs, []string{"x", "y"})
+			},
+		},
+		{
+			name:    "first variable takes precedence",
+			profile: "prod",
+			env:     map[string]string{"TRUSTAR_CLIENT_ID": "first", "TRUSTAR_USER_API_KEY": "second"},
+			check:   func(p *Profile) bool { return p.ClientID == "first" },
+		},
+		{
+			name:    "environment secret replaces the file secret sources",
+			profile: "files",
+			env:     map[string]string{"TRUSTAR_CLIENT_SECRET": "env-secret"},
+			check: func(p *Profile) bool {
+				return p.Secret == "env-secret" && p.SecretFile == "" && p.SecretCommand == ""
+			},
+		},
+		{
+			name:    "environment secret command replaces the file secret sources",
+			profile: "files",
+			env:     map[string]string{"TRUSTAR_CLIENT_SECRET_COMMAND": "pass show trustar"},
+			check: func(p *Profile) bool {
+				return p.Secret == "" && p.SecretFile == "" && p.SecretCommand == "pass show trustar"
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			restore := setenv(tt.env)
+			defer restore()
+
+			p, err := cfg.Profile(tt.profile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !tt.check(p) {
+				t.Errorf("got %+v", *p)
+			}
+		})
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	defer clearEnv()()
+
+	dir, err := ioutil.TempDir("", "trustar-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config")
+	if err := ioutil.WriteFile(path, []byte(testConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadProfile(path, "prod")
+	if err != nil || p.ClientID != "prod-id" {
+		t.Errorf("got %+v, %v, want the prod profile", p, err)
+	}
+
+	// TRUSTAR_CONFIG is used when no path is given
+	restore := setenv(map[string]string{"TRUSTAR_CONFIG": path})
+	p, err = LoadProfile("", "")
+	restore()
+	if err != nil || p.ClientID != "default-id" {
+		t.Errorf("got %+v, %v, want the default profile", p, err)
+	}
+
+	// a missing default file leaves the environment to supply credentials
+	restore = setenv(map[string]string{"TRUSTAR_CONFIG": filepath.Join(dir, "missing"), "TRUSTAR_CLIENT_ID": "env-id"})
+	p, err = LoadProfile("", "")
+	restore()
+	if err != nil || p.ClientID != "env-id" {
+		t.Errorf("got %+v, %v, want a profile from the environment", p, err)
+	}
+
+	if _, err := LoadProfile(filepath.Join(dir, "missing"), ""); !os.IsNotExist(err) {
+		t.Errorf("got error %v, want a missing explicit config to fail", err)
+	}
+}
+
+func TestNewClient(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile Profile
+		err     string
+	}{
+		{name: "static", profile: Profile{Name: "p", ClientID: "id", Secret: "s", APIBase: trustar.APIBaseLive}},
+		{name: "secret file", profile: Profile{Name: "p", ClientID: "id", SecretFile: "/run/secrets/x", APIBase: trustar.APIBaseLive}},
+		{name: "secret command", profile: Profile{Name: "p", ClientID: "id", SecretCommand: "echo s", APIBase: trustar.APIBaseLive}},
+		{name: "blank secret command", profile: Profile{Name: "p", ClientID: "id", SecretCommand: "  ", APIBase: trustar.APIBaseLive}, err: "profile p: secret_command is blank"},
+		{name: "no client ID", profile: Profile{Name: "p", SecretFile: "/run/secrets/x", APIBase: trustar.APIBaseLive}, err: "profile p: a client ID is required to create a Client"},
+		{name: "no secret", profile: Profile{Name: "p", ClientID: "id", APIBase: trustar.APIBaseLive}, err: "profile p: "},
+		{name: "bad proxy", profile: Profile{Name: "p", ClientID: "id", Secret: "s", APIBase: trustar.APIBaseLive, Proxy: "http://[::1"}, err: "profile p: invalid proxy"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := tt.profile.NewClient()
+			if tt.err != "" {
+				if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+					t.Errorf("got error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if c.APIBase != tt.profile.APIBase {
+				t.Errorf("got API base %q, want %q", c.APIBase, tt.profile.APIBase)
+			}
+		})
+	}
+
+	p := Profile{Name: "p", ClientID: "id", Secret: "s", APIBase: trustar.APIBaseLive, Proxy: "http://proxy.example.com:8080", Timeout: 5 * time.Second}
+	c, err := p.NewClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.Client.Timeout != 5*time.Second || c.Client.Transport == nil {
+		t.Errorf("got timeout %v and transport %v, want the profile's timeout and proxy", c.Client.Timeout, c.Client.Transport)
+	}
+}
+
+func TestNewClientPool(t *testing.T) {
+	defer clearEnv()()
+	defer setenv(map[string]string{"TRUSTAR_CLIENT_ID": "env-id", "TRUSTAR_CLIENT_SECRET": "env-secret"})()
+
+	cfg, err := Parse(strings.NewReader(testConfig + "\n[no-credentials]\ntimeout = 5\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool, err := cfg.NewClientPool()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := pool.Names(), []string{"default", "files", "prod"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got tenants %v, want %v", got, want)
+	}
+
+	// environment overrides are not applied to the tenants
+	prod, _ := pool.Tenant("prod")
+	if prod.Client.ClientID != "prod-id" || prod.Client.Secret != "prod-secret" || prod.Client.Client.Timeout != 30*time.Second {
+		t.Errorf("got %+v, want the prod profile's settings", prod.Client)
+	}
+	files, _ := pool.Tenant("files")
+	if files.Client.Credentials == nil {
+		t.Error("got no credentials provider, want the secret file")
+	}
+
+	bad, _ := Parse(strings.NewReader("[bad]\nclient_id = x\nclient_secret_command = \" \"\n"))
+	if _, err := bad.NewClientPool(); err == nil || err.Error() != "profile bad: secret_command is blank" {
+		t.Errorf("got %v, want the profile error", err)
+	}
+}
@@ -0,0 +1,185 @@
+package trustar
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+)
+
+// StationReportURL is the base of the links to reports in TruSTAR Station
+const StationReportURL = "https://station.trustar.co/constellation/reports/"
+
+// CopyOptions controls CopyReport and MoveReport
+type CopyOptions struct {
+	EnclaveIDs       []string // [required] enclaves the copy is submitted to, unless DistributionType is COMMUNITY
+	DistributionType string   // distribution of the copy, defaults to ENCLAVE
+
+	// SourceIDType selects how the source report ID is interpreted
+	SourceIDType IDType
+
+	// ExternalTrackingID of the copy. By default it is derived from the source report ID and the target
+	// enclaves, so copying the same report to the same enclaves again updates the earlier copy.
+	ExternalTrackingID string
+
+	// ExternalURL of the copy, defaults to the Station link of the source report
+	ExternalURL string
+
+	// SkipTags does not carry the source report's tags over to the copy
+	SkipTags bool
+
+	// Transform is applied to the copy before it is submitted, to strip or redact content, such as the hook of a redact.Engine
+	Transform ReportHook
+
+	// Enclaves checks the target enclaves and resolves enclave names before anything is sent when set
+	Enclaves *EnclaveRegistry
+}
+
+// CopyResult describes a copied report
+type CopyResult struct {
+	SourceID string   // internal ID of the source report
+	ReportID string   // internal ID of the copy
+	Created  bool     // false if an earlier copy was updated
+	Tags     []string // names of the tags carried over, none for a COMMUNITY copy as tags belong to an enclave
+}
+
+// CopyReport recreates a report in other enclaves. The copy links back to the source through
+// its ExternalURL and ExternalTrackingID, and carries over the source report's tags to each target enclave.
+// A COMMUNITY copy has no target enclave to add tags in, so it is made without them.
+func (c *Client) CopyReport(id string, opts CopyOptions) (*CopyResult, error) {
+	source, submission, err := c.prepareCopy(id, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return c.submitCopy(source, submission, opts)
+}
+
+// MoveReport copies a report to other enclaves and deletes the source.
+// The copy takes over the external tracking ID of the source once the source is deleted.
+func (c *Client) MoveReport(id string, opts CopyOptions) (*CopyResult, error) {
+	source, submission, err := c.prepareCopy(id, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := c.submitCopy(source, submission, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = c.DeleteReport(source.ID); err != nil {
+		return result, fmt.Errorf("report copied to %s but the source could not be deleted: %v", result.ReportID, err)
+	}
+
+	if source.ExternalID != "" && opts.ExternalTrackingID == "" {
+		submission.ExternalTrackingID = source.ExternalID
+		if err = c.UpdateReport(result.ReportID, submission); err != nil {
+			return result, fmt.Errorf("report moved to %s but its external tracking ID could not be set to %s: %v", result.ReportID, source.ExternalID, err)
+		}
+	}
+
+	return result, nil
+}
+
+// prepareCopy reads the source report and builds the submission for the copy
+func (c *Client) prepareCopy(id string, opts CopyOptions) (ReportDetails, ReportSubmission, error) {
+	var submission ReportSubmission
+
+	source, err := c.GetReportDetails(id, opts.SourceIDType)
+	if err != nil {
+		return source, submission, err
+	}
+
+	submission = ReportSubmission{
+		DistributionType:   opts.DistributionType,
+		EnclaveIds:         opts.EnclaveIDs,
+		ExternalTrackingID: opts.ExternalTrackingID,
+		ExternalURL:        opts.ExternalURL,
+		ReportBody:         source.ReportBody,
+		Title:              source.Title,
+	}
+
+	if submission.DistributionType == "" {
+		submission.DistributionType = "ENCLAVE"
+	}
+	if submission.DistributionType == "ENCLAVE" && len(submission.EnclaveIds) == 0 {
+		return source, submission, errors.New("at least one target enclave is required to copy a report")
+	}
+	if submission.ExternalURL == "" {
+		submission.ExternalURL = StationReportURL + source.ID
+	}
+	if source.TimeBegan > 0 {
+		t, _ := MsEpochToTime(source.TimeBegan)
+		submission.TimeBegan = t.UTC().Format(time.RFC3339)
+	}
+
+	if opts.Enclaves != nil {
+		if submission, err = opts.Enclaves.ValidateReport(submission); err != nil {
+			return source, submission, err
+		}
+	}
+
+	if submission.ExternalTrackingID == "" {
+		submission.ExternalTrackingID = copyTrackingID(source.ID, submission.EnclaveIds)
+	}
+
+	if opts.Transform != nil {
+		if submission, err = opts.Transform(submission); err != nil {
+			return source, submission, err
+		}
+	}
+
+	return source, submission, nil
+}
+
+// submitCopy creates or updates the copy and carries the tags over
+func (c *Client) submitCopy(source ReportDetails, submission ReportSubmission, opts CopyOptions) (*CopyResult, error) {
+	var tags []IndicatorTag
+	if !opts.SkipTags && len(submission.EnclaveIds) > 0 {
+		var err error
+		if tags, err = c.GetReportTags(source.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	id, created, err := c.UpsertReport(submission)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &CopyResult{SourceID: source.ID, ReportID: id, Created: created}
+
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		if seen[tag.Name] {
+			continue
+		}
+		seen[tag.Name] = true
+
+		added := false
+		for _, enclaveID := range submission.EnclaveIds {
+			if _, err = c.AddReportTag(id, tag.Name, enclaveID); err != nil {
+				return result, fmt.Errorf("report copied to %s but tag %s could not be added: %v", id, tag.Name, err)
+			}
+			added = true
+		}
+		if added {
+			result.Tags = append(result.Tags, tag.Name)
+		}
+	}
+
+	return result, nil
+}
+
+// copyTrackingID derives the external tracking ID of a copy from the source report and target enclaves
+func copyTrackingID(sourceID string, enclaveIDs []string) string {
+	ids := append([]string(nil), enclaveIDs...)
+	sort.Strings(ids)
+
+	h := fnv.New32a()
+	h.Write([]byte(strings.Join(ids, ",")))
+	return fmt.Sprintf("%s-copy-%08x", sourceID, h.Sum32())
+}
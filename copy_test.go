@@ -0,0 +1,331 @@
+package trustar
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// copyAPI keeps reports and their tags in memory, recording each request as "METHOD URI"
+type copyAPI struct {
+	t        *testing.T
+	reports  map[string]*ReportDetails
+	tags     map[string][]IndicatorTag
+	created  int
+	failOn   string // a "METHOD path" answered with an error
+	calls    []string
+	submits  []ReportSubmission // bodies of POST and PUT requests
+	enclaves []Enclave
+}
+
+func newCopyAPI(t *testing.T) *copyAPI {
+	return &copyAPI{
+		t: t,
+		reports: map[string]*ReportDetails{
+			"src": {ID: "src", ExternalID: "INC-1", Title: "Source", ReportBody: "body", TimeBegan: 1500000000000, EnclaveIds: []string{"e0"}},
+		},
+		tags: map[string][]IndicatorTag{
+			"src": {{Name: "apt", EnclaveID: "e0"}, {Name: "phish", EnclaveID: "e0"}, {Name: "apt", EnclaveID: "e9"}},
+		},
+		enclaves: []Enclave{{ID: "e1", Name: "Team", Create: true}, {ID: "e2", Name: "Read Only", Read: true}},
+	}
+}
+
+func (a *copyAPI) find(id string, external bool) *ReportDetails {
+	for _, r := range a.reports {
+		if (!external && r.ID == id) || (external && r.ExternalID == id) {
+			return r
+		}
+	}
+	return nil
+}
+
+func (a *copyAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	a.calls = append(a.calls, r.Method+" "+r.URL.RequestURI())
+	if a.failOn == r.Method+" "+r.URL.Path {
+		http.Error(w, "boom", http.StatusInternalServerError)
+		return
+	}
+
+	q := r.URL.Query()
+	path := strings.TrimPrefix(r.URL.Path, "/reports")
+	id := strings.TrimPrefix(strings.TrimSuffix(path, "/tags"), "/")
+
+	switch {
+	case r.URL.Path == "/enclaves":
+		writeJSON(w, a.enclaves)
+	case r.Method == "GET" && strings.HasSuffix(path, "/tags"):
+		if report := a.find(id, q.Get("idType") == "external"); report != nil {
+			writeJSON(w, a.tags[report.ID])
+			return
+		}
+		http.Error(w, "no such report", http.StatusNotFound)
+	case r.Method == "POST" && strings.HasSuffix(path, "/tags"):
+		a.tags[id] = append(a.tags[id], IndicatorTag{Name: q.Get("tagName"), EnclaveID: q.Get("enclaveId")})
+		w.Write([]byte(`"tag-guid"`))
+	case r.Method == "GET":
+		report := a.find(id, q.Get("idType") == "external")
+		if report == nil {
+			http.Error(w, "no such report", http.StatusNotFound)
+			return
+		}
+		writeJSON(w, report)
+	case r.Method == "POST" || r.Method == "PUT":
+		var s ReportSubmission
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			a.t.Error(err)
+		}
+		a.submits = append(a.submits, s)
+		if r.Method == "POST" {
+			a.created++
+			id = fmt.Sprintf("copy-%d", a.created)
+			a.reports[id] = &ReportDetails{ID: id}
+		}
+		a.reports[id].ExternalID = s.ExternalTrackingID
+		a.reports[id].EnclaveIds = s.EnclaveIds
+		w.Write([]byte(id))
+	case r.Method == "DELETE":
+		delete(a.reports, id)
+	}
+}
+
+func TestCopyReport(t *testing.T) {
+	api := newCopyAPI(t)
+	c, done := newTestClient(t, api.ServeHTTP)
+	defer done()
+
+	opts := CopyOptions{EnclaveIDs: []string{"e2", "e1"}}
+	result, err := c.CopyReport("src", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &CopyResult{SourceID: "src", ReportID: "copy-1", Created: true, Tags: []string{"apt", "phish"}}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("got %+v, want %+v", result, want)
+	}
+
+	s := api.submits[0]
+	wantSubmission := ReportSubmission{
+		DistributionType:   "ENCLAVE",
+		EnclaveIds:         []string{"e2", "e1"},
+		ExternalTrackingID: copyTrackingID("src", []string{"e1", "e2"}),
+		ExternalURL:        StationReportURL + "src",
+		ReportBody:         "body",
+		TimeBegan:          "2017-07-14T02:40:00Z",
+		Title:              "Source",
+	}
+	if !reflect.DeepEqual(s, wantSubmission) {
+		t.Errorf("got submission %+v, want %+v", s, wantSubmission)
+	}
+	if !strings.HasPrefix(s.ExternalTrackingID, "src-copy-") {
+		t.Errorf("got tracking ID %q", s.ExternalTrackingID)
+	}
+
+	// each tag is added once per target enclave
+	if got := api.tags["copy-1"]; len(got) != 4 || got[0] != (IndicatorTag{Name: "apt", EnclaveID: "e2"}) || got[3] != (IndicatorTag{Name: "phish", EnclaveID: "e1"}) {
+		t.Errorf("got tags %+v", got)
+	}
+
+	// copying to the same enclaves in any order updates the earlier copy
+	result, err = c.CopyReport("src", CopyOptions{EnclaveIDs: []string{"e1", "e2"}, SkipTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ReportID != "copy-1" || result.Created || result.Tags != nil {
+		t.Errorf("got %+v, want copy-1 updated without tags", result)
+	}
+	if last := api.calls[len(api.calls)-1]; last != "PUT /reports/copy-1" {
+		t.Errorf("got last request %q, want the copy updated", last)
+	}
+}
+
+func TestCopyReportCommunity(t *testing.T) {
+	api := newCopyAPI(t)
+	c, done := newTestClient(t, api.ServeHTTP)
+	defer done()
+
+	result, err := c.CopyReport("src", CopyOptions{DistributionType: "COMMUNITY"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// tags belong to an enclave, so none are carried over or reported
+	if result.ReportID != "copy-1" || len(result.Tags) != 0 {
+		t.Errorf("got %+v, want copy-1 without tags", result)
+	}
+	for _, call := range api.calls {
+		if strings.Contains(call, "/tags") {
+			t.Errorf("got request %q, want no tag requests", call)
+		}
+	}
+	if len(api.tags["copy-1"]) != 0 {
+		t.Errorf("got tags %+v on the copy", api.tags["copy-1"])
+	}
+}
+
+func TestCopyReportOptions(t *testing.T) {
+	tests := []struct {
+		name  string
+		opts  CopyOptions
+		check func(t *testing.T, s ReportSubmission)
+		err   string
+	}{
+		{
+			name: "explicit IDs",
+			opts: CopyOptions{EnclaveIDs: []string{"e1"}, ExternalTrackingID: "mine", ExternalURL: "https://example.com/r"},
+			check: func(t *testing.T, s ReportSubmission) {
+				if s.ExternalTrackingID != "mine" || s.ExternalURL != "https://example.com/r" {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
+		{
+			name: "community",
+			opts: CopyOptions{DistributionType: "COMMUNITY", ExternalTrackingID: "c"},
+			check: func(t *testing.T, s ReportSubmission) {
+				if s.DistributionType != "COMMUNITY" || len(s.EnclaveIds) != 0 {
+					t.Errorf("got %+v", s)
+				}
+			},
+		},
+		{
+			name: "transform",
+			opts: CopyOptions{EnclaveIDs: []string{"e1"}, Transform: func(s ReportSubmission) (ReportSubmission, error) {
+				s.ReportBody = "[redacted]"
+				return s, nil
+			}},
+			check: func(t *testing.T, s ReportSubmission) {
+				if s.ReportBody != "[redacted]" {
+					t.Errorf("got body %q", s.ReportBody)
+				}
+			},
+		},
+		{
+			name: "enclave names",
+			opts: CopyOptions{EnclaveIDs: []string{"team"}},
+			check: func(t *testing.T, s ReportSubmission) {
+				if !reflect.DeepEqual(s.EnclaveIds, []string{"e1"}) || s.ExternalTrackingID != copyTrackingID("src", []string{"e1"}) {
+					t.Errorf("got %+v, want the name resolved before the tracking ID is derived", s)
+				}
+			},
+		},
+		{name: "no enclaves", opts: CopyOptions{}, err: "at least one target enclave is required to copy a report"},
+		{name: "no permission", opts: CopyOptions{EnclaveIDs: []string{"e2"}}, err: `no Create permission on enclave "Read Only" (e2)`},
+		{
+			name: "transform error",
+			opts: CopyOptions{EnclaveIDs: []string{"e1"}, Transform: func(s ReportSubmission) (ReportSubmission, error) {
+				return s, errors.New("rejected")
+			}},
+			err: "rejected",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := newCopyAPI(t)
+			c, done := newTestClient(t, api.ServeHTTP)
+			defer done()
+			if strings.HasPrefix(tt.name, "enclave") || tt.name == "no permission" {
+				tt.opts.Enclaves = NewEnclaveRegistry(c)
+			}
+
+			_, err := c.CopyReport("src", tt.opts)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("got error %v, want %q", err, tt.err)
+				}
+				if len(api.submits) != 0 {
+					t.Errorf("got %d submissions, want nothing sent", len(api.submits))
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.check(t, api.submits[0])
+		})
+	}
+}
+
+func TestMoveReport(t *testing.T) {
+	api := newCopyAPI(t)
+	c, done := newTestClient(t, api.ServeHTTP)
+	defer done()
+
+	result, err := c.MoveReport("INC-1", CopyOptions{EnclaveIDs: []string{"e1"}, SourceIDType: IDTypeExternal, SkipTags: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.ReportID != "copy-1" || !result.Created {
+		t.Errorf("got %+v", result)
+	}
+
+	want := []string{
+		"GET /reports/INC-1?idType=external",
+		"GET /reports/" + copyTrackingID("src", []string{"e1"}) + "?idType=external",
+		"POST /reports",
+		"DELETE /reports/src",
+		"PUT /reports/copy-1",
+	}
+	if !reflect.DeepEqual(api.calls, want) {
+		t.Errorf("got requests %q, want %q", api.calls, want)
+	}
+
+	if _, ok := api.reports["src"]; ok {
+		t.Error("got the source report kept")
+	}
+	if got := api.reports["copy-1"].ExternalID; got != "INC-1" {
+		t.Errorf("got external ID %q, want the copy to take over the source's", got)
+	}
+}
+
+func TestMoveReportErrors(t *testing.T) {
+	api := newCopyAPI(t)
+	api.failOn = "DELETE /reports/src"
+	c, done := newTestClient(t, api.ServeHTTP)
+	defer done()
+
+	result, err := c.MoveReport("src", CopyOptions{EnclaveIDs: []string{"e1"}})
+	if err == nil || !strings.HasPrefix(err.Error(), "report copied to copy-1 but the source could not be deleted: ") {
+		t.Errorf("got error %v", err)
+	}
+	if result == nil || result.ReportID != "copy-1" {
+		t.Errorf("got %+v, want the copy returned", result)
+	}
+
+	api = newCopyAPI(t)
+	api.failOn = "POST /reports/copy-1/tags"
+	c, done = newTestClient(t, api.ServeHTTP)
+	defer done()
+
+	if _, err := c.CopyReport("src", CopyOptions{EnclaveIDs: []string{"e1"}}); err == nil || !strings.HasPrefix(err.Error(), "report copied to copy-1 but tag apt could not be added: ") {
+		t.Errorf("got error %v", err)
+	}
+	if _, err := c.MoveReport("missing", CopyOptions{EnclaveIDs: []string{"e1"}}); !IsNotFound(err) {
+		t.Errorf("got error %v, want not found", err)
+	}
+}
+
+func TestReportTags(t *testing.T) {
+	api := newCopyAPI(t)
+	c, done := newTestClient(t, api.ServeHTTP)
+	defer done()
+
+	tags, err := c.GetReportTags("INC-1", IDTypeExternal)
+	if err != nil || len(tags) != 3 {
+		t.Fatalf("got %v, %v", tags, err)
+	}
+
+	guid, err := c.AddReportTag("src", "new tag", "e1")
+	if err != nil || guid != "tag-guid" {
+		t.Errorf("got %q, %v, want the unquoted tag ID", guid, err)
+	}
+	if got := api.calls[len(api.calls)-1]; got != "POST /reports/src/tags?enclaveId=e1&tagName=new+tag" {
+		t.Errorf("got request %q", got)
+	}
+}
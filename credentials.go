@@ -0,0 +1,169 @@
+package trustar
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CredentialsProvider supplies the client ID and secret used to request access tokens.
+// It is consulted every time GetAccessToken runs, so a rotated secret is picked up the next time the token is refreshed.
+type CredentialsProvider interface {
+	Credentials() (clientID string, secret string, err error)
+}
+
+// StaticCredentials is a CredentialsProvider for fixed values
+type StaticCredentials struct {
+	ClientID string
+	Secret   string
+}
+
+// Credentials returns the static client ID and secret
+func (s StaticCredentials) Credentials() (string, string, error) {
+	return s.ClientID, s.Secret, nil
+}
+
+// EnvCredentials is a CredentialsProvider that reads the client ID and secret from environment variables
+type EnvCredentials struct {
+	ClientIDVar string // defaults to TRUSTAR_CLIENT_ID
+	SecretVar   string // defaults to TRUSTAR_CLIENT_SECRET
+}
+
+// Credentials reads the environment variables
+func (e EnvCredentials) Credentials() (string, string, error) {
+	idVar, secretVar := e.ClientIDVar, e.SecretVar
+	if idVar == "" {
+		idVar = "TRUSTAR_CLIENT_ID"
+	}
+	if secretVar == "" {
+		secretVar = "TRUSTAR_CLIENT_SECRET"
+	}
+
+	id, secret := os.Getenv(idVar), os.Getenv(secretVar)
+	if id == "" || secret == "" {
+		return "", "", fmt.Errorf("%s and %s must be set", idVar, secretVar)
+	}
+
+	return id, secret, nil
+}
+
+// FileCredentials is a CredentialsProvider that reads the secret from a file, such as a mounted
+// Kubernetes secret. The file is read again whenever its modification time or size changes.
+type FileCredentials struct {
+	ClientID string
+	Path     string
+
+	mu      sync.Mutex
+	modTime time.Time
+	size    int64
+	secret  string
+}
+
+// NewFileCredentials returns a FileCredentials reading the secret from path
+func NewFileCredentials(clientID, path string) *FileCredentials {
+	return &FileCredentials{ClientID: clientID, Path: path}
+}
+
+// Credentials returns the client ID and the current contents of the secret file
+func (f *FileCredentials) Credentials() (string, string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	info, err := os.Stat(f.Path)
+	if err != nil {
+		return "", "", err
+	}
+
+	if f.secret == "" || !info.ModTime().Equal(f.modTime) || info.Size() != f.size {
+		data, err := ioutil.ReadFile(f.Path)
+		if err != nil {
+			return "", "", err
+		}
+
+		secret := strings.TrimSpace(string(data))
+		if secret == "" {
+			return "", "", fmt.Errorf("secret file %s is empty", f.Path)
+		}
+
+		f.secret, f.modTime, f.size = secret, info.ModTime(), info.Size()
+	}
+
+	return f.ClientID, f.secret, nil
+}
+
+// ExecCredentials is a CredentialsProvider that runs a helper command and uses its trimmed
+// standard output as the secret. The output is cached for TTL, or not cached at all if TTL is zero.
+type ExecCredentials struct {
+	ClientID string
+	Command  string
+	Args     []string
+	TTL      time.Duration
+
+	mu        sync.Mutex
+	secret    string
+	fetchedAt time.Time
+}
+
+// NewExecCredentials returns an ExecCredentials running command with the given arguments
+func NewExecCredentials(clientID, command string, args ...string) *ExecCredentials {
+	return &ExecCredentials{ClientID: clientID, Command: command, Args: args}
+}
+
+// Credentials returns the client ID and the secret printed by the helper command
+func (e *ExecCredentials) Credentials() (string, string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.secret != "" && e.TTL > 0 && time.Since(e.fetchedAt) < e.TTL {
+		return e.ClientID, e.secret, nil
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command(e.Command, e.Args...)
+	cmd.Stderr = &stderr
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", "", fmt.Errorf("running %s: %v: %s", e.Command, err, strings.TrimSpace(stderr.String()))
+	}
+
+	secret := strings.TrimSpace(string(out))
+	if secret == "" {
+		return "", "", fmt.Errorf("%s printed an empty secret", e.Command)
+	}
+
+	e.secret, e.fetchedAt = secret, time.Now()
+	return e.ClientID, e.secret, nil
+}
+
+// NewClientWithCredentials returns new Client struct that requests access tokens with the credentials from provider
+func NewClientWithCredentials(provider CredentialsProvider, APIBase string) (*Client, error) {
+	if provider == nil || APIBase == "" {
+		return nil, errors.New("a CredentialsProvider and APIBase are required to create a Client")
+	}
+
+	client := &http.Client{
+		Timeout: 90 * time.Second,
+	}
+
+	return &Client{
+		Client:      client,
+		Credentials: provider,
+		APIBase:     APIBase,
+	}, nil
+}
+
+// credentials returns the client ID and secret from the provider, or the ClientID and Secret fields if there is none
+func (c *Client) credentials() (string, string, error) {
+	if c.Credentials == nil {
+		return c.ClientID, c.Secret, nil
+	}
+	return c.Credentials.Credentials()
+}
@@ -0,0 +1,173 @@
+package trustar
+
+import (
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc answers requests without a server
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestStaticAndEnvCredentials(t *testing.T) {
+	id, secret, err := StaticCredentials{ClientID: "id", Secret: "s"}.Credentials()
+	if id != "id" || secret != "s" || err != nil {
+		t.Errorf("got %q, %q, %v", id, secret, err)
+	}
+
+	os.Setenv("TEST_TRUSTAR_ID", "env-id")
+	defer os.Unsetenv("TEST_TRUSTAR_ID")
+
+	env := EnvCredentials{ClientIDVar: "TEST_TRUSTAR_ID", SecretVar: "TEST_TRUSTAR_SECRET"}
+	if _, _, err := env.Credentials(); err == nil || err.Error() != "TEST_TRUSTAR_ID and TEST_TRUSTAR_SECRET must be set" {
+		t.Errorf("got error %v, want the missing variables", err)
+	}
+
+	os.Setenv("TEST_TRUSTAR_SECRET", "env-secret")
+	defer os.Unsetenv("TEST_TRUSTAR_SECRET")
+
+	if id, secret, err := env.Credentials(); id != "env-id" || secret != "env-secret" || err != nil {
+		t.Errorf("got %q, %q, %v", id, secret, err)
+	}
+}
+
+func TestFileCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trustar-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "secret")
+	f := NewFileCredentials("id", path)
+
+	if _, _, err := f.Credentials(); !os.IsNotExist(err) {
+		t.Errorf("got error %v, want the missing file", err)
+	}
+
+	if err := ioutil.WriteFile(path, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if id, secret, err := f.Credentials(); id != "id" || secret != "first" || err != nil {
+		t.Errorf("got %q, %q, %v, want the trimmed secret", id, secret, err)
+	}
+
+	// a rotated secret is read again
+	if err := ioutil.WriteFile(path, []byte("rotated-secret"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, secret, _ := f.Credentials(); secret != "rotated-secret" {
+		t.Errorf("got %q after rotation, want rotated-secret", secret)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(" \n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := f.Credentials(); err == nil || !strings.Contains(err.Error(), "is empty") {
+		t.Errorf("got error %v, want an empty file error", err)
+	}
+}
+
+func TestExecCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "trustar-credentials")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "secret")
+	if err := ioutil.WriteFile(path, []byte("first\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	uncached := NewExecCredentials("id", "cat", path)
+	cached := NewExecCredentials("id", "cat", path)
+	cached.TTL = time.Hour
+
+	for _, e := range []*ExecCredentials{uncached, cached} {
+		if id, secret, err := e.Credentials(); id != "id" || secret != "first" || err != nil {
+			t.Errorf("got %q, %q, %v", id, secret, err)
+		}
+	}
+
+	if err := ioutil.WriteFile(path, []byte("second"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, secret, _ := uncached.Credentials(); secret != "second" {
+		t.Errorf("got %q, want the command run again without a TTL", secret)
+	}
+	if _, secret, _ := cached.Credentials(); secret != "first" {
+		t.Errorf("got %q, want the cached secret within the TTL", secret)
+	}
+
+	if _, _, err := NewExecCredentials("id", "cat", filepath.Join(dir, "missing")).Credentials(); err == nil || !strings.Contains(err.Error(), "running cat") {
+		t.Errorf("got error %v, want the command failure", err)
+	}
+	if _, _, err := NewExecCredentials("id", "true").Credentials(); err == nil || err.Error() != "true printed an empty secret" {
+		t.Errorf("got error %v, want an empty secret error", err)
+	}
+}
+
+func TestGetAccessTokenCredentials(t *testing.T) {
+	secrets := []string{"one", "two"}
+	provider := &rotating{secrets: secrets}
+
+	c, err := NewClientWithCredentials(provider, "http://localhost/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	c.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		id, secret, _ := req.BasicAuth()
+		got = append(got, id+":"+secret)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(`{"access_token":"token","expires_in":3600}`)),
+			Request:    req,
+		}, nil
+	})})
+
+	for range secrets {
+		if _, err := c.GetAccessToken(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if strings.Join(got, ",") != "id:one,id:two" {
+		t.Errorf("got credentials %v, want the provider consulted for each token", got)
+	}
+
+	provider.err = true
+	if _, err := c.GetAccessToken(); err == nil {
+		t.Error("want the provider error")
+	}
+
+	if _, err := NewClientWithCredentials(nil, "http://localhost/"); err == nil {
+		t.Error("want an error without a provider")
+	}
+}
+
+// rotating returns a different secret on each call
+type rotating struct {
+	secrets []string
+	n       int
+	err     bool
+}
+
+func (r *rotating) Credentials() (string, string, error) {
+	if r.err {
+		return "", "", os.ErrPermission
+	}
+	s := r.secrets[r.n%len(r.secrets)]
+	r.n++
+	return "id", s, nil
+}
@@ -0,0 +1,145 @@
+package trustar
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// writeOperations are the operations intercepted in dry-run mode. Reads and token requests are sent as usual.
+var writeOperations = map[string]bool{
+	"SubmitReport":        true,
+	"UpdateReport":        true,
+	"DeleteReport":        true,
+	"AddReportTag":        true,
+	"SubmitIndicators":    true,
+	"WhitelistIndicators": true,
+	"DeleteFromWhitelist": true,
+}
+
+// SetDryRun turns dry-run mode on for the Client, or off if w is nil. Write requests are validated and
+// written to w exactly as they would be sent, and answered with a synthetic success instead of being sent.
+// New reports and tags get placeholder IDs such as 00000000-0000-0000-0000-000000000001.
+// The Authorization header is not written. Reads and token requests are sent as usual.
+func (c *Client) SetDryRun(w io.Writer) {
+	c.DryRun = w
+}
+
+// dryRun answers write requests for the Client in dry-run mode, it is applied by do inside every middleware
+func (c *Client) dryRun(next Handler) Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		w := c.DryRun
+		op := Operation(req)
+		if w == nil || !writeOperations[op] {
+			return next(req)
+		}
+
+		var body []byte
+		if req.Body != nil {
+			var err error
+			if body, err = ioutil.ReadAll(req.Body); err != nil {
+				return nil, err
+			}
+			req.Body.Close()
+		}
+
+		if err := validateWrite(op, req, body); err != nil {
+			return nil, fmt.Errorf("dry run %s: %v", op, err)
+		}
+
+		c.dryRunMu.Lock()
+		defer c.dryRunMu.Unlock()
+
+		writeDryRun(w, op, req, body)
+
+		result := "{}"
+		switch op {
+		case "SubmitReport", "AddReportTag":
+			c.dryRunSeq++
+			result = fmt.Sprintf("00000000-0000-0000-0000-%012d", c.dryRunSeq)
+		}
+
+		return &http.Response{
+			Status:        "200 OK",
+			StatusCode:    http.StatusOK,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        http.Header{"Content-Type": {"application/json"}},
+			Body:          ioutil.NopCloser(strings.NewReader(result)),
+			ContentLength: int64(len(result)),
+			Request:       req,
+		}, nil
+	}
+}
+
+// validateWrite decodes and checks the payload of a write request
+func validateWrite(op string, req *http.Request, body []byte) error {
+	q := req.URL.Query()
+
+	switch op {
+	case "SubmitReport", "UpdateReport":
+		var r ReportSubmission
+		if err := json.Unmarshal(body, &r); err != nil {
+			return err
+		}
+		return r.Validate()
+	case "SubmitIndicators":
+		var s IndicatorSubmission
+		if err := json.Unmarshal(body, &s); err != nil {
+			return err
+		}
+		return s.Validate()
+	case "WhitelistIndicators":
+		var values []string
+		if err := json.Unmarshal(body, &values); err != nil {
+			return err
+		}
+		if len(values) == 0 {
+			return errors.New("no indicators to whitelist")
+		}
+	case "DeleteFromWhitelist":
+		if q.Get("indicatorType") == "" || q.Get("value") == "" {
+			return errors.New("indicatorType and value are required")
+		}
+	case "AddReportTag":
+		if q.Get("tagName") == "" || q.Get("enclaveId") == "" {
+			return errors.New("tagName and enclaveId are required")
+		}
+	}
+	return nil
+}
+
+// writeDryRun writes a request the way it would go over the wire, without credentials
+func writeDryRun(w io.Writer, op string, req *http.Request, body []byte) {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "DRY RUN %s: %s %s\n", op, req.Method, req.URL)
+
+	keys := make([]string, 0, len(req.Header))
+	for k := range req.Header {
+		if k != "Authorization" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%s: %s\n", k, strings.Join(req.Header[k], ", "))
+	}
+
+	if len(body) > 0 {
+		b.WriteByte('\n')
+		if json.Indent(&b, body, "", "  ") != nil {
+			b.Write(body)
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteByte('\n')
+
+	w.Write(b.Bytes())
+}
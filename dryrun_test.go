@@ -0,0 +1,172 @@
+package trustar
+
+import (
+	"bytes"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// dryRunClient returns a Client in dry-run mode, recording every request the server receives
+func dryRunClient(t *testing.T) (*Client, *bytes.Buffer, *[]string, func()) {
+	t.Helper()
+
+	var sent []string
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		sent = append(sent, r.Method+" "+r.URL.Path)
+		writeJSON(w, []Enclave{{ID: "e1"}})
+	})
+
+	var out bytes.Buffer
+	c.SetDryRun(&out)
+	return c, &out, &sent, done
+}
+
+func TestDryRunOutput(t *testing.T) {
+	c, out, sent, done := dryRunClient(t)
+	defer done()
+
+	err := c.DeleteReport("r1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "DRY RUN DeleteReport: DELETE " + c.APIBase + "reports/r1\n" +
+		"Accept: application/json\n" +
+		"Accept-Language: en_US\n" +
+		"Client-Metatag: github.com/jakewarren/trustar-golang\n" +
+		"Client-Type: API\n" +
+		"Client-Version: v0.1.0\n" +
+		"Content-Type: application/json\n" +
+		"\n"
+	if out.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", out, want)
+	}
+	if len(*sent) != 0 {
+		t.Errorf("got requests %v, want none sent", *sent)
+	}
+
+	out.Reset()
+	err = c.SubmitIndicators(IndicatorSubmission{EnclaveIDS: []string{"e1"}, Content: []IndicatorContent{{Value: "evil.com"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := "\n{\n  \"enclaveIds\": [\n    \"e1\"\n  ],\n  \"content\": [\n    {\n      \"value\": \"evil.com\"\n    }\n  ]\n}\n\n"
+	if !strings.HasPrefix(out.String(), "DRY RUN SubmitIndicators: POST "+c.APIBase+"indicators\n") || !strings.HasSuffix(out.String(), body) {
+		t.Errorf("got\n%s", out)
+	}
+	if strings.Contains(out.String(), "Authorization") || strings.Contains(out.String(), "token") {
+		t.Errorf("got credentials in\n%s", out)
+	}
+}
+
+func TestDryRunResults(t *testing.T) {
+	c, out, sent, done := dryRunClient(t)
+	defer done()
+
+	report := ReportSubmission{Title: "t", ReportBody: "b", DistributionType: "COMMUNITY"}
+	for i, want := range []string{"00000000-0000-0000-0000-000000000001", "00000000-0000-0000-0000-000000000002"} {
+		id, err := c.SubmitReport(report)
+		if err != nil || id != want {
+			t.Errorf("report %d: got %q, %v, want %q", i+1, id, err, want)
+		}
+	}
+	if id, err := c.AddReportTag("r1", "tag", "e1"); err != nil || id != "00000000-0000-0000-0000-000000000003" {
+		t.Errorf("got tag %q, %v", id, err)
+	}
+
+	// the remaining writes succeed without being sent
+	if err := c.UpdateReport("r1", report); err != nil {
+		t.Error(err)
+	}
+	if err := c.WhitelistIndicators([]string{"evil.com"}); err != nil {
+		t.Error(err)
+	}
+	if err := c.DeleteFromWhitelist(url.Values{"indicatorType": {"DOMAIN"}, "value": {"evil.com"}}); err != nil {
+		t.Error(err)
+	}
+	if len(*sent) != 0 {
+		t.Errorf("got requests %v, want none sent", *sent)
+	}
+	if n := strings.Count(out.String(), "DRY RUN "); n != 6 {
+		t.Errorf("got %d dry-run requests written, want 6", n)
+	}
+
+	// reads are still sent
+	enclaves, err := c.GetEnclaves()
+	if err != nil || len(enclaves) != 1 {
+		t.Errorf("got %v, %v", enclaves, err)
+	}
+	if len(*sent) != 1 || (*sent)[0] != "GET /enclaves" {
+		t.Errorf("got requests %v, want the read sent", *sent)
+	}
+
+	// turning dry-run off sends writes again
+	c.SetDryRun(nil)
+	out.Reset()
+	c.DeleteReport("r1")
+	if len(*sent) != 2 || out.Len() != 0 {
+		t.Errorf("got requests %v and output %q after SetDryRun(nil)", *sent, out)
+	}
+}
+
+func TestDryRunValidation(t *testing.T) {
+	c, out, sent, done := dryRunClient(t)
+	defer done()
+
+	tests := []struct {
+		name string
+		call func() error
+		want string
+	}{
+		{
+			name: "report",
+			call: func() error {
+				_, err := c.SubmitReport(ReportSubmission{Title: "t", DistributionType: "ENCLAVE"})
+				return err
+			},
+			want: "dry run SubmitReport: invalid report: reportBody is required; ENCLAVE distribution requires at least one enclave ID",
+		},
+		{
+			name: "update",
+			call: func() error { return c.UpdateReport("r1", ReportSubmission{}) },
+			want: "dry run UpdateReport: invalid report: title is required; reportBody is required; distributionType is required",
+		},
+		{
+			name: "indicators",
+			call: func() error { return c.SubmitIndicators(IndicatorSubmission{EnclaveIDS: []string{"e1"}}) },
+			want: "dry run SubmitIndicators: invalid indicator submission: at least one indicator is required",
+		},
+		{
+			name: "whitelist",
+			call: func() error { return c.WhitelistIndicators(nil) },
+			want: "dry run WhitelistIndicators: no indicators to whitelist",
+		},
+		{
+			name: "whitelist delete",
+			call: func() error { return c.DeleteFromWhitelist(url.Values{"value": {"evil.com"}}) },
+			want: "dry run DeleteFromWhitelist: indicatorType and value are required",
+		},
+		{
+			name: "tag",
+			call: func() error {
+				_, err := c.AddReportTag("r1", "tag", "")
+				return err
+			},
+			want: "dry run AddReportTag: tagName and enclaveId are required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); err == nil || err.Error() != tt.want {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+
+	if out.Len() != 0 || len(*sent) != 0 {
+		t.Errorf("got output %q and requests %v for invalid writes", out, *sent)
+	}
+}
@@ -1,8 +1,12 @@
 package trustar
 
 import (
+	"errors"
 	"fmt"
-	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 )
 
 // Reference: https://docs.trustar.co/api/v13/enclaves/index.html
@@ -14,7 +18,7 @@ func (c *Client) GetEnclaves() ([]Enclave, error) {
 	var enclaves []Enclave
 
 	url := fmt.Sprintf("%s%s", c.APIBase, "enclaves")
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest("GetEnclaves", "GET", url, nil)
 
 	if err != nil {
 		return enclaves, err
@@ -26,3 +30,233 @@ func (c *Client) GetEnclaves() ([]Enclave, error) {
 
 	return enclaves, nil
 }
+
+// DefaultEnclaveTTL is how long an EnclaveRegistry serves the enclave list before reloading it
+const DefaultEnclaveTTL = time.Hour
+
+// EnclavePermission is an action a user may be allowed to take in an enclave
+type EnclavePermission string
+
+// Enclave permissions
+const (
+	PermissionRead   EnclavePermission = "Read"
+	PermissionCreate EnclavePermission = "Create"
+	PermissionUpdate EnclavePermission = "Update"
+)
+
+// EnclaveError describes an enclave that cannot be used for a request
+type EnclaveError struct {
+	Enclave    string            // the enclave ID or name as given
+	Permission EnclavePermission // the missing permission, empty if the enclave is unknown
+	Reason     string            // why the enclave could not be resolved, such as "unknown"
+}
+
+func (e *EnclaveError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("%s enclave %s", e.Reason, e.Enclave)
+	}
+	return fmt.Sprintf("no %s permission on enclave %s", e.Permission, e.Enclave)
+}
+
+// EnclaveRegistry caches the enclaves the user has access to, resolves enclave names to IDs
+// and checks permissions before a request is sent. It is safe for concurrent use.
+type EnclaveRegistry struct {
+	mu       sync.RWMutex
+	client   *Client
+	byID     map[string]Enclave
+	byName   map[string][]Enclave // keyed by lowercased name
+	loadedAt time.Time
+
+	// TTL is how long the enclave list is served before it is reloaded, defaults to DefaultEnclaveTTL
+	TTL time.Duration
+}
+
+// NewEnclaveRegistry returns an EnclaveRegistry for the given Client. The enclaves are loaded on first use.
+func NewEnclaveRegistry(c *Client) *EnclaveRegistry {
+	return &EnclaveRegistry{client: c, TTL: DefaultEnclaveTTL}
+}
+
+// Load fetches the enclave list, replacing the cached copy
+func (r *EnclaveRegistry) Load() error {
+	enclaves, err := r.client.GetEnclaves()
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]Enclave, len(enclaves))
+	byName := make(map[string][]Enclave, len(enclaves))
+	for _, e := range enclaves {
+		byID[e.ID] = e
+		key := strings.ToLower(e.Name)
+		byName[key] = append(byName[key], e)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.byID, r.byName, r.loadedAt = byID, byName, time.Now()
+	return nil
+}
+
+func (r *EnclaveRegistry) ensure() error {
+	ttl := r.TTL
+	if ttl <= 0 {
+		ttl = DefaultEnclaveTTL
+	}
+
+	r.mu.RLock()
+	fresh := r.byID != nil && time.Since(r.loadedAt) < ttl
+	r.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+	return r.Load()
+}
+
+// Enclaves returns the cached enclaves sorted by name
+func (r *EnclaveRegistry) Enclaves() ([]Enclave, error) {
+	if err := r.ensure(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	enclaves := make([]Enclave, 0, len(r.byID))
+	for _, e := range r.byID {
+		enclaves = append(enclaves, e)
+	}
+	sort.Slice(enclaves, func(i, j int) bool { return enclaves[i].Name < enclaves[j].Name })
+	return enclaves, nil
+}
+
+// Get returns the enclave with the given ID, or failing that, the only enclave with the given name.
+// Names are matched case-insensitively; a name shared by several enclaves is reported as ambiguous.
+func (r *EnclaveRegistry) Get(idOrName string) (Enclave, error) {
+	if err := r.ensure(); err != nil {
+		return Enclave{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.get(idOrName)
+}
+
+func (r *EnclaveRegistry) get(idOrName string) (Enclave, error) {
+	if e, ok := r.byID[idOrName]; ok {
+		return e, nil
+	}
+
+	switch matches := r.byName[strings.ToLower(strings.TrimSpace(idOrName))]; len(matches) {
+	case 0:
+		return Enclave{}, &EnclaveError{Enclave: idOrName, Reason: "unknown"}
+	case 1:
+		return matches[0], nil
+	}
+	return Enclave{}, &EnclaveError{Enclave: idOrName, Reason: "ambiguous"}
+}
+
+// Resolve returns the IDs of the given enclave IDs or names
+func (r *EnclaveRegistry) Resolve(idsOrNames ...string) ([]string, error) {
+	return r.Check("", idsOrNames...)
+}
+
+// Check resolves the given enclave IDs or names and verifies the user has the permission in each of them.
+// It returns the resolved IDs; the error lists every enclave that failed.
+func (r *EnclaveRegistry) Check(perm EnclavePermission, idsOrNames ...string) ([]string, error) {
+	if err := r.ensure(); err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]string, 0, len(idsOrNames))
+	var errs []error
+	for _, v := range idsOrNames {
+		e, err := r.get(v)
+		if err == nil && !hasPermission(e, perm) {
+			err = &EnclaveError{Enclave: enclaveLabel(e), Permission: perm}
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ids = append(ids, e.ID)
+	}
+
+	switch len(errs) {
+	case 0:
+		return ids, nil
+	case 1:
+		return ids, errs[0]
+	}
+
+	msgs := make([]string, len(errs))
+	for i, err := range errs {
+		msgs[i] = err.Error()
+	}
+	return ids, errors.New(strings.Join(msgs, "; "))
+}
+
+// ValidateReport resolves the enclaves of a new report and checks the user can create reports in them.
+// COMMUNITY reports are not checked as the API disregards their enclaves.
+func (r *EnclaveRegistry) ValidateReport(report ReportSubmission) (ReportSubmission, error) {
+	return r.validateReport(report, PermissionCreate)
+}
+
+// ValidateReportUpdate resolves the enclaves of a report update and checks the user can update reports in them
+func (r *EnclaveRegistry) ValidateReportUpdate(report ReportSubmission) (ReportSubmission, error) {
+	return r.validateReport(report, PermissionUpdate)
+}
+
+func (r *EnclaveRegistry) validateReport(report ReportSubmission, perm EnclavePermission) (ReportSubmission, error) {
+	if strings.EqualFold(report.DistributionType, "COMMUNITY") {
+		return report, nil
+	}
+	if len(report.EnclaveIds) == 0 {
+		return report, errors.New("ENCLAVE distribution requires at least one enclave")
+	}
+
+	ids, err := r.Check(perm, report.EnclaveIds...)
+	if err != nil {
+		return report, err
+	}
+	report.EnclaveIds = ids
+	return report, nil
+}
+
+// ValidateIndicators resolves the enclaves of an indicator submission and checks the user can create in them
+func (r *EnclaveRegistry) ValidateIndicators(submission IndicatorSubmission) (IndicatorSubmission, error) {
+	if len(submission.EnclaveIDS) == 0 {
+		return submission, errors.New("at least one enclave is required to submit indicators")
+	}
+
+	ids, err := r.Check(PermissionCreate, submission.EnclaveIDS...)
+	if err != nil {
+		return submission, err
+	}
+	submission.EnclaveIDS = ids
+	return submission, nil
+}
+
+func hasPermission(e Enclave, perm EnclavePermission) bool {
+	switch perm {
+	case PermissionRead:
+		return e.Read
+	case PermissionCreate:
+		return e.Create
+	case PermissionUpdate:
+		return e.Update
+	}
+	return true
+}
+
+// enclaveLabel names an enclave in error messages
+func enclaveLabel(e Enclave) string {
+	if e.Name == "" || e.Name == e.ID {
+		return e.ID
+	}
+	return fmt.Sprintf("%q (%s)", e.Name, e.ID)
+}
@@ -0,0 +1,137 @@
+package trustar
+
+import (
+	"net/http"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newEnclaveRegistry(t *testing.T, loads *int32) (*EnclaveRegistry, func()) {
+	t.Helper()
+
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/enclaves" {
+			http.NotFound(w, r)
+			return
+		}
+		atomic.AddInt32(loads, 1)
+		writeJSON(w, []Enclave{
+			{ID: "e1", Name: "Research", Read: true, Create: true, Update: true},
+			{ID: "e2", Name: "Read Only", Read: true},
+			{ID: "e3", Name: "Shared", Read: true, Create: true},
+			{ID: "e4", Name: "shared", Read: true, Create: true},
+		})
+	})
+	return NewEnclaveRegistry(c), done
+}
+
+func TestEnclaveRegistryCheck(t *testing.T) {
+	var loads int32
+	r, done := newEnclaveRegistry(t, &loads)
+	defer done()
+
+	tests := []struct {
+		name  string
+		perm  EnclavePermission
+		given []string
+		ids   []string
+		err   string
+	}{
+		{name: "IDs", perm: PermissionCreate, given: []string{"e1", "e3"}, ids: []string{"e1", "e3"}},
+		{name: "names", given: []string{"research", " READ ONLY "}, ids: []string{"e1", "e2"}},
+		{name: "read", perm: PermissionRead, given: []string{"Read Only"}, ids: []string{"e2"}},
+		{name: "missing permission", perm: PermissionCreate, given: []string{"e1", "Read Only"}, ids: []string{"e1"}, err: `no Create permission on enclave "Read Only" (e2)`},
+		{name: "update", perm: PermissionUpdate, given: []string{"e3"}, ids: []string{}, err: `no Update permission on enclave "Shared" (e3)`},
+		{name: "unknown", given: []string{"nope"}, ids: []string{}, err: "unknown enclave nope"},
+		{name: "ambiguous", given: []string{"SHARED"}, ids: []string{}, err: "ambiguous enclave SHARED"},
+		{name: "several errors", perm: PermissionUpdate, given: []string{"nope", "e2", "e1"}, ids: []string{"e1"}, err: `unknown enclave nope; no Update permission on enclave "Read Only" (e2)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ids, err := r.Check(tt.perm, tt.given...)
+			if (err != nil || tt.err != "") && (err == nil || err.Error() != tt.err) {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) {
+				t.Errorf("got IDs %v, want %v", ids, tt.ids)
+			}
+		})
+	}
+
+	if n := atomic.LoadInt32(&loads); n != 1 {
+		t.Errorf("got %d loads, want 1 within the TTL", n)
+	}
+
+	if _, err := r.Check(PermissionRead, "nope"); err == nil {
+		t.Fatal("want an error")
+	} else if e, ok := err.(*EnclaveError); !ok || e.Reason != "unknown" {
+		t.Errorf("got %#v, want an *EnclaveError", err)
+	}
+}
+
+func TestEnclaveRegistryLookup(t *testing.T) {
+	var loads int32
+	r, done := newEnclaveRegistry(t, &loads)
+	defer done()
+
+	if e, err := r.Get("research"); err != nil || e.ID != "e1" {
+		t.Errorf("got %+v, %v", e, err)
+	}
+	if ids, err := r.Resolve("e2", "Research"); err != nil || !reflect.DeepEqual(ids, []string{"e2", "e1"}) {
+		t.Errorf("got %v, %v", ids, err)
+	}
+
+	enclaves, err := r.Enclaves()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range enclaves {
+		names = append(names, e.Name)
+	}
+	if want := []string{"Read Only", "Research", "Shared", "shared"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("got %v, want %v", names, want)
+	}
+
+	r.TTL = time.Nanosecond
+	r.Get("e1")
+	if n := atomic.LoadInt32(&loads); n != 2 {
+		t.Errorf("got %d loads, want a reload after the TTL", n)
+	}
+}
+
+func TestEnclaveRegistryValidate(t *testing.T) {
+	var loads int32
+	r, done := newEnclaveRegistry(t, &loads)
+	defer done()
+
+	report, err := r.ValidateReport(ReportSubmission{DistributionType: "ENCLAVE", EnclaveIds: []string{"Research", "e3"}})
+	if err != nil || !reflect.DeepEqual(report.EnclaveIds, []string{"e1", "e3"}) {
+		t.Errorf("got %v, %v, want names resolved to IDs", report.EnclaveIds, err)
+	}
+
+	if _, err := r.ValidateReport(ReportSubmission{DistributionType: "ENCLAVE"}); err == nil {
+		t.Error("want an error for a report without enclaves")
+	}
+	if report, err := r.ValidateReport(ReportSubmission{DistributionType: "community", EnclaveIds: []string{"nope"}}); err != nil || report.EnclaveIds[0] != "nope" {
+		t.Errorf("got %v, %v, want COMMUNITY reports left alone", report.EnclaveIds, err)
+	}
+
+	if _, err := r.ValidateReportUpdate(ReportSubmission{DistributionType: "ENCLAVE", EnclaveIds: []string{"e3"}}); err == nil {
+		t.Error("want an error for an enclave without update permission")
+	}
+
+	sub, err := r.ValidateIndicators(IndicatorSubmission{EnclaveIDS: []string{"research"}})
+	if err != nil || !reflect.DeepEqual(sub.EnclaveIDS, []string{"e1"}) {
+		t.Errorf("got %v, %v", sub.EnclaveIDS, err)
+	}
+	if _, err := r.ValidateIndicators(IndicatorSubmission{}); err == nil {
+		t.Error("want an error for a submission without enclaves")
+	}
+	if _, err := r.ValidateIndicators(IndicatorSubmission{EnclaveIDS: []string{"e2"}}); err == nil {
+		t.Error("want an error for an enclave without create permission")
+	}
+}
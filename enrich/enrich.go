@@ -0,0 +1,200 @@
+// Package enrich combines TruSTAR indicator data with other sources into a single record per indicator.
+package enrich
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+const (
+	// DefaultBatchSize is the number of indicators passed to each Enrich call
+	DefaultBatchSize = 1000
+
+	// DefaultConcurrency is the number of Enrich calls run at once
+	DefaultConcurrency = 4
+)
+
+// Enricher adds information about a batch of indicators.
+// Enrich returns an Update for each indicator it has something to say about, keyed by indicator value.
+// Enrichers run concurrently with each other and must not modify the indicators they are given.
+type Enricher interface {
+	Name() string
+	Enrich(indicators []trustar.Indicator) (map[string]Update, error)
+}
+
+// Update sets the fields an Enricher contributes to an EnrichedIndicator.
+// Updates are applied one at a time after all enrichers have finished.
+type Update func(*EnrichedIndicator)
+
+// EnrichedIndicator holds everything known about an indicator
+type EnrichedIndicator struct {
+	trustar.Indicator
+
+	Metadata *trustar.IndicatorMetadata `json:"metadata,omitempty"` // TruSTAR metadata, nil if TruSTAR has none
+
+	Whitelisted bool `json:"whitelisted"` // whether the value is on the company whitelist
+
+	Trending         bool  `json:"trending"`                   // whether the indicator is currently trending in the community
+	CorrelationCount int64 `json:"correlationCount,omitempty"` // the number of community reports a trending indicator appeared in
+
+	Geo *Geo `json:"geo,omitempty"` // location of an IP indicator
+	ASN *ASN `json:"asn,omitempty"` // autonomous system of an IP indicator
+
+	Fields map[string]interface{} `json:"fields,omitempty"` // values added by custom enrichers
+	Errors map[string]string      `json:"errors,omitempty"` // errors keyed by enricher name
+}
+
+// Set stores a custom field value
+func (e *EnrichedIndicator) Set(key string, value interface{}) {
+	if e.Fields == nil {
+		e.Fields = make(map[string]interface{})
+	}
+	e.Fields[key] = value
+}
+
+func (e *EnrichedIndicator) setError(enricher, msg string) {
+	if e.Errors == nil {
+		e.Errors = make(map[string]string)
+	}
+	e.Errors[enricher] = msg
+}
+
+// FailedEnrichers lists the names of the enrichers that failed for this indicator in a stable order
+func (e *EnrichedIndicator) FailedEnrichers() []string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Pipeline runs a set of enrichers over indicators
+type Pipeline struct {
+	Enrichers []Enricher
+
+	// BatchSize is the number of indicators passed to each Enrich call. Defaults to DefaultBatchSize.
+	BatchSize int
+
+	// Concurrency is the number of Enrich calls run at once. Defaults to DefaultConcurrency.
+	Concurrency int
+}
+
+// NewPipeline returns a Pipeline running the given enrichers
+func NewPipeline(enrichers ...Enricher) *Pipeline {
+	return &Pipeline{
+		Enrichers:   enrichers,
+		BatchSize:   DefaultBatchSize,
+		Concurrency: DefaultConcurrency,
+	}
+}
+
+// Values returns a Pipeline input for plain indicator values
+func Values(values ...string) []trustar.Indicator {
+	indicators := make([]trustar.Indicator, 0, len(values))
+	for _, v := range values {
+		indicators = append(indicators, trustar.Indicator{Value: v})
+	}
+	return indicators
+}
+
+// Run enriches the indicators and returns one record per distinct value, in input order.
+// An enricher that fails for a batch records its error on each indicator of that batch instead of stopping the pipeline.
+func (p *Pipeline) Run(indicators []trustar.Indicator) []EnrichedIndicator {
+	records := make([]EnrichedIndicator, 0, len(indicators))
+	index := make(map[string]int, len(indicators))
+	unique := make([]trustar.Indicator, 0, len(indicators))
+	for _, ind := range indicators {
+		ind.Value = strings.TrimSpace(ind.Value)
+		if ind.Value == "" {
+			continue
+		}
+		if _, ok := index[ind.Value]; ok {
+			continue
+		}
+		index[ind.Value] = len(records)
+		records = append(records, EnrichedIndicator{Indicator: ind})
+		unique = append(unique, ind)
+	}
+
+	batchSize := p.BatchSize
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	concurrency := p.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	type job struct {
+		enricher Enricher
+		batch    []trustar.Indicator
+	}
+	type result struct {
+		job
+		updates map[string]Update
+		err     error
+	}
+
+	var jobs []job
+	for _, e := range p.Enrichers {
+		for start := 0; start < len(unique); start += batchSize {
+			end := start + batchSize
+			if end > len(unique) {
+				end = len(unique)
+			}
+			jobs = append(jobs, job{enricher: e, batch: unique[start:end]})
+		}
+	}
+
+	results := make([]result, len(jobs))
+	sem := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			updates, err := jobs[i].enricher.Enrich(jobs[i].batch)
+			results[i] = result{job: jobs[i], updates: updates, err: err}
+		}(i)
+	}
+	wg.Wait()
+
+	// apply updates in enricher order so later enrichers win when they set the same field
+	for _, r := range results {
+		name := r.enricher.Name()
+		if r.err != nil {
+			for _, ind := range r.batch {
+				records[index[ind.Value]].setError(name, r.err.Error())
+			}
+		}
+		for value, update := range r.updates {
+			if i, ok := index[value]; ok && update != nil {
+				update(&records[i])
+			}
+		}
+	}
+
+	return records
+}
+
+// Func adapts a function to the Enricher interface
+func Func(name string, fn func([]trustar.Indicator) (map[string]Update, error)) Enricher {
+	return funcEnricher{name: name, fn: fn}
+}
+
+type funcEnricher struct {
+	name string
+	fn   func([]trustar.Indicator) (map[string]Update, error)
+}
+
+func (f funcEnricher) Name() string { return f.name }
+
+func (f funcEnricher) Enrich(indicators []trustar.Indicator) (map[string]Update, error) {
+	return f.fn(indicators)
+}
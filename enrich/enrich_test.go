@@ -0,0 +1,107 @@
+package enrich
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// recorder is an Enricher that sets a field on every indicator and records the batches it was given
+type recorder struct {
+	name  string
+	field string
+	fail  string // value making a batch fail
+
+	mu      sync.Mutex
+	batches [][]string
+}
+
+func (r *recorder) Name() string { return r.name }
+
+func (r *recorder) Enrich(indicators []trustar.Indicator) (map[string]Update, error) {
+	var values []string
+	for _, ind := range indicators {
+		values = append(values, ind.Value)
+	}
+	r.mu.Lock()
+	r.batches = append(r.batches, values)
+	r.mu.Unlock()
+
+	updates := make(map[string]Update)
+	for _, ind := range indicators {
+		if ind.Value == r.fail {
+			return updates, errors.New("failed on " + ind.Value)
+		}
+		updates[ind.Value] = func(e *EnrichedIndicator) { e.Set(r.field, r.name) }
+	}
+	return updates, nil
+}
+
+func TestPipelineRun(t *testing.T) {
+	first := &recorder{name: "first", field: "shared"}
+	second := &recorder{name: "second", field: "shared", fail: "c"}
+	p := NewPipeline(first, second)
+	p.BatchSize = 2
+
+	records := p.Run(Values("a", " b ", "", "a", "c", "d"))
+
+	var values []string
+	for _, r := range records {
+		values = append(values, r.Value)
+	}
+	if want := []string{"a", "b", "c", "d"}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("got %v, want the distinct trimmed values %v", values, want)
+	}
+
+	if want := [][]string{{"a", "b"}, {"c", "d"}}; !reflect.DeepEqual(first.batches, want) && !reflect.DeepEqual(first.batches, [][]string{want[1], want[0]}) {
+		t.Errorf("got batches %v, want %v", first.batches, want)
+	}
+
+	// the later enricher wins, except where its batch failed
+	for _, r := range records[:2] {
+		if r.Fields["shared"] != "second" || r.Errors != nil {
+			t.Errorf("got %+v, want the second enricher's value", r)
+		}
+	}
+	for _, r := range records[2:] {
+		if got := r.FailedEnrichers(); !reflect.DeepEqual(got, []string{"second"}) || r.Errors["second"] != "failed on c" {
+			t.Errorf("got errors %v for %s, want the second enricher's failure", r.Errors, r.Value)
+		}
+	}
+	if records[2].Fields["shared"] != "first" {
+		t.Errorf("got %v, want the first enricher's value to survive a failed batch", records[2].Fields)
+	}
+}
+
+func TestPipelineConcurrency(t *testing.T) {
+	var running, peak int32
+	slow := Func("slow", func(indicators []trustar.Indicator) (map[string]Update, error) {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			p := atomic.LoadInt32(&peak)
+			if n <= p || atomic.CompareAndSwapInt32(&peak, p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil, nil
+	})
+
+	p := &Pipeline{Enrichers: []Enricher{slow}, BatchSize: 1, Concurrency: 2}
+	if records := p.Run(Values("a", "b", "c", "d", "e")); len(records) != 5 {
+		t.Fatalf("got %d records, want 5", len(records))
+	}
+	if got := atomic.LoadInt32(&peak); got != 2 {
+		t.Errorf("got %d concurrent calls, want 2", got)
+	}
+
+	if slow.Name() != "slow" {
+		t.Errorf("got name %q", slow.Name())
+	}
+}
@@ -0,0 +1,140 @@
+package enrich
+
+import (
+	"net"
+
+	trustar "github.com/jakewarren/trustar-golang"
+	"github.com/jakewarren/trustar-golang/enrich/mmdb"
+)
+
+// Geo is the location of an IP address
+type Geo struct {
+	Continent   string  `json:"continent,omitempty"`
+	CountryCode string  `json:"countryCode,omitempty"`
+	Country     string  `json:"country,omitempty"`
+	Region      string  `json:"region,omitempty"`
+	City        string  `json:"city,omitempty"`
+	Latitude    float64 `json:"latitude,omitempty"`
+	Longitude   float64 `json:"longitude,omitempty"`
+}
+
+// ASN is the autonomous system an IP address belongs to
+type ASN struct {
+	Number       uint64 `json:"number"`
+	Organization string `json:"organization,omitempty"`
+}
+
+// GeoIP adds location and ASN data for IP indicators from local MaxMind databases
+// such as GeoLite2-City.mmdb and GeoLite2-ASN.mmdb. Either database may be nil.
+type GeoIP struct {
+	City *mmdb.Reader
+	ASN  *mmdb.Reader
+
+	// Language selects the localized names used. Defaults to "en".
+	Language string
+}
+
+// NewGeoIP opens the city and ASN databases. Pass an empty path to skip a database.
+func NewGeoIP(cityPath, asnPath string) (*GeoIP, error) {
+	g := &GeoIP{}
+	var err error
+	if cityPath != "" {
+		if g.City, err = mmdb.Open(cityPath); err != nil {
+			return nil, err
+		}
+	}
+	if asnPath != "" {
+		if g.ASN, err = mmdb.Open(asnPath); err != nil {
+			return nil, err
+		}
+	}
+	return g, nil
+}
+
+// Name implements Enricher
+func (g *GeoIP) Name() string { return "geoip" }
+
+// Enrich implements Enricher. Indicators that are not IP addresses are skipped.
+func (g *GeoIP) Enrich(indicators []trustar.Indicator) (map[string]Update, error) {
+	updates := make(map[string]Update)
+	for _, ind := range indicators {
+		ip := net.ParseIP(ind.Value)
+		if ip == nil {
+			continue
+		}
+
+		geo, err := g.lookupCity(ip)
+		if err != nil {
+			return nil, err
+		}
+		asn, err := g.lookupASN(ip)
+		if err != nil {
+			return nil, err
+		}
+		if geo == nil && asn == nil {
+			continue
+		}
+
+		updates[ind.Value] = func(e *EnrichedIndicator) {
+			if geo != nil {
+				e.Geo = geo
+			}
+			if asn != nil {
+				e.ASN = asn
+			}
+		}
+	}
+	return updates, nil
+}
+
+func (g *GeoIP) lookupCity(ip net.IP) (*Geo, error) {
+	if g.City == nil {
+		return nil, nil
+	}
+
+	rec, ok, err := g.City.Lookup(ip)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	lang := g.Language
+	if lang == "" {
+		lang = "en"
+	}
+
+	geo := &Geo{
+		Continent:   str(rec, "continent", "names", lang),
+		CountryCode: str(rec, "country", "iso_code"),
+		Country:     str(rec, "country", "names", lang),
+		Region:      str(rec, "subdivisions", 0, "names", lang),
+		City:        str(rec, "city", "names", lang),
+	}
+	geo.Latitude, _ = value(rec, "location", "latitude").(float64)
+	geo.Longitude, _ = value(rec, "location", "longitude").(float64)
+	return geo, nil
+}
+
+func (g *GeoIP) lookupASN(ip net.IP) (*ASN, error) {
+	if g.ASN == nil {
+		return nil, nil
+	}
+
+	rec, ok, err := g.ASN.Lookup(ip)
+	if err != nil || !ok {
+		return nil, err
+	}
+
+	asn := &ASN{Organization: str(rec, "autonomous_system_organization")}
+	asn.Number, _ = value(rec, "autonomous_system_number").(uint64)
+	return asn, nil
+}
+
+func value(rec map[string]interface{}, path ...interface{}) interface{} {
+	v, _ := mmdb.Get(rec, path...)
+	return v
+}
+
+func str(rec map[string]interface{}, path ...interface{}) string {
+	s, _ := value(rec, path...).(string)
+	return s
+}
@@ -0,0 +1,55 @@
+package enrich
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestGeoIP(t *testing.T) {
+	// the fixture is written by mmdb/testdata/gen.go and holds both city and ASN fields
+	db := filepath.Join("mmdb", "testdata", "test-ipv6-24.mmdb")
+	g, err := NewGeoIP(db, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := NewPipeline(g).Run(Values("1.2.3.4", "1.2.4.4", "2001:db8::1", "8.8.8.8", "example.com"))
+
+	want := Geo{
+		Continent:   "North America",
+		CountryCode: "US",
+		Country:     "United States",
+		Region:      "Illinois",
+		City:        "Springfield",
+		Latitude:    39.7817,
+		Longitude:   -89.6501,
+	}
+	if r := records[0]; r.Geo == nil || *r.Geo != want || r.ASN == nil || *r.ASN != (ASN{Number: 64500, Organization: "Example Networks"}) {
+		t.Errorf("got geo %+v, asn %+v", r.Geo, r.ASN)
+	}
+	if r := records[1]; r.Geo == nil || r.Geo.City != "Shelbyville" || r.Geo.Region != "" || r.ASN == nil || r.ASN.Number != 0 {
+		t.Errorf("got geo %+v, asn %+v, want a partial record", r.Geo, r.ASN)
+	}
+	if r := records[2]; r.Geo == nil || r.Geo.CountryCode != "NL" || r.ASN.Number != 64501 {
+		t.Errorf("got geo %+v, asn %+v", r.Geo, r.ASN)
+	}
+	for _, r := range records[3:] {
+		if r.Geo != nil || r.ASN != nil || r.Errors != nil {
+			t.Errorf("got %+v, want %s left alone", r, r.Value)
+		}
+	}
+
+	g.Language = "de"
+	if r := NewPipeline(g).Run(Values("2001:db8::1"))[0]; r.Geo.Country != "Niederlande" || r.Geo.City != "" {
+		t.Errorf("got %+v, want German names where the database has them", r.Geo)
+	}
+
+	asnOnly := &GeoIP{ASN: g.ASN}
+	if r := NewPipeline(asnOnly).Run(Values("1.2.3.4"))[0]; r.Geo != nil || r.ASN == nil {
+		t.Errorf("got geo %+v, asn %+v, want only the ASN", r.Geo, r.ASN)
+	}
+
+	if _, err := NewGeoIP(filepath.Join("mmdb", "testdata", "missing.mmdb"), ""); err == nil {
+		t.Error("want an error for a missing database")
+	}
+}
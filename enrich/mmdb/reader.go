@@ -0,0 +1,424 @@
+// Package mmdb reads MaxMind DB files such as GeoLite2-City.mmdb and GeoLite2-ASN.mmdb.
+// Reference: https://maxmind.github.io/MaxMind-DB/
+package mmdb
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/big"
+	"net"
+)
+
+// metadataStart marks the beginning of the metadata section at the end of the file
+var metadataStart = []byte("\xab\xcd\xefMaxMind.com")
+
+// dataSectionSeparator is the number of zero bytes between the search tree and the data section
+const dataSectionSeparator = 16
+
+// Metadata describes a database
+type Metadata struct {
+	NodeCount    uint
+	RecordSize   uint
+	IPVersion    uint
+	DatabaseType string
+	BuildEpoch   uint64
+	Description  map[string]interface{}
+}
+
+// Reader looks up IP addresses in a database held in memory. It is safe for concurrent use.
+type Reader struct {
+	Metadata Metadata
+
+	buf       []byte
+	treeSize  uint
+	dataStart uint
+	ipv4Start uint // node IPv4 lookups start from in an IPv6 tree
+}
+
+// Open reads the database file at path
+func Open(path string) (*Reader, error) {
+	buf, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(buf)
+}
+
+// FromBytes returns a Reader for a database already in memory
+func FromBytes(buf []byte) (*Reader, error) {
+	i := bytes.LastIndex(buf, metadataStart)
+	if i < 0 {
+		return nil, errors.New("mmdb: metadata section not found")
+	}
+
+	metaStart := uint(i + len(metadataStart))
+	d := decoder{buf: buf[metaStart:]}
+	raw, _, err := d.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("mmdb: decoding metadata: %v", err)
+	}
+
+	meta, ok := raw.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("mmdb: metadata is not a map")
+	}
+
+	r := &Reader{buf: buf}
+	r.Metadata.NodeCount = uint(toUint(meta["node_count"]))
+	r.Metadata.RecordSize = uint(toUint(meta["record_size"]))
+	r.Metadata.IPVersion = uint(toUint(meta["ip_version"]))
+	r.Metadata.BuildEpoch = toUint(meta["build_epoch"])
+	r.Metadata.DatabaseType, _ = meta["database_type"].(string)
+	r.Metadata.Description, _ = meta["description"].(map[string]interface{})
+
+	switch r.Metadata.RecordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("mmdb: unsupported record size %d", r.Metadata.RecordSize)
+	}
+
+	r.treeSize = r.Metadata.NodeCount * r.Metadata.RecordSize / 4
+	r.dataStart = r.treeSize + dataSectionSeparator
+	if r.dataStart > uint(i) {
+		return nil, errors.New("mmdb: search tree is larger than the file")
+	}
+
+	if r.Metadata.IPVersion == 6 {
+		r.ipv4Start = r.ipv4Node()
+	}
+
+	return r, nil
+}
+
+// Lookup returns the record for an IP address. The boolean is false if the database has no record for it.
+// Records are decoded into map[string]interface{}, []interface{}, string, float64, uint64, int64, bool, []byte and *big.Int values.
+func (r *Reader) Lookup(ip net.IP) (map[string]interface{}, bool, error) {
+	pointer, err := r.find(ip)
+	if err != nil || pointer == 0 {
+		return nil, false, err
+	}
+
+	offset := pointer - r.Metadata.NodeCount - dataSectionSeparator
+	d := decoder{buf: r.buf[r.dataStart:]}
+	value, _, err := d.decode(offset)
+	if err != nil {
+		return nil, false, err
+	}
+
+	record, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, false, fmt.Errorf("mmdb: record for %s is not a map", ip)
+	}
+	return record, true, nil
+}
+
+// find walks the search tree and returns the data record pointer, or zero if there is no record
+func (r *Reader) find(ip net.IP) (uint, error) {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	} else if ip = ip.To16(); ip == nil {
+		return 0, errors.New("mmdb: invalid IP address")
+	}
+
+	if r.Metadata.IPVersion == 4 && len(ip) == 16 {
+		return 0, errors.New("mmdb: cannot look up an IPv6 address in an IPv4 database")
+	}
+
+	node := uint(0)
+	if len(ip) == 4 && r.Metadata.IPVersion == 6 {
+		node = r.ipv4Start
+	}
+
+	bits := uint(len(ip) * 8)
+	for i := uint(0); i < bits && node < r.Metadata.NodeCount; i++ {
+		bit := uint(ip[i/8]>>(7-i%8)) & 1
+		var err error
+		if node, err = r.readNode(node, bit); err != nil {
+			return 0, err
+		}
+	}
+
+	switch {
+	case node == r.Metadata.NodeCount:
+		return 0, nil
+	case node > r.Metadata.NodeCount:
+		return node, nil
+	}
+	return 0, errors.New("mmdb: invalid search tree")
+}
+
+// ipv4Node returns the node IPv4 lookups start from in an IPv6 tree, the node reached by 96 zero bits
+func (r *Reader) ipv4Node() uint {
+	node := uint(0)
+	for i := 0; i < 96 && node < r.Metadata.NodeCount; i++ {
+		next, err := r.readNode(node, 0)
+		if err != nil {
+			return r.Metadata.NodeCount
+		}
+		node = next
+	}
+	return node
+}
+
+func (r *Reader) readNode(node, bit uint) (uint, error) {
+	size := r.Metadata.RecordSize / 4 // bytes per node
+	base := node * size
+	if base+size > r.treeSize {
+		return 0, errors.New("mmdb: node outside the search tree")
+	}
+	b := r.buf[base : base+size]
+
+	switch r.Metadata.RecordSize {
+	case 24:
+		if bit == 0 {
+			return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return uint(b[3])<<16 | uint(b[4])<<8 | uint(b[5]), nil
+	case 28:
+		if bit == 0 {
+			return (uint(b[3])&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return (uint(b[3])&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6]), nil
+	default:
+		if bit == 0 {
+			return uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3]), nil
+		}
+		return uint(b[4])<<24 | uint(b[5])<<16 | uint(b[6])<<8 | uint(b[7]), nil
+	}
+}
+
+// data section types
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// decoder decodes values from the data section
+type decoder struct {
+	buf []byte
+}
+
+// decode decodes the value at offset and returns it with the offset of the following value
+func (d *decoder) decode(offset uint) (interface{}, uint, error) {
+	return d.decodeDepth(offset, 0)
+}
+
+func (d *decoder) decodeDepth(offset uint, depth int) (interface{}, uint, error) {
+	if depth > 64 {
+		return nil, 0, errors.New("mmdb: data nested too deeply")
+	}
+
+	ctrl, err := d.byteAt(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset++
+
+	typ := uint(ctrl >> 5)
+	if typ == typePointer {
+		pointer, next, err := d.pointer(ctrl, offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		value, _, err := d.decodeDepth(pointer, depth+1)
+		return value, next, err
+	}
+
+	if typ == typeExtended {
+		ext, err := d.byteAt(offset)
+		if err != nil {
+			return nil, 0, err
+		}
+		typ = 7 + uint(ext)
+		offset++
+	}
+
+	size := uint(ctrl & 0x1f)
+	if typ != typeBool && size >= 29 {
+		n := size - 28 // number of size bytes
+		b, err := d.slice(offset, n)
+		if err != nil {
+			return nil, 0, err
+		}
+		offset += n
+
+		switch size {
+		case 29:
+			size = 29 + uint(b[0])
+		case 30:
+			size = 285 + (uint(b[0])<<8 | uint(b[1]))
+		default:
+			size = 65821 + (uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]))
+		}
+	}
+
+	switch typ {
+	case typeMap:
+		m := make(map[string]interface{}, size)
+		for i := uint(0); i < size; i++ {
+			key, next, err := d.decodeDepth(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			k, ok := key.(string)
+			if !ok {
+				return nil, 0, errors.New("mmdb: map key is not a string")
+			}
+			value, after, err := d.decodeDepth(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[k] = value
+			offset = after
+		}
+		return m, offset, nil
+	case typeArray:
+		a := make([]interface{}, 0, size)
+		for i := uint(0); i < size; i++ {
+			value, next, err := d.decodeDepth(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, value)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+
+	b, err := d.slice(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += size
+
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return append([]byte(nil), b...), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, errors.New("mmdb: invalid double size")
+		}
+		return math.Float64frombits(uint64(beUint(b))), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, errors.New("mmdb: invalid float size")
+		}
+		return float64(math.Float32frombits(uint32(beUint(b)))), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		return beUint(b), offset, nil
+	case typeInt32:
+		return int64(int32(uint32(beUint(b)))), offset, nil
+	case typeUint128:
+		return new(big.Int).SetBytes(b), offset, nil
+	}
+
+	return nil, 0, fmt.Errorf("mmdb: unknown data type %d", typ)
+}
+
+// pointer decodes a pointer value and returns the offset it points to and the offset after it
+func (d *decoder) pointer(ctrl byte, offset uint) (uint, uint, error) {
+	ss := uint(ctrl>>3) & 0x3
+	vvv := uint(ctrl & 0x7)
+
+	b, err := d.slice(offset, ss+1)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var p uint
+	switch ss {
+	case 0:
+		p = vvv<<8 | uint(b[0])
+	case 1:
+		p = (vvv<<16 | uint(b[0])<<8 | uint(b[1])) + 2048
+	case 2:
+		p = (vvv<<24 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2])) + 526336
+	default:
+		p = uint(b[0])<<24 | uint(b[1])<<16 | uint(b[2])<<8 | uint(b[3])
+	}
+
+	return p, offset + ss + 1, nil
+}
+
+func (d *decoder) byteAt(offset uint) (byte, error) {
+	if offset >= uint(len(d.buf)) {
+		return 0, errors.New("mmdb: unexpected end of data")
+	}
+	return d.buf[offset], nil
+}
+
+func (d *decoder) slice(offset, n uint) ([]byte, error) {
+	if offset+n > uint(len(d.buf)) {
+		return nil, errors.New("mmdb: unexpected end of data")
+	}
+	return d.buf[offset : offset+n], nil
+}
+
+func beUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func toUint(v interface{}) uint64 {
+	switch n := v.(type) {
+	case uint64:
+		return n
+	case int64:
+		if n >= 0 {
+			return uint64(n)
+		}
+	}
+	return 0
+}
+
+// Get follows a path of map keys and array indexes through a record, such as ("country", "names", "en")
+func Get(record map[string]interface{}, path ...interface{}) (interface{}, bool) {
+	var cur interface{} = record
+	for _, p := range path {
+		switch key := p.(type) {
+		case string:
+			m, ok := cur.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if cur, ok = m[key]; !ok {
+				return nil, false
+			}
+		case int:
+			a, ok := cur.([]interface{})
+			if !ok || key < 0 || key >= len(a) {
+				return nil, false
+			}
+			cur = a[key]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
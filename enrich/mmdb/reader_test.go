@@ -0,0 +1,289 @@
+package mmdb
+
+import (
+	"bytes"
+	"math/big"
+	"net"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The fixtures are written by testdata/gen.go
+func open(t *testing.T, name string) *Reader {
+	t.Helper()
+
+	r, err := Open(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestLookup(t *testing.T) {
+	tests := []struct {
+		ip   string
+		city string // empty when the address has no record
+	}{
+		{"1.2.3.4", "Springfield"},
+		{"1.2.3.255", "Springfield"},
+		{"1.2.4.0", "Shelbyville"},
+		{"1.2.5.1", ""},
+		{"8.8.8.8", ""},
+		{"::1.2.3.4", "Springfield"}, // IPv4-compatible addresses share the ::/96 subtree
+		{"::ffff:1.2.4.9", "Shelbyville"},
+		{"2001:db8::1", "Amsterdam"},
+		{"2001:db8:ffff::", "Amsterdam"},
+		{"2001:db9::1", ""},
+		{"::1", ""},
+	}
+
+	for _, file := range []string{"test-ipv6-24.mmdb", "test-ipv6-28.mmdb", "test-ipv6-32.mmdb"} {
+		t.Run(file, func(t *testing.T) {
+			r := open(t, file)
+
+			for _, tt := range tests {
+				rec, ok, err := r.Lookup(net.ParseIP(tt.ip))
+				if err != nil {
+					t.Fatalf("%s: %v", tt.ip, err)
+				}
+				if ok != (tt.city != "") {
+					t.Errorf("%s: got found %v, want %v", tt.ip, ok, tt.city != "")
+					continue
+				}
+				if !ok {
+					continue
+				}
+				if city, _ := Get(rec, "city", "names", "en"); city != tt.city {
+					t.Errorf("%s: got city %v, want %s", tt.ip, city, tt.city)
+				}
+			}
+		})
+	}
+}
+
+func TestIPv4Start(t *testing.T) {
+	for _, file := range []string{"test-ipv6-24.mmdb", "test-ipv6-28.mmdb", "test-ipv6-32.mmdb"} {
+		r := open(t, file)
+
+		// computed once when the database is opened, the node reached by 96 zero bits
+		if r.ipv4Start == 0 || r.ipv4Start >= r.Metadata.NodeCount || r.ipv4Start != r.ipv4Node() {
+			t.Errorf("%s: got IPv4 start node %d, want %d", file, r.ipv4Start, r.ipv4Node())
+		}
+	}
+
+	if r := open(t, "test-ipv4-24.mmdb"); r.ipv4Start != 0 {
+		t.Errorf("got IPv4 start node %d in an IPv4 database, want the root", r.ipv4Start)
+	}
+}
+
+func TestLookupIPv4Database(t *testing.T) {
+	r := open(t, "test-ipv4-24.mmdb")
+
+	rec, ok, err := r.Lookup(net.ParseIP("1.2.4.200"))
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+	if city, _ := Get(rec, "city", "names", "en"); city != "Shelbyville" {
+		t.Errorf("got city %v, want Shelbyville", city)
+	}
+
+	if _, _, err := r.Lookup(net.ParseIP("2001:db8::1")); err == nil || !strings.Contains(err.Error(), "IPv4 database") {
+		t.Errorf("got error %v, want IPv6 lookups refused", err)
+	}
+	if _, _, err := r.Lookup(net.IP{1, 2, 3}); err == nil {
+		t.Error("want an invalid IP error")
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	tests := []struct {
+		file       string
+		recordSize uint
+		ipVersion  uint
+	}{
+		{"test-ipv6-24.mmdb", 24, 6},
+		{"test-ipv6-28.mmdb", 28, 6},
+		{"test-ipv6-32.mmdb", 32, 6},
+		{"test-ipv4-24.mmdb", 24, 4},
+	}
+
+	for _, tt := range tests {
+		m := open(t, tt.file).Metadata
+		if m.RecordSize != tt.recordSize || m.IPVersion != tt.ipVersion || m.NodeCount == 0 {
+			t.Errorf("%s: got %+v", tt.file, m)
+		}
+		if m.DatabaseType != "Test-City-ASN" || m.BuildEpoch != 1500000000 || !strings.Contains(m.Description["en"].(string), "-bit records") {
+			t.Errorf("%s: got %+v", tt.file, m)
+		}
+	}
+}
+
+func TestDataTypes(t *testing.T) {
+	rec, ok, err := open(t, "test-ipv6-28.mmdb").Lookup(net.ParseIP("10.1.2.3"))
+	if err != nil || !ok {
+		t.Fatalf("got %v, %v", ok, err)
+	}
+
+	uint128, _ := new(big.Int).SetString("1329227995784915872903807060280344576", 10) // 1 << 120
+	want := map[string]interface{}{
+		"bool":    true,
+		"bytes":   []byte{1, 2, 3},
+		"double":  1.5,
+		"float":   0.25,
+		"int32":   int64(-42),
+		"uint16":  uint64(7),
+		"uint32":  uint64(1 << 31),
+		"uint64":  uint64(1 << 63),
+		"uint128": uint128,
+		"array":   []interface{}{"a", uint64(1), false},
+		"long":    strings.Repeat("y", 300),
+	}
+	if !reflect.DeepEqual(rec, want) {
+		t.Errorf("got %#v, want %#v", rec, want)
+	}
+}
+
+func TestPointers(t *testing.T) {
+	r := open(t, "test-ipv6-32.mmdb")
+
+	// both records point to the same country and continent maps, stored more than 2048 bytes into the data section
+	for _, ip := range []string{"1.2.3.4", "1.2.4.4"} {
+		rec, _, err := r.Lookup(net.ParseIP(ip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if v, _ := Get(rec, "country", "names", "de"); v != "USA" {
+			t.Errorf("%s: got country %v, want USA", ip, v)
+		}
+		if v, _ := Get(rec, "continent", "names", "en"); v != "North America" {
+			t.Errorf("%s: got continent %v, want North America", ip, v)
+		}
+	}
+
+	tests := []struct {
+		name string
+		data []byte
+		want uint
+		next uint
+	}{
+		{"one byte", []byte{0x23, 0x45}, 0x345, 2},
+		{"two bytes", []byte{0x2b, 0x45, 0x67}, 0x34567 + 2048, 3},
+		{"three bytes", []byte{0x33, 0x45, 0x67, 0x89}, 0x3456789 + 526336, 4},
+		{"four bytes", []byte{0x38, 0x12, 0x34, 0x56, 0x78}, 0x12345678, 5},
+	}
+	for _, tt := range tests {
+		d := decoder{buf: tt.data}
+		p, next, err := d.pointer(tt.data[0], 1)
+		if err != nil || p != tt.want || next != tt.next {
+			t.Errorf("%s: got %#x, %d, %v, want %#x, %d", tt.name, p, next, err, tt.want, tt.next)
+		}
+	}
+}
+
+func TestReadNode(t *testing.T) {
+	tests := []struct {
+		size        uint
+		node        []byte
+		left, right uint
+	}{
+		{24, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}, 0x010203, 0x040506},
+		{28, []byte{0x01, 0x02, 0x03, 0xab, 0x04, 0x05, 0x06}, 0xa010203, 0xb040506},
+		{32, []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08}, 0x01020304, 0x05060708},
+	}
+
+	for _, tt := range tests {
+		r := &Reader{buf: tt.node, treeSize: uint(len(tt.node))}
+		r.Metadata.RecordSize = tt.size
+
+		left, err := r.readNode(0, 0)
+		if err != nil || left != tt.left {
+			t.Errorf("%d-bit left: got %#x, %v, want %#x", tt.size, left, err, tt.left)
+		}
+		right, err := r.readNode(0, 1)
+		if err != nil || right != tt.right {
+			t.Errorf("%d-bit right: got %#x, %v, want %#x", tt.size, right, err, tt.right)
+		}
+		if _, err := r.readNode(1, 0); err == nil {
+			t.Errorf("%d-bit: want an error for a node outside the tree", tt.size)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	nested := bytes.Repeat([]byte{0x01, 0x04}, 70) // arrays of one array
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"empty", nil, "unexpected end of data"},
+		{"short string", []byte{0x45, 'a'}, "unexpected end of data"},
+		{"bad double", []byte{0x62, 0, 0}, "invalid double size"},
+		{"non-string key", []byte{0xe1, 0xa1, 0x01, 0x41, 'a'}, "map key is not a string"},
+		{"too deep", nested, "nested too deeply"},
+		{"unknown type", []byte{0x00, 0x10}, "unknown data type"},
+	}
+
+	for _, tt := range tests {
+		d := decoder{buf: tt.data}
+		if _, _, err := d.decode(0); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestFromBytesErrors(t *testing.T) {
+	meta := func(fields ...byte) []byte {
+		return append(append([]byte(nil), metadataStart...), fields...)
+	}
+	recordSize := func(size byte) []byte {
+		return append([]byte{0xe2, 0x4b}, append([]byte("record_size"), 0xa1, size, 0x4a)...)
+	}
+	nodeCount := append([]byte("node_count"), 0xa2, 0x03, 0xe8) // 1000
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{"no metadata", []byte("not a database"), "metadata section not found"},
+		{"not a map", meta(0x41, 'a'), "metadata is not a map"},
+		{"record size", meta(append(recordSize(20), nodeCount...)...), "unsupported record size 20"},
+		{"tree too large", meta(append(recordSize(24), nodeCount...)...), "search tree is larger than the file"},
+	}
+
+	for _, tt := range tests {
+		if _, err := FromBytes(tt.data); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: got %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestGet(t *testing.T) {
+	rec := map[string]interface{}{
+		"subdivisions": []interface{}{map[string]interface{}{"iso_code": "IL"}},
+	}
+
+	tests := []struct {
+		path []interface{}
+		want interface{}
+		ok   bool
+	}{
+		{[]interface{}{"subdivisions", 0, "iso_code"}, "IL", true},
+		{[]interface{}{"subdivisions", 1}, nil, false},
+		{[]interface{}{"subdivisions", -1}, nil, false},
+		{[]interface{}{"subdivisions", "iso_code"}, nil, false},
+		{[]interface{}{"missing"}, nil, false},
+		{[]interface{}{"subdivisions", 0, 1.5}, nil, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := Get(rec, tt.path...)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("Get(%v) = %v, %v, want %v, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+}
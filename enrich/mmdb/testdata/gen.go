@@ -0,0 +1,329 @@
+//go:build ignore
+// +build ignore
+
+// gen writes the MaxMind DB fixtures used by the mmdb and enrich tests. Run it from this directory:
+//
+//	go run gen.go
+//
+// Every IPv6 fixture holds the same networks with 24, 28 and 32-bit records. IPv4 networks are stored in the
+// ::/96 subtree, so they can be looked up as IPv4 addresses or as IPv4-compatible IPv6 addresses. Records share
+// their country maps through pointers placed after a padding string, so the pointers need two size bytes.
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"math"
+	"net"
+	"strings"
+)
+
+type (
+	u16    uint16
+	u32    uint32
+	u64    uint64
+	u128   []byte
+	i32    int32
+	f32    float32
+	bin    []byte
+	ptr    int
+	object []kv
+	kv     struct {
+		key   string
+		value interface{}
+	}
+)
+
+func main() {
+	for _, size := range []int{24, 28, 32} {
+		write(fmt.Sprintf("test-ipv6-%d.mmdb", size), 6, size)
+	}
+	write("test-ipv4-24.mmdb", 4, 24)
+}
+
+func write(name string, ipVersion, recordSize int) {
+	var data bytes.Buffer
+
+	// the shared values live past offset 2048, so pointers to them are two bytes long
+	encode(&data, object{{"padding", strings.Repeat("x", 3000)}})
+	us := ptr(data.Len())
+	encode(&data, object{{"iso_code", "US"}, {"names", object{{"en", "United States"}, {"de", "USA"}}}})
+	northAmerica := ptr(data.Len())
+	encode(&data, object{{"names", object{{"en", "North America"}}}})
+
+	springfield := data.Len()
+	encode(&data, object{
+		{"city", object{{"names", object{{"en", "Springfield"}}}}},
+		{"continent", northAmerica},
+		{"country", us},
+		{"subdivisions", []interface{}{object{{"iso_code", "IL"}, {"names", object{{"en", "Illinois"}}}}}},
+		{"location", object{{"latitude", 39.7817}, {"longitude", -89.6501}}},
+		{"autonomous_system_number", u32(64500)},
+		{"autonomous_system_organization", "Example Networks"},
+	})
+
+	shelbyville := data.Len()
+	encode(&data, object{
+		{"city", object{{"names", object{{"en", "Shelbyville"}}}}},
+		{"continent", northAmerica},
+		{"country", us},
+	})
+
+	amsterdam := data.Len()
+	encode(&data, object{
+		{"city", object{{"names", object{{"en", "Amsterdam"}, {"nl", "Amsterdam"}}}}},
+		{"country", object{{"iso_code", "NL"}, {"names", object{{"en", "Netherlands"}, {"de", "Niederlande"}}}}},
+		{"location", object{{"latitude", 52.3740}, {"longitude", 4.8897}}},
+		{"autonomous_system_number", u32(64501)},
+	})
+
+	types := data.Len()
+	encode(&data, object{
+		{"bool", true},
+		{"bytes", bin{1, 2, 3}},
+		{"double", 1.5},
+		{"float", f32(0.25)},
+		{"int32", i32(-42)},
+		{"uint16", u16(7)},
+		{"uint32", u32(1 << 31)},
+		{"uint64", u64(1 << 63)},
+		{"uint128", u128{1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}},
+		{"array", []interface{}{"a", u16(1), false}},
+		{"long", strings.Repeat("y", 300)},
+	})
+
+	tree := newNode()
+	insert(tree, ipVersion, "1.2.3.0/24", springfield)
+	insert(tree, ipVersion, "1.2.4.0/24", shelbyville)
+	insert(tree, ipVersion, "10.0.0.0/8", types)
+	if ipVersion == 6 {
+		insert(tree, ipVersion, "2001:db8::/32", amsterdam)
+	}
+
+	nodes := number(tree)
+	nodeCount := len(nodes)
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		var recs [2]uint32
+		for bit, child := range n.child {
+			switch {
+			case child != nil:
+				recs[bit] = uint32(child.id)
+			case n.data[bit] >= 0:
+				recs[bit] = uint32(nodeCount + 16 + n.data[bit])
+			default:
+				recs[bit] = uint32(nodeCount)
+			}
+		}
+		out.Write(record(recordSize, recs[0], recs[1]))
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	encode(&out, object{
+		{"binary_format_major_version", u16(2)},
+		{"binary_format_minor_version", u16(0)},
+		{"build_epoch", u64(1500000000)},
+		{"database_type", "Test-City-ASN"},
+		{"description", object{{"en", fmt.Sprintf("Test database with %d-bit records", recordSize)}}},
+		{"ip_version", u16(ipVersion)},
+		{"languages", []interface{}{"en"}},
+		{"node_count", u32(nodeCount)},
+		{"record_size", u16(recordSize)},
+	})
+
+	if err := ioutil.WriteFile(name, out.Bytes(), 0644); err != nil {
+		log.Fatal(err)
+	}
+}
+
+type node struct {
+	child [2]*node
+	data  [2]int // data section offset of a record, or -1
+	id    int
+}
+
+func newNode() *node {
+	return &node{data: [2]int{-1, -1}}
+}
+
+// insert adds a network, IPv4 networks in an IPv6 tree go under ::/96
+func insert(root *node, ipVersion int, cidr string, offset int) {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	ones, _ := network.Mask.Size()
+	ip := network.IP
+	if ipVersion == 6 {
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = append(make(net.IP, 12), ip4...)
+			ones += 96
+		}
+	}
+
+	n := root
+	for i := 0; i < ones; i++ {
+		bit := ip[i/8] >> (7 - uint(i%8)) & 1
+		if i == ones-1 {
+			n.data[bit] = offset
+			return
+		}
+		if n.child[bit] == nil {
+			n.child[bit] = newNode()
+		}
+		n = n.child[bit]
+	}
+}
+
+// number assigns node IDs in breadth-first order, the root is node 0
+func number(root *node) []*node {
+	var nodes []*node
+	queue := []*node{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		n.id = len(nodes)
+		nodes = append(nodes, n)
+		for _, c := range n.child {
+			if c != nil {
+				queue = append(queue, c)
+			}
+		}
+	}
+	return nodes
+}
+
+func record(size int, left, right uint32) []byte {
+	switch size {
+	case 24:
+		return []byte{byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right)}
+	case 28:
+		return []byte{byte(left >> 16), byte(left >> 8), byte(left), byte(left>>24)<<4 | byte(right>>24)&0x0F, byte(right >> 16), byte(right >> 8), byte(right)}
+	}
+	b := make([]byte, 8)
+	binary.BigEndian.PutUint32(b, left)
+	binary.BigEndian.PutUint32(b[4:], right)
+	return b
+}
+
+const (
+	typePointer = 1
+	typeString  = 2
+	typeDouble  = 3
+	typeBytes   = 4
+	typeUint16  = 5
+	typeUint32  = 6
+	typeMap     = 7
+	typeInt32   = 8
+	typeUint64  = 9
+	typeUint128 = 10
+	typeArray   = 11
+	typeBool    = 14
+	typeFloat   = 15
+)
+
+func encode(w *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case ptr:
+		p := int(v)
+		switch {
+		case p < 2048:
+			w.Write([]byte{typePointer<<5 | byte(p>>8), byte(p)})
+		case p < 526336:
+			p -= 2048
+			w.Write([]byte{typePointer<<5 | 1<<3 | byte(p>>16), byte(p >> 8), byte(p)})
+		default:
+			log.Fatalf("pointer %d too large", p)
+		}
+	case string:
+		header(w, typeString, len(v))
+		w.WriteString(v)
+	case float64:
+		header(w, typeDouble, 8)
+		binary.Write(w, binary.BigEndian, math.Float64bits(v))
+	case f32:
+		header(w, typeFloat, 4)
+		binary.Write(w, binary.BigEndian, math.Float32bits(float32(v)))
+	case bin:
+		header(w, typeBytes, len(v))
+		w.Write(v)
+	case u16:
+		writeUint(w, typeUint16, uint64(v))
+	case u32:
+		writeUint(w, typeUint32, uint64(v))
+	case u64:
+		writeUint(w, typeUint64, uint64(v))
+	case u128:
+		header(w, typeUint128, len(v))
+		w.Write(v)
+	case i32:
+		header(w, typeInt32, 4)
+		binary.Write(w, binary.BigEndian, int32(v))
+	case bool:
+		n := 0
+		if v {
+			n = 1
+		}
+		header(w, typeBool, n)
+	case object:
+		header(w, typeMap, len(v))
+		for _, e := range v {
+			encode(w, e.key)
+			encode(w, e.value)
+		}
+	case []interface{}:
+		header(w, typeArray, len(v))
+		for _, e := range v {
+			encode(w, e)
+		}
+	default:
+		log.Fatalf("cannot encode %T", v)
+	}
+}
+
+// writeUint writes an unsigned integer without leading zero bytes
+func writeUint(w *bytes.Buffer, typ int, v uint64) {
+	var b []byte
+	for ; v > 0; v >>= 8 {
+		b = append([]byte{byte(v)}, b...)
+	}
+	header(w, typ, len(b))
+	w.Write(b)
+}
+
+func header(w *bytes.Buffer, typ, size int) {
+	var ctrl byte
+	var ext []byte
+	if typ > 7 {
+		ext = []byte{byte(typ - 7)}
+	} else {
+		ctrl = byte(typ) << 5
+	}
+
+	var sizeBytes []byte
+	switch {
+	case size < 29:
+		ctrl |= byte(size)
+	case size < 285:
+		ctrl |= 29
+		sizeBytes = []byte{byte(size - 29)}
+	case size < 65821:
+		ctrl |= 30
+		size -= 285
+		sizeBytes = []byte{byte(size >> 8), byte(size)}
+	default:
+		ctrl |= 31
+		size -= 65821
+		sizeBytes = []byte{byte(size >> 16), byte(size >> 8), byte(size)}
+	}
+
+	w.WriteByte(ctrl)
+	w.Write(ext)
+	w.Write(sizeBytes)
+}
@@ -0,0 +1,160 @@
+package enrich
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// DefaultCacheTTL is how long the trending enricher reuses the fetched lists
+const DefaultCacheTTL = 15 * time.Minute
+
+// Metadata adds TruSTAR indicator metadata from GetIndicatorMetadataBatch
+type Metadata struct {
+	Client  *trustar.Client
+	Options trustar.BatchOptions
+}
+
+// Name implements Enricher
+func (m *Metadata) Name() string { return "metadata" }
+
+// Enrich implements Enricher. Errors from individual requests are recorded on the indicators they affected.
+func (m *Metadata) Enrich(indicators []trustar.Indicator) (map[string]Update, error) {
+	batch, err := m.Client.GetIndicatorMetadataBatch(indicators, m.Options)
+	if batch == nil {
+		return nil, err
+	}
+
+	updates := make(map[string]Update, len(batch.Metadata))
+	for value, md := range batch.Metadata {
+		md := md
+		updates[value] = func(e *EnrichedIndicator) {
+			e.Metadata = &md
+			if e.IndicatorType == "" {
+				e.IndicatorType = md.IndicatorType
+			}
+			if e.GUID == "" {
+				e.GUID = md.GUID
+			}
+			if e.PriorityLevel == "" {
+				e.PriorityLevel = md.PriorityLevel
+			}
+		}
+	}
+	for value, ferr := range batch.Failed {
+		msg := ferr.Error()
+		updates[value] = func(e *EnrichedIndicator) { e.setError("metadata", msg) }
+	}
+	return updates, nil
+}
+
+// Whitelist marks indicators that are covered by the company whitelist, including CIDR and domain-suffix matches
+type Whitelist struct {
+	Cache *trustar.WhitelistCache
+}
+
+// NewWhitelist returns a Whitelist enricher with its own WhitelistCache
+func NewWhitelist(c *trustar.Client) *Whitelist {
+	return &Whitelist{Cache: trustar.NewWhitelistCache(c)}
+}
+
+// Name implements Enricher
+func (w *Whitelist) Name() string { return "whitelist" }
+
+// Enrich implements Enricher
+func (w *Whitelist) Enrich(indicators []trustar.Indicator) (map[string]Update, error) {
+	updates := make(map[string]Update)
+	for _, ind := range indicators {
+		whitelisted, err := w.Cache.Contains(ind.Value)
+		if err != nil {
+			return nil, err
+		}
+		updates[ind.Value] = func(e *EnrichedIndicator) { e.Whitelisted = whitelisted }
+	}
+	return updates, nil
+}
+
+// Trending marks indicators that appear in GetTrendingIndicators
+type Trending struct {
+	Client *trustar.Client
+
+	// Types restricts the trending lists fetched to these indicator types. All types are fetched by default.
+	Types []string
+
+	// DaysBack is the number of days the trending window covers. The API default is used when zero.
+	DaysBack int
+
+	TTL time.Duration // defaults to DefaultCacheTTL
+
+	mu      sync.Mutex
+	counts  map[string]int64
+	fetched time.Time
+}
+
+// Name implements Enricher
+func (t *Trending) Name() string { return "trending" }
+
+// Enrich implements Enricher
+func (t *Trending) Enrich(indicators []trustar.Indicator) (map[string]Update, error) {
+	counts, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+
+	updates := make(map[string]Update)
+	for _, ind := range indicators {
+		count, ok := counts[strings.ToLower(ind.Value)]
+		updates[ind.Value] = func(e *EnrichedIndicator) {
+			e.Trending = ok
+			e.CorrelationCount = count
+		}
+	}
+	return updates, nil
+}
+
+func (t *Trending) load() (map[string]int64, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.counts != nil && time.Since(t.fetched) < ttl(t.TTL) {
+		return t.counts, nil
+	}
+
+	types := t.Types
+	if len(types) == 0 {
+		types = []string{""}
+	}
+
+	counts := make(map[string]int64)
+	for _, typ := range types {
+		v := url.Values{}
+		if typ != "" {
+			v.Set("type", typ)
+		}
+		if t.DaysBack > 0 {
+			v.Set("daysBack", strconv.Itoa(t.DaysBack))
+		}
+
+		trending, err := t.Client.GetTrendingIndicators(v)
+		if err != nil {
+			return nil, err
+		}
+		for _, ind := range trending {
+			counts[strings.ToLower(ind.Value)] = ind.CorrelationCount
+		}
+	}
+
+	t.counts, t.fetched = counts, time.Now()
+	return counts, nil
+}
+
+func ttl(d time.Duration) time.Duration {
+	if d <= 0 {
+		return DefaultCacheTTL
+	}
+	return d
+}
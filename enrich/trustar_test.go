@@ -0,0 +1,133 @@
+package enrich
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// fakeAPI serves indicator metadata, request quotas, the whitelist and trending indicators
+func fakeAPI(t *testing.T, trendingCalls *int32) (*trustar.Client, func()) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/indicators/metadata":
+			var req []trustar.Indicator
+			json.NewDecoder(r.Body).Decode(&req)
+			var resp trustar.IndicatorMetadataResponse
+			for _, ind := range req {
+				if ind.Value == "fail.com" {
+					http.Error(w, "boom", http.StatusInternalServerError)
+					return
+				}
+				if ind.Value == "evil.com" {
+					resp = append(resp, trustar.IndicatorMetadata{Value: "EVIL.com", GUID: "g1", IndicatorType: "URL", PriorityLevel: "HIGH"})
+				}
+			}
+			json.NewEncoder(w).Encode(resp)
+		case "/request-quotas":
+			json.NewEncoder(w).Encode(trustar.RequestQuotas{{MaxRequests: 100}})
+		case "/whitelist":
+			json.NewEncoder(w).Encode(trustar.WhitelistIndicatorsResponse{Items: []trustar.Indicator{
+				{Value: "good.com", IndicatorType: "DOMAIN"},
+				{Value: "10.0.0.0/8", IndicatorType: "CIDR_BLOCK"},
+			}})
+		case "/indicators/community-trending":
+			atomic.AddInt32(trendingCalls, 1)
+			var resp []map[string]interface{}
+			if r.URL.Query().Get("type") == "URL" {
+				resp = append(resp, map[string]interface{}{"value": "Evil.com", "correlationCount": 12})
+			}
+			if r.URL.Query().Get("daysBack") != "3" {
+				http.Error(w, "want daysBack", http.StatusBadRequest)
+				return
+			}
+			json.NewEncoder(w).Encode(resp)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+
+	c, err := trustar.NewClient("id", "secret", srv.URL+"/")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	c.SetAccessToken("token")
+	return c, srv.Close
+}
+
+func TestMetadata(t *testing.T) {
+	var calls int32
+	c, done := fakeAPI(t, &calls)
+	defer done()
+
+	m := &Metadata{Client: c, Options: trustar.BatchOptions{ChunkSize: 1}}
+
+	records := NewPipeline(m).Run([]trustar.Indicator{{Value: "evil.com", PriorityLevel: "LOW"}, {Value: "fail.com"}, {Value: "plain.com"}})
+
+	evil := records[0]
+	if evil.Metadata == nil || evil.GUID != "g1" || evil.IndicatorType != "URL" || evil.PriorityLevel != "LOW" {
+		t.Errorf("got %+v, want metadata filling only the empty fields", evil)
+	}
+	if records[1].Metadata != nil || records[1].Errors["metadata"] == "" {
+		t.Errorf("got %+v, want the failed request recorded", records[1])
+	}
+	if records[2].Metadata != nil || records[2].Errors != nil {
+		t.Errorf("got %+v, want nothing for an unknown indicator", records[2])
+	}
+}
+
+func TestWhitelist(t *testing.T) {
+	var calls int32
+	c, done := fakeAPI(t, &calls)
+	defer done()
+
+	records := NewPipeline(NewWhitelist(c)).Run(Values("good.com", "www.good.com", "10.1.2.3", "http://10.4.5.6/x", "evil.com"))
+
+	want := []bool{true, true, true, true, false}
+	for i, r := range records {
+		if r.Whitelisted != want[i] {
+			t.Errorf("got whitelisted %v for %s, want %v", r.Whitelisted, r.Value, want[i])
+		}
+	}
+}
+
+func TestTrending(t *testing.T) {
+	var calls int32
+	c, done := fakeAPI(t, &calls)
+	defer done()
+
+	tr := &Trending{Client: c, Types: []string{"URL", "IP"}, DaysBack: 3}
+	p := NewPipeline(tr)
+
+	for i := 0; i < 2; i++ {
+		records := p.Run(Values("evil.com", "1.2.3.4"))
+		if r := records[0]; !r.Trending || r.CorrelationCount != 12 {
+			t.Errorf("got %+v, want trending matched case-insensitively", r)
+		}
+		if r := records[1]; r.Trending || r.CorrelationCount != 0 {
+			t.Errorf("got %+v, want not trending", r)
+		}
+	}
+	if n := atomic.LoadInt32(&calls); n != 2 {
+		t.Errorf("got %d trending requests, want one per type within the TTL", n)
+	}
+
+	tr.TTL = time.Nanosecond
+	p.Run(Values("evil.com"))
+	if n := atomic.LoadInt32(&calls); n != 4 {
+		t.Errorf("got %d trending requests, want the lists fetched again after the TTL", n)
+	}
+
+	failing := &Trending{Client: c}
+	if r := NewPipeline(failing).Run(Values("evil.com"))[0]; r.Errors["trending"] == "" {
+		t.Errorf("got %+v, want the request error recorded", r)
+	}
+}
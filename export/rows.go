@@ -0,0 +1,426 @@
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// Format selects the encoding produced by a RowWriter
+type Format int
+
+const (
+	// CSV writes a header line followed by one comma separated line per row
+	CSV Format = iota
+	// JSONL writes one JSON object per line
+	JSONL
+	// Table writes aligned, human readable columns. Rows are buffered until Flush so the columns can be aligned.
+	Table
+)
+
+// epochMsFields lists the JSON field names that hold a time in milliseconds since epoch
+var epochMsFields = map[string]bool{
+	"created":       true,
+	"updated":       true,
+	"timeBegan":     true,
+	"firstSeen":     true,
+	"lastSeen":      true,
+	"lastResetTime": true,
+	"nextResetTime": true,
+}
+
+// Options controls which columns a RowWriter writes and how they are formatted
+type Options struct {
+	// Columns lists the JSON field names to write, in order. All fields are written when empty.
+	Columns []string
+
+	// HumanTime converts the epoch-ms fields such as created, updated, firstSeen and lastSeen to formatted times
+	HumanTime bool
+
+	// TimeFormat is the layout used when HumanTime is set, defaults to time.RFC3339
+	TimeFormat string
+
+	// Location is the time zone used when HumanTime is set, defaults to UTC
+	Location *time.Location
+}
+
+// RowWriter streams ReportDetails, Indicator and IndicatorMetadata rows as CSV, JSON Lines or a table.
+// Rows are written as they arrive so memory use does not grow with the number of rows.
+// All rows written to a RowWriter must be of the same type.
+type RowWriter struct {
+	format Format
+	opts   Options
+
+	buf     *bufio.Writer
+	records recordWriter // writes CSV and table rows
+
+	rowType reflect.Type
+	columns []column
+	header  bool
+	count   int
+}
+
+// column is a single exported field, identified by its JSON name
+type column struct {
+	name  string
+	index []int
+}
+
+// NewRowWriter returns a RowWriter that writes to w
+func NewRowWriter(w io.Writer, format Format, opts Options) *RowWriter {
+	if opts.TimeFormat == "" {
+		opts.TimeFormat = time.RFC3339
+	}
+	if opts.Location == nil {
+		opts.Location = time.UTC
+	}
+
+	rw := &RowWriter{format: format, opts: opts, buf: bufio.NewWriter(w)}
+	switch format {
+	case CSV:
+		rw.records = &csvRecords{csv.NewWriter(rw.buf)}
+	case Table:
+		rw.records = &tableRecords{tabwriter.NewWriter(rw.buf, 0, 4, 2, ' ', 0)}
+	}
+
+	return rw
+}
+
+// Columns returns the JSON field names available for the given row type, in the order they are written by default
+func Columns(row interface{}) []string {
+	var names []string
+	for _, c := range fieldColumns(indirectType(reflect.TypeOf(row)), nil) {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+// Count returns the number of rows written so far
+func (rw *RowWriter) Count() int {
+	return rw.count
+}
+
+// Write writes a single row. row must be a ReportDetails, Indicator, IndicatorMetadata or a pointer to one of them.
+func (rw *RowWriter) Write(row interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(row))
+	if v.Kind() != reflect.Struct {
+		return fmt.Errorf("export: cannot write row of type %T", row)
+	}
+
+	if rw.rowType == nil {
+		if err := rw.init(v.Type()); err != nil {
+			return err
+		}
+	} else if v.Type() != rw.rowType {
+		return fmt.Errorf("export: cannot write %s row to a %s export", v.Type(), rw.rowType)
+	}
+
+	if err := rw.writeHeader(); err != nil {
+		return err
+	}
+
+	var err error
+	if rw.format == JSONL {
+		err = rw.writeJSONL(v)
+	} else {
+		err = rw.writeRecord(v)
+	}
+	if err != nil {
+		return err
+	}
+
+	rw.count++
+	return nil
+}
+
+// Flush writes any buffered rows to the underlying writer. A CSV or table header is written even if
+// no rows were written, as long as the columns were selected in Options.
+func (rw *RowWriter) Flush() error {
+	if rw.records == nil {
+		return rw.buf.Flush()
+	}
+
+	if !rw.header && len(rw.opts.Columns) > 0 {
+		if err := rw.records.Write(rw.opts.Columns); err != nil {
+			return err
+		}
+		rw.header = true
+	}
+
+	if err := rw.records.Flush(); err != nil {
+		return err
+	}
+
+	return rw.buf.Flush()
+}
+
+func (rw *RowWriter) init(t reflect.Type) error {
+	all := fieldColumns(t, nil)
+	rw.rowType = t
+
+	if len(rw.opts.Columns) == 0 {
+		rw.columns = all
+		return nil
+	}
+
+	byName := map[string]column{}
+	for _, c := range all {
+		byName[c.name] = c
+	}
+
+	for _, name := range rw.opts.Columns {
+		c, ok := byName[name]
+		if !ok {
+			return fmt.Errorf("export: unknown column %q for %s rows", name, t)
+		}
+		rw.columns = append(rw.columns, c)
+	}
+
+	return nil
+}
+
+func (rw *RowWriter) writeHeader() error {
+	if rw.header || rw.records == nil {
+		return nil
+	}
+	rw.header = true
+
+	names := make([]string, len(rw.columns))
+	for i, c := range rw.columns {
+		names[i] = c.name
+	}
+
+	return rw.records.Write(names)
+}
+
+func (rw *RowWriter) writeRecord(v reflect.Value) error {
+	record := make([]string, len(rw.columns))
+	for i, c := range rw.columns {
+		f := v.FieldByIndex(c.index)
+		if t, ok := rw.timeValue(c.name, f); ok {
+			record[i] = t
+			continue
+		}
+		record[i] = cellString(f)
+	}
+
+	return rw.records.Write(record)
+}
+
+func (rw *RowWriter) writeJSONL(v reflect.Value) error {
+	var b bytes.Buffer
+	b.WriteByte('{')
+
+	for i, c := range rw.columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(c.name)
+		b.Write(key)
+		b.WriteByte(':')
+
+		var field interface{} = v.FieldByIndex(c.index).Interface()
+		if t, ok := rw.timeValue(c.name, v.FieldByIndex(c.index)); ok {
+			field = t
+		}
+
+		data, err := json.Marshal(field)
+		if err != nil {
+			return err
+		}
+		b.Write(data)
+	}
+
+	b.WriteString("}\n")
+	_, err := rw.buf.Write(b.Bytes())
+	return err
+}
+
+// timeValue formats an epoch-ms field when HumanTime is set
+func (rw *RowWriter) timeValue(name string, f reflect.Value) (string, bool) {
+	if !rw.opts.HumanTime || !epochMsFields[name] || f.Kind() != reflect.Int64 {
+		return "", false
+	}
+
+	if f.Int() == 0 {
+		return "", true
+	}
+
+	t, _ := trustar.MsEpochToTime(f.Int())
+	return t.In(rw.opts.Location).Format(rw.opts.TimeFormat), true
+}
+
+// recordWriter writes rows of cells
+type recordWriter interface {
+	Write(record []string) error
+	Flush() error
+}
+
+type csvRecords struct {
+	w *csv.Writer
+}
+
+func (c *csvRecords) Write(record []string) error {
+	return c.w.Write(record)
+}
+
+func (c *csvRecords) Flush() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+type tableRecords struct {
+	w *tabwriter.Writer
+}
+
+func (t *tableRecords) Write(record []string) error {
+	cells := make([]string, len(record))
+	for i, c := range record {
+		cells[i] = strings.NewReplacer("\t", " ", "\n", " ", "\r", "").Replace(c)
+	}
+	_, err := io.WriteString(t.w, strings.Join(cells, "\t")+"\n")
+	return err
+}
+
+func (t *tableRecords) Flush() error {
+	return t.w.Flush()
+}
+
+// fieldColumns lists the JSON tagged fields of a struct, flattening embedded structs
+func fieldColumns(t reflect.Type, index []int) []column {
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var cols []column
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		idx := append(append([]int(nil), index...), i)
+
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			cols = append(cols, fieldColumns(f.Type, idx)...)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+
+		name := strings.Split(f.Tag.Get("json"), ",")[0]
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		cols = append(cols, column{name: name, index: idx})
+	}
+
+	return cols
+}
+
+// cellString flattens a field into a single CSV cell. Lists are joined with ";" and
+// structs such as tags and sectors are represented by their name.
+func cellString(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.String:
+		return v.String()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10)
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool())
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return ""
+		}
+		return cellString(v.Elem())
+	case reflect.Slice, reflect.Array:
+		parts := make([]string, v.Len())
+		for i := range parts {
+			parts[i] = cellString(v.Index(i))
+		}
+		return strings.Join(parts, ";")
+	case reflect.Struct:
+		for _, c := range fieldColumns(v.Type(), nil) {
+			if c.name == "name" {
+				return cellString(v.FieldByIndex(c.index))
+			}
+		}
+	}
+
+	data, _ := json.Marshal(v.Interface())
+	return string(data)
+}
+
+func indirectType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// Reports writes every report matching the GetReports query and flushes the writer
+func Reports(c *trustar.Client, v url.Values, rw *RowWriter) error {
+	return flushAfter(rw, c.ForEachReport(v, func(r trustar.ReportDetails) error {
+		return rw.Write(r)
+	}))
+}
+
+// SearchReports writes every report matching the SearchReports query and flushes the writer
+func SearchReports(c *trustar.Client, v url.Values, rw *RowWriter) error {
+	return flushAfter(rw, c.ForEachSearchReport(v, func(r trustar.ReportDetails) error {
+		return rw.Write(r)
+	}))
+}
+
+// Indicators writes every indicator matching the SearchIndicators query and flushes the writer
+func Indicators(c *trustar.Client, v url.Values, rw *RowWriter) error {
+	return flushAfter(rw, c.ForEachIndicator(v, func(i trustar.Indicator) error {
+		return rw.Write(i)
+	}))
+}
+
+// ReportIndicators writes every indicator contained in the specified report and flushes the writer
+func ReportIndicators(c *trustar.Client, id string, v url.Values, rw *RowWriter) error {
+	return flushAfter(rw, c.ForEachReportIndicator(id, v, func(i trustar.Indicator) error {
+		return rw.Write(i)
+	}))
+}
+
+// IndicatorMetadata writes the metadata of the given indicators and flushes the writer
+func IndicatorMetadata(c *trustar.Client, indicators []trustar.Indicator, rw *RowWriter) error {
+	imr, err := c.GetIndicatorMetadata(indicators)
+	if err != nil {
+		return err
+	}
+
+	for _, m := range imr {
+		if err := rw.Write(m); err != nil {
+			return err
+		}
+	}
+
+	return rw.Flush()
+}
+
+// flushAfter flushes the writer, keeping the first error encountered
+func flushAfter(rw *RowWriter, err error) error {
+	if ferr := rw.Flush(); err == nil {
+		err = ferr
+	}
+	return err
+}
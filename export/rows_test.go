@@ -0,0 +1,189 @@
+package export
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+var rowReports = []trustar.ReportDetails{
+	{
+		ID:         "r1",
+		Title:      "Phishing, \"urgent\"",
+		EnclaveIds: []string{"e1", "e2"},
+		Sector:     trustar.Sector{ID: 4, Name: "Finance"},
+		Created:    1500000000000,
+		Updated:    1500000060000,
+	},
+	{ID: "r2", Title: "Multi\nline", Created: 0, Updated: 1500000000000},
+}
+
+func writeRows(t *testing.T, format Format, opts Options, rows ...interface{}) string {
+	t.Helper()
+
+	var b bytes.Buffer
+	rw := NewRowWriter(&b, format, opts)
+	for _, r := range rows {
+		if err := rw.Write(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := rw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if rw.Count() != len(rows) {
+		t.Errorf("got count %d, want %d", rw.Count(), len(rows))
+	}
+	return b.String()
+}
+
+func TestRowWriter(t *testing.T) {
+	cols := []string{"id", "title", "enclaveIds", "sector", "created", "updated"}
+
+	tests := []struct {
+		name   string
+		format Format
+		opts   Options
+		want   string
+	}{
+		{
+			name:   "csv",
+			format: CSV,
+			opts:   Options{Columns: cols},
+			want: "id,title,enclaveIds,sector,created,updated\n" +
+				"r1,\"Phishing, \"\"urgent\"\"\",e1;e2,Finance,1500000000000,1500000060000\n" +
+				"r2,\"Multi\nline\",,,0,1500000000000\n",
+		},
+		{
+			name:   "csv human time",
+			format: CSV,
+			opts:   Options{Columns: []string{"id", "created", "updated"}, HumanTime: true},
+			want: "id,created,updated\n" +
+				"r1,2017-07-14T02:40:00Z,2017-07-14T02:41:00Z\n" +
+				"r2,,2017-07-14T02:40:00Z\n",
+		},
+		{
+			name:   "csv time format and location",
+			format: CSV,
+			opts:   Options{Columns: []string{"id", "updated"}, HumanTime: true, TimeFormat: "2006-01-02 15:04", Location: time.FixedZone("X", 3600)},
+			want:   "id,updated\nr1,2017-07-14 03:41\nr2,2017-07-14 03:40\n",
+		},
+		{
+			name:   "jsonl",
+			format: JSONL,
+			opts:   Options{Columns: []string{"id", "enclaveIds", "updated"}},
+			want: `{"id":"r1","enclaveIds":["e1","e2"],"updated":1500000060000}` + "\n" +
+				`{"id":"r2","enclaveIds":null,"updated":1500000000000}` + "\n",
+		},
+		{
+			name:   "jsonl human time",
+			format: JSONL,
+			opts:   Options{Columns: []string{"id", "created"}, HumanTime: true},
+			want:   `{"id":"r1","created":"2017-07-14T02:40:00Z"}` + "\n" + `{"id":"r2","created":""}` + "\n",
+		},
+		{
+			name:   "table",
+			format: Table,
+			opts:   Options{Columns: []string{"id", "title", "sector"}},
+			want: "id  title               sector\n" +
+				"r1  Phishing, \"urgent\"  Finance\n" +
+				"r2  Multi line          \n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := writeRows(t, tt.format, tt.opts, rowReports[0], &rowReports[1])
+			if got != tt.want {
+				t.Errorf("got\n%q\nwant\n%q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowWriterAllColumns(t *testing.T) {
+	got := writeRows(t, CSV, Options{}, trustar.Indicator{Value: "1.2.3.4", IndicatorType: "IP", Weight: 1})
+
+	header := strings.SplitN(got, "\n", 2)[0]
+	if want := strings.Join(Columns(trustar.Indicator{}), ","); header != want {
+		t.Errorf("got header %q, want %q", header, want)
+	}
+	if !strings.Contains(got, "IP,,1.2.3.4,1,,") {
+		t.Errorf("got %q, want the indicator in column order", got)
+	}
+}
+
+func TestColumns(t *testing.T) {
+	got := Columns(&trustar.IndicatorMetadata{})
+	want := []string{"enclaveIds", "firstSeen", "guid", "indicatorType", "lastSeen", "noteCount", "notes", "priorityLevel", "sightings", "tags", "value"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// embedded structs are flattened
+	got = Columns(trustar.TrendingIndicators{{}}[0])
+	want = append([]string{"correlationCount"}, Columns(trustar.Indicator{})...)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRowWriterHeaderOnly(t *testing.T) {
+	if got := writeRows(t, CSV, Options{Columns: []string{"id", "title"}}); got != "id,title\n" {
+		t.Errorf("got %q, want only the header", got)
+	}
+	if got := writeRows(t, CSV, Options{}); got != "" {
+		t.Errorf("got %q, want no output without rows or columns", got)
+	}
+}
+
+func TestRowWriterErrors(t *testing.T) {
+	var b bytes.Buffer
+
+	rw := NewRowWriter(&b, CSV, Options{Columns: []string{"id", "nope"}})
+	if err := rw.Write(rowReports[0]); err == nil || !strings.Contains(err.Error(), `unknown column "nope"`) {
+		t.Errorf("got error %v, want an unknown column error", err)
+	}
+
+	rw = NewRowWriter(&b, JSONL, Options{})
+	if err := rw.Write(rowReports[0]); err != nil {
+		t.Fatal(err)
+	}
+	if err := rw.Write(trustar.Indicator{}); err == nil {
+		t.Error("want an error writing an indicator to a report export")
+	}
+	if err := rw.Write("text"); err == nil {
+		t.Error("want an error writing a non-struct row")
+	}
+}
+
+func TestReports(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"hasNext":false,"items":[{"id":"r1","title":"One","updated":2},{"id":"r2","title":"Two","updated":1}]}`))
+	}))
+	defer srv.Close()
+
+	c, err := trustar.NewClient("id", "secret", srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetAccessToken("token")
+
+	var b bytes.Buffer
+	rw := NewRowWriter(&b, CSV, Options{Columns: []string{"id", "title"}})
+	if err := Reports(c, url.Values{}, rw); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "id,title\nr1,One\nr2,Two\n"; b.String() != want {
+		t.Errorf("got %q, want %q", b.String(), want)
+	}
+}
@@ -0,0 +1,226 @@
+package export
+
+import (
+	"fmt"
+	"hash/fnv"
+	"io"
+	"net"
+	"strings"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// RuleDialect selects the IDS rule syntax produced by a RuleWriter
+type RuleDialect int
+
+const (
+	// Suricata produces rules using Suricata sticky buffers (dns.query, http.host)
+	Suricata RuleDialect = iota
+	// Snort produces rules using Snort 2.9 compatible content modifiers
+	Snort
+)
+
+const (
+	// DefaultSIDBase is the first SID handed out by a RuleWriter, the start of the range reserved for local rules
+	DefaultSIDBase uint32 = 1000000
+	// DefaultSIDRange is the size of the SID range used by a RuleWriter
+	DefaultSIDRange uint32 = 1000000
+)
+
+// RuleWriter writes indicators as Suricata or Snort rules.
+//
+// SIDs are derived from the indicator GUID so regenerating a rule file after an indicator is updated
+// replaces the existing rule instead of adding a duplicate. When two indicators hash to the same SID
+// the one written later is moved to the next free SID in the range, so the SIDs of colliding indicators
+// depend on the order they are written in and can change when other indicators are added or removed.
+// Domains produce a DNS query rule and an HTTP host rule matching the domain and its subdomains, IP
+// addresses and CIDR blocks produce an IP reputation rule, and all other indicator types and malformed
+// addresses are skipped.
+type RuleWriter struct {
+	w    io.Writer
+	sids map[uint32]string // SIDs handed out so far, by the key they were issued for
+	keys map[string]uint32
+
+	Dialect            RuleDialect
+	SIDBase            uint32 // first SID of the range rules are assigned from
+	SIDRange           uint32 // number of SIDs available from SIDBase
+	Rev                int    // rule revision, defaults to 1
+	MsgPrefix          string // prefix of the msg option, defaults to "TruSTAR"
+	IncludeWhitelisted bool   // write indicators that have been whitelisted by the user's company
+}
+
+// NewRuleWriter returns a RuleWriter for the given dialect that writes to w
+func NewRuleWriter(w io.Writer, dialect RuleDialect) *RuleWriter {
+	return &RuleWriter{
+		w:         w,
+		Dialect:   dialect,
+		SIDBase:   DefaultSIDBase,
+		SIDRange:  DefaultSIDRange,
+		Rev:       1,
+		MsgPrefix: "TruSTAR",
+	}
+}
+
+// Write writes rules for the indicators found in the given report. It returns the number of rules written.
+func (r *RuleWriter) Write(reportID string, indicators []trustar.Indicator) (int, error) {
+	n := 0
+	for _, i := range indicators {
+		if i.Whitelisted == "true" && !r.IncludeWhitelisted {
+			continue
+		}
+
+		for _, rule := range r.Rules(reportID, i) {
+			if _, err := io.WriteString(r.w, rule+"\n"); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// Rules returns the rules generated for a single indicator
+func (r *RuleWriter) Rules(reportID string, i trustar.Indicator) []string {
+	value := strings.TrimSpace(i.Value)
+	if value == "" {
+		return nil
+	}
+
+	msg := func(kind string) string {
+		m := fmt.Sprintf("%s %s %s", r.MsgPrefix, kind, value)
+		if reportID != "" {
+			m = fmt.Sprintf("%s report %s", m, reportID)
+		}
+		return ruleMsg(m)
+	}
+
+	switch strings.ToUpper(i.IndicatorType) {
+	case "DOMAIN":
+		domain := strings.ToLower(strings.TrimSuffix(value, "."))
+		if r.Dialect == Snort {
+			return []string{
+				fmt.Sprintf(`alert udp $HOME_NET any -> any 53 (msg:"%s"; content:"%s"; nocase; sid:%d; rev:%d;)`,
+					msg("DNS query for"), dnsWireContent(domain), r.sid(i, "dns"), r.rev()),
+				// the pcre anchors the domain to the Host header, after its start or a dot and before an optional port,
+				// so it matches example.com and its subdomains but not badexample.com or example.com.evil.net
+				fmt.Sprintf(`alert tcp $HOME_NET any -> $EXTERNAL_NET $HTTP_PORTS (msg:"%s"; flow:to_server,established; content:"%s"; http_header; nocase; pcre:"/^Host\x3a[ \t]*(?:[^\r\n]*\.)?%s(?:\x3a\d+)?\r?$/Hmi"; sid:%d; rev:%d;)`,
+					msg("HTTP host"), ruleContent(domain), pcreLiteral(domain), r.sid(i, "http"), r.rev()),
+			}
+		}
+		// dotprefix puts a dot in front of the buffer so ".example.com" matches example.com and its
+		// subdomains but not badexample.com
+		return []string{
+			fmt.Sprintf(`alert dns $HOME_NET any -> any any (msg:"%s"; dns.query; dotprefix; content:".%s"; nocase; endswith; sid:%d; rev:%d;)`,
+				msg("DNS query for"), ruleContent(domain), r.sid(i, "dns"), r.rev()),
+			fmt.Sprintf(`alert http $HOME_NET any -> any any (msg:"%s"; flow:to_server,established; http.host; dotprefix; content:".%s"; nocase; endswith; sid:%d; rev:%d;)`,
+				msg("HTTP host"), ruleContent(domain), r.sid(i, "http"), r.rev()),
+		}
+	case "IP", "CIDR_BLOCK":
+		if net.ParseIP(value) == nil {
+			if _, _, err := net.ParseCIDR(value); err != nil {
+				return nil
+			}
+		}
+		return []string{
+			fmt.Sprintf(`alert ip $HOME_NET any <> [%s] any (msg:"%s"; classtype:bad-unknown; sid:%d; rev:%d;)`,
+				value, msg("IP reputation"), r.sid(i, "ip"), r.rev()),
+		}
+	}
+
+	return nil
+}
+
+// SID returns the SID assigned to the given kind ("dns", "http" or "ip") of rule for an indicator
+func (r *RuleWriter) SID(i trustar.Indicator, kind string) uint32 {
+	return r.sid(i, kind)
+}
+
+func (r *RuleWriter) sid(i trustar.Indicator, kind string) uint32 {
+	key := i.GUID
+	if key == "" {
+		// fall back to the indicator itself so rules are still stable for indicators without a GUID
+		key = strings.ToUpper(i.IndicatorType) + ":" + strings.ToLower(i.Value)
+	}
+	key += ":" + kind
+
+	if sid, ok := r.keys[key]; ok {
+		return sid
+	}
+	if r.keys == nil {
+		r.keys = map[string]uint32{}
+		r.sids = map[uint32]string{}
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	base, span := r.SIDBase, r.SIDRange
+	if base == 0 {
+		base = DefaultSIDBase
+	}
+	if span == 0 {
+		span = DefaultSIDRange
+	}
+
+	// Suricata refuses to load a rule file with duplicate SIDs, so probe for the next free one
+	off := h.Sum32() % span
+	for n := uint32(0); n < span; n++ {
+		sid := base + (off+n)%span
+		if _, taken := r.sids[sid]; !taken {
+			r.sids[sid] = key
+			r.keys[key] = sid
+			return sid
+		}
+	}
+
+	// the range is exhausted, reuse the hashed SID rather than loop forever
+	return base + off
+}
+
+func (r *RuleWriter) rev() int {
+	if r.Rev < 1 {
+		return 1
+	}
+	return r.Rev
+}
+
+// ruleMsg escapes the characters that are reserved inside a rule msg option
+func ruleMsg(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `;`, `\;`).Replace(s)
+}
+
+// ruleContent hex encodes the characters that are reserved inside a rule content option
+func ruleContent(s string) string {
+	return strings.NewReplacer(`"`, "|22|", `;`, "|3B|", `\`, "|5C|", `|`, "|7C|", ":", "|3A|").Replace(s)
+}
+
+// pcreLiteral escapes a value for a pcre option, hex encoding everything but letters, digits, '-' and '_'
+func pcreLiteral(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9', c == '-', c == '_':
+			b.WriteByte(c)
+		case c == '.':
+			b.WriteString(`\.`)
+		default:
+			fmt.Fprintf(&b, `\x%02x`, c)
+		}
+	}
+	return b.String()
+}
+
+// dnsWireContent encodes a domain name as length prefixed labels, the way it appears in a DNS query
+func dnsWireContent(domain string) string {
+	var b strings.Builder
+	for _, label := range strings.Split(domain, ".") {
+		if label == "" {
+			continue
+		}
+		fmt.Fprintf(&b, "|%02X|%s", len(label), ruleContent(label))
+	}
+	b.WriteString("|00|")
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+func TestRuleWriterGolden(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		dialect RuleDialect
+	}{
+		{"suricata.golden", Suricata},
+		{"snort.golden", Snort},
+	} {
+		var b bytes.Buffer
+		if _, err := NewRuleWriter(&b, tt.dialect).Write("report-1", feed); err != nil {
+			t.Fatal(err)
+		}
+		golden(t, tt.name, b.Bytes())
+	}
+}
+
+func TestRuleWriterDomainBoundary(t *testing.T) {
+	rules := NewRuleWriter(nil, Suricata).Rules("", trustar.Indicator{IndicatorType: "DOMAIN", Value: "example.com"})
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+	for _, rule := range rules {
+		// the dot prefixed buffer must match example.com and sub.example.com but not badexample.com
+		if !strings.Contains(rule, `dotprefix; content:".example.com"; nocase; endswith;`) {
+			t.Errorf("rule is not anchored at a label boundary: %s", rule)
+		}
+	}
+}
+
+func TestRuleWriterSnortHostBoundary(t *testing.T) {
+	rules := NewRuleWriter(nil, Snort).Rules("", trustar.Indicator{IndicatorType: "DOMAIN", Value: "example.com"})
+	if len(rules) != 2 {
+		t.Fatalf("got %d rules, want 2", len(rules))
+	}
+
+	m := regexp.MustCompile(`pcre:"/(.*)/Hmi";`).FindStringSubmatch(rules[1])
+	if m == nil {
+		t.Fatalf("no pcre in %s", rules[1])
+	}
+	re := regexp.MustCompile("(?mi)" + m[1])
+
+	tests := []struct {
+		host  string
+		match bool
+	}{
+		{"example.com", true},
+		{"EXAMPLE.com:8080", true},
+		{"sub.example.com", true},
+		{"a.b.example.com", true},
+		{"badexample.com", false},
+		{"example.com.evil.net", false},
+		{"example.community", false},
+		{"example.com:8080x", false},
+	}
+	for _, tt := range tests {
+		headers := "User-Agent: curl example.com\r\nHost: " + tt.host + "\r\nAccept: */*\r\n"
+		if got := re.MatchString(headers); got != tt.match {
+			t.Errorf("Host %s: got match %v, want %v", tt.host, got, tt.match)
+		}
+	}
+}
+
+func TestRuleWriterSkipsMalformedAddresses(t *testing.T) {
+	rw := NewRuleWriter(nil, Suricata)
+	for _, v := range []string{"not-an-ip", "10.0.0.0/33", "1.2.3.4] any -> any any (sid:1;)"} {
+		if rules := rw.Rules("", trustar.Indicator{IndicatorType: "IP", Value: v}); len(rules) != 0 {
+			t.Errorf("Rules(%q) = %v, want none", v, rules)
+		}
+	}
+	for _, v := range []string{"10.1.2.3", "2001:db8::1", "10.0.0.0/8"} {
+		if rules := rw.Rules("", trustar.Indicator{IndicatorType: "IP", Value: v}); len(rules) != 1 {
+			t.Errorf("Rules(%q) returned %d rules, want 1", v, len(rules))
+		}
+	}
+}
+
+func TestRuleWriterSIDs(t *testing.T) {
+	rw := NewRuleWriter(nil, Suricata)
+	rw.SIDBase, rw.SIDRange = 5000, 64
+
+	// more indicators than a hash alone could spread over 64 SIDs without collisions
+	seen := map[uint32]string{}
+	for n := 0; n < 64; n++ {
+		i := trustar.Indicator{GUID: fmt.Sprint("guid-", n), IndicatorType: "IP", Value: "10.0.0.1"}
+		sid := rw.SID(i, "ip")
+		if sid < 5000 || sid >= 5064 {
+			t.Fatalf("SID %d is outside the range", sid)
+		}
+		if prev, dup := seen[sid]; dup {
+			t.Fatalf("SID %d issued to %s and %s", sid, prev, i.GUID)
+		}
+		seen[sid] = i.GUID
+	}
+
+	// the same indicator always gets the same SID, and a fresh writer derives it from the GUID again
+	i := trustar.Indicator{GUID: "stable", IndicatorType: "DOMAIN", Value: "example.com"}
+	a := NewRuleWriter(nil, Suricata).SID(i, "dns")
+	b := NewRuleWriter(nil, Suricata).SID(i, "dns")
+	if a != b {
+		t.Errorf("SID changed between writers: %d != %d", a, b)
+	}
+	if NewRuleWriter(nil, Suricata).SID(i, "http") == a {
+		t.Error("dns and http rules share a SID")
+	}
+}
+
+func TestRuleWriterEscaping(t *testing.T) {
+	rules := NewRuleWriter(nil, Suricata).Rules(`r"1;`, trustar.Indicator{IndicatorType: "DOMAIN", Value: `a"b;c.com`})
+	if len(rules) == 0 {
+		t.Fatal("no rules")
+	}
+	if !strings.Contains(rules[0], `report r\"1\;`) || !strings.Contains(rules[0], `content:".a|22|b|3B|c.com"`) {
+		t.Errorf("reserved characters not escaped: %s", rules[0])
+	}
+}
@@ -0,0 +1,116 @@
+// Package export converts TruSTAR reports and indicators into the formats
+// consumed by other security tools.
+package export
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// zeekIntelTypes maps TruSTAR indicator types to Zeek Intel framework types.
+// Reference: https://docs.zeek.org/en/current/scripts/base/frameworks/intel/main.zeek.html
+var zeekIntelTypes = map[string]string{
+	"IP":            "Intel::ADDR",
+	"CIDR_BLOCK":    "Intel::SUBNET",
+	"DOMAIN":        "Intel::DOMAIN",
+	"URL":           "Intel::URL",
+	"EMAIL_ADDRESS": "Intel::EMAIL",
+	"MD5":           "Intel::FILE_HASH",
+	"SHA1":          "Intel::FILE_HASH",
+	"SHA256":        "Intel::FILE_HASH",
+	"SOFTWARE":      "Intel::FILE_NAME",
+}
+
+// ZeekIntelType returns the Zeek Intel framework type for a TruSTAR indicator type
+func ZeekIntelType(indicatorType string) (string, bool) {
+	t, ok := zeekIntelTypes[strings.ToUpper(indicatorType)]
+	return t, ok
+}
+
+// ZeekIntelWriter writes indicators as a Zeek Intel framework input file.
+// The header line is written before the first indicator.
+type ZeekIntelWriter struct {
+	w           io.Writer
+	wroteHeader bool
+
+	// IncludeWhitelisted writes indicators that have been whitelisted by the user's company, which are skipped by default
+	IncludeWhitelisted bool
+}
+
+// NewZeekIntelWriter returns a ZeekIntelWriter that writes to w
+func NewZeekIntelWriter(w io.Writer) *ZeekIntelWriter {
+	return &ZeekIntelWriter{w: w}
+}
+
+// Write writes the indicators found in the given report, setting meta.source to the report ID.
+// Indicators without a Zeek equivalent and malformed addresses are skipped. It returns the number of indicators written.
+func (z *ZeekIntelWriter) Write(reportID string, indicators []trustar.Indicator) (int, error) {
+	if !z.wroteHeader {
+		if _, err := io.WriteString(z.w, "#fields\tindicator\tindicator_type\tmeta.source\tmeta.desc\n"); err != nil {
+			return 0, err
+		}
+		z.wroteHeader = true
+	}
+
+	n := 0
+	for _, i := range indicators {
+		if i.Whitelisted == "true" && !z.IncludeWhitelisted {
+			continue
+		}
+
+		intelType, ok := ZeekIntelType(i.IndicatorType)
+		if !ok {
+			continue
+		}
+
+		value := i.Value
+		switch intelType {
+		case "Intel::URL":
+			// Zeek matches URLs without the scheme
+			value = strings.TrimPrefix(strings.TrimPrefix(value, "http://"), "https://")
+		case "Intel::ADDR":
+			// Zeek refuses to load an intel file with a malformed address, or a subnet typed as one
+			value = strings.TrimSpace(value)
+			if net.ParseIP(value) == nil {
+				continue
+			}
+		case "Intel::SUBNET":
+			value = strings.TrimSpace(value)
+			if _, _, err := net.ParseCIDR(value); err != nil {
+				continue
+			}
+		}
+
+		desc := fmt.Sprintf("TruSTAR %s indicator", strings.ToUpper(i.IndicatorType))
+		if i.PriorityLevel != "" && i.PriorityLevel != "NOT_FOUND" {
+			desc = fmt.Sprintf("%s (priority %s)", desc, i.PriorityLevel)
+		}
+
+		line := strings.Join([]string{zeekField(value), intelType, zeekField(reportID), zeekField(desc)}, "\t")
+		if _, err := io.WriteString(z.w, line+"\n"); err != nil {
+			return n, err
+		}
+		n++
+	}
+
+	return n, nil
+}
+
+// WriteZeekIntel writes a complete Zeek Intel file for the indicators of a single report
+func WriteZeekIntel(w io.Writer, reportID string, indicators []trustar.Indicator) error {
+	_, err := NewZeekIntelWriter(w).Write(reportID, indicators)
+	return err
+}
+
+// zeekField sanitizes a value for the tab separated intel format, using "-" for empty fields
+func zeekField(s string) string {
+	s = strings.TrimSpace(strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(s))
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,109 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+var feed = []trustar.Indicator{
+	{GUID: "g-ip", IndicatorType: "IP", Value: "198.51.100.7", PriorityLevel: "HIGH"},
+	{GUID: "g-cidr", IndicatorType: "CIDR_BLOCK", Value: "203.0.113.0/24"},
+	{GUID: "g-domain", IndicatorType: "DOMAIN", Value: "Evil.Example.com."},
+	{GUID: "g-url", IndicatorType: "URL", Value: "https://evil.example.com/login\tnow"},
+	{GUID: "g-email", IndicatorType: "EMAIL_ADDRESS", Value: "phish@example.net", PriorityLevel: "NOT_FOUND"},
+	{GUID: "g-md5", IndicatorType: "MD5", Value: "d41d8cd98f00b204e9800998ecf8427e"},
+	{GUID: "g-cve", IndicatorType: "CVE", Value: "CVE-2019-0708"},
+	{GUID: "g-white", IndicatorType: "IP", Value: "192.0.2.1", Whitelisted: "true"},
+	{GUID: "g-bad", IndicatorType: "IP", Value: "not-an-ip"},
+}
+
+func TestZeekIntelWriter(t *testing.T) {
+	var b bytes.Buffer
+	z := NewZeekIntelWriter(&b)
+
+	n, err := z.Write("report-1", feed[:4])
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := z.Write("", feed[4:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the CVE has no Zeek type, the whitelisted IP is skipped and so is the malformed one
+	if n+m != 6 {
+		t.Errorf("wrote %d indicators, want 6", n+m)
+	}
+	golden(t, "intel.golden", b.Bytes())
+}
+
+func TestZeekIntelWriterIncludeWhitelisted(t *testing.T) {
+	var b bytes.Buffer
+	z := NewZeekIntelWriter(&b)
+	z.IncludeWhitelisted = true
+
+	n, err := z.Write("r", []trustar.Indicator{{IndicatorType: "IP", Value: "192.0.2.1", Whitelisted: "true"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 1 {
+		t.Errorf("wrote %d indicators, want 1", n)
+	}
+}
+
+func TestZeekIntelWriterAddressTypes(t *testing.T) {
+	tests := []struct {
+		indicatorType string
+		value         string
+		want          string
+	}{
+		{"IP", "198.51.100.7", "198.51.100.7\tIntel::ADDR"},
+		{"IP", "2001:db8::1", "2001:db8::1\tIntel::ADDR"},
+		{"IP", "198.51.100.0/24", ""},
+		{"IP", "not-an-ip", ""},
+		{"CIDR_BLOCK", "203.0.113.0/24", "203.0.113.0/24\tIntel::SUBNET"},
+		{"CIDR_BLOCK", "203.0.113.7", ""},
+		{"CIDR_BLOCK", "10.0.0.0/33", ""},
+	}
+
+	for _, tt := range tests {
+		var b bytes.Buffer
+		n, err := NewZeekIntelWriter(&b).Write("r", []trustar.Indicator{{IndicatorType: tt.indicatorType, Value: tt.value}})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+		if tt.want == "" {
+			if n != 0 || len(lines) != 1 {
+				t.Errorf("%s %q: wrote %q, want it skipped", tt.indicatorType, tt.value, b.String())
+			}
+			continue
+		}
+		if n != 1 || len(lines) != 2 || !strings.HasPrefix(lines[1], tt.want+"\t") {
+			t.Errorf("%s %q: wrote %q, want a line starting %q", tt.indicatorType, tt.value, b.String(), tt.want)
+		}
+	}
+}
+
+func TestZeekIntelType(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+		ok   bool
+	}{
+		{"ip", "Intel::ADDR", true},
+		{"CIDR_BLOCK", "Intel::SUBNET", true},
+		{"SHA256", "Intel::FILE_HASH", true},
+		{"CVE", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := ZeekIntelType(tt.in)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("ZeekIntelType(%q) = %q, %v, want %q, %v", tt.in, got, ok, tt.want, tt.ok)
+		}
+	}
+}
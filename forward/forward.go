@@ -0,0 +1,456 @@
+// Package forward posts watched report events to webhooks such as Slack, Microsoft Teams or a generic JSON receiver.
+package forward
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+	"github.com/jakewarren/trustar-golang/watch"
+)
+
+// Format selects the payload layout sent to an endpoint
+type Format int
+
+const (
+	// Generic posts the Payload as JSON, or the rendered Template when one is set
+	Generic Format = iota
+	// Slack posts a Slack incoming webhook message
+	Slack
+	// Teams posts a Microsoft Teams connector card
+	Teams
+)
+
+const (
+	// SignatureHeader carries the hex encoded HMAC-SHA256 of the timestamp, a dot and the body, prefixed with "sha256="
+	SignatureHeader = "X-Trustar-Signature"
+	// TimestampHeader carries the Unix time the payload was signed at
+	TimestampHeader = "X-Trustar-Timestamp"
+
+	// DefaultQueueSize is the number of events Handle queues for Run before dead-lettering them
+	DefaultQueueSize = 256
+)
+
+// ErrQueueFull is passed to ErrorHandler when Handle drops an event because the queue is full
+var ErrQueueFull = errors.New("forward queue is full")
+
+// Endpoint is a webhook events are forwarded to
+type Endpoint struct {
+	Name    string
+	URL     string
+	Format  Format
+	Secret  string            // signs payloads with HMAC-SHA256 when set
+	Headers map[string]string // extra request headers
+
+	// Template overrides the message text of Slack and Teams payloads, or the whole body of Generic payloads.
+	// It is a text/template executed with a Payload.
+	Template string
+}
+
+// Payload is the data templates are executed with, and the body of Generic payloads without a template
+type Payload struct {
+	Event      string                `json:"event"`
+	EnclaveID  string                `json:"enclaveId"`
+	ReportURL  string                `json:"reportUrl"`
+	Report     trustar.ReportDetails `json:"report"`
+	Indicators []trustar.Indicator   `json:"indicators"`
+}
+
+// Forwarder posts events to every endpoint, retrying failed deliveries with exponential backoff
+// and recording deliveries that never succeed in a dead-letter file.
+//
+// Handle only queues events, so a slow or failing endpoint never blocks the watch.Watcher calling
+// it; Run delivers the queued events.
+type Forwarder struct {
+	Client     *trustar.Client // used to fetch the indicators of each report, nil to send reports only
+	Endpoints  []Endpoint
+	HTTPClient *http.Client
+
+	MaxAttempts    int           // delivery attempts per endpoint, defaults to 5
+	InitialBackoff time.Duration // delay before the first retry, doubled after each attempt, defaults to one second
+	MaxBackoff     time.Duration // longest delay between retries, defaults to one minute
+	MaxIndicators  int           // indicators included per report, defaults to 50, negative for no limit
+	StationURL     string        // base of report links, defaults to trustar.StationReportURL
+	QueueSize      int           // events Handle queues for Run, defaults to DefaultQueueSize
+
+	// DeadLetterPath is the JSON Lines file failed deliveries are appended to
+	DeadLetterPath string
+
+	// ErrorHandler receives delivery errors from Run and ErrQueueFull from Handle
+	ErrorHandler func(error)
+
+	mu        sync.Mutex
+	templates map[string]*template.Template
+	queue     chan watch.Event
+}
+
+// DeadLetter is a delivery that failed every attempt, as written to the dead-letter file
+type DeadLetter struct {
+	Time     time.Time       `json:"time"`
+	Endpoint string          `json:"endpoint"`
+	URL      string          `json:"url"`
+	Error    string          `json:"error"`
+	Body     json.RawMessage `json:"body"`
+}
+
+// New returns a Forwarder posting to the given endpoints
+func New(c *trustar.Client, endpoints ...Endpoint) *Forwarder {
+	return &Forwarder{Client: c, Endpoints: endpoints}
+}
+
+// Handle queues an event for Run. If the queue is full the event is dead-lettered and ErrQueueFull
+// is passed to ErrorHandler. It can be used as a watch.Watcher Handler.
+func (f *Forwarder) Handle(e watch.Event) {
+	select {
+	case f.events() <- e:
+		return
+	default:
+	}
+
+	err := ErrQueueFull
+	if ferr := f.Forward(canceled, e); ferr != nil {
+		err = fmt.Errorf("%v: %v", ErrQueueFull, ferr)
+	}
+	if f.ErrorHandler != nil {
+		f.ErrorHandler(err)
+	}
+}
+
+// Run forwards the events queued by Handle until ctx is cancelled, passing errors to ErrorHandler.
+// Events still queued when ctx is cancelled are dead-lettered.
+func (f *Forwarder) Run(ctx context.Context) error {
+	queue := f.events()
+
+	for {
+		select {
+		case e := <-queue:
+			if err := f.Forward(ctx, e); err != nil && f.ErrorHandler != nil {
+				f.ErrorHandler(err)
+			}
+		case <-ctx.Done():
+			for {
+				select {
+				case e := <-queue:
+					if err := f.Forward(ctx, e); err != nil && f.ErrorHandler != nil {
+						f.ErrorHandler(err)
+					}
+				default:
+					return ctx.Err()
+				}
+			}
+		}
+	}
+}
+
+func (f *Forwarder) events() chan watch.Event {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.queue == nil {
+		size := f.QueueSize
+		if size <= 0 {
+			size = DefaultQueueSize
+		}
+		f.queue = make(chan watch.Event, size)
+	}
+	return f.queue
+}
+
+// canceled is a done context, used to dead-letter events without attempting delivery
+var canceled = func() context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	return ctx
+}()
+
+// Forward posts an event to every endpoint. Deliveries that fail every attempt, or are still pending
+// when ctx is done, are written to the dead-letter file and reported in the returned error.
+func (f *Forwarder) Forward(ctx context.Context, e watch.Event) error {
+	payload, err := f.payload(ctx, e)
+	if err != nil {
+		return err
+	}
+
+	var failed []string
+	for _, ep := range f.Endpoints {
+		body, err := f.render(ep, payload)
+		if err == nil {
+			err = f.deliver(ctx, ep, body)
+		}
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", endpointName(ep), err))
+			if dlErr := f.deadLetter(ep, body, err); dlErr != nil {
+				failed = append(failed, fmt.Sprintf("dead letter: %v", dlErr))
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("forwarding report %s failed: %s", e.Report.ID, strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// payload builds the template data for an event, fetching the report's indicators unless ctx is done
+func (f *Forwarder) payload(ctx context.Context, e watch.Event) (Payload, error) {
+	base := f.StationURL
+	if base == "" {
+		base = trustar.StationReportURL
+	}
+
+	p := Payload{
+		Event:     e.Type.String(),
+		EnclaveID: e.EnclaveID,
+		ReportURL: base + url.PathEscape(e.Report.ID),
+		Report:    e.Report,
+	}
+
+	if f.Client == nil || ctx.Err() != nil {
+		return p, nil
+	}
+
+	limit := f.MaxIndicators
+	if limit == 0 {
+		limit = 50
+	}
+
+	errLimit := errors.New("indicator limit reached")
+	err := f.Client.ForEachReportIndicator(e.Report.ID, url.Values{}, func(i trustar.Indicator) error {
+		if limit > 0 && len(p.Indicators) >= limit {
+			return errLimit
+		}
+		p.Indicators = append(p.Indicators, i)
+		return nil
+	})
+	if err != nil && err != errLimit {
+		return p, err
+	}
+
+	return p, nil
+}
+
+// render produces the request body for an endpoint
+func (f *Forwarder) render(ep Endpoint, p Payload) ([]byte, error) {
+	if ep.Format == Generic && ep.Template == "" {
+		return json.Marshal(p)
+	}
+
+	text := ep.Template
+	if text == "" {
+		text = defaultTemplates[ep.Format]
+	}
+
+	tmpl, err := f.template(text)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	if err = tmpl.Execute(&b, p); err != nil {
+		return nil, err
+	}
+
+	switch ep.Format {
+	case Slack:
+		return json.Marshal(map[string]string{"text": b.String()})
+	case Teams:
+		return json.Marshal(map[string]interface{}{
+			"@type":      "MessageCard",
+			"@context":   "https://schema.org/extensions",
+			"summary":    p.Report.Title,
+			"title":      fmt.Sprintf("TruSTAR %s: %s", p.Event, p.Report.Title),
+			"text":       b.String(),
+			"themeColor": "0076D7",
+			"potentialAction": []interface{}{map[string]interface{}{
+				"@type":   "OpenUri",
+				"name":    "Open in Station",
+				"targets": []interface{}{map[string]string{"os": "default", "uri": p.ReportURL}},
+			}},
+		})
+	}
+
+	return b.Bytes(), nil
+}
+
+// deliver posts the body, retrying network errors, 429 and 5xx responses until ctx is done
+func (f *Forwarder) deliver(ctx context.Context, ep Endpoint, body []byte) error {
+	attempts := f.MaxAttempts
+	if attempts <= 0 {
+		attempts = 5
+	}
+	backoff := f.InitialBackoff
+	if backoff <= 0 {
+		backoff = time.Second
+	}
+	maxBackoff := f.MaxBackoff
+	if maxBackoff <= 0 {
+		maxBackoff = time.Minute
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		var retry bool
+		if retry, err = f.post(ctx, ep, body); err == nil || !retry {
+			return err
+		}
+
+		if attempt < attempts {
+			t := time.NewTimer(backoff)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return fmt.Errorf("%v after %d attempts: %v", ctx.Err(), attempt, err)
+			}
+			if backoff *= 2; backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+	}
+
+	return fmt.Errorf("giving up after %d attempts: %v", attempts, err)
+}
+
+// post sends a single request and reports whether a failure is worth retrying
+func (f *Forwarder) post(ctx context.Context, ep Endpoint, body []byte) (bool, error) {
+	req, err := http.NewRequest("POST", ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range ep.Headers {
+		req.Header.Set(k, v)
+	}
+
+	if ep.Secret != "" {
+		ts := strconv.FormatInt(time.Now().Unix(), 10)
+		req.Header.Set(TimestampHeader, ts)
+		req.Header.Set(SignatureHeader, "sha256="+Sign(ep.Secret, ts, body))
+	}
+
+	client := f.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return true, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 200 && resp.StatusCode <= 299 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return false, nil
+	}
+
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("%s returned %d", ep.URL, resp.StatusCode)
+	if m := strings.TrimSpace(string(msg)); m != "" {
+		err = fmt.Errorf("%v: %s", err, m)
+	}
+
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500, err
+}
+
+// Sign returns the hex encoded HMAC-SHA256 of timestamp + "." + body, as sent in SignatureHeader
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature, the value of SignatureHeader, matches the body
+func Verify(secret, timestamp, signature string, body []byte) bool {
+	expected := "sha256=" + Sign(secret, timestamp, body)
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// deadLetter appends a failed delivery to the dead-letter file
+func (f *Forwarder) deadLetter(ep Endpoint, body []byte, cause error) error {
+	if f.DeadLetterPath == "" {
+		return nil
+	}
+
+	dl := DeadLetter{
+		Time:     time.Now().UTC(),
+		Endpoint: endpointName(ep),
+		URL:      ep.URL,
+		Error:    cause.Error(),
+	}
+	if json.Valid(body) {
+		dl.Body = body
+	} else {
+		dl.Body, _ = json.Marshal(string(body))
+	}
+
+	line, err := json.Marshal(dl)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	file, err := os.OpenFile(f.DeadLetterPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err = file.Write(append(line, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// template parses and caches a template
+func (f *Forwarder) template(text string) (*template.Template, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if t, ok := f.templates[text]; ok {
+		return t, nil
+	}
+
+	t, err := template.New("payload").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.templates == nil {
+		f.templates = map[string]*template.Template{}
+	}
+	f.templates[text] = t
+	return t, nil
+}
+
+func endpointName(ep Endpoint) string {
+	if ep.Name != "" {
+		return ep.Name
+	}
+	return ep.URL
+}
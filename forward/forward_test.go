@@ -0,0 +1,352 @@
+package forward
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+	"github.com/jakewarren/trustar-golang/watch"
+)
+
+var event = watch.Event{
+	Type:      watch.UpdatedReport,
+	EnclaveID: "e1",
+	Report: trustar.ReportDetails{
+		ID:         "guid/1",
+		Title:      "Phish <urgent> & more",
+		ReportBody: "Body text",
+		Updated:    1500000000000,
+	},
+}
+
+// receiver records the requests posted to it, answering with the given statuses in turn
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	headers  []http.Header
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(req.Body)
+	r.bodies = append(r.bodies, body)
+	r.headers = append(r.headers, req.Header)
+
+	status := http.StatusOK
+	if n := len(r.bodies); n <= len(r.statuses) {
+		status = r.statuses[n-1]
+	}
+	w.WriteHeader(status)
+	w.Write([]byte("status " + http.StatusText(status)))
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.bodies)
+}
+
+func TestRender(t *testing.T) {
+	f := &Forwarder{}
+	p, err := f.payload(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.Indicators = []trustar.Indicator{{Value: "evil.com", IndicatorType: "URL"}}
+
+	if p.ReportURL != trustar.StationReportURL+"guid%2F1" || p.Event != "UpdatedReport" {
+		t.Errorf("got %+v", p)
+	}
+
+	tests := []struct {
+		name string
+		ep   Endpoint
+		want []string
+	}{
+		{
+			name: "generic",
+			ep:   Endpoint{Format: Generic},
+			want: []string{`"event":"UpdatedReport"`, `"enclaveId":"e1"`, `"indicators":[{`, `"reportUrl":"https://station.trustar.co/constellation/reports/guid%2F1"`},
+		},
+		{
+			name: "generic template",
+			ep:   Endpoint{Format: Generic, Template: `{{.Report.ID}} {{time .Report.Updated}} {{truncate 5 .Report.Title}}`},
+			want: []string{"guid/1 2017-07-14T02:40:00Z Phish…"},
+		},
+		{
+			name: "slack",
+			ep:   Endpoint{Format: Slack},
+			want: []string{`{"text":"*TruSTAR UpdatedReport*: <` + trustar.StationReportURL + `guid%2F1|Phish &lt;urgent&gt; &amp; more>`, "in enclave e1", "Indicators: `evil.com`"},
+		},
+		{
+			name: "teams",
+			ep:   Endpoint{Format: Teams},
+			want: []string{`"@type":"MessageCard"`, `"title":"TruSTAR UpdatedReport: Phish <urgent> & more"`, `evil.com (URL);`, `"uri":"` + trustar.StationReportURL + `guid%2F1"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, err := f.render(tt.ep, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.ep.Template == "" {
+				body = unescape(t, body)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(string(body), want) {
+					t.Errorf("got %s, want it to contain %s", body, want)
+				}
+			}
+		})
+	}
+
+	if _, err := f.render(Endpoint{Template: "{{.Nope"}, p); err == nil {
+		t.Error("want a template parse error")
+	}
+}
+
+// unescape re-encodes a JSON body without HTML escaping, so it can be compared with readable strings
+func unescape(t *testing.T, body []byte) []byte {
+	t.Helper()
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		t.Fatalf("got invalid JSON %s: %v", body, err)
+	}
+
+	var b bytes.Buffer
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		t.Fatal(err)
+	}
+	return b.Bytes()
+}
+
+func TestSignature(t *testing.T) {
+	rec := &receiver{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	f := New(nil, Endpoint{URL: srv.URL, Secret: "s3cret", Headers: map[string]string{"X-Extra": "1"}})
+	if err := f.Forward(context.Background(), event); err != nil {
+		t.Fatal(err)
+	}
+
+	h := rec.headers[0]
+	if !Verify("s3cret", h.Get(TimestampHeader), h.Get(SignatureHeader), rec.bodies[0]) {
+		t.Errorf("got signature %q, want it to verify", h.Get(SignatureHeader))
+	}
+	if Verify("other", h.Get(TimestampHeader), h.Get(SignatureHeader), rec.bodies[0]) {
+		t.Error("want a different secret to fail verification")
+	}
+	if Verify("s3cret", h.Get(TimestampHeader), h.Get(SignatureHeader), append(rec.bodies[0], ' ')) {
+		t.Error("want a modified body to fail verification")
+	}
+	if h.Get("X-Extra") != "1" || h.Get("Content-Type") != "application/json" {
+		t.Errorf("got headers %v", h)
+	}
+
+	// HMAC-SHA256 of "1.body" keyed with "key"
+	if got, want := Sign("key", "1", []byte("body")), "91b5374b153842ad05b2c4eab9349b8321b14703165bd3fb8b034dfb8be98ae5"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestDeliverRetries(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		name     string
+		statuses []int
+		attempts int
+		failed   bool
+	}{
+		{"success", nil, 1, false},
+		{"retried until success", []int{503, 429, 200}, 3, false},
+		{"client error is not retried", []int{400}, 1, true},
+		{"gives up", []int{500, 500, 500}, 3, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := &receiver{statuses: tt.statuses}
+			srv := httptest.NewServer(rec)
+			defer srv.Close()
+
+			deadLetters := filepath.Join(dir, strings.Replace(tt.name, " ", "-", -1)+".jsonl")
+			f := New(nil, Endpoint{Name: "hook", URL: srv.URL})
+			f.MaxAttempts = 3
+			f.InitialBackoff = time.Millisecond
+			f.DeadLetterPath = deadLetters
+
+			err := f.Forward(context.Background(), event)
+			if (err != nil) != tt.failed {
+				t.Errorf("got error %v, want failed %v", err, tt.failed)
+			}
+			if rec.count() != tt.attempts {
+				t.Errorf("got %d attempts, want %d", rec.count(), tt.attempts)
+			}
+
+			letters := readDeadLetters(t, deadLetters)
+			if !tt.failed {
+				if len(letters) != 0 {
+					t.Errorf("got dead letters %v, want none", letters)
+				}
+				return
+			}
+			if len(letters) != 1 || letters[0].Endpoint != "hook" || letters[0].URL != srv.URL || !strings.Contains(letters[0].Error, "returned") {
+				t.Fatalf("got dead letters %+v", letters)
+			}
+			var p Payload
+			if err := json.Unmarshal(letters[0].Body, &p); err != nil || p.Report.ID != event.Report.ID {
+				t.Errorf("got dead letter body %s, want the payload", letters[0].Body)
+			}
+		})
+	}
+}
+
+func readDeadLetters(t *testing.T, path string) []DeadLetter {
+	t.Helper()
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	var letters []DeadLetter
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		var dl DeadLetter
+		if err := json.Unmarshal(s.Bytes(), &dl); err != nil {
+			t.Fatal(err)
+		}
+		letters = append(letters, dl)
+	}
+	return letters
+}
+
+func TestQueue(t *testing.T) {
+	dir, err := ioutil.TempDir("", "forward")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	rec := &receiver{}
+	srv := httptest.NewServer(rec)
+	defer srv.Close()
+
+	var (
+		mu   sync.Mutex
+		errs []error
+	)
+	f := New(nil, Endpoint{URL: srv.URL})
+	f.QueueSize = 2
+	f.DeadLetterPath = filepath.Join(dir, "dead.jsonl")
+	f.ErrorHandler = func(err error) {
+		mu.Lock()
+		errs = append(errs, err)
+		mu.Unlock()
+	}
+
+	// Handle never blocks: events beyond the queue are dead-lettered without being sent
+	for i := 0; i < 3; i++ {
+		f.Handle(event)
+	}
+	if rec.count() != 0 {
+		t.Errorf("got %d deliveries before Run, want none", rec.count())
+	}
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), ErrQueueFull.Error()) {
+		t.Errorf("got errors %v, want %v", errs, ErrQueueFull)
+	}
+	if n := len(readDeadLetters(t, f.DeadLetterPath)); n != 1 {
+		t.Errorf("got %d dead letters, want 1", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- f.Run(ctx) }()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for rec.count() < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if rec.count() != 2 {
+		t.Fatalf("got %d deliveries, want the 2 queued events", rec.count())
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+
+	// events queued after Run stopped are dead-lettered by the next Run once cancelled
+	f.Handle(event)
+	if err := f.Run(ctx); err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+	if rec.count() != 2 {
+		t.Errorf("got %d deliveries, want none after cancellation", rec.count())
+	}
+	if n := len(readDeadLetters(t, f.DeadLetterPath)); n != 2 {
+		t.Errorf("got %d dead letters, want 2", n)
+	}
+}
+
+func TestPayloadIndicators(t *testing.T) {
+	var pages int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pages++
+		json.NewEncoder(w).Encode(trustar.ReportIndicatorsResponse{
+			Items:   []trustar.Indicator{{Value: "a"}, {Value: "b"}},
+			HasNext: true,
+		})
+	}))
+	defer srv.Close()
+
+	c, err := trustar.NewClient("id", "secret", srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetAccessToken("token")
+
+	f := New(c)
+	f.MaxIndicators = 3
+	p, err := f.payload(context.Background(), event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Indicators) != 3 || pages != 2 {
+		t.Errorf("got %d indicators from %d pages, want 3 from 2", len(p.Indicators), pages)
+	}
+
+	// a done context skips the lookup
+	if p, _ = f.payload(canceled, event); p.Indicators != nil {
+		t.Errorf("got %v, want no indicators fetched", p.Indicators)
+	}
+}
@@ -0,0 +1,48 @@
+package forward
+
+import (
+	"encoding/json"
+	"strings"
+	"text/template"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// templateFuncs are available to endpoint templates in addition to the text/template builtins
+var templateFuncs = template.FuncMap{
+	// time formats a time in milliseconds since epoch as RFC 3339
+	"time": func(ms int64) string {
+		if ms == 0 {
+			return ""
+		}
+		t, _ := trustar.MsEpochToTime(ms)
+		return t.UTC().Format(time.RFC3339)
+	},
+	// truncate shortens a string to n runes, adding an ellipsis
+	"truncate": func(n int, s string) string {
+		r := []rune(s)
+		if len(r) <= n {
+			return s
+		}
+		return string(r[:n]) + "…"
+	},
+	// json encodes a value, for building Generic payloads from a template
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	// slack escapes the characters Slack reserves for links and mentions
+	"slack": strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace,
+	"join":  strings.Join,
+}
+
+var defaultTemplates = map[Format]string{
+	Slack: `*TruSTAR {{.Event}}*: <{{.ReportURL}}|{{slack .Report.Title}}>
+Updated {{time .Report.Updated}}{{if .EnclaveID}} in enclave {{.EnclaveID}}{{end}}
+{{if .Indicators}}Indicators:{{range .Indicators}} ` + "`{{.Value}}`" + `{{end}}{{end}}`,
+
+	Teams: `**Updated:** {{time .Report.Updated}}{{if .EnclaveID}}<br>**Enclave:** {{.EnclaveID}}{{end}}
+{{if .Indicators}}<br>**Indicators:**{{range .Indicators}} {{.Value}}{{if .IndicatorType}} ({{.IndicatorType}}){{end}};{{end}}{{end}}
+<br>{{truncate 500 .Report.ReportBody}}`,
+}
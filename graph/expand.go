@@ -0,0 +1,165 @@
+package graph
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// Expander grows a graph from seed indicators and reports, one hop at a time, using
+// FindCorrelatedReports for indicators and GetReportIndicators for reports.
+type Expander struct {
+	Client     *trustar.Client
+	EnclaveIDs []string // enclaves to search for correlated reports, all accessible enclaves if empty
+
+	MaxHops                int // hops from the seeds, defaults to 2
+	MaxRequests            int // request budget for the whole expansion, defaults to 50
+	MaxReportsPerIndicator int // correlated reports fetched per indicator, defaults to 25
+	MaxIndicatorsPerReport int // indicators fetched per report, defaults to 100
+}
+
+// Expand builds a graph from the seeds. When the request budget runs out the partial graph is
+// returned with Truncated set.
+func (e *Expander) Expand(seedIndicators []string, seedReports []string) (*Graph, error) {
+	g := New()
+	budget := e.maxRequests()
+
+	var frontier []*Node
+	for _, v := range seedIndicators {
+		if v = strings.TrimSpace(v); v != "" {
+			frontier = append(frontier, g.AddIndicator(trustar.Indicator{Value: v}, 0))
+		}
+	}
+	for _, id := range seedReports {
+		if id == "" {
+			continue
+		}
+		r := trustar.ReportDetails{ID: id}
+		if budget > 0 {
+			// seed reports are fetched for their titles
+			budget--
+			details, err := e.Client.GetReportDetails(id)
+			if err != nil {
+				return g, err
+			}
+			r = details
+		}
+		frontier = append(frontier, g.AddReport(r, 0))
+	}
+
+	expanded := map[string]bool{}
+
+	for hop := 1; hop <= e.maxHops() && len(frontier) > 0; hop++ {
+		var next []*Node
+
+		for _, n := range frontier {
+			if expanded[n.ID] {
+				continue
+			}
+			if budget == 0 {
+				g.Truncated = true
+				return g, nil
+			}
+			budget--
+			expanded[n.ID] = true
+
+			var (
+				found []*Node
+				err   error
+			)
+			if n.Kind == IndicatorNode {
+				found, err = e.expandIndicator(g, n, hop)
+			} else {
+				found, err = e.expandReport(g, n, hop)
+			}
+			if err != nil {
+				return g, err
+			}
+			next = append(next, found...)
+		}
+
+		frontier = next
+	}
+
+	return g, nil
+}
+
+// expandIndicator adds the reports containing an indicator
+func (e *Expander) expandIndicator(g *Graph, n *Node, hop int) ([]*Node, error) {
+	v := url.Values{}
+	v.Set("indicators", n.Key)
+	v.Set("pageSize", strconv.Itoa(e.maxReports()))
+	if len(e.EnclaveIDs) > 0 {
+		v.Set("enclaveIds", strings.Join(e.EnclaveIDs, ","))
+	}
+
+	crr, err := e.Client.FindCorrelatedReports(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*Node
+	for _, r := range crr.Items {
+		_, known := g.Node(ReportID(r.ID))
+		rn := g.AddReport(r, hop)
+		g.AddEdge(r.ID, n.Key)
+		if !known {
+			found = append(found, rn)
+		}
+	}
+
+	return found, nil
+}
+
+// expandReport adds the indicators contained in a report
+func (e *Expander) expandReport(g *Graph, n *Node, hop int) ([]*Node, error) {
+	v := url.Values{}
+	v.Set("pageSize", strconv.Itoa(e.maxIndicators()))
+
+	rir, err := e.Client.GetReportIndicators(n.Key, v)
+	if err != nil {
+		return nil, err
+	}
+
+	var found []*Node
+	for _, i := range rir.Items {
+		_, known := g.Node(IndicatorID(i.Value))
+		in := g.AddIndicator(i, hop)
+		g.AddEdge(n.Key, i.Value)
+		if !known {
+			found = append(found, in)
+		}
+	}
+
+	return found, nil
+}
+
+func (e *Expander) maxHops() int {
+	if e.MaxHops <= 0 {
+		return 2
+	}
+	return e.MaxHops
+}
+
+func (e *Expander) maxRequests() int {
+	if e.MaxRequests <= 0 {
+		return 50
+	}
+	return e.MaxRequests
+}
+
+func (e *Expander) maxReports() int {
+	if e.MaxReportsPerIndicator <= 0 {
+		return 25
+	}
+	return e.MaxReportsPerIndicator
+}
+
+func (e *Expander) maxIndicators() int {
+	if e.MaxIndicatorsPerReport <= 0 {
+		return 100
+	}
+	return e.MaxIndicatorsPerReport
+}
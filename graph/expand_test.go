@@ -0,0 +1,198 @@
+package graph
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// fakeAPI serves correlated reports, report details and report indicators for a fixed set of reports
+type fakeAPI struct {
+	mu       sync.Mutex
+	reports  map[string][]string // report ID to the indicator values it contains
+	requests []string
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.requests = append(f.requests, r.URL.Path+"?"+r.URL.RawQuery)
+
+	path := strings.TrimPrefix(r.URL.Path, "/reports/")
+	switch {
+	case path == "correlated":
+		value := r.URL.Query().Get("indicators")
+		var resp trustar.CorrelatedReportResponse
+		for _, id := range []string{"r1", "r2", "r3"} {
+			for _, v := range f.reports[id] {
+				if v == value {
+					resp.Items = append(resp.Items, trustar.ReportDetails{ID: id, Title: "Title " + id})
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(resp)
+	case strings.HasSuffix(path, "/indicators"):
+		var resp trustar.ReportIndicatorsResponse
+		for _, v := range f.reports[strings.TrimSuffix(path, "/indicators")] {
+			resp.Items = append(resp.Items, trustar.Indicator{Value: v, IndicatorType: "URL"})
+		}
+		json.NewEncoder(w).Encode(resp)
+	case f.reports[path] != nil:
+		json.NewEncoder(w).Encode(trustar.ReportDetails{ID: path, Title: "Title " + path})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newExpander(t *testing.T) (*Expander, *fakeAPI, func()) {
+	t.Helper()
+
+	api := &fakeAPI{reports: map[string][]string{
+		"r1": {"a", "b"},
+		"r2": {"b", "c"},
+		"r3": {"c"},
+	}}
+	srv := httptest.NewServer(api)
+
+	c, err := trustar.NewClient("id", "secret", srv.URL+"/")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	c.SetAccessToken("token")
+
+	return &Expander{Client: c}, api, srv.Close
+}
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name      string
+		indicator string
+		report    string
+		hops      int
+		budget    int
+		nodes     []string
+		hopCounts map[string]int
+		requests  int
+		truncated bool
+	}{
+		{
+			name:      "default hops",
+			indicator: "a",
+			nodes:     []string{"indicator:a", "report:r1", "indicator:b"},
+			hopCounts: map[string]int{"indicator:a": 0, "report:r1": 1, "indicator:b": 2},
+			requests:  2,
+		},
+		{
+			name:      "more hops",
+			indicator: "a",
+			hops:      4,
+			nodes:     []string{"indicator:a", "report:r1", "indicator:b", "report:r2", "indicator:c"},
+			hopCounts: map[string]int{"report:r2": 3, "indicator:c": 4},
+			requests:  4,
+		},
+		{
+			name:      "budget",
+			indicator: "a",
+			hops:      4,
+			budget:    2,
+			nodes:     []string{"indicator:a", "report:r1", "indicator:b"},
+			requests:  2,
+			truncated: true,
+		},
+		{
+			name:      "seed report",
+			report:    "r2",
+			nodes:     []string{"report:r2", "indicator:b", "indicator:c", "report:r1", "report:r3"},
+			hopCounts: map[string]int{"report:r2": 0, "indicator:c": 1, "report:r3": 2},
+			requests:  4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, api, done := newExpander(t)
+			defer done()
+			e.MaxHops = tt.hops
+			e.MaxRequests = tt.budget
+
+			var seedIndicators, seedReports []string
+			if tt.indicator != "" {
+				seedIndicators = []string{tt.indicator, " "}
+			}
+			if tt.report != "" {
+				seedReports = []string{tt.report}
+			}
+
+			g, err := e.Expand(seedIndicators, seedReports)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if got := ids(g.Nodes()); !equal(got, tt.nodes) {
+				t.Errorf("got nodes %v, want %v", got, tt.nodes)
+			}
+			for id, hop := range tt.hopCounts {
+				if n, ok := g.Node(id); !ok || n.Hop != hop {
+					t.Errorf("got %+v, want hop %d", n, hop)
+				}
+			}
+			if len(api.requests) != tt.requests {
+				t.Errorf("got requests %v, want %d", api.requests, tt.requests)
+			}
+			if g.Truncated != tt.truncated {
+				t.Errorf("got truncated %v, want %v", g.Truncated, tt.truncated)
+			}
+		})
+	}
+}
+
+func TestExpandRequests(t *testing.T) {
+	e, api, done := newExpander(t)
+	defer done()
+	e.MaxHops = 2
+	e.EnclaveIDs = []string{"e1", "e2"}
+	e.MaxReportsPerIndicator = 5
+	e.MaxIndicatorsPerReport = 7
+
+	g, err := e.Expand(nil, []string{"r1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"/reports/r1?",
+		"/reports/r1/indicators?pageSize=7",
+		"/reports/correlated?enclaveIds=e1%2Ce2&indicators=a&pageSize=5",
+		"/reports/correlated?enclaveIds=e1%2Ce2&indicators=b&pageSize=5",
+	}
+	if !equal(api.requests, want) {
+		t.Errorf("got requests %v, want %v", api.requests, want)
+	}
+
+	if n, _ := g.Node("report:r1"); n.Label != "Title r1" {
+		t.Errorf("got label %q, want the fetched title", n.Label)
+	}
+	if n, _ := g.Node("indicator:a"); n.Type != "URL" {
+		t.Errorf("got type %q, want the indicator type", n.Type)
+	}
+}
+
+func TestExpandError(t *testing.T) {
+	e, _, done := newExpander(t)
+	defer done()
+
+	g, err := e.Expand(nil, []string{"missing"})
+	if !trustar.IsNotFound(err) {
+		t.Errorf("got error %v, want not found", err)
+	}
+	if g == nil {
+		t.Error("got a nil graph, want the partial graph")
+	}
+}
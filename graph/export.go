@@ -0,0 +1,160 @@
+package graph
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// WriteDOT writes the graph in Graphviz DOT format. Reports are drawn as boxes and indicators as ellipses.
+func (g *Graph) WriteDOT(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+
+	fmt.Fprintln(bw, "graph trustar {")
+	for _, n := range g.Nodes() {
+		shape := "ellipse"
+		if n.Kind == ReportNode {
+			shape = "box"
+		}
+		fmt.Fprintf(bw, "  %s [label=%s, shape=%s, kind=%s", dotID(n.ID), dotID(n.Label), shape, n.Kind)
+		if n.Type != "" {
+			fmt.Fprintf(bw, ", type=%s", dotID(n.Type))
+		}
+		fmt.Fprintln(bw, "];")
+	}
+	for _, e := range g.Edges() {
+		fmt.Fprintf(bw, "  %s -- %s;\n", dotID(e.Report), dotID(e.Indicator))
+	}
+	fmt.Fprintln(bw, "}")
+
+	return bw.Flush()
+}
+
+func dotID(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+// WriteGraphML writes the graph in GraphML format with kind, label, type, hop, degree centrality and component attributes
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	type data struct {
+		Key   string `xml:"key,attr"`
+		Value string `xml:",chardata"`
+	}
+	type node struct {
+		ID   string `xml:"id,attr"`
+		Data []data `xml:"data"`
+	}
+	type edge struct {
+		ID     string `xml:"id,attr"`
+		Source string `xml:"source,attr"`
+		Target string `xml:"target,attr"`
+	}
+	type key struct {
+		ID       string `xml:"id,attr"`
+		For      string `xml:"for,attr"`
+		AttrName string `xml:"attr.name,attr"`
+		AttrType string `xml:"attr.type,attr"`
+	}
+	type graph struct {
+		ID          string `xml:"id,attr"`
+		EdgeDefault string `xml:"edgedefault,attr"`
+		Nodes       []node `xml:"node"`
+		Edges       []edge `xml:"edge"`
+	}
+	type graphml struct {
+		XMLName xml.Name `xml:"graphml"`
+		XMLNS   string   `xml:"xmlns,attr"`
+		Keys    []key    `xml:"key"`
+		Graph   graph    `xml:"graph"`
+	}
+
+	doc := graphml{
+		XMLNS: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []key{
+			{"kind", "node", "kind", "string"},
+			{"label", "node", "label", "string"},
+			{"type", "node", "type", "string"},
+			{"hop", "node", "hop", "int"},
+			{"centrality", "node", "centrality", "double"},
+			{"component", "node", "component", "int"},
+		},
+		Graph: graph{ID: "trustar", EdgeDefault: "undirected"},
+	}
+
+	centrality := g.DegreeCentrality()
+	components := g.componentIndex()
+
+	for _, n := range g.Nodes() {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, node{ID: n.ID, Data: []data{
+			{"kind", n.Kind.String()},
+			{"label", n.Label},
+			{"type", n.Type},
+			{"hop", strconv.Itoa(n.Hop)},
+			{"centrality", strconv.FormatFloat(centrality[n.ID], 'f', -1, 64)},
+			{"component", strconv.Itoa(components[n.ID])},
+		}})
+	}
+	for i, e := range g.Edges() {
+		doc.Graph.Edges = append(doc.Graph.Edges, edge{ID: "e" + strconv.Itoa(i), Source: e.Report, Target: e.Indicator})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteCytoscapeJSON writes the graph in the Cytoscape.js elements JSON format
+func (g *Graph) WriteCytoscapeJSON(w io.Writer) error {
+	type element struct {
+		Data map[string]interface{} `json:"data"`
+	}
+
+	centrality := g.DegreeCentrality()
+	components := g.componentIndex()
+
+	var doc struct {
+		Elements struct {
+			Nodes []element `json:"nodes"`
+			Edges []element `json:"edges"`
+		} `json:"elements"`
+	}
+	doc.Elements.Nodes = []element{}
+	doc.Elements.Edges = []element{}
+
+	for _, n := range g.Nodes() {
+		doc.Elements.Nodes = append(doc.Elements.Nodes, element{Data: map[string]interface{}{
+			"id":         n.ID,
+			"label":      n.Label,
+			"kind":       n.Kind.String(),
+			"type":       n.Type,
+			"hop":        n.Hop,
+			"degree":     g.Degree(n.ID),
+			"centrality": centrality[n.ID],
+			"component":  components[n.ID],
+		}})
+	}
+	for i, e := range g.Edges() {
+		doc.Elements.Edges = append(doc.Elements.Edges, element{Data: map[string]interface{}{
+			"id":     "e" + strconv.Itoa(i),
+			"source": e.Report,
+			"target": e.Indicator,
+		}})
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
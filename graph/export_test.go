@@ -0,0 +1,134 @@
+package graph
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+func TestWriteDOT(t *testing.T) {
+	g := sample()
+	g.AddReport(trustar.ReportDetails{ID: "r4", Title: `Say "hi"` + "\n" + `C:\temp`}, 0)
+
+	var b bytes.Buffer
+	if err := g.WriteDOT(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	want := `graph trustar {
+  "indicator:a" [label="a", shape=ellipse, kind=indicator];
+  "report:r1" [label="Report one", shape=box, kind=report];
+  "indicator:b" [label="b", shape=ellipse, kind=indicator, type="URL"];
+  "report:r2" [label="r2", shape=box, kind=report];
+  "report:r3" [label="r3", shape=box, kind=report];
+  "indicator:d" [label="d", shape=ellipse, kind=indicator];
+  "report:r4" [label="Say \"hi\"\nC:\\temp", shape=box, kind=report];
+  "report:r1" -- "indicator:a";
+  "report:r1" -- "indicator:b";
+  "report:r2" -- "indicator:b";
+  "report:r3" -- "indicator:d";
+}
+`
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+}
+
+func TestWriteGraphML(t *testing.T) {
+	var b bytes.Buffer
+	if err := sample().WriteGraphML(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(b.String(), `<?xml version="1.0" encoding="UTF-8"?>`) {
+		t.Errorf("got %q, want an XML header", b.String()[:40])
+	}
+
+	var doc struct {
+		Keys []struct {
+			ID string `xml:"id,attr"`
+		} `xml:"key"`
+		Graph struct {
+			EdgeDefault string `xml:"edgedefault,attr"`
+			Nodes       []struct {
+				ID   string `xml:"id,attr"`
+				Data []struct {
+					Key   string `xml:"key,attr"`
+					Value string `xml:",chardata"`
+				} `xml:"data"`
+			} `xml:"node"`
+			Edges []struct {
+				ID     string `xml:"id,attr"`
+				Source string `xml:"source,attr"`
+				Target string `xml:"target,attr"`
+			} `xml:"edge"`
+		} `xml:"graph"`
+	}
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Keys) != 6 || doc.Graph.EdgeDefault != "undirected" || len(doc.Graph.Nodes) != 6 || len(doc.Graph.Edges) != 4 {
+		t.Fatalf("got %+v", doc)
+	}
+
+	b3 := doc.Graph.Nodes[2]
+	data := map[string]string{}
+	for _, d := range b3.Data {
+		data[d.Key] = d.Value
+	}
+	want := map[string]string{"kind": "indicator", "label": "b", "type": "URL", "hop": "2", "centrality": "0.4", "component": "0"}
+	if b3.ID != "indicator:b" {
+		t.Errorf("got node %s, want indicator:b", b3.ID)
+	}
+	for k, v := range want {
+		if data[k] != v {
+			t.Errorf("got %s=%q, want %q", k, data[k], v)
+		}
+	}
+
+	if e := doc.Graph.Edges[3]; e.ID != "e3" || e.Source != "report:r3" || e.Target != "indicator:d" {
+		t.Errorf("got edge %+v", e)
+	}
+}
+
+func TestWriteCytoscapeJSON(t *testing.T) {
+	var b bytes.Buffer
+	if err := sample().WriteCytoscapeJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc struct {
+		Elements struct {
+			Nodes []struct{ Data map[string]interface{} } `json:"nodes"`
+			Edges []struct{ Data map[string]interface{} } `json:"edges"`
+		} `json:"elements"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Elements.Nodes) != 6 || len(doc.Elements.Edges) != 4 {
+		t.Fatalf("got %d nodes and %d edges, want 6 and 4", len(doc.Elements.Nodes), len(doc.Elements.Edges))
+	}
+
+	r3 := doc.Elements.Nodes[4].Data
+	if r3["id"] != "report:r3" || r3["kind"] != "report" || r3["degree"] != 1.0 || r3["component"] != 1.0 || r3["centrality"] != 0.2 {
+		t.Errorf("got %v", r3)
+	}
+	if e := doc.Elements.Edges[0].Data; e["id"] != "e0" || e["source"] != "report:r1" || e["target"] != "indicator:a" {
+		t.Errorf("got edge %v", e)
+	}
+
+	// an empty graph has empty lists rather than nulls
+	b.Reset()
+	if err := New().WriteCytoscapeJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(strings.Fields(b.String()), ""); got != `{"elements":{"nodes":[],"edges":[]}}` {
+		t.Errorf("got %s", got)
+	}
+}
@@ -0,0 +1,211 @@
+// Package graph builds bipartite report/indicator correlation graphs and exports them for visualization tools.
+package graph
+
+import (
+	"sort"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// NodeKind distinguishes report nodes from indicator nodes
+type NodeKind int
+
+const (
+	// ReportNode is a TruSTAR report
+	ReportNode NodeKind = iota
+	// IndicatorNode is an indicator value
+	IndicatorNode
+)
+
+func (k NodeKind) String() string {
+	if k == ReportNode {
+		return "report"
+	}
+	return "indicator"
+}
+
+// Node is a report or indicator in the graph
+type Node struct {
+	ID    string // unique node ID, "report:<report ID>" or "indicator:<value>"
+	Kind  NodeKind
+	Key   string // the report ID or indicator value
+	Label string // the report title or indicator value
+	Type  string // the indicator type, empty for reports
+	Hop   int    // the number of hops from the nearest seed
+}
+
+// Graph is an undirected bipartite graph of reports and the indicators they contain
+type Graph struct {
+	nodes map[string]*Node
+	order []string // node IDs in insertion order, for stable output
+	adj   map[string]map[string]bool
+
+	// Truncated is set when expansion stopped because the request budget ran out
+	Truncated bool
+}
+
+// New returns an empty Graph
+func New() *Graph {
+	return &Graph{nodes: map[string]*Node{}, adj: map[string]map[string]bool{}}
+}
+
+// ReportID returns the node ID of a report
+func ReportID(id string) string { return "report:" + id }
+
+// IndicatorID returns the node ID of an indicator value
+func IndicatorID(value string) string { return "indicator:" + value }
+
+// AddReport adds a report node, or updates the label of an existing one, and returns it
+func (g *Graph) AddReport(r trustar.ReportDetails, hop int) *Node {
+	n := g.add(&Node{ID: ReportID(r.ID), Kind: ReportNode, Key: r.ID, Label: r.ID, Hop: hop})
+	if r.Title != "" {
+		n.Label = r.Title
+	}
+	return n
+}
+
+// AddIndicator adds an indicator node, or updates the type of an existing one, and returns it
+func (g *Graph) AddIndicator(i trustar.Indicator, hop int) *Node {
+	n := g.add(&Node{ID: IndicatorID(i.Value), Kind: IndicatorNode, Key: i.Value, Label: i.Value, Hop: hop})
+	if i.IndicatorType != "" {
+		n.Type = i.IndicatorType
+	}
+	return n
+}
+
+// AddEdge links a report with an indicator it contains. Both nodes must already exist.
+func (g *Graph) AddEdge(reportID, value string) {
+	a, b := ReportID(reportID), IndicatorID(value)
+	if g.nodes[a] == nil || g.nodes[b] == nil {
+		return
+	}
+	g.adj[a][b] = true
+	g.adj[b][a] = true
+}
+
+func (g *Graph) add(n *Node) *Node {
+	if existing, ok := g.nodes[n.ID]; ok {
+		if n.Hop < existing.Hop {
+			existing.Hop = n.Hop
+		}
+		return existing
+	}
+
+	g.nodes[n.ID] = n
+	g.order = append(g.order, n.ID)
+	g.adj[n.ID] = map[string]bool{}
+	return n
+}
+
+// Node returns a node by ID
+func (g *Graph) Node(id string) (*Node, bool) {
+	n, ok := g.nodes[id]
+	return n, ok
+}
+
+// Nodes returns every node in insertion order
+func (g *Graph) Nodes() []*Node {
+	nodes := make([]*Node, len(g.order))
+	for i, id := range g.order {
+		nodes[i] = g.nodes[id]
+	}
+	return nodes
+}
+
+// Edge links a report node with an indicator node
+type Edge struct {
+	Report    string // the report node ID
+	Indicator string // the indicator node ID
+}
+
+// Edges returns every edge, ordered by report then indicator insertion order
+func (g *Graph) Edges() []Edge {
+	var edges []Edge
+	for _, id := range g.order {
+		if g.nodes[id].Kind != ReportNode {
+			continue
+		}
+		for _, other := range g.Neighbors(id) {
+			edges = append(edges, Edge{Report: id, Indicator: other.ID})
+		}
+	}
+	return edges
+}
+
+// Neighbors returns the nodes linked to a node, in insertion order
+func (g *Graph) Neighbors(id string) []*Node {
+	var out []*Node
+	for _, other := range g.order {
+		if g.adj[id][other] {
+			out = append(out, g.nodes[other])
+		}
+	}
+	return out
+}
+
+// Degree returns the number of edges of a node
+func (g *Graph) Degree(id string) int {
+	return len(g.adj[id])
+}
+
+// DegreeCentrality returns the degree of every node divided by the number of other nodes
+func (g *Graph) DegreeCentrality() map[string]float64 {
+	c := make(map[string]float64, len(g.nodes))
+	for id := range g.nodes {
+		if len(g.nodes) > 1 {
+			c[id] = float64(len(g.adj[id])) / float64(len(g.nodes)-1)
+		} else {
+			c[id] = 0
+		}
+	}
+	return c
+}
+
+// Components returns the connected components, largest first. Nodes within a component keep insertion order.
+func (g *Graph) Components() [][]*Node {
+	seen := map[string]bool{}
+	var components [][]*Node
+
+	for _, start := range g.order {
+		if seen[start] {
+			continue
+		}
+
+		members := map[string]bool{start: true}
+		seen[start] = true
+		queue := []string{start}
+		for len(queue) > 0 {
+			id := queue[0]
+			queue = queue[1:]
+			for other := range g.adj[id] {
+				if !seen[other] {
+					seen[other] = true
+					members[other] = true
+					queue = append(queue, other)
+				}
+			}
+		}
+
+		var component []*Node
+		for _, id := range g.order {
+			if members[id] {
+				component = append(component, g.nodes[id])
+			}
+		}
+		components = append(components, component)
+	}
+
+	sort.SliceStable(components, func(i, j int) bool { return len(components[i]) > len(components[j]) })
+	return components
+}
+
+// componentIndex maps each node ID to the index of its component in Components
+func (g *Graph) componentIndex() map[string]int {
+	index := map[string]int{}
+	for i, c := range g.Components() {
+		for _, n := range c {
+			index[n.ID] = i
+		}
+	}
+	return index
+}
@@ -0,0 +1,127 @@
+package graph
+
+import (
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// sample returns two components: r1 and r2 sharing indicator b, and r3 alone with d
+func sample() *Graph {
+	g := New()
+	g.AddIndicator(trustar.Indicator{Value: "a"}, 0)
+	g.AddReport(trustar.ReportDetails{ID: "r1", Title: "Report one"}, 1)
+	g.AddIndicator(trustar.Indicator{Value: "b", IndicatorType: "URL"}, 2)
+	g.AddReport(trustar.ReportDetails{ID: "r2"}, 3)
+	g.AddReport(trustar.ReportDetails{ID: "r3"}, 0)
+	g.AddIndicator(trustar.Indicator{Value: "d"}, 1)
+
+	g.AddEdge("r1", "a")
+	g.AddEdge("r1", "b")
+	g.AddEdge("r2", "b")
+	g.AddEdge("r3", "d")
+	return g
+}
+
+func ids(nodes []*Node) []string {
+	var out []string
+	for _, n := range nodes {
+		out = append(out, n.ID)
+	}
+	return out
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestGraph(t *testing.T) {
+	g := sample()
+
+	if got, want := ids(g.Nodes()), []string{"indicator:a", "report:r1", "indicator:b", "report:r2", "report:r3", "indicator:d"}; !equal(got, want) {
+		t.Errorf("got nodes %v, want %v", got, want)
+	}
+
+	want := []Edge{{"report:r1", "indicator:a"}, {"report:r1", "indicator:b"}, {"report:r2", "indicator:b"}, {"report:r3", "indicator:d"}}
+	if got := g.Edges(); len(got) != len(want) {
+		t.Errorf("got edges %v, want %v", got, want)
+	} else {
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("got edge %v, want %v", got[i], want[i])
+			}
+		}
+	}
+
+	if got := ids(g.Neighbors("indicator:b")); !equal(got, []string{"report:r1", "report:r2"}) {
+		t.Errorf("got neighbors %v", got)
+	}
+
+	r1, _ := g.Node("report:r1")
+	if r1.Label != "Report one" || r1.Kind != ReportNode || r1.Key != "r1" {
+		t.Errorf("got %+v", r1)
+	}
+	if r2, _ := g.Node("report:r2"); r2.Label != "r2" {
+		t.Errorf("got label %q, want the report ID for an untitled report", r2.Label)
+	}
+
+	// adding a node again keeps the lowest hop and fills in missing details
+	g.AddReport(trustar.ReportDetails{ID: "r1"}, 0)
+	g.AddIndicator(trustar.Indicator{Value: "a", IndicatorType: "IP"}, 5)
+	a, _ := g.Node("indicator:a")
+	if r1.Hop != 0 || r1.Label != "Report one" || a.Hop != 0 || a.Type != "IP" || len(g.Nodes()) != 6 {
+		t.Errorf("got r1 %+v, a %+v", r1, a)
+	}
+
+	// edges to unknown nodes are ignored
+	g.AddEdge("r1", "missing")
+	g.AddEdge("missing", "a")
+	if g.Degree("report:r1") != 2 || len(g.Edges()) != 4 {
+		t.Errorf("got degree %d and %d edges, want unknown nodes ignored", g.Degree("report:r1"), len(g.Edges()))
+	}
+	if _, ok := g.Node("indicator:missing"); ok {
+		t.Error("AddEdge created a node")
+	}
+}
+
+func TestComponents(t *testing.T) {
+	g := sample()
+
+	components := g.Components()
+	if len(components) != 2 {
+		t.Fatalf("got %d components, want 2", len(components))
+	}
+	if got := ids(components[0]); !equal(got, []string{"indicator:a", "report:r1", "indicator:b", "report:r2"}) {
+		t.Errorf("got largest component %v", got)
+	}
+	if got := ids(components[1]); !equal(got, []string{"report:r3", "indicator:d"}) {
+		t.Errorf("got second component %v", got)
+	}
+
+	c := g.DegreeCentrality()
+	tests := map[string]float64{
+		"indicator:a": 0.2,
+		"report:r1":   0.4,
+		"indicator:b": 0.4,
+		"report:r3":   0.2,
+	}
+	for id, want := range tests {
+		if c[id] != want {
+			t.Errorf("got centrality %v for %s, want %v", c[id], id, want)
+		}
+	}
+
+	single := New()
+	single.AddIndicator(trustar.Indicator{Value: "a"}, 0)
+	if got := single.DegreeCentrality()["indicator:a"]; got != 0 {
+		t.Errorf("got centrality %v for a single node, want 0", got)
+	}
+}
@@ -0,0 +1,30 @@
+package trustar
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestClient returns a Client with an access token sending every request to handler.
+// The returned function shuts the server down.
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, func()) {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	c, err := NewClient("id", "secret", srv.URL+"/")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	c.SetAccessToken("token")
+
+	return c, srv.Close
+}
+
+// writeJSON encodes v as the response body
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
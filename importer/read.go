@@ -0,0 +1,269 @@
+// Package importer bulk loads indicators from CSV and JSON Lines files into TruSTAR.
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// Row is a validated indicator read from an import file
+type Row struct {
+	Row     int // 1-based position of the row in the file, not counting the CSV header
+	Content trustar.IndicatorContent
+}
+
+// Result records the outcome of importing a single row
+type Result struct {
+	Row   int
+	Value string
+	Err   error // nil if the row was submitted successfully
+}
+
+// columns recognized in import files, matched case-insensitively
+var columns = []string{"value", "firstSeen", "lastSeen", "sightings", "source", "notes", "tags"}
+
+// ReadCSV reads indicators from CSV data with a header line naming the columns.
+// Rows that fail validation are returned as failed results instead of rows.
+func ReadCSV(r io.Reader) ([]Row, []Result, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading CSV header: %v", err)
+	}
+
+	index := map[string]int{}
+	for i, h := range header {
+		for _, c := range columns {
+			if strings.EqualFold(strings.TrimSpace(h), c) {
+				index[c] = i
+			}
+		}
+	}
+	if _, ok := index["value"]; !ok {
+		return nil, nil, fmt.Errorf("CSV header has no value column")
+	}
+
+	var (
+		rows    []Row
+		invalid []Result
+	)
+
+	for n := 1; ; n++ {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if _, ok := err.(*csv.ParseError); ok {
+				invalid = append(invalid, Result{Row: n, Err: err})
+				continue
+			}
+			return nil, nil, err
+		}
+
+		fields := map[string]string{}
+		for c, i := range index {
+			if i < len(record) {
+				fields[c] = strings.TrimSpace(record[i])
+			}
+		}
+
+		content, err := parseFields(fields, splitTags(fields["tags"]))
+		if err != nil {
+			invalid = append(invalid, Result{Row: n, Value: fields["value"], Err: err})
+			continue
+		}
+		rows = append(rows, Row{Row: n, Content: content})
+	}
+
+	return rows, invalid, nil
+}
+
+// ReadJSONL reads indicators from JSON Lines data, one object per line using the same keys as
+// the CSV columns. Tags may be given as an array of names or a single comma or semicolon separated string.
+func ReadJSONL(r io.Reader) ([]Row, []Result, error) {
+	var (
+		rows    []Row
+		invalid []Result
+	)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	for n := 1; scanner.Scan(); n++ {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			n--
+			continue
+		}
+
+		var obj map[string]interface{}
+		d := json.NewDecoder(bytes.NewReader(line))
+		d.UseNumber()
+		if err := d.Decode(&obj); err != nil {
+			invalid = append(invalid, Result{Row: n, Err: err})
+			continue
+		}
+
+		fields := map[string]string{}
+		var (
+			tags []string
+			err  error
+		)
+		for k, v := range obj {
+			for _, c := range columns {
+				if !strings.EqualFold(k, c) || err != nil {
+					continue
+				}
+				if c == "tags" {
+					tags, err = jsonTags(v)
+				} else {
+					fields[c], err = jsonScalar(v)
+				}
+				if err != nil {
+					err = fmt.Errorf("line %d: %s %v", n, c, err)
+				}
+			}
+		}
+		if err != nil {
+			invalid = append(invalid, Result{Row: n, Value: fields["value"], Err: err})
+			continue
+		}
+
+		content, err := parseFields(fields, tags)
+		if err != nil {
+			invalid = append(invalid, Result{Row: n, Value: fields["value"], Err: err})
+			continue
+		}
+		rows = append(rows, Row{Row: n, Content: content})
+	}
+
+	return rows, invalid, scanner.Err()
+}
+
+// parseFields validates a row and converts it to the submission format
+func parseFields(fields map[string]string, tags []string) (trustar.IndicatorContent, error) {
+	content := trustar.IndicatorContent{
+		Value:  fields["value"],
+		Source: fields["source"],
+		Notes:  fields["notes"],
+	}
+
+	if content.Value == "" {
+		return content, fmt.Errorf("value is required")
+	}
+
+	var err error
+	if content.FirstSeen, err = parseTime(fields["firstSeen"]); err != nil {
+		return content, fmt.Errorf("invalid firstSeen: %v", err)
+	}
+	if content.LastSeen, err = parseTime(fields["lastSeen"]); err != nil {
+		return content, fmt.Errorf("invalid lastSeen: %v", err)
+	}
+	if content.FirstSeen != 0 && content.LastSeen != 0 && content.LastSeen < content.FirstSeen {
+		return content, fmt.Errorf("lastSeen is before firstSeen")
+	}
+
+	if s := fields["sightings"]; s != "" {
+		if content.Sightings, err = strconv.ParseInt(s, 10, 64); err != nil || content.Sightings < 0 {
+			return content, fmt.Errorf("invalid sightings %q", s)
+		}
+	}
+
+	for _, t := range tags {
+		content.Tags = append(content.Tags, trustar.IndicatorTag{Name: t})
+	}
+
+	return content, nil
+}
+
+// parseTime accepts milliseconds since epoch, RFC 3339 timestamps or YYYY-MM-DD dates
+func parseTime(s string) (int64, error) {
+	if s == "" {
+		return 0, nil
+	}
+
+	if ms, err := strconv.ParseInt(s, 10, 64); err == nil {
+		if ms < 0 {
+			return 0, fmt.Errorf("negative time %q", s)
+		}
+		return ms, nil
+	}
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return trustar.TimeToMsEpoch(t), nil
+		}
+	}
+
+	return 0, fmt.Errorf("unrecognized time %q", s)
+}
+
+func splitTags(s string) []string {
+	var tags []string
+	for _, t := range strings.FieldsFunc(s, func(r rune) bool { return r == ';' || r == ',' }) {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+func jsonTags(v interface{}) ([]string, error) {
+	switch t := v.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return splitTags(t), nil
+	case []interface{}:
+		var tags []string
+		for _, e := range t {
+			s, err := jsonScalar(e)
+			if err != nil {
+				return nil, err
+			}
+			if s != "" {
+				tags = append(tags, s)
+			}
+		}
+		return tags, nil
+	}
+	return nil, fmt.Errorf("must be an array of names or a string, not %s", jsonKind(v))
+}
+
+// jsonScalar converts a decoded JSON value to a field value. null is treated as empty.
+func jsonScalar(v interface{}) (string, error) {
+	switch t := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return strings.TrimSpace(t), nil
+	case json.Number:
+		return t.String(), nil
+	case bool:
+		return strconv.FormatBool(t), nil
+	}
+	return "", fmt.Errorf("must be a string or number, not %s", jsonKind(v))
+}
+
+func jsonKind(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "an object"
+	case []interface{}:
+		return "an array"
+	}
+	return fmt.Sprintf("%T", v)
+}
@@ -0,0 +1,157 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+func tags(names ...string) []trustar.IndicatorTag {
+	var t []trustar.IndicatorTag
+	for _, n := range names {
+		t = append(t, trustar.IndicatorTag{Name: n})
+	}
+	return t
+}
+
+func TestReadJSONL(t *testing.T) {
+	tests := []struct {
+		name string
+		line string
+		want trustar.IndicatorContent
+		err  string // substring of the row error, empty if the row is valid
+	}{
+		{
+			name: "all fields",
+			line: `{"value":"1.2.3.4","firstSeen":1500000000000,"lastSeen":"2017-07-15","sightings":3,"source":"feed","notes":"n","tags":["a","b"]}`,
+			want: trustar.IndicatorContent{Value: "1.2.3.4", FirstSeen: 1500000000000, LastSeen: 1500076800000, Sightings: 3, Source: "feed", Notes: "n", Tags: tags("a", "b")},
+		},
+		{
+			name: "keys are case-insensitive and values trimmed",
+			line: `{"VALUE":" evil.com ","Source":"x"}`,
+			want: trustar.IndicatorContent{Value: "evil.com", Source: "x"},
+		},
+		{
+			name: "tags as a string",
+			line: `{"value":"evil.com","tags":"a; b,c"}`,
+			want: trustar.IndicatorContent{Value: "evil.com", Tags: tags("a", "b", "c")},
+		},
+		{
+			name: "null values are empty",
+			line: `{"value":"evil.com","source":null,"tags":null,"sightings":null}`,
+			want: trustar.IndicatorContent{Value: "evil.com"},
+		},
+		{
+			name: "null and empty tags are skipped",
+			line: `{"value":"evil.com","tags":["a",null,""]}`,
+			want: trustar.IndicatorContent{Value: "evil.com", Tags: tags("a")},
+		},
+		{
+			name: "numbers keep their precision",
+			line: `{"value":12345678901234567890,"sightings":9007199254740993}`,
+			want: trustar.IndicatorContent{Value: "12345678901234567890", Sightings: 9007199254740993},
+		},
+		{
+			name: "unknown keys are ignored",
+			line: `{"value":"evil.com","extra":{"a":1}}`,
+			want: trustar.IndicatorContent{Value: "evil.com"},
+		},
+		{name: "object value", line: `{"value":{"ip":"1.2.3.4"}}`, err: "line 1: value must be a string or number, not an object"},
+		{name: "array notes", line: `{"value":"x","notes":["a"]}`, err: "line 1: notes must be a string or number, not an array"},
+		{name: "object tags", line: `{"value":"x","tags":{"a":1}}`, err: "line 1: tags must be an array of names or a string, not an object"},
+		{name: "nested tag", line: `{"value":"x","tags":[["a"]]}`, err: "line 1: tags must be a string or number, not an array"},
+		{name: "null value", line: `{"value":null}`, err: "value is required"},
+		{name: "bad sightings", line: `{"value":"x","sightings":-1}`, err: `invalid sightings "-1"`},
+		{name: "fractional sightings", line: `{"value":"x","sightings":1.5}`, err: `invalid sightings "1.5"`},
+		{name: "bad time", line: `{"value":"x","firstSeen":"yesterday"}`, err: `invalid firstSeen: unrecognized time "yesterday"`},
+		{name: "lastSeen before firstSeen", line: `{"value":"x","firstSeen":2,"lastSeen":1}`, err: "lastSeen is before firstSeen"},
+		{name: "malformed JSON", line: `{"value":`, err: "unexpected EOF"},
+		{name: "not an object", line: `["x"]`, err: "cannot unmarshal array"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, invalid, err := ReadJSONL(strings.NewReader(tt.line + "\n"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if tt.err != "" {
+				if len(rows) != 0 || len(invalid) != 1 {
+					t.Fatalf("got %d rows and %d invalid results, want one invalid result", len(rows), len(invalid))
+				}
+				if invalid[0].Row != 1 || invalid[0].Err == nil || !strings.Contains(invalid[0].Err.Error(), tt.err) {
+					t.Errorf("got result %+v, want row 1 with error %q", invalid[0], tt.err)
+				}
+				return
+			}
+
+			if len(invalid) != 0 {
+				t.Fatalf("got invalid results %+v", invalid)
+			}
+			if len(rows) != 1 || !reflect.DeepEqual(rows[0].Content, tt.want) {
+				t.Errorf("got rows %+v, want %+v", rows, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadJSONLRowNumbers(t *testing.T) {
+	data := "\n" + `{"value":"a"}` + "\n\n" + `{"value":{}}` + "\n  \n" + `{"value":"c"}`
+
+	rows, invalid, err := ReadJSONL(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rows) != 2 || rows[0].Row != 1 || rows[1].Row != 3 {
+		t.Errorf("got rows %+v, want rows 1 and 3", rows)
+	}
+	if len(invalid) != 1 || invalid[0].Row != 2 {
+		t.Errorf("got invalid %+v, want row 2", invalid)
+	}
+}
+
+func TestReadCSV(t *testing.T) {
+	data := "Value, Sightings,tags,ignored\n" +
+		"1.2.3.4,2,a;b,x\n" +
+		"evil.com,,\n" +
+		",1,,\n" +
+		"bad.com,many,,\n" +
+		"\"unterminated,1\n"
+
+	rows, invalid, err := ReadCSV(strings.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []Row{
+		{Row: 1, Content: trustar.IndicatorContent{Value: "1.2.3.4", Sightings: 2, Tags: tags("a", "b")}},
+		{Row: 2, Content: trustar.IndicatorContent{Value: "evil.com"}},
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("got rows %+v, want %+v", rows, want)
+	}
+
+	var got []string
+	for _, res := range invalid {
+		got = append(got, res.Value+": "+res.Err.Error())
+	}
+	if len(invalid) != 3 || invalid[0].Row != 3 || invalid[1].Row != 4 || invalid[2].Row != 5 {
+		t.Fatalf("got invalid rows %q, want rows 3, 4 and 5", got)
+	}
+	if got[0] != ": value is required" || got[1] != `bad.com: invalid sightings "many"` {
+		t.Errorf("got errors %q", got)
+	}
+}
+
+func TestReadCSVHeader(t *testing.T) {
+	if _, _, err := ReadCSV(strings.NewReader("source,notes\nx,y\n")); err == nil || err.Error() != "CSV header has no value column" {
+		t.Errorf("got error %v, want a missing value column error", err)
+	}
+	if _, _, err := ReadCSV(strings.NewReader("")); err == nil {
+		t.Error("want an error for empty data")
+	}
+}
@@ -0,0 +1,164 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sort"
+	"sync"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+const (
+	// DefaultChunkSize is the number of indicators sent per SubmitIndicators request
+	DefaultChunkSize = 1000
+	// DefaultConcurrency is the number of chunks submitted at the same time
+	DefaultConcurrency = 4
+)
+
+// Options controls how rows are submitted
+type Options struct {
+	EnclaveIDs  []string                 // [required] enclaves the indicators are submitted to
+	Tags        []trustar.IndicatorTag   // tags applied to every submitted indicator
+	ChunkSize   int                      // indicators per request, defaults to DefaultChunkSize
+	Concurrency int                      // concurrent requests, defaults to DefaultConcurrency
+	Limiter     *trustar.QuotaLimiter    // keeps the import within the request quotas, created from the client if nil
+	Enclaves    *trustar.EnclaveRegistry // when set, resolves enclave names and checks Create permission before anything is sent
+	Context     context.Context          // cancels waiting for the quotas to reset, defaults to context.Background()
+}
+
+// Report summarizes an import with one result per row, ordered by row
+type Report struct {
+	Results   []Result
+	Submitted int
+	Failed    int
+}
+
+// Errors returns the results of the rows that failed
+func (r *Report) Errors() []Result {
+	var failed []Result
+	for _, res := range r.Results {
+		if res.Err != nil {
+			failed = append(failed, res)
+		}
+	}
+	return failed
+}
+
+// ImportCSV reads indicators from CSV data and submits them
+func ImportCSV(c *trustar.Client, r io.Reader, opts Options) (*Report, error) {
+	rows, invalid, err := ReadCSV(r)
+	if err != nil {
+		return nil, err
+	}
+	return submitWithInvalid(c, rows, invalid, opts)
+}
+
+// ImportJSONL reads indicators from JSON Lines data and submits them
+func ImportJSONL(c *trustar.Client, r io.Reader, opts Options) (*Report, error) {
+	rows, invalid, err := ReadJSONL(r)
+	if err != nil {
+		return nil, err
+	}
+	return submitWithInvalid(c, rows, invalid, opts)
+}
+
+// Submit splits the rows into chunks and submits them concurrently with SubmitIndicators.
+// A failed request marks every row of its chunk as failed.
+func Submit(c *trustar.Client, rows []Row, opts Options) (*Report, error) {
+	return submitWithInvalid(c, rows, nil, opts)
+}
+
+func submitWithInvalid(c *trustar.Client, rows []Row, invalid []Result, opts Options) (*Report, error) {
+	if len(opts.EnclaveIDs) == 0 {
+		return nil, errors.New("at least one enclave ID is required to submit indicators")
+	}
+	if opts.Enclaves != nil {
+		ids, err := opts.Enclaves.Check(trustar.PermissionCreate, opts.EnclaveIDs...)
+		if err != nil {
+			return nil, err
+		}
+		opts.EnclaveIDs = ids
+	}
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	if opts.Limiter == nil {
+		opts.Limiter = trustar.NewQuotaLimiter(c)
+	}
+	if opts.Context == nil {
+		opts.Context = context.Background()
+	}
+
+	var chunks [][]Row
+	for start := 0; start < len(rows); start += opts.ChunkSize {
+		end := start + opts.ChunkSize
+		if end > len(rows) {
+			end = len(rows)
+		}
+		chunks = append(chunks, rows[start:end])
+	}
+
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		results = append([]Result(nil), invalid...)
+		work    = make(chan []Row)
+	)
+
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range work {
+				err := submitChunk(c, chunk, opts)
+
+				mu.Lock()
+				for _, row := range chunk {
+					results = append(results, Result{Row: row.Row, Value: row.Content.Value, Err: err})
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, chunk := range chunks {
+		work <- chunk
+	}
+	close(work)
+	wg.Wait()
+
+	sort.Slice(results, func(i, j int) bool { return results[i].Row < results[j].Row })
+
+	report := &Report{Results: results}
+	for _, res := range results {
+		if res.Err != nil {
+			report.Failed++
+		} else {
+			report.Submitted++
+		}
+	}
+
+	return report, nil
+}
+
+func submitChunk(c *trustar.Client, chunk []Row, opts Options) error {
+	if err := opts.Limiter.Wait(opts.Context); err != nil {
+		return err
+	}
+
+	submission := trustar.IndicatorSubmission{
+		EnclaveIDS: opts.EnclaveIDs,
+		Tags:       opts.Tags,
+		Content:    make([]trustar.IndicatorContent, len(chunk)),
+	}
+	for i, row := range chunk {
+		submission.Content[i] = row.Content
+	}
+
+	return c.SubmitIndicators(submission)
+}
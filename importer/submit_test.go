@@ -0,0 +1,147 @@
+package importer
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// indicatorServer accepts SubmitIndicators requests, failing any chunk containing a value
+// starting with "fail", and reports plenty of quota
+func indicatorServer(t *testing.T, mu *sync.Mutex, chunks *[][]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/request-quotas":
+			json.NewEncoder(w).Encode(trustar.RequestQuotas{{MaxRequests: 1000}})
+		case "/indicators":
+			var s trustar.IndicatorSubmission
+			if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+				t.Error(err)
+			}
+
+			var values []string
+			for _, c := range s.Content {
+				values = append(values, c.Value)
+			}
+			mu.Lock()
+			*chunks = append(*chunks, values)
+			mu.Unlock()
+
+			if len(s.EnclaveIDS) != 1 || s.EnclaveIDS[0] != "e1" || len(s.Tags) != 1 {
+				t.Errorf("got enclaves %v and tags %v, want the import options", s.EnclaveIDS, s.Tags)
+			}
+			for _, v := range values {
+				if strings.HasPrefix(v, "fail") {
+					http.Error(w, "rejected", http.StatusBadRequest)
+					return
+				}
+			}
+			w.Write([]byte("{}"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+}
+
+func newClient(t *testing.T, srv *httptest.Server) *trustar.Client {
+	c, err := trustar.NewClient("id", "secret", srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetAccessToken("token")
+	return c
+}
+
+func TestImportJSONL(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		chunks [][]string
+	)
+	srv := indicatorServer(t, &mu, &chunks)
+	defer srv.Close()
+
+	data := strings.Join([]string{
+		`{"value":"a"}`,
+		`{"value":"b"}`,
+		`{"value":{}}`,
+		`{"value":"c"}`,
+		`{"value":"fail-d"}`,
+		`{"value":"e"}`,
+	}, "\n")
+
+	report, err := ImportJSONL(newClient(t, srv), strings.NewReader(data), Options{
+		EnclaveIDs:  []string{"e1"},
+		Tags:        []trustar.IndicatorTag{{Name: "import"}},
+		ChunkSize:   2,
+		Concurrency: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var sizes []int
+	for _, c := range chunks {
+		sizes = append(sizes, len(c))
+	}
+	sort.Ints(sizes)
+	if len(sizes) != 3 || sizes[0] != 1 || sizes[2] != 2 {
+		t.Errorf("got chunk sizes %v, want two chunks of 2 and one of 1", sizes)
+	}
+
+	if report.Submitted != 3 || report.Failed != 3 || len(report.Results) != 6 {
+		t.Fatalf("got %d submitted and %d failed of %d, want 3 and 3 of 6", report.Submitted, report.Failed, len(report.Results))
+	}
+	for i, res := range report.Results {
+		if res.Row != i+1 {
+			t.Errorf("got result %d for row %d, want results ordered by row", i, res.Row)
+		}
+	}
+
+	var failed []string
+	for _, res := range report.Errors() {
+		failed = append(failed, res.Value)
+	}
+	// c shares a chunk with fail-d, so both fail with the row that could not be read
+	if got := strings.Join(failed, ","); got != ",c,fail-d" {
+		t.Errorf("got failed values %q, want \",c,fail-d\"", got)
+	}
+}
+
+func TestSubmitRequiresEnclave(t *testing.T) {
+	if _, err := Submit(nil, []Row{{Row: 1}}, Options{}); err == nil {
+		t.Error("want an error without enclave IDs")
+	}
+}
+
+func TestSubmitCancelled(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/request-quotas" {
+			t.Errorf("got %s, want no submissions while the quota is exhausted", r.URL.Path)
+		}
+		reset := trustar.TimeToMsEpoch(time.Now().Add(time.Hour))
+		json.NewEncoder(w).Encode(trustar.RequestQuotas{{MaxRequests: 10, UsedRequests: 10, NextResetTime: reset}})
+	}))
+	defer srv.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	report, err := Submit(newClient(t, srv), []Row{{Row: 1, Content: trustar.IndicatorContent{Value: "a"}}}, Options{
+		EnclaveIDs: []string{"e1"},
+		Context:    ctx,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Failed != 1 || report.Results[0].Err != context.DeadlineExceeded {
+		t.Errorf("got results %+v, want the row to fail with the context error", report.Results)
+	}
+}
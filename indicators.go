@@ -4,7 +4,6 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"net/http"
 	"net/url"
 )
 
@@ -17,7 +16,7 @@ func (c *Client) SearchIndicators(v url.Values) (SearchIndicatorReponse, error)
 	var sir SearchIndicatorReponse
 
 	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("indicators/search?%s", v.Encode()))
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest("SearchIndicators", "GET", url, nil)
 
 	if err != nil {
 		return sir, err
@@ -37,7 +36,7 @@ func (c *Client) FindRelatedIndicators(v url.Values) (RelatedIndicatorsResponse,
 	var rir RelatedIndicatorsResponse
 
 	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("indicators/related?%s", v.Encode()))
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest("FindRelatedIndicators", "GET", url, nil)
 
 	if err != nil {
 		return rir, err
@@ -54,19 +53,32 @@ func (c *Client) FindRelatedIndicators(v url.Values) (RelatedIndicatorsResponse,
 //
 // Endpoint: POST /1.3/whitelist
 func (c *Client) WhitelistIndicators(indicators []string) error {
+	_, err := c.whitelistIndicators(indicators)
+	return err
+}
 
-	var wr interface{}
+// whitelistIndicators whitelists the values and returns the indicators the API reports as whitelisted, with their types
+func (c *Client) whitelistIndicators(indicators []string) ([]Indicator, error) {
+
+	var wr json.RawMessage
 
 	i, _ := json.Marshal(indicators)
 
 	url := fmt.Sprintf("%s%s", c.APIBase, "whitelist")
-	req, err := http.NewRequest("POST", url, bytes.NewReader(i))
+	req, err := c.newRequest("WhitelistIndicators", "POST", url, bytes.NewReader(i))
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return c.SendWithAuth(req, &wr)
+	if err = c.SendWithAuth(req, &wr); err != nil {
+		return nil, err
+	}
+
+	// the response is not documented to hold the indicators, so anything else is ignored
+	var added []Indicator
+	json.Unmarshal(wr, &added)
+	return added, nil
 }
 
 // GetWhitelist Get a paginated list of the indicators that have been whitelisted by the user’s company.
@@ -76,7 +88,7 @@ func (c *Client) GetWhitelist(v url.Values) (WhitelistIndicatorsResponse, error)
 	var wir WhitelistIndicatorsResponse
 
 	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("whitelist?%s", v.Encode()))
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest("GetWhitelist", "GET", url, nil)
 
 	if err != nil {
 		return wir, err
@@ -96,7 +108,7 @@ func (c *Client) DeleteFromWhitelist(v url.Values) error {
 	var wr interface{}
 
 	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("whitelist?%s", v.Encode()))
-	req, err := http.NewRequest("DELETE", url, nil)
+	req, err := c.newRequest("DeleteFromWhitelist", "DELETE", url, nil)
 
 	if err != nil {
 		return err
@@ -122,7 +134,7 @@ func (c *Client) GetIndicatorMetadata(indicators []Indicator) (IndicatorMetadata
 	i, _ := json.Marshal(indicators)
 
 	url := fmt.Sprintf("%s%s", c.APIBase, "indicators/metadata")
-	req, err := http.NewRequest("POST", url, bytes.NewReader(i))
+	req, err := c.newRequest("GetIndicatorMetadata", "POST", url, bytes.NewReader(i))
 
 	if err != nil {
 		return nil, err
@@ -142,9 +154,7 @@ func (c *Client) GetTrendingIndicators(v url.Values) (TrendingIndicators, error)
 	var ti TrendingIndicators
 
 	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("indicators/community-trending?%s", v.Encode()))
-	req, err := http.NewRequest("GET", url, nil)
-
-	fmt.Println(req.URL)
+	req, err := c.newRequest("GetTrendingIndicators", "GET", url, nil)
 
 	if err != nil {
 		return nil, err
@@ -170,11 +180,12 @@ func (c *Client) SubmitIndicators(indicators IndicatorSubmission) error {
 	i, _ := json.Marshal(indicators)
 
 	url := fmt.Sprintf("%s%s", c.APIBase, "indicators")
-	req, err := http.NewRequest("POST", url, bytes.NewReader(i))
+	req, err := c.newRequest("SubmitIndicators", "POST", url, bytes.NewReader(i))
 
 	if err != nil {
 		return err
 	}
+	req = withEnclaves(req, indicators.EnclaveIDS)
 
 	return c.SendWithAuth(req, &imr)
 }
@@ -0,0 +1,150 @@
+package trustar
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+const (
+	// DefaultMetadataChunkSize is the number of indicators sent per GetIndicatorMetadata request
+	DefaultMetadataChunkSize = 1000
+	// DefaultBatchConcurrency is the number of batch requests sent at the same time
+	DefaultBatchConcurrency = 4
+)
+
+// BatchOptions controls how a batch call is split into requests
+type BatchOptions struct {
+	ChunkSize   int             // indicators per request, defaults to DefaultMetadataChunkSize
+	Concurrency int             // concurrent requests, defaults to DefaultBatchConcurrency
+	Limiter     *QuotaLimiter   // keeps the batch within the request quotas, created from the client if nil and more than one request is needed
+	Context     context.Context // cancels waiting for the quotas to reset, defaults to context.Background()
+}
+
+// IndicatorMetadataBatch holds the merged results of GetIndicatorMetadataBatch
+type IndicatorMetadataBatch struct {
+	Metadata map[string]IndicatorMetadata // metadata keyed by the requested indicator value
+	Missing  []string                     // requested values the API returned no metadata for
+	Failed   map[string]error             // requested values whose request failed, with the error
+}
+
+// GetIndicatorMetadataBatch Provide metadata for any number of indicators.
+// The indicators are split into chunks sent concurrently with GetIndicatorMetadata and the results are merged by value.
+// Values are matched case-insensitively, as the API may normalize them.
+// If any request fails the batch holds the partial results and the first error is returned.
+//
+// Endpoint: POST /1.3/indicators/metadata
+func (c *Client) GetIndicatorMetadataBatch(indicators []Indicator, opts BatchOptions) (*IndicatorMetadataBatch, error) {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultMetadataChunkSize
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultBatchConcurrency
+	}
+	if opts.Context == nil {
+		opts.Context = context.Background()
+	}
+
+	batch := &IndicatorMetadataBatch{
+		Metadata: make(map[string]IndicatorMetadata),
+		Failed:   make(map[string]error),
+	}
+
+	// drop duplicates and remember the requested spelling of each value
+	requested := make(map[string]string, len(indicators))
+	unique := make([]Indicator, 0, len(indicators))
+	for _, ind := range indicators {
+		key := strings.ToLower(ind.Value)
+		if ind.Value == "" {
+			continue
+		}
+		if _, ok := requested[key]; ok {
+			continue
+		}
+		requested[key] = ind.Value
+		unique = append(unique, Indicator{Value: ind.Value, IndicatorType: ind.IndicatorType})
+	}
+
+	if len(unique) == 0 {
+		return batch, nil
+	}
+
+	var chunks [][]Indicator
+	for start := 0; start < len(unique); start += opts.ChunkSize {
+		end := start + opts.ChunkSize
+		if end > len(unique) {
+			end = len(unique)
+		}
+		chunks = append(chunks, unique[start:end])
+	}
+
+	if opts.Limiter == nil && len(chunks) > 1 {
+		opts.Limiter = NewQuotaLimiter(c)
+	}
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+		work     = make(chan []Indicator)
+	)
+
+	for w := 0; w < opts.Concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for chunk := range work {
+				resp, err := c.getIndicatorMetadataChunk(opts.Context, chunk, opts.Limiter)
+
+				mu.Lock()
+				if err != nil {
+					if firstErr == nil {
+						firstErr = err
+					}
+					for _, ind := range chunk {
+						batch.Failed[ind.Value] = err
+					}
+				}
+				for _, md := range resp {
+					if value, ok := requested[strings.ToLower(md.Value)]; ok {
+						batch.Metadata[value] = md
+					}
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for _, chunk := range chunks {
+		work <- chunk
+	}
+	close(work)
+	wg.Wait()
+
+	for _, ind := range unique {
+		if _, ok := batch.Metadata[ind.Value]; ok {
+			continue
+		}
+		if _, ok := batch.Failed[ind.Value]; ok {
+			continue
+		}
+		batch.Missing = append(batch.Missing, ind.Value)
+	}
+
+	if firstErr != nil {
+		return batch, fmt.Errorf("%d of %d indicators failed: %v", len(batch.Failed), len(unique), firstErr)
+	}
+
+	return batch, nil
+}
+
+func (c *Client) getIndicatorMetadataChunk(ctx context.Context, chunk []Indicator, limiter *QuotaLimiter) (IndicatorMetadataResponse, error) {
+	if limiter != nil {
+		if err := limiter.Wait(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.GetIndicatorMetadata(chunk)
+}
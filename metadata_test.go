@@ -0,0 +1,200 @@
+package trustar
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// metadataServer answers metadata requests with an upper-cased copy of each value, except values
+// starting with "unknown", and fails requests containing a value starting with "fail".
+// It records the values of each request and counts quota reads.
+type metadataServer struct {
+	mu       sync.Mutex
+	requests [][]string
+	quotas   int32
+}
+
+func (s *metadataServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/request-quotas" {
+		atomic.AddInt32(&s.quotas, 1)
+		writeJSON(w, RequestQuotas{{MaxRequests: 100}})
+		return
+	}
+
+	var req []Indicator
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var values []string
+	var resp IndicatorMetadataResponse
+	for _, ind := range req {
+		values = append(values, ind.Value)
+		if !strings.HasPrefix(ind.Value, "unknown") {
+			resp = append(resp, IndicatorMetadata{Value: strings.ToUpper(ind.Value), IndicatorType: ind.IndicatorType})
+		}
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, values)
+	s.mu.Unlock()
+
+	for _, v := range values {
+		if strings.HasPrefix(v, "fail") {
+			http.Error(w, "boom", http.StatusInternalServerError)
+			return
+		}
+	}
+	writeJSON(w, resp)
+}
+
+func TestGetIndicatorMetadataBatch(t *testing.T) {
+	tests := []struct {
+		name      string
+		values    []string
+		chunkSize int
+		requests  int
+		metadata  []string
+		missing   []string
+		failed    []string
+		quotas    int32
+	}{
+		{
+			name:     "single request",
+			values:   []string{"a.com", "b.com", "unknown.com"},
+			requests: 1,
+			metadata: []string{"a.com", "b.com"},
+			missing:  []string{"unknown.com"},
+		},
+		{
+			name:     "duplicates and blanks",
+			values:   []string{"a.com", "A.com", "", "a.com"},
+			requests: 1,
+			metadata: []string{"a.com"},
+		},
+		{
+			name:      "chunked with a quota check",
+			values:    []string{"a", "b", "c", "d", "e"},
+			chunkSize: 2,
+			requests:  3,
+			metadata:  []string{"a", "b", "c", "d", "e"},
+			quotas:    1,
+		},
+		{
+			name:      "failed chunk",
+			values:    []string{"a", "fail", "c", "unknown"},
+			chunkSize: 2,
+			requests:  2,
+			metadata:  []string{"c"},
+			missing:   []string{"unknown"},
+			failed:    []string{"a", "fail"},
+			quotas:    1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &metadataServer{}
+			c, done := newTestClient(t, srv.ServeHTTP)
+			defer done()
+
+			var indicators []Indicator
+			for _, v := range tt.values {
+				indicators = append(indicators, Indicator{Value: v, IndicatorType: "URL"})
+			}
+
+			batch, err := c.GetIndicatorMetadataBatch(indicators, BatchOptions{ChunkSize: tt.chunkSize})
+			if (err != nil) != (len(tt.failed) > 0) {
+				t.Errorf("got error %v", err)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), "2 of 4 indicators failed") {
+				t.Errorf("got error %v", err)
+			}
+
+			if len(srv.requests) != tt.requests {
+				t.Errorf("got requests %v, want %d", srv.requests, tt.requests)
+			}
+			if got := keys(batch.Metadata); !reflect.DeepEqual(got, sorted(tt.metadata)) {
+				t.Errorf("got metadata for %v, want %v", got, tt.metadata)
+			}
+			for value, md := range batch.Metadata {
+				if md.Value != strings.ToUpper(value) || md.IndicatorType != "URL" {
+					t.Errorf("got %+v for %s", md, value)
+				}
+			}
+			if !reflect.DeepEqual(batch.Missing, tt.missing) {
+				t.Errorf("got missing %v, want %v", batch.Missing, tt.missing)
+			}
+			if got := keys(batch.Failed); !reflect.DeepEqual(got, sorted(tt.failed)) {
+				t.Errorf("got failed %v, want %v", got, tt.failed)
+			}
+			if n := atomic.LoadInt32(&srv.quotas); n != tt.quotas {
+				t.Errorf("got %d quota reads, want %d", n, tt.quotas)
+			}
+		})
+	}
+}
+
+func keys(m interface{}) []string {
+	var out []string
+	for _, k := range reflect.ValueOf(m).MapKeys() {
+		out = append(out, k.String())
+	}
+	return sorted(out)
+}
+
+func sorted(s []string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	out := append([]string(nil), s...)
+	sort.Strings(out)
+	return out
+}
+
+func TestGetIndicatorMetadataBatchEmpty(t *testing.T) {
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request %s", r.URL)
+	})
+	defer done()
+
+	batch, err := c.GetIndicatorMetadataBatch([]Indicator{{Value: ""}}, BatchOptions{})
+	if err != nil || len(batch.Metadata) != 0 || batch.Missing != nil {
+		t.Errorf("got %+v, %v", batch, err)
+	}
+}
+
+func TestGetIndicatorMetadataBatchQuota(t *testing.T) {
+	srv := &metadataServer{}
+	c, done := newTestClient(t, srv.ServeHTTP)
+	defer done()
+
+	// an exhausted limiter holds every chunk until the context ends
+	limiter := NewQuotaLimiter(c)
+	limiter.RefreshInterval = time.Hour
+	limiter.Reserve = 100
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	batch, err := c.GetIndicatorMetadataBatch([]Indicator{{Value: "a"}, {Value: "b"}}, BatchOptions{
+		ChunkSize: 1,
+		Limiter:   limiter,
+		Context:   ctx,
+	})
+	if err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Errorf("got error %v, want the context deadline", err)
+	}
+	if len(batch.Failed) != 2 || len(srv.requests) != 0 {
+		t.Errorf("got %d failed and %d requests, want every chunk failed unsent", len(batch.Failed), len(srv.requests))
+	}
+}
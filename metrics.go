@@ -0,0 +1,108 @@
+package trustar
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// RequestMetrics describes one API call as seen by the Instrument middleware
+type RequestMetrics struct {
+	Operation     string        // SDK method, such as GetReports
+	Method        string        // HTTP method
+	StatusCode    int           // status of the final attempt, zero if no response was received
+	Err           error         // transport error of the final attempt
+	Latency       time.Duration // from sending the request until the response body was closed, including retries
+	Retries       int           // attempts after the first
+	BytesSent     int64         // request body size of the final attempt
+	BytesReceived int64         // response body bytes read
+}
+
+// Metrics receives one RequestMetrics per API call
+type Metrics interface {
+	ObserveRequest(RequestMetrics)
+}
+
+type statsKey struct{}
+
+// callStats collects the counts middlewares further down the chain report about a call
+type callStats struct {
+	mu      sync.Mutex
+	retries int
+	parent  *callStats // stats of an Instrument middleware further up the chain
+}
+
+// countRetry records a retry on the stats of every Instrument middleware the request passed through
+func countRetry(req *http.Request) {
+	s, _ := req.Context().Value(statsKey{}).(*callStats)
+	for ; s != nil; s = s.parent {
+		s.mu.Lock()
+		s.retries++
+		s.mu.Unlock()
+	}
+}
+
+// Instrument returns a middleware reporting every call to m. Add it before Retry so retries are counted
+// and the latency covers all attempts.
+func Instrument(m Metrics) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			parent, _ := req.Context().Value(statsKey{}).(*callStats)
+			stats := &callStats{parent: parent}
+			req = req.WithContext(context.WithValue(req.Context(), statsKey{}, stats))
+
+			start := time.Now()
+			resp, err := next(req)
+
+			observe := func(received int64) {
+				stats.mu.Lock()
+				retries := stats.retries
+				stats.mu.Unlock()
+
+				rm := RequestMetrics{
+					Operation:     Operation(req),
+					Method:        req.Method,
+					Err:           err,
+					Latency:       time.Since(start),
+					Retries:       retries,
+					BytesSent:     req.ContentLength,
+					BytesReceived: received,
+				}
+				if resp != nil {
+					rm.StatusCode = resp.StatusCode
+				}
+				m.ObserveRequest(rm)
+			}
+
+			if err != nil || resp == nil || resp.Body == nil {
+				observe(0)
+				return resp, err
+			}
+
+			resp.Body = &meteredBody{ReadCloser: resp.Body, done: observe}
+			return resp, nil
+		}
+	}
+}
+
+// meteredBody counts the bytes read from a response body and reports them when it is closed
+type meteredBody struct {
+	io.ReadCloser
+	n    int64
+	once sync.Once
+	done func(received int64)
+}
+
+func (b *meteredBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n += int64(n)
+	return n, err
+}
+
+func (b *meteredBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(func() { b.done(b.n) })
+	return err
+}
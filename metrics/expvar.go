@@ -0,0 +1,51 @@
+package metrics
+
+import (
+	"expvar"
+	"sync"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// Expvar publishes request metrics through the expvar package, under /debug/vars.
+// Each operation gets a map with requests, errors, retries, latency_ms, bytes_sent and bytes_received counters.
+type Expvar struct {
+	mu   sync.Mutex
+	vars *expvar.Map
+}
+
+// NewExpvar publishes the metrics under the given name, "trustar" if empty.
+// Like expvar.NewMap it panics if the name is already in use.
+func NewExpvar(name string) *Expvar {
+	if name == "" {
+		name = "trustar"
+	}
+	return &Expvar{vars: expvar.NewMap(name)}
+}
+
+// ObserveRequest implements trustar.Metrics
+func (e *Expvar) ObserveRequest(m trustar.RequestMetrics) {
+	op := m.Operation
+	if op == "" {
+		op = "unknown"
+	}
+
+	e.mu.Lock()
+	stats, ok := e.vars.Get(op).(*expvar.Map)
+	if !ok {
+		stats = new(expvar.Map).Init()
+		e.vars.Set(op, stats)
+	}
+	e.mu.Unlock()
+
+	stats.Add("requests", 1)
+	if m.Err != nil || m.StatusCode >= 400 {
+		stats.Add("errors", 1)
+	}
+	stats.Add("retries", int64(m.Retries))
+	stats.Add("latency_ms", m.Latency.Milliseconds())
+	if m.BytesSent > 0 {
+		stats.Add("bytes_sent", m.BytesSent)
+	}
+	stats.Add("bytes_received", m.BytesReceived)
+}
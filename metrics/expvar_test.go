@@ -0,0 +1,32 @@
+package metrics
+
+import (
+	"encoding/json"
+	"errors"
+	"expvar"
+	"reflect"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+func TestExpvar(t *testing.T) {
+	e := NewExpvar("trustar_test")
+	e.ObserveRequest(trustar.RequestMetrics{Operation: "GetReports", StatusCode: 200, Latency: 1500 * time.Millisecond, Retries: 1, BytesReceived: 100})
+	e.ObserveRequest(trustar.RequestMetrics{Operation: "GetReports", StatusCode: 503, Latency: 500 * time.Millisecond, BytesReceived: 10})
+	e.ObserveRequest(trustar.RequestMetrics{StatusCode: 0, Err: errors.New("refused"), BytesSent: 50})
+
+	var got map[string]map[string]int64
+	if err := json.Unmarshal([]byte(expvar.Get("trustar_test").String()), &got); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]map[string]int64{
+		"GetReports": {"requests": 2, "errors": 1, "retries": 1, "latency_ms": 2000, "bytes_received": 110},
+		"unknown":    {"requests": 1, "errors": 1, "retries": 0, "latency_ms": 0, "bytes_sent": 50, "bytes_received": 0},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v\nwant %v", got, want)
+	}
+}
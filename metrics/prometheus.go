@@ -0,0 +1,197 @@
+// Package metrics collects the RequestMetrics reported by the trustar.Instrument middleware
+// and exposes them in the Prometheus text format or through expvar.
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// DefaultBuckets are the request duration histogram buckets, in seconds
+var DefaultBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Collector aggregates request metrics per operation and serves them in the Prometheus
+// text exposition format. It implements trustar.Metrics and http.Handler and is safe for concurrent use.
+type Collector struct {
+	mu        sync.Mutex
+	namespace string
+	buckets   []float64
+	requests  map[requestKey]uint64
+	durations map[string]*histogram
+	retries   map[string]uint64
+	sent      map[string]uint64
+	received  map[string]uint64
+}
+
+type requestKey struct {
+	operation string
+	code      string
+}
+
+type histogram struct {
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// NewCollector returns a Collector whose metric names start with namespace, "trustar" if empty.
+// Buckets defaults to DefaultBuckets.
+func NewCollector(namespace string, buckets ...float64) *Collector {
+	if namespace == "" {
+		namespace = "trustar"
+	}
+	if len(buckets) == 0 {
+		buckets = DefaultBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+
+	return &Collector{
+		namespace: namespace,
+		buckets:   buckets,
+		requests:  make(map[requestKey]uint64),
+		durations: make(map[string]*histogram),
+		retries:   make(map[string]uint64),
+		sent:      make(map[string]uint64),
+		received:  make(map[string]uint64),
+	}
+}
+
+// ObserveRequest implements trustar.Metrics
+func (c *Collector) ObserveRequest(m trustar.RequestMetrics) {
+	op := m.Operation
+	if op == "" {
+		op = "unknown"
+	}
+	code := "error"
+	if m.Err == nil && m.StatusCode > 0 {
+		code = strconv.Itoa(m.StatusCode)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.requests[requestKey{op, code}]++
+	c.retries[op] += uint64(m.Retries)
+	if m.BytesSent > 0 {
+		c.sent[op] += uint64(m.BytesSent)
+	}
+	c.received[op] += uint64(m.BytesReceived)
+
+	h, ok := c.durations[op]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(c.buckets))}
+		c.durations[op] = h
+	}
+	secs := m.Latency.Seconds()
+	for i, le := range c.buckets {
+		if secs <= le {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += secs
+	h.count++
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format, for mounting at /metrics
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.WriteTo(w)
+}
+
+// WriteTo writes the metrics in the Prometheus text format
+func (c *Collector) WriteTo(w io.Writer) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	cw := &countingWriter{w: bufio.NewWriter(w)}
+	ns := c.namespace
+
+	fmt.Fprintf(cw, "# HELP %s_requests_total API requests by operation and response status.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_requests_total counter\n", ns)
+	keys := make([]requestKey, 0, len(c.requests))
+	for k := range c.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].operation != keys[j].operation {
+			return keys[i].operation < keys[j].operation
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		fmt.Fprintf(cw, "%s_requests_total{operation=%s,code=%s} %d\n", ns, quote(k.operation), quote(k.code), c.requests[k])
+	}
+
+	ops := make([]string, 0, len(c.durations))
+	for op := range c.durations {
+		ops = append(ops, op)
+	}
+	sort.Strings(ops)
+
+	fmt.Fprintf(cw, "# HELP %s_request_duration_seconds API request latency including retries.\n", ns)
+	fmt.Fprintf(cw, "# TYPE %s_request_duration_seconds histogram\n", ns)
+	for _, op := range ops {
+		h := c.durations[op]
+		var cumulative uint64
+		for i, le := range c.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(cw, "%s_request_duration_seconds_bucket{operation=%s,le=\"%s\"} %d\n", ns, quote(op), formatFloat(le), cumulative)
+		}
+		fmt.Fprintf(cw, "%s_request_duration_seconds_bucket{operation=%s,le=\"+Inf\"} %d\n", ns, quote(op), h.count)
+		fmt.Fprintf(cw, "%s_request_duration_seconds_sum{operation=%s} %s\n", ns, quote(op), formatFloat(h.sum))
+		fmt.Fprintf(cw, "%s_request_duration_seconds_count{operation=%s} %d\n", ns, quote(op), h.count)
+	}
+
+	writeCounter(cw, ns+"_request_retries_total", "API request retries.", ops, c.retries)
+	writeCounter(cw, ns+"_request_sent_bytes_total", "API request body bytes sent.", ops, c.sent)
+	writeCounter(cw, ns+"_response_received_bytes_total", "API response body bytes received.", ops, c.received)
+
+	err := cw.w.(*bufio.Writer).Flush()
+	if err == nil {
+		err = cw.err
+	}
+	return cw.n, err
+}
+
+func writeCounter(w io.Writer, name, help string, ops []string, values map[string]uint64) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s counter\n", name)
+	for _, op := range ops {
+		fmt.Fprintf(w, "%s{operation=%s} %d\n", name, quote(op), values[op])
+	}
+}
+
+// quote escapes a label value as the text format requires
+func quote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+	return `"` + r.Replace(s) + `"`
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+type countingWriter struct {
+	w   io.Writer
+	n   int64
+	err error
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	if err != nil && cw.err == nil {
+		cw.err = err
+	}
+	return n, err
+}
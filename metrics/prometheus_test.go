@@ -0,0 +1,95 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"io/ioutil"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// golden compares got with testdata/name, rewriting the file when -update is set
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := ioutil.WriteFile(path, got, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s mismatch\ngot:\n%s\nwant:\n%s", name, got, want)
+	}
+}
+
+// observations covers every label and bucket case the collector handles
+var observations = []trustar.RequestMetrics{
+	{Operation: "GetReports", Method: "GET", StatusCode: 200, Latency: 30 * time.Millisecond, BytesReceived: 1024},
+	{Operation: "GetReports", Method: "GET", StatusCode: 200, Latency: 750 * time.Millisecond, Retries: 2, BytesReceived: 512},
+	{Operation: "GetReports", Method: "GET", StatusCode: 429, Latency: 2 * time.Second, Retries: 2, BytesReceived: 20},
+	{Operation: "SubmitReport", Method: "POST", StatusCode: 200, Latency: 100 * time.Millisecond, BytesSent: 300, BytesReceived: 40},
+	{Operation: "SubmitReport", Method: "POST", Err: errors.New("refused"), Latency: 90 * time.Second, BytesSent: 300},
+	{Method: "GET", StatusCode: 404, Latency: time.Millisecond},
+	{Operation: "quote\"back\\slash", Method: "GET", StatusCode: 200, Latency: 10 * time.Millisecond},
+}
+
+func TestCollector(t *testing.T) {
+	c := NewCollector("")
+	for _, m := range observations {
+		c.ObserveRequest(m)
+	}
+
+	var b bytes.Buffer
+	n, err := c.WriteTo(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(b.Len()) {
+		t.Errorf("WriteTo returned %d, wrote %d bytes", n, b.Len())
+	}
+	golden(t, "collector.prom", b.Bytes())
+}
+
+func TestCollectorBuckets(t *testing.T) {
+	c := NewCollector("app", 1, 0.5)
+	c.ObserveRequest(trustar.RequestMetrics{Operation: "GetEnclaves", StatusCode: 200, Latency: 200 * time.Millisecond})
+	c.ObserveRequest(trustar.RequestMetrics{Operation: "GetEnclaves", StatusCode: 200, Latency: 800 * time.Millisecond})
+
+	var b bytes.Buffer
+	c.WriteTo(&b)
+	golden(t, "buckets.prom", b.Bytes())
+}
+
+func TestCollectorEmpty(t *testing.T) {
+	var b bytes.Buffer
+	NewCollector("").WriteTo(&b)
+	golden(t, "empty.prom", b.Bytes())
+}
+
+func TestCollectorServeHTTP(t *testing.T) {
+	c := NewCollector("")
+	c.ObserveRequest(observations[0])
+
+	rec := httptest.NewRecorder()
+	c.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+
+	if ct := rec.Header().Get("Content-Type"); ct != "text/plain; version=0.0.4; charset=utf-8" {
+		t.Errorf("got Content-Type %q", ct)
+	}
+	if !bytes.Contains(rec.Body.Bytes(), []byte(`trustar_requests_total{operation="GetReports",code="200"} 1`)) {
+		t.Errorf("got\n%s", rec.Body.String())
+	}
+}
@@ -0,0 +1,113 @@
+package trustar
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recorder is a Metrics keeping every observation
+type recorder struct {
+	mu  sync.Mutex
+	got []RequestMetrics
+}
+
+func (r *recorder) ObserveRequest(m RequestMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.got = append(r.got, m)
+}
+
+func TestInstrument(t *testing.T) {
+	srv := &statusServer{statuses: []int{503}}
+	c, done := newTestClient(t, srv.ServeHTTP)
+	defer done()
+
+	m := &recorder{}
+	c.Use(Instrument(m), Retry(RetryPolicy{InitialBackoff: time.Millisecond}))
+
+	err := c.SubmitIndicators(IndicatorSubmission{EnclaveIDS: []string{"e1"}, Content: []IndicatorContent{{Value: "evil.com"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.got) != 1 {
+		t.Fatalf("got %d observations, want 1", len(m.got))
+	}
+	rm := m.got[0]
+	if rm.Operation != "SubmitIndicators" || rm.Method != "POST" || rm.StatusCode != 200 || rm.Err != nil {
+		t.Errorf("got %+v", rm)
+	}
+	if rm.Retries != 1 {
+		t.Errorf("got %d retries, want 1", rm.Retries)
+	}
+	if rm.BytesSent != int64(len(srv.bodies[1])) {
+		t.Errorf("got %d bytes sent, want %d", rm.BytesSent, len(srv.bodies[1]))
+	}
+	if rm.BytesReceived != int64(len("{}")) {
+		t.Errorf("got %d bytes received, want 2", rm.BytesReceived)
+	}
+	if rm.Latency <= 0 {
+		t.Errorf("got latency %v", rm.Latency)
+	}
+
+	// an error status is reported with its code
+	m.got = nil
+	srv.statuses, srv.bodies = []int{404}, nil
+	c.GetReportDetails("r1")
+	if len(m.got) != 1 || m.got[0].StatusCode != 404 || m.got[0].Operation != "GetReportDetails" || m.got[0].Retries != 0 {
+		t.Errorf("got %+v", m.got)
+	}
+}
+
+func TestInstrumentHandler(t *testing.T) {
+	refused := errors.New("refused")
+
+	// transport errors are reported at once, without a status
+	m := &recorder{}
+	h := Instrument(m)(func(req *http.Request) (*http.Response, error) {
+		return nil, refused
+	})
+	req, _ := http.NewRequest("GET", "http://example.invalid/", nil)
+	if _, err := h(WithOperation(req, "GetEnclaves")); err != refused {
+		t.Errorf("got %v, want %v", err, refused)
+	}
+	if len(m.got) != 1 || m.got[0].Err != refused || m.got[0].StatusCode != 0 || m.got[0].Operation != "GetEnclaves" {
+		t.Errorf("got %+v", m.got)
+	}
+
+	// nested Instrument middlewares both count the retries below them
+	outer, inner := &recorder{}, &recorder{}
+	calls := 0
+	h = Instrument(outer)(Instrument(inner)(Retry(RetryPolicy{InitialBackoff: time.Millisecond})(func(req *http.Request) (*http.Response, error) {
+		calls++
+		status := http.StatusServiceUnavailable
+		if calls == 3 {
+			status = http.StatusOK
+		}
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader("hello"))}, nil
+	})))
+	req, _ = http.NewRequest("GET", "http://example.invalid/", nil)
+	resp, err := h(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// nothing is reported until the body is closed
+	if len(outer.got) != 0 {
+		t.Errorf("got %+v before the body was closed", outer.got)
+	}
+	ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body.Close()
+
+	for _, r := range []*recorder{outer, inner} {
+		if len(r.got) != 1 || r.got[0].Retries != 2 || r.got[0].BytesReceived != 5 {
+			t.Errorf("got %+v, want one observation with 2 retries and 5 bytes", r.got)
+		}
+	}
+}
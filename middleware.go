@@ -0,0 +1,249 @@
+package trustar
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"net/http"
+	"net/http/httputil"
+	"strconv"
+	"time"
+)
+
+// Handler sends an HTTP request and returns the response
+type Handler func(req *http.Request) (*http.Response, error)
+
+// Middleware wraps a Handler to inspect or modify requests and responses.
+// A middleware that does not call next must return a response or an error itself.
+type Middleware func(next Handler) Handler
+
+type operationKey struct{}
+
+// WithOperation returns a copy of req tagged with the name of the SDK method sending it, such as "GetReports"
+func WithOperation(req *http.Request, name string) *http.Request {
+	return req.WithContext(context.WithValue(req.Context(), operationKey{}, name))
+}
+
+// Operation returns the name of the SDK method that sent req, or an empty string if it was not tagged
+func Operation(req *http.Request) string {
+	name, _ := req.Context().Value(operationKey{}).(string)
+	return name
+}
+
+// Use appends middleware to the Client's chain
+func (c *Client) Use(m ...Middleware) {
+	c.Middleware = append(c.Middleware, m...)
+}
+
+// newRequest creates a request tagged with the operation name
+func (c *Client) newRequest(operation, method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	return WithOperation(req, operation), nil
+}
+
+// do sends req through the middleware chain, inside a span for the call.
+// DefaultHeaders is always the outermost middleware, then come c.Middleware, the SetLog dump and
+// dry-run mode, so the log and a dry run show requests exactly as every middleware left them.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	h := c.dryRun(c.Client.Do)
+	if c.Log != nil {
+		h = DumpLog(c.Log)(h)
+	}
+	for i := len(c.Middleware) - 1; i >= 0; i-- {
+		h = c.Middleware[i](h)
+	}
+	h = DefaultHeaders(h)
+
+	ctx, span := c.startSpan(req.Context(), "trustar."+Operation(req), requestAttributes(req)...)
+	resp, err := h(req.WithContext(ctx))
+	endSpan(span, resp, err)
+
+	return resp, err
+}
+
+// DefaultHeaders sets the headers every API request carries, see https://docs.trustar.co/api/index.html#headers.
+// A Content-Type already set on the request is kept.
+func DefaultHeaders(next Handler) Handler {
+	return func(req *http.Request) (*http.Response, error) {
+		req.Header.Set("Client-Type", "API")
+		req.Header.Set("Client-Version", "v0.1.0")
+		req.Header.Set("Client-Metatag", "github.com/jakewarren/trustar-golang")
+
+		req.Header.Set("Accept", "application/json")
+		req.Header.Set("Accept-Language", "en_US")
+
+		if req.Header.Get("Content-type") == "" {
+			req.Header.Set("Content-type", "application/json")
+		}
+
+		return next(req)
+	}
+}
+
+// DumpLog returns a middleware writing the full request and response to w, as SetLog does
+func DumpLog(w io.Writer) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			reqDump, _ := httputil.DumpRequest(req, true)
+
+			resp, err := next(req)
+
+			var respDump []byte
+			if resp != nil {
+				respDump, _ = httputil.DumpResponse(resp, true)
+			}
+
+			_, _ = w.Write([]byte(fmt.Sprintf("Request: %s\nResponse: %s\n", string(reqDump), string(respDump))))
+			return resp, err
+		}
+	}
+}
+
+// Logging returns a middleware writing one line per request to w, with the operation, status and duration
+func Logging(w io.Writer) Middleware {
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			start := time.Now()
+			resp, err := next(req)
+			elapsed := time.Since(start).Round(time.Millisecond)
+
+			op := Operation(req)
+			if op == "" {
+				op = "-"
+			}
+
+			if err != nil {
+				fmt.Fprintf(w, "%s %s %s error: %v (%s)\n", op, req.Method, req.URL, err, elapsed)
+			} else {
+				fmt.Fprintf(w, "%s %s %s %d (%s)\n", op, req.Method, req.URL, resp.StatusCode, elapsed)
+			}
+			return resp, err
+		}
+	}
+}
+
+// RetryPolicy controls the Retry middleware
+type RetryPolicy struct {
+	MaxAttempts    int           // total attempts including the first, defaults to 3
+	InitialBackoff time.Duration // wait before the first retry, doubled for each further retry, defaults to one second
+	MaxBackoff     time.Duration // longest wait between attempts, defaults to 30 seconds
+
+	// MaxRetryAfter is the longest Retry-After the server may ask for. Longer waits are not retried
+	// and the response is returned instead. Defaults to five minutes.
+	MaxRetryAfter time.Duration
+
+	// ShouldRetry decides whether an attempt is retried. By default requests are retried on 429 and 503 responses,
+	// and GET, PUT and DELETE requests also on network errors and 500, 502 and 504 responses.
+	ShouldRetry func(req *http.Request, resp *http.Response, err error) bool
+}
+
+// Retry returns a middleware resending failed requests with exponential backoff.
+// A Retry-After header on the response is honored up to MaxRetryAfter. Requests whose body cannot be replayed are not retried.
+func Retry(policy RetryPolicy) Middleware {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = 3
+	}
+	if policy.InitialBackoff <= 0 {
+		policy.InitialBackoff = time.Second
+	}
+	if policy.MaxBackoff <= 0 {
+		policy.MaxBackoff = 30 * time.Second
+	}
+	if policy.MaxRetryAfter <= 0 {
+		policy.MaxRetryAfter = 5 * time.Minute
+	}
+	if policy.ShouldRetry == nil {
+		policy.ShouldRetry = defaultShouldRetry
+	}
+
+	return func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			backoff := policy.InitialBackoff
+
+			for attempt := 1; ; attempt++ {
+				attemptReq, span := startChildSpan(req, "trustar.Attempt", Attr("trustar.retry.attempt", attempt))
+				resp, err := next(attemptReq)
+				endSpan(span, resp, err)
+
+				if attempt >= policy.MaxAttempts || !policy.ShouldRetry(req, resp, err) {
+					return resp, err
+				}
+				if req.Body != nil && req.GetBody == nil {
+					return resp, err
+				}
+
+				wait := backoff + time.Duration(rand.Int63n(int64(backoff)/2+1))
+				if wait > policy.MaxBackoff {
+					wait = policy.MaxBackoff
+				}
+				if resp != nil {
+					// retrying before the server asked would only fail again
+					if after := retryAfter(resp); after > policy.MaxRetryAfter {
+						return resp, err
+					} else if after > 0 {
+						wait = after
+					}
+					io.Copy(ioutil.Discard, resp.Body)
+					resp.Body.Close()
+				}
+
+				select {
+				case <-req.Context().Done():
+					return nil, req.Context().Err()
+				case <-time.After(wait):
+				}
+
+				countRetry(req)
+
+				if req.GetBody != nil {
+					body, berr := req.GetBody()
+					if berr != nil {
+						return nil, berr
+					}
+					req = req.Clone(req.Context())
+					req.Body = body
+				}
+
+				if backoff *= 2; backoff > policy.MaxBackoff {
+					backoff = policy.MaxBackoff
+				}
+			}
+		}
+	}
+}
+
+func defaultShouldRetry(req *http.Request, resp *http.Response, err error) bool {
+	idempotent := req.Method == "GET" || req.Method == "PUT" || req.Method == "DELETE"
+
+	if err != nil {
+		return idempotent && req.Context().Err() == nil
+	}
+
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+		return true
+	case http.StatusInternalServerError, http.StatusBadGateway, http.StatusGatewayTimeout:
+		return idempotent
+	}
+	return false
+}
+
+// retryAfter parses the Retry-After header as seconds or an HTTP date
+func retryAfter(resp *http.Response) time.Duration {
+	h := resp.Header.Get("Retry-After")
+	if h == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(h); err == nil {
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(h); err == nil {
+		return time.Until(t)
+	}
+	return 0
+}
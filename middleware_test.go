@@ -0,0 +1,394 @@
+package trustar
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// statusServer answers with the given statuses in turn, then 200, recording the body of each request
+type statusServer struct {
+	mu         sync.Mutex
+	statuses   []int
+	retryAfter string
+	bodies     []string
+}
+
+func (s *statusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, _ := ioutil.ReadAll(r.Body)
+	s.bodies = append(s.bodies, string(body))
+
+	status := http.StatusOK
+	if n := len(s.bodies); n <= len(s.statuses) {
+		status = s.statuses[n-1]
+	}
+	if s.retryAfter != "" {
+		w.Header().Set("Retry-After", s.retryAfter)
+	}
+	w.WriteHeader(status)
+	w.Write([]byte(`{}`))
+}
+
+func (s *statusServer) attempts() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.bodies)
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name        string
+		post        bool
+		statuses    []int
+		maxAttempts int
+		attempts    int
+		status      int // the status of the error returned, zero for success
+	}{
+		{name: "success", attempts: 1},
+		{name: "unavailable", statuses: []int{503, 503}, attempts: 3},
+		{name: "rate limited post", post: true, statuses: []int{429}, attempts: 2},
+		{name: "server error get", statuses: []int{500, 502, 504}, maxAttempts: 4, attempts: 4},
+		{name: "server error post", post: true, statuses: []int{500}, attempts: 1, status: 500},
+		{name: "client error", statuses: []int{400}, attempts: 1, status: 400},
+		{name: "gives up", statuses: []int{503, 503, 503}, attempts: 3, status: 503},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &statusServer{statuses: tt.statuses}
+			c, done := newTestClient(t, srv.ServeHTTP)
+			defer done()
+			c.Use(Retry(RetryPolicy{MaxAttempts: tt.maxAttempts, InitialBackoff: time.Millisecond}))
+
+			var err error
+			if tt.post {
+				err = c.SubmitIndicators(IndicatorSubmission{EnclaveIDS: []string{"e1"}, Content: []IndicatorContent{{Value: "evil.com"}}})
+			} else {
+				_, err = c.GetReports(url.Values{})
+			}
+
+			if tt.status == 0 && err != nil {
+				t.Errorf("got error %v", err)
+			}
+			if tt.status != 0 {
+				if e, ok := err.(*ErrorResponse); !ok || e.Response.StatusCode != tt.status {
+					t.Errorf("got error %v, want status %d", err, tt.status)
+				}
+			}
+			if srv.attempts() != tt.attempts {
+				t.Errorf("got %d attempts, want %d", srv.attempts(), tt.attempts)
+			}
+
+			// replayed bodies are sent in full
+			for _, body := range srv.bodies[1:] {
+				if body != srv.bodies[0] {
+					t.Errorf("got body %q on a retry, want %q", body, srv.bodies[0])
+				}
+			}
+			if tt.post && !strings.Contains(srv.bodies[0], "evil.com") {
+				t.Errorf("got body %q", srv.bodies[0])
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		name          string
+		retryAfter    string
+		maxRetryAfter time.Duration
+		attempts      int
+		minElapsed    time.Duration
+	}{
+		{name: "honored", retryAfter: "1", attempts: 2, minElapsed: time.Second},
+		{name: "too long", retryAfter: "120", maxRetryAfter: time.Minute, attempts: 1},
+		{name: "past date", retryAfter: time.Now().Add(-time.Hour).UTC().Format(http.TimeFormat), attempts: 2},
+		{name: "unparsable", retryAfter: "soon", attempts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &statusServer{statuses: []int{503}, retryAfter: tt.retryAfter}
+			c, done := newTestClient(t, srv.ServeHTTP)
+			defer done()
+			c.Use(Retry(RetryPolicy{InitialBackoff: time.Millisecond, MaxRetryAfter: tt.maxRetryAfter}))
+
+			start := time.Now()
+			_, err := c.GetReports(url.Values{})
+			if tt.attempts == 1 {
+				if e, ok := err.(*ErrorResponse); !ok || e.Response.StatusCode != 503 {
+					t.Errorf("got error %v, want the 503 returned", err)
+				}
+			} else if err != nil {
+				t.Errorf("got error %v", err)
+			}
+			if srv.attempts() != tt.attempts {
+				t.Errorf("got %d attempts, want %d", srv.attempts(), tt.attempts)
+			}
+			if elapsed := time.Since(start); elapsed < tt.minElapsed {
+				t.Errorf("got a retry after %v, want at least %v", elapsed, tt.minElapsed)
+			}
+		})
+	}
+}
+
+func TestRetryHandler(t *testing.T) {
+	netErr := errors.New("connection reset")
+
+	// network errors are retried for idempotent requests only
+	for _, method := range []string{"GET", "POST"} {
+		calls := 0
+		h := Retry(RetryPolicy{InitialBackoff: time.Millisecond})(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return nil, netErr
+		})
+		req, _ := http.NewRequest(method, "http://example.invalid/", nil)
+		if _, err := h(req); err != netErr {
+			t.Errorf("%s: got %v, want %v", method, err, netErr)
+		}
+		if want := map[string]int{"GET": 3, "POST": 1}[method]; calls != want {
+			t.Errorf("%s: got %d calls, want %d", method, calls, want)
+		}
+	}
+
+	// a body that cannot be replayed is sent once
+	calls := 0
+	h := Retry(RetryPolicy{InitialBackoff: time.Millisecond})(func(req *http.Request) (*http.Response, error) {
+		calls++
+		return &http.Response{StatusCode: 503, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+	req, _ := http.NewRequest("PUT", "http://example.invalid/", ioutil.NopCloser(strings.NewReader("once")))
+	if resp, err := h(req); err != nil || resp.StatusCode != 503 || calls != 1 {
+		t.Errorf("got %v, %v after %d calls, want one attempt", resp, err, calls)
+	}
+
+	// waiting for a retry stops with the context
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	h = Retry(RetryPolicy{InitialBackoff: time.Hour, MaxBackoff: time.Hour})(func(req *http.Request) (*http.Response, error) {
+		return nil, netErr
+	})
+	req, _ = http.NewRequest("GET", "http://example.invalid/", nil)
+	if _, err := h(req.WithContext(ctx)); err != context.DeadlineExceeded {
+		t.Errorf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// ShouldRetry replaces the default decision
+	calls = 0
+	h = Retry(RetryPolicy{
+		MaxAttempts:    5,
+		InitialBackoff: time.Millisecond,
+		ShouldRetry: func(req *http.Request, resp *http.Response, err error) bool {
+			return resp.StatusCode == http.StatusNotFound
+		},
+	})(func(req *http.Request) (*http.Response, error) {
+		calls++
+		status := http.StatusNotFound
+		if calls == 2 {
+			status = http.StatusTeapot
+		}
+		return &http.Response{StatusCode: status, Header: http.Header{}, Body: ioutil.NopCloser(strings.NewReader(""))}, nil
+	})
+	req, _ = http.NewRequest("POST", "http://example.invalid/", nil)
+	if resp, _ := h(req); resp.StatusCode != http.StatusTeapot || calls != 2 {
+		t.Errorf("got %d after %d calls, want 418 after 2", resp.StatusCode, calls)
+	}
+}
+
+func TestDefaultHeaders(t *testing.T) {
+	var headers []http.Header
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		headers = append(headers, r.Header)
+		w.Write([]byte("{}"))
+	})
+	defer done()
+
+	if _, err := c.GetReports(url.Values{}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"Client-Type":     "API",
+		"Client-Version":  "v0.1.0",
+		"Client-Metatag":  "github.com/jakewarren/trustar-golang",
+		"Accept":          "application/json",
+		"Accept-Language": "en_US",
+		"Content-Type":    "application/json",
+		"Authorization":   "Bearer token",
+	}
+	for k, v := range want {
+		if got := headers[0].Get(k); got != v {
+			t.Errorf("got %s %q, want %q", k, got, v)
+		}
+	}
+
+	// a Content-Type set by the request is kept
+	var got string
+	h := DefaultHeaders(func(req *http.Request) (*http.Response, error) {
+		got = req.Header.Get("Content-Type")
+		return nil, nil
+	})
+	req, _ := http.NewRequest("POST", "http://example.invalid/", nil)
+	req.Header.Set("Content-type", "application/x-www-form-urlencoded")
+	h(req)
+	if got != "application/x-www-form-urlencoded" {
+		t.Errorf("got Content-Type %q, want the request's kept", got)
+	}
+}
+
+func TestMiddlewareOrder(t *testing.T) {
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("[]"))
+	})
+	defer done()
+
+	var order []string
+	mark := func(name string) Middleware {
+		return func(next Handler) Handler {
+			return func(req *http.Request) (*http.Response, error) {
+				order = append(order, name+":"+Operation(req))
+				resp, err := next(req)
+				order = append(order, name+" done")
+				return resp, err
+			}
+		}
+	}
+	c.Use(mark("first"), mark("second"))
+
+	if _, err := c.GetEnclaves(); err != nil {
+		t.Fatal(err)
+	}
+	if want := "first:GetEnclaves second:GetEnclaves second done first done"; strings.Join(order, " ") != want {
+		t.Errorf("got %v, want %s", order, want)
+	}
+
+	req, _ := http.NewRequest("GET", "http://example.invalid/", nil)
+	if Operation(req) != "" || Operation(WithOperation(req, "Op")) != "Op" {
+		t.Error("got the wrong operation name")
+	}
+}
+
+func TestSetLog(t *testing.T) {
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"items":[]}`))
+	})
+	defer done()
+
+	var b bytes.Buffer
+	c.SetLog(&b)
+	c.SetLog(&b) // installing twice must not log twice
+
+	if _, err := c.GetReports(url.Values{"from": {"1"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	log := b.String()
+	if strings.Count(log, "Request: ") != 1 {
+		t.Errorf("got %d requests logged, want 1:\n%s", strings.Count(log, "Request: "), log)
+	}
+	for _, want := range []string{"Request: GET /reports?from=1 HTTP/1.1", "Authorization: Bearer token", "Response: HTTP/1.1 200 OK", `{"items":[]}`} {
+		if !strings.Contains(log, want) {
+			t.Errorf("got log\n%s\nwant it to contain %q", log, want)
+		}
+	}
+
+	c.SetLog(nil)
+	b.Reset()
+	c.GetReports(url.Values{})
+	if b.Len() != 0 {
+		t.Errorf("got %q logged after SetLog(nil)", b.String())
+	}
+}
+
+func TestSetLogInsideMiddleware(t *testing.T) {
+	srv := &statusServer{statuses: []int{503}}
+	c, done := newTestClient(t, srv.ServeHTTP)
+	defer done()
+
+	// middleware added after SetLog still runs before the dump
+	var b bytes.Buffer
+	c.SetLog(&b)
+	c.Use(func(next Handler) Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			req.Header.Set("X-Added", "later")
+			return next(req)
+		}
+	}, Retry(RetryPolicy{InitialBackoff: time.Millisecond}))
+
+	if _, err := c.GetReports(url.Values{}); err != nil {
+		t.Fatal(err)
+	}
+
+	log := b.String()
+	if n := strings.Count(log, "Request: "); n != 2 {
+		t.Errorf("got %d requests logged, want one per attempt:\n%s", n, log)
+	}
+	if n := strings.Count(log, "X-Added: later"); n != 2 {
+		t.Errorf("got the later middleware's header logged %d times, want 2:\n%s", n, log)
+	}
+	if !strings.Contains(log, "Response: HTTP/1.1 503 Service Unavailable") {
+		t.Errorf("got log without the retried response:\n%s", log)
+	}
+}
+
+func TestLogging(t *testing.T) {
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "missing", http.StatusNotFound)
+	})
+	defer done()
+
+	var b bytes.Buffer
+	c.Use(Logging(&b))
+	c.GetReportDetails("r1")
+
+	line := regexp.MustCompile(`^GetReportDetails GET http://127\.0\.0\.1:\d+/reports/r1 404 \(\d+m?s\)\n$`)
+	if !line.MatchString(b.String()) {
+		t.Errorf("got %q", b.String())
+	}
+
+	b.Reset()
+	h := Logging(&b)(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("refused")
+	})
+	req, _ := http.NewRequest("GET", "http://example.invalid/x", nil)
+	h(req)
+	if !strings.HasPrefix(b.String(), "- GET http://example.invalid/x error: refused (") {
+		t.Errorf("got %q", b.String())
+	}
+}
+
+func TestRetryAfterParsing(t *testing.T) {
+	tests := []struct {
+		header string
+		want   time.Duration
+	}{
+		{"", 0},
+		{"5", 5 * time.Second},
+		{"soon", 0},
+	}
+	for _, tt := range tests {
+		resp := &http.Response{Header: http.Header{}}
+		if tt.header != "" {
+			resp.Header.Set("Retry-After", tt.header)
+		}
+		if got := retryAfter(resp); got != tt.want {
+			t.Errorf("retryAfter(%q) = %v, want %v", tt.header, got, tt.want)
+		}
+	}
+
+	resp := &http.Response{Header: http.Header{"Retry-After": {time.Now().Add(time.Minute).UTC().Format(http.TimeFormat)}}}
+	if got := retryAfter(resp); got < 58*time.Second || got > time.Minute {
+		t.Errorf("got %v, want about a minute", got)
+	}
+}
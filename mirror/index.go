@@ -0,0 +1,183 @@
+package mirror
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// titleWeight is how much more a term in the title counts than a term in the body
+const titleWeight = 3
+
+// Index is a positional inverted index over the titles and bodies of mirrored reports.
+// It is safe for concurrent use.
+type Index struct {
+	mu       sync.RWMutex
+	postings map[string]map[string][]int // term -> report ID -> token positions
+	terms    map[string][]string         // report ID -> distinct terms, so a report is removed without scanning postings
+	titleLen map[string]int              // report ID -> number of title tokens
+	docLen   map[string]int              // report ID -> number of tokens
+}
+
+// NewIndex returns an empty Index
+func NewIndex() *Index {
+	return &Index{
+		postings: map[string]map[string][]int{},
+		terms:    map[string][]string{},
+		titleLen: map[string]int{},
+		docLen:   map[string]int{},
+	}
+}
+
+// Add indexes a record, replacing any previous version of the same report
+func (ix *Index) Add(rec Record) {
+	id := rec.Report.ID
+
+	title := tokenize(rec.Report.Title)
+	body := tokenize(rec.Report.ReportBody)
+
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+
+	// body positions start after a gap so phrases never span the title and body
+	for pos, term := range title {
+		ix.addPosting(term, id, pos)
+	}
+	for pos, term := range body {
+		ix.addPosting(term, id, len(title)+1+pos)
+	}
+
+	ix.titleLen[id] = len(title)
+	ix.docLen[id] = len(title) + len(body)
+}
+
+// Remove drops a report from the index
+func (ix *Index) Remove(id string) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+
+	ix.remove(id)
+}
+
+// Len returns the number of indexed reports
+func (ix *Index) Len() int {
+	ix.mu.RLock()
+	defer ix.mu.RUnlock()
+
+	return len(ix.docLen)
+}
+
+func (ix *Index) addPosting(term, id string, pos int) {
+	docs, ok := ix.postings[term]
+	if !ok {
+		docs = map[string][]int{}
+		ix.postings[term] = docs
+	}
+	if _, ok := docs[id]; !ok {
+		ix.terms[id] = append(ix.terms[id], term)
+	}
+	docs[id] = append(docs[id], pos)
+}
+
+func (ix *Index) remove(id string) {
+	if _, ok := ix.docLen[id]; !ok {
+		return
+	}
+
+	for _, term := range ix.terms[id] {
+		docs := ix.postings[term]
+		delete(docs, id)
+		if len(docs) == 0 {
+			delete(ix.postings, term)
+		}
+	}
+
+	delete(ix.terms, id)
+	delete(ix.titleLen, id)
+	delete(ix.docLen, id)
+}
+
+// matchPhrase scores the reports containing the terms in sequence. A single term matches anywhere.
+func (ix *Index) matchPhrase(terms []string) map[string]float64 {
+	if len(terms) == 0 {
+		return map[string]float64{}
+	}
+
+	first := ix.postings[terms[0]]
+	scores := map[string]float64{}
+
+	for id, positions := range first {
+		var titleHits, bodyHits int
+
+	next:
+		for _, start := range positions {
+			for k := 1; k < len(terms); k++ {
+				if !containsInt(ix.postings[terms[k]][id], start+k) {
+					continue next
+				}
+			}
+			if start < ix.titleLen[id] {
+				titleHits++
+			} else {
+				bodyHits++
+			}
+		}
+
+		if titleHits+bodyHits > 0 {
+			scores[id] = float64(titleWeight*titleHits + bodyHits)
+		}
+	}
+
+	// weight by rarity and dampen long reports so a single mention in a short report ranks well
+	idf := math.Log(1 + float64(len(ix.docLen))/float64(len(scores)+1))
+	for id, tf := range scores {
+		scores[id] = (1 + math.Log(tf)) * idf / math.Sqrt(float64(ix.docLen[id]+1))
+	}
+
+	return scores
+}
+
+// all returns every indexed report with a zero score
+func (ix *Index) all() map[string]float64 {
+	docs := make(map[string]float64, len(ix.docLen))
+	for id := range ix.docLen {
+		docs[id] = 0
+	}
+	return docs
+}
+
+// tokenize splits text into lowercase terms. Dots, dashes, underscores and @ are kept inside terms so
+// indicator values such as IP addresses, domains and email addresses stay searchable as a single term.
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !strings.ContainsRune(".-_@", r)
+	})
+
+	terms := fields[:0]
+	for _, f := range fields {
+		if f = strings.Trim(f, ".-_@"); f != "" {
+			terms = append(terms, f)
+		}
+	}
+	return terms
+}
+
+func containsInt(sorted []int, v int) bool {
+	// positions are appended in increasing order
+	lo, hi := 0, len(sorted)
+	for lo < hi {
+		mid := (lo + hi) / 2
+		switch {
+		case sorted[mid] == v:
+			return true
+		case sorted[mid] < v:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+	return false
+}
@@ -0,0 +1,296 @@
+package mirror
+
+import (
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// defaultPageSize matches the page size returned by the API when none is requested
+const defaultPageSize = 25
+
+// GetReports returns a page of mirrored reports matching the same filters as Client.GetReports:
+// from, to, enclaveIds, distributionType, tags and excludedTags. Reports are ordered newest first
+// and paged with pageNumber and pageSize.
+func (s *Store) GetReports(v url.Values) (trustar.ReportResponse, error) {
+	f, err := parseFilter(v)
+	if err != nil {
+		return trustar.ReportResponse{}, err
+	}
+
+	var reports []trustar.ReportDetails
+	s.Each(func(rec Record) bool {
+		if f.match(rec) {
+			reports = append(reports, rec.Report)
+		}
+		return true
+	})
+
+	sortReports(reports)
+
+	return pageReports(reports, v)
+}
+
+// GetReportDetails returns a mirrored report. Pass trustar.IDTypeExternal to look the report up by its external ID.
+func (s *Store) GetReportDetails(id string, idType ...trustar.IDType) (trustar.ReportDetails, error) {
+	rec, err := s.lookup(id, idType)
+	return rec.Report, err
+}
+
+// GetReportIndicators returns a page of the indicators of a mirrored report
+func (s *Store) GetReportIndicators(id string, v url.Values, idType ...trustar.IDType) (trustar.ReportIndicatorsResponse, error) {
+	rec, err := s.lookup(id, idType)
+	if err != nil {
+		return trustar.ReportIndicatorsResponse{}, err
+	}
+
+	pageNumber, pageSize, err := pageParams(v)
+	if err != nil {
+		return trustar.ReportIndicatorsResponse{}, err
+	}
+
+	start, end, hasNext := pageBounds(len(rec.Indicators), pageNumber, pageSize)
+
+	return trustar.ReportIndicatorsResponse{
+		Empty:      end == start,
+		HasNext:    hasNext,
+		Items:      append([]trustar.Indicator(nil), rec.Indicators[start:end]...),
+		PageNumber: int64(pageNumber),
+		PageSize:   int64(pageSize),
+	}, nil
+}
+
+// GetReportTags returns the tags of a mirrored report
+func (s *Store) GetReportTags(id string, idType ...trustar.IDType) ([]trustar.IndicatorTag, error) {
+	rec, err := s.lookup(id, idType)
+	return append([]trustar.IndicatorTag(nil), rec.Tags...), err
+}
+
+// FindCorrelatedReports returns a page of mirrored reports containing any of the values of the
+// indicators parameter, filtered by enclaveIds
+func (s *Store) FindCorrelatedReports(v url.Values) (trustar.CorrelatedReportResponse, error) {
+	values := map[string]bool{}
+	for _, list := range v["indicators"] {
+		for _, i := range strings.Split(list, ",") {
+			if i = strings.TrimSpace(i); i != "" {
+				values[strings.ToLower(i)] = true
+			}
+		}
+	}
+
+	enclaves := listParam(v, "enclaveIds")
+
+	var reports []trustar.ReportDetails
+	s.Each(func(rec Record) bool {
+		if len(enclaves) > 0 && !anyIn(rec.Report.EnclaveIds, enclaves) {
+			return true
+		}
+		for _, i := range rec.Indicators {
+			if values[strings.ToLower(i.Value)] {
+				reports = append(reports, rec.Report)
+				break
+			}
+		}
+		return true
+	})
+
+	sortReports(reports)
+
+	rr, err := pageReports(reports, v)
+	return trustar.CorrelatedReportResponse{
+		Empty:      rr.Empty,
+		HasNext:    rr.HasNext,
+		Items:      rr.Reports,
+		PageNumber: rr.PageNumber,
+		PageSize:   rr.PageSize,
+	}, err
+}
+
+// lookup finds a record by internal or external ID
+func (s *Store) lookup(id string, idType []trustar.IDType) (Record, error) {
+	if len(idType) == 0 || idType[0] != trustar.IDTypeExternal {
+		if rec, ok := s.Get(id); ok {
+			return rec, nil
+		}
+		return Record{}, ErrNotFound
+	}
+
+	var found *Record
+	s.Each(func(rec Record) bool {
+		if rec.Report.ExternalID == id {
+			found = &rec
+			return false
+		}
+		return true
+	})
+
+	if found == nil {
+		return Record{}, ErrNotFound
+	}
+	return *found, nil
+}
+
+// filter holds the report filters shared by the query methods
+type filter struct {
+	from, to     int64
+	enclaves     map[string]bool
+	distribution string
+	tags         map[string]bool
+	excludedTags map[string]bool
+}
+
+func parseFilter(v url.Values) (filter, error) {
+	f := filter{
+		distribution: v.Get("distributionType"),
+		enclaves:     listParam(v, "enclaveIds"),
+		tags:         listParam(v, "tags"),
+		excludedTags: listParam(v, "excludedTags"),
+	}
+
+	var err error
+	if s := v.Get("from"); s != "" {
+		if f.from, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return f, err
+		}
+	}
+	if s := v.Get("to"); s != "" {
+		if f.to, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return f, err
+		}
+	}
+
+	return f, nil
+}
+
+func (f filter) match(rec Record) bool {
+	r := rec.Report
+
+	if f.from > 0 && r.Updated < f.from {
+		return false
+	}
+	if f.to > 0 && r.Updated > f.to {
+		return false
+	}
+	if f.distribution != "" && !strings.EqualFold(r.DistributionType, f.distribution) {
+		return false
+	}
+	if len(f.enclaves) > 0 && !anyIn(r.EnclaveIds, f.enclaves) {
+		return false
+	}
+
+	names := make([]string, len(rec.Tags))
+	for i, t := range rec.Tags {
+		names[i] = t.Name
+	}
+	if len(f.tags) > 0 && !allIn(f.tags, names) {
+		return false
+	}
+	if len(f.excludedTags) > 0 && anyIn(names, f.excludedTags) {
+		return false
+	}
+
+	return true
+}
+
+func sortReports(reports []trustar.ReportDetails) {
+	sort.Slice(reports, func(i, j int) bool {
+		if reports[i].Updated != reports[j].Updated {
+			return reports[i].Updated > reports[j].Updated
+		}
+		return reports[i].ID < reports[j].ID
+	})
+}
+
+func pageReports(reports []trustar.ReportDetails, v url.Values) (trustar.ReportResponse, error) {
+	pageNumber, pageSize, err := pageParams(v)
+	if err != nil {
+		return trustar.ReportResponse{}, err
+	}
+
+	start, end, hasNext := pageBounds(len(reports), pageNumber, pageSize)
+
+	return trustar.ReportResponse{
+		Empty:      end == start,
+		HasNext:    hasNext,
+		Reports:    reports[start:end],
+		PageNumber: int64(pageNumber),
+		PageSize:   int64(pageSize),
+	}, nil
+}
+
+func pageParams(v url.Values) (int, int, error) {
+	pageNumber, pageSize := 0, defaultPageSize
+
+	var err error
+	if s := v.Get("pageNumber"); s != "" {
+		if pageNumber, err = strconv.Atoi(s); err != nil {
+			return 0, 0, err
+		}
+	}
+	if s := v.Get("pageSize"); s != "" {
+		if pageSize, err = strconv.Atoi(s); err != nil {
+			return 0, 0, err
+		}
+	}
+	if pageNumber < 0 {
+		pageNumber = 0
+	}
+	if pageSize <= 0 {
+		pageSize = defaultPageSize
+	}
+
+	return pageNumber, pageSize, nil
+}
+
+func pageBounds(n, pageNumber, pageSize int) (int, int, bool) {
+	start := pageNumber * pageSize
+	if start > n {
+		start = n
+	}
+	end := start + pageSize
+	if end > n {
+		end = n
+	}
+	return start, end, end < n
+}
+
+// listParam collects the comma separated values of a query parameter
+func listParam(v url.Values, key string) map[string]bool {
+	set := map[string]bool{}
+	for _, list := range v[key] {
+		for _, item := range strings.Split(list, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				set[item] = true
+			}
+		}
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	return set
+}
+
+func anyIn(items []string, set map[string]bool) bool {
+	for _, i := range items {
+		if set[i] {
+			return true
+		}
+	}
+	return false
+}
+
+func allIn(set map[string]bool, items []string) bool {
+	have := map[string]bool{}
+	for _, i := range items {
+		have[i] = true
+	}
+	for i := range set {
+		if !have[i] {
+			return false
+		}
+	}
+	return true
+}
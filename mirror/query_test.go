@@ -0,0 +1,119 @@
+package mirror
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+func queryStore(t *testing.T) (*Store, func()) {
+	s, done := tempStore(t)
+
+	s.Put(Record{
+		Report:     trustar.ReportDetails{ID: "a", ExternalID: "INC-1", EnclaveIds: []string{"e1"}, DistributionType: "ENCLAVE", Updated: 30},
+		Indicators: []trustar.Indicator{{Value: "1.2.3.4"}, {Value: "Evil.com"}, {Value: "x@y.z"}},
+		Tags:       []trustar.IndicatorTag{{Name: "phish"}, {Name: "apt"}},
+	})
+	s.Put(Record{
+		Report:     trustar.ReportDetails{ID: "b", EnclaveIds: []string{"e2"}, DistributionType: "COMMUNITY", Updated: 20},
+		Indicators: []trustar.Indicator{{Value: "evil.com"}},
+		Tags:       []trustar.IndicatorTag{{Name: "phish"}},
+	})
+	s.Put(Record{Report: trustar.ReportDetails{ID: "c", EnclaveIds: []string{"e1", "e2"}, Updated: 20}})
+
+	return s, done
+}
+
+func ids(reports []trustar.ReportDetails) []string {
+	var out []string
+	for _, r := range reports {
+		out = append(out, r.ID)
+	}
+	return out
+}
+
+func TestGetReports(t *testing.T) {
+	s, done := queryStore(t)
+	defer done()
+
+	tests := []struct {
+		query   string
+		want    []string
+		hasNext bool
+	}{
+		{"", []string{"a", "b", "c"}, false},
+		{"from=25", []string{"a"}, false},
+		{"to=20", []string{"b", "c"}, false},
+		{"enclaveIds=e2", []string{"b", "c"}, false},
+		{"enclaveIds=e1,e2&distributionType=enclave", []string{"a"}, false},
+		{"tags=phish", []string{"a", "b"}, false},
+		{"tags=phish,apt", []string{"a"}, false},
+		{"excludedTags=apt", []string{"b", "c"}, false},
+		{"pageSize=2", []string{"a", "b"}, true},
+		{"pageSize=2&pageNumber=1", []string{"c"}, false},
+		{"pageSize=2&pageNumber=5", nil, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			v, _ := url.ParseQuery(tt.query)
+			rr, err := s.GetReports(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ids(rr.Reports); !reflect.DeepEqual(got, tt.want) || rr.HasNext != tt.hasNext {
+				t.Errorf("got %v, hasNext %v, want %v, %v", got, rr.HasNext, tt.want, tt.hasNext)
+			}
+		})
+	}
+
+	if _, err := s.GetReports(url.Values{"from": {"yesterday"}}); err == nil {
+		t.Error("want an error for a malformed from")
+	}
+}
+
+func TestStoreLookup(t *testing.T) {
+	s, done := queryStore(t)
+	defer done()
+
+	if rd, err := s.GetReportDetails("INC-1", trustar.IDTypeExternal); err != nil || rd.ID != "a" {
+		t.Errorf("got %+v, %v, want report a", rd, err)
+	}
+	if _, err := s.GetReportDetails("INC-1"); err != ErrNotFound {
+		t.Errorf("got error %v, want %v for an external ID looked up as internal", err, ErrNotFound)
+	}
+	if _, err := s.GetReportTags("missing"); err != ErrNotFound {
+		t.Errorf("got error %v, want %v", err, ErrNotFound)
+	}
+	if tags, err := s.GetReportTags("a"); err != nil || len(tags) != 2 {
+		t.Errorf("got tags %v, %v", tags, err)
+	}
+
+	rir, err := s.GetReportIndicators("a", url.Values{"pageSize": {"2"}, "pageNumber": {"1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rir.Items) != 1 || rir.Items[0].Value != "x@y.z" || rir.HasNext {
+		t.Errorf("got %+v, want the last indicator", rir)
+	}
+}
+
+func TestFindCorrelatedReports(t *testing.T) {
+	s, done := queryStore(t)
+	defer done()
+
+	crr, err := s.FindCorrelatedReports(url.Values{"indicators": {"EVIL.com, 9.9.9.9"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(crr.Items); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("got %v, want the reports containing evil.com", got)
+	}
+
+	crr, _ = s.FindCorrelatedReports(url.Values{"indicators": {"evil.com"}, "enclaveIds": {"e2"}})
+	if got := ids(crr.Items); !reflect.DeepEqual(got, []string{"b"}) {
+		t.Errorf("got %v, want the reports in e2", got)
+	}
+}
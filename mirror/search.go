@@ -0,0 +1,291 @@
+package mirror
+
+import (
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// SearchReports searches the titles and bodies of mirrored reports, accepting the same parameters as
+// Client.SearchReports: searchTerm, enclaveIds, from, to, tags, excludedTags, pageNumber and pageSize.
+//
+// The search term supports "quoted phrases", the AND, OR and NOT operators (AND is implied between
+// terms), a leading - as shorthand for NOT, and parentheses. Results are ranked by relevance, with
+// matches in the title counting more than matches in the body, then by updated time.
+func (s *Store) SearchReports(v url.Values) (trustar.ReportResponse, error) {
+	f, err := parseFilter(v)
+	if err != nil {
+		return trustar.ReportResponse{}, err
+	}
+
+	q, err := ParseQuery(v.Get("searchTerm"))
+	if err != nil {
+		return trustar.ReportResponse{}, err
+	}
+
+	ix := s.Index()
+	ix.mu.RLock()
+	scores := q.eval(ix)
+	ix.mu.RUnlock()
+
+	type hit struct {
+		report trustar.ReportDetails
+		score  float64
+	}
+
+	var hits []hit
+	for id, score := range scores {
+		rec, ok := s.Get(id)
+		if ok && f.match(rec) {
+			hits = append(hits, hit{rec.Report, score})
+		}
+	}
+
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].score != hits[j].score {
+			return hits[i].score > hits[j].score
+		}
+		if hits[i].report.Updated != hits[j].report.Updated {
+			return hits[i].report.Updated > hits[j].report.Updated
+		}
+		return hits[i].report.ID < hits[j].report.ID
+	})
+
+	reports := make([]trustar.ReportDetails, len(hits))
+	for i, h := range hits {
+		reports[i] = h.report
+	}
+
+	return pageReports(reports, v)
+}
+
+// Query is a parsed search term
+type Query struct {
+	root node
+}
+
+// ParseQuery parses a search term. An empty term matches every report.
+func ParseQuery(term string) (*Query, error) {
+	p := &parser{tokens: lexQuery(term)}
+	if len(p.tokens) == 0 {
+		return &Query{root: allNode{}}, nil
+	}
+
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q in search term", p.tokens[p.pos].text)
+	}
+
+	return &Query{root: root}, nil
+}
+
+func (q *Query) eval(ix *Index) map[string]float64 {
+	return q.root.eval(ix)
+}
+
+// node is an element of a parsed query
+type node interface {
+	eval(ix *Index) map[string]float64
+}
+
+type allNode struct{}
+
+func (allNode) eval(ix *Index) map[string]float64 { return ix.all() }
+
+type phraseNode struct {
+	terms []string
+}
+
+func (n phraseNode) eval(ix *Index) map[string]float64 { return ix.matchPhrase(n.terms) }
+
+type andNode struct {
+	left, right node
+}
+
+func (n andNode) eval(ix *Index) map[string]float64 {
+	left, right := n.left.eval(ix), n.right.eval(ix)
+	out := map[string]float64{}
+	for id, score := range left {
+		if other, ok := right[id]; ok {
+			out[id] = score + other
+		}
+	}
+	return out
+}
+
+type orNode struct {
+	left, right node
+}
+
+func (n orNode) eval(ix *Index) map[string]float64 {
+	out := n.left.eval(ix)
+	for id, score := range n.right.eval(ix) {
+		out[id] += score
+	}
+	return out
+}
+
+type notNode struct {
+	inner node
+}
+
+func (n notNode) eval(ix *Index) map[string]float64 {
+	excluded := n.inner.eval(ix)
+	out := map[string]float64{}
+	for id := range ix.docLen {
+		if _, ok := excluded[id]; !ok {
+			out[id] = 0
+		}
+	}
+	return out
+}
+
+// queryToken is a lexical element of a search term
+type queryToken struct {
+	kind string // "word", "phrase", "(", ")", "-"
+	text string
+}
+
+func lexQuery(term string) []queryToken {
+	var tokens []queryToken
+
+	for i := 0; i < len(term); {
+		c := term[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')':
+			tokens = append(tokens, queryToken{kind: string(c), text: string(c)})
+			i++
+		case c == '"':
+			end := strings.IndexByte(term[i+1:], '"')
+			if end < 0 {
+				end = len(term) - i - 1
+			}
+			tokens = append(tokens, queryToken{kind: "phrase", text: term[i+1 : i+1+end]})
+			i += end + 2
+		case c == '-' && (i == 0 || strings.ContainsRune(" \t(", rune(term[i-1]))):
+			tokens = append(tokens, queryToken{kind: "-", text: "-"})
+			i++
+		default:
+			end := strings.IndexAny(term[i:], " \t\n\r()\"")
+			if end < 0 {
+				end = len(term) - i
+			}
+			tokens = append(tokens, queryToken{kind: "word", text: term[i : i+end]})
+			i += end
+		}
+	}
+
+	return tokens
+}
+
+type parser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *parser) peek() (queryToken, bool) {
+	if p.pos >= len(p.tokens) {
+		return queryToken{}, false
+	}
+	return p.tokens[p.pos], true
+}
+
+func (p *parser) isOperator(op string) bool {
+	t, ok := p.peek()
+	return ok && t.kind == "word" && t.text == op
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isOperator("OR") {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == ")" || p.isOperator("OR") {
+			return left, nil
+		}
+		if p.isOperator("AND") {
+			p.pos++
+		}
+
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+}
+
+func (p *parser) parseUnary() (node, error) {
+	t, ok := p.peek()
+	if ok && (t.kind == "-" || (t.kind == "word" && t.text == "NOT")) {
+		p.pos++
+		inner, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+
+	return p.parsePrimary()
+}
+
+func (p *parser) parsePrimary() (node, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("search term ends unexpectedly")
+	}
+	p.pos++
+
+	switch t.kind {
+	case "(":
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if closing, ok := p.peek(); !ok || closing.kind != ")" {
+			return nil, fmt.Errorf("missing ) in search term")
+		}
+		p.pos++
+		return inner, nil
+	case ")":
+		return nil, fmt.Errorf("unexpected ) in search term")
+	}
+
+	terms := tokenize(t.text)
+	if len(terms) == 0 {
+		// punctuation only, matches everything so it does not change the result
+		return allNode{}, nil
+	}
+
+	// a word such as evil.com/path tokenizes to several terms and is matched as a phrase
+	return phraseNode{terms}, nil
+}
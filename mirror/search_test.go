@@ -0,0 +1,181 @@
+package mirror
+
+import (
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+func searchStore(t *testing.T) (*Store, func()) {
+	s, done := tempStore(t)
+
+	for _, r := range []trustar.ReportDetails{
+		{ID: "phish", Title: "Phishing campaign", ReportBody: "Emails from evil.com deliver a dropper to finance staff.", EnclaveIds: []string{"e1"}, Updated: 3},
+		{ID: "c2", Title: "Command and control", ReportBody: "The dropper beacons to 10.0.0.1 and evil.com over HTTPS.", EnclaveIds: []string{"e2"}, Updated: 2},
+		{ID: "ransom", Title: "Ransomware note", ReportBody: "Files encrypted; contact support@ransom.example for a key.", EnclaveIds: []string{"e1"}, Updated: 1},
+	} {
+		s.Put(Record{Report: r})
+	}
+
+	return s, done
+}
+
+func TestParseQuery(t *testing.T) {
+	tests := []struct {
+		term string
+		err  string
+	}{
+		{term: ""},
+		{term: "evil.com"},
+		{term: `"control dropper"`},
+		{term: "a AND b OR c"},
+		{term: "(a OR b) -c"},
+		{term: "NOT (a b)"},
+		{term: `"unterminated phrase`},
+		{term: "---"},
+		{term: "a OR", err: "search term ends unexpectedly"},
+		{term: "(a OR b", err: "missing ) in search term"},
+		{term: "a)", err: `unexpected ")" in search term`},
+		{term: ")", err: "unexpected ) in search term"},
+		{term: "NOT", err: "search term ends unexpectedly"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term, func(t *testing.T) {
+			q, err := ParseQuery(tt.term)
+			if tt.err == "" {
+				if err != nil || q == nil {
+					t.Errorf("got %v, %v, want a query", q, err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.err {
+				t.Errorf("got error %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestSearchReports(t *testing.T) {
+	s, done := searchStore(t)
+	defer done()
+
+	tests := []struct {
+		term   string
+		query  string
+		want   []string
+		sorted bool // compare ignoring rank
+	}{
+		{term: "", want: []string{"phish", "c2", "ransom"}},
+		{term: "dropper", want: []string{"c2", "phish"}, sorted: true},
+		{term: "EVIL.COM", want: []string{"c2", "phish"}, sorted: true},
+		{term: "10.0.0.1", want: []string{"c2"}},
+		{term: "support@ransom.example", want: []string{"ransom"}},
+		{term: `"dropper beacons"`, want: []string{"c2"}},
+		{term: `"beacons dropper"`, want: nil},
+		{term: "dropper finance", want: []string{"phish"}},
+		{term: "dropper AND finance", want: []string{"phish"}},
+		{term: "finance OR ransomware", want: []string{"phish", "ransom"}, sorted: true},
+		{term: "dropper -finance", want: []string{"c2"}},
+		{term: "dropper NOT finance", want: []string{"c2"}},
+		{term: "NOT dropper", want: []string{"ransom"}},
+		{term: "(finance OR beacons) evil.com", want: []string{"c2", "phish"}, sorted: true},
+		{term: "evil.com/path", want: nil},
+		{term: "dropper", query: "enclaveIds=e1", want: []string{"phish"}},
+		{term: "", query: "pageSize=1&pageNumber=1", want: []string{"c2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.term+" "+tt.query, func(t *testing.T) {
+			v, _ := url.ParseQuery(tt.query)
+			v.Set("searchTerm", tt.term)
+
+			rr, err := s.SearchReports(v)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := ids(rr.Reports)
+			if tt.sorted {
+				sort.Strings(got)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := s.SearchReports(url.Values{"searchTerm": {"(a"}}); err == nil {
+		t.Error("want the parse error")
+	}
+}
+
+func TestSearchRanking(t *testing.T) {
+	s, done := tempStore(t)
+	defer done()
+
+	s.Put(Record{Report: trustar.ReportDetails{ID: "body", Title: "Weekly summary", ReportBody: "Mentions emotet once among a long list of other unrelated findings."}})
+	s.Put(Record{Report: trustar.ReportDetails{ID: "title", Title: "Emotet resurgence", ReportBody: "A new wave was observed."}})
+
+	rr, err := s.SearchReports(url.Values{"searchTerm": {"emotet"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := ids(rr.Reports); !reflect.DeepEqual(got, []string{"title", "body"}) {
+		t.Errorf("got %v, want the title match first", got)
+	}
+}
+
+func TestIndexUpdates(t *testing.T) {
+	s, done := searchStore(t)
+	defer done()
+
+	search := func(term string) []string {
+		rr, err := s.SearchReports(url.Values{"searchTerm": {term}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := ids(rr.Reports)
+		sort.Strings(got)
+		return got
+	}
+
+	if got := search("dropper"); len(got) != 2 {
+		t.Fatalf("got %v, want 2 matches", got)
+	}
+
+	// the index built by the first search follows later changes
+	s.Put(Record{Report: trustar.ReportDetails{ID: "c2", Title: "Command and control", ReportBody: "Beacons to 10.0.0.2."}})
+	s.Delete("phish")
+
+	if got := search("dropper"); got != nil {
+		t.Errorf("got %v, want no matches after the update and delete", got)
+	}
+	if got := search("10.0.0.2"); !reflect.DeepEqual(got, []string{"c2"}) {
+		t.Errorf("got %v, want the new body indexed", got)
+	}
+
+	ix := s.Index()
+	if ix.Len() != 2 {
+		t.Errorf("got %d indexed reports, want 2", ix.Len())
+	}
+
+	// removing a report leaves no postings behind
+	ix.Remove("c2")
+	ix.Remove("ransom")
+	ix.Remove("missing")
+	if len(ix.postings) != 0 || len(ix.terms) != 0 || ix.Len() != 0 {
+		t.Errorf("got %d terms left in the index, want none", len(ix.postings))
+	}
+}
+
+func TestTokenize(t *testing.T) {
+	got := tokenize("Visit evil.com, then mail x_y@ex-ample.org (10.0.0.1). Done.")
+	want := []string{"visit", "evil.com", "then", "mail", "x_y@ex-ample.org", "10.0.0.1", "done"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
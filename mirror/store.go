@@ -0,0 +1,335 @@
+// Package mirror keeps a local copy of the reports, indicators and tags of selected enclaves
+// so they can be queried without spending API requests.
+package mirror
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	trustar "github.com/jakewarren/trustar-golang"
+	"github.com/jakewarren/trustar-golang/internal/atomicfile"
+)
+
+// storeVersion is the version of the store file layout
+const storeVersion = 2
+
+// compactMinEntries is the log length below which Save never compacts the store file
+const compactMinEntries = 1000
+
+// ErrNotFound is returned when a report is not in the store
+var ErrNotFound = errors.New("report not found in mirror")
+
+// Record is a mirrored report together with its indicators and tags
+type Record struct {
+	Report     trustar.ReportDetails  `json:"report"`
+	Indicators []trustar.Indicator    `json:"indicators,omitempty"`
+	Tags       []trustar.IndicatorTag `json:"tags,omitempty"`
+	SyncedAt   int64                  `json:"syncedAt"` // the time the record was last fetched, in milliseconds since epoch
+}
+
+// Checkpoint records how far an enclave has been synced
+type Checkpoint struct {
+	Updated  int64 `json:"updated"`  // the latest report updated time seen, in milliseconds since epoch
+	LastSync int64 `json:"lastSync"` // the time the last sync finished, in milliseconds since epoch
+}
+
+// Store is an embedded report store kept in a JSON Lines log file. The whole store is held in
+// memory; Save appends the changes made since the previous Save to the log, and rewrites the
+// file atomically once most of the log has been superseded. It is safe for concurrent use.
+type Store struct {
+	mu   sync.RWMutex
+	path string
+	data storeFile
+
+	pending []logEntry // changes not yet written to the log
+	logged  int        // entries in the log file
+	compact bool       // the log must be rewritten rather than appended to
+
+	// index is built by the first search and kept up to date by Put and Delete
+	index *Index
+}
+
+type storeFile struct {
+	Version     int                   `json:"version"`
+	Reports     map[string]*Record    `json:"reports"`
+	Checkpoints map[string]Checkpoint `json:"checkpoints"`
+}
+
+// logEntry is one line of the store file
+type logEntry struct {
+	Version    int         `json:"version,omitempty"` // set on the first line only
+	Put        *Record     `json:"put,omitempty"`
+	Delete     string      `json:"delete,omitempty"`
+	Enclave    string      `json:"enclave,omitempty"`
+	Checkpoint *Checkpoint `json:"checkpoint,omitempty"`
+}
+
+// Open opens the store file at path, creating an empty store if it does not exist yet.
+// Store files written in the single JSON document layout are read and converted by the next Save.
+func Open(path string) (*Store, error) {
+	s := &Store{
+		path: path,
+		data: storeFile{
+			Version:     storeVersion,
+			Reports:     map[string]*Record{},
+			Checkpoints: map[string]Checkpoint{},
+		},
+	}
+
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		s.compact = true
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+	for n := 1; ; n++ {
+		line, err := r.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		eof := err == io.EOF
+
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if perr := s.replay(n, line); perr != nil {
+				if !eof {
+					return nil, perr
+				}
+				// a torn final line from an interrupted Save, drop it with the next rewrite
+				s.compact = true
+			}
+		}
+
+		if eof {
+			break
+		}
+	}
+
+	return s, nil
+}
+
+// replay applies one line of the store file
+func (s *Store) replay(n int, line []byte) error {
+	if n == 1 {
+		var legacy storeFile
+		if err := json.Unmarshal(line, &legacy); err != nil {
+			return err
+		}
+		if legacy.Version > storeVersion {
+			return fmt.Errorf("store file version %d is newer than this version of mirror supports", legacy.Version)
+		}
+		if legacy.Version < storeVersion {
+			for id, rec := range legacy.Reports {
+				s.data.Reports[id] = rec
+			}
+			for id, cp := range legacy.Checkpoints {
+				s.data.Checkpoints[id] = cp
+			}
+			s.compact = true
+		}
+		s.logged++
+		return nil
+	}
+
+	var e logEntry
+	if err := json.Unmarshal(line, &e); err != nil {
+		return fmt.Errorf("%s line %d: %v", s.path, n, err)
+	}
+	s.apply(e)
+	s.logged++
+	return nil
+}
+
+// apply makes the change recorded by a log entry
+func (s *Store) apply(e logEntry) {
+	switch {
+	case e.Put != nil:
+		s.data.Reports[e.Put.Report.ID] = e.Put
+	case e.Delete != "":
+		delete(s.data.Reports, e.Delete)
+	case e.Checkpoint != nil:
+		s.data.Checkpoints[e.Enclave] = *e.Checkpoint
+	}
+}
+
+// Path returns the path of the store file
+func (s *Store) Path() string {
+	return s.path
+}
+
+// Save writes the changes made since the last Save to disk
+func (s *Store) Save() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	live := len(s.data.Reports) + len(s.data.Checkpoints)
+	if s.compact || (s.logged > compactMinEntries && s.logged+len(s.pending) > 2*live) {
+		return s.rewrite()
+	}
+	if len(s.pending) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range s.pending {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(s.path, os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(buf.Bytes()); err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		// part of the batch may have been written, start over from a full copy
+		s.compact = true
+		return err
+	}
+
+	s.logged += len(s.pending)
+	s.pending = nil
+	return nil
+}
+
+// rewrite replaces the store file with one entry per live record, it must be called with s.mu held
+func (s *Store) rewrite() error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+
+	entries := []logEntry{{Version: storeVersion}}
+	for _, rec := range s.data.Reports {
+		entries = append(entries, logEntry{Put: rec})
+	}
+	for id := range s.data.Checkpoints {
+		cp := s.data.Checkpoints[id]
+		entries = append(entries, logEntry{Enclave: id, Checkpoint: &cp})
+	}
+	for _, e := range entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+
+	if err := atomicfile.Write(s.path, buf.Bytes()); err != nil {
+		return err
+	}
+
+	s.logged = len(entries)
+	s.pending = nil
+	s.compact = false
+	return nil
+}
+
+// Close saves the store
+func (s *Store) Close() error {
+	return s.Save()
+}
+
+// Get returns a copy of the record for a report
+func (s *Store) Get(id string) (Record, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	rec, ok := s.data.Reports[id]
+	if !ok {
+		return Record{}, false
+	}
+	return *rec, true
+}
+
+// Put adds or replaces the record of a report
+func (s *Store) Put(rec Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Reports[rec.Report.ID] = &rec
+	s.pending = append(s.pending, logEntry{Put: &rec})
+	if s.index != nil {
+		s.index.Add(rec)
+	}
+}
+
+// Delete removes a report from the store
+func (s *Store) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.data.Reports[id]; !ok {
+		return
+	}
+	delete(s.data.Reports, id)
+	s.pending = append(s.pending, logEntry{Delete: id})
+	if s.index != nil {
+		s.index.Remove(id)
+	}
+}
+
+// Len returns the number of reports in the store
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.data.Reports)
+}
+
+// Each calls fn for every record in the store, in no particular order, until fn returns false
+func (s *Store) Each(fn func(Record) bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, rec := range s.data.Reports {
+		if !fn(*rec) {
+			return
+		}
+	}
+}
+
+// Index returns the full-text index of the store, building it on first use
+func (s *Store) Index() *Index {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.index == nil {
+		s.index = NewIndex()
+		for _, rec := range s.data.Reports {
+			s.index.Add(*rec)
+		}
+	}
+
+	return s.index
+}
+
+// Checkpoint returns the sync position of an enclave
+func (s *Store) Checkpoint(enclaveID string) Checkpoint {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.data.Checkpoints[enclaveID]
+}
+
+// SetCheckpoint records the sync position of an enclave
+func (s *Store) SetCheckpoint(enclaveID string, cp Checkpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Checkpoints[enclaveID] = cp
+	s.pending = append(s.pending, logEntry{Enclave: enclaveID, Checkpoint: &cp})
+}
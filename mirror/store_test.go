@@ -0,0 +1,187 @@
+package mirror
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// tempStore opens a store in a new temporary directory, returning a function removing it
+func tempStore(t *testing.T) (*Store, func()) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "mirror")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(filepath.Join(dir, "mirror.jsonl"))
+	if err != nil {
+		os.RemoveAll(dir)
+		t.Fatal(err)
+	}
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func record(id string, updated int64) Record {
+	return Record{Report: trustar.ReportDetails{ID: id, Title: "report " + id, Updated: updated}}
+}
+
+func lines(t *testing.T, path string) int {
+	t.Helper()
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return bytes.Count(data, []byte("\n"))
+}
+
+func TestStoreSaveAndOpen(t *testing.T) {
+	s, done := tempStore(t)
+	defer done()
+
+	s.Put(record("a", 1))
+	s.Put(record("b", 2))
+	s.SetCheckpoint("e1", Checkpoint{Updated: 2, LastSync: 3})
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if n := lines(t, s.Path()); n != 4 {
+		t.Errorf("got %d lines after the first save, want the header and 3 entries", n)
+	}
+
+	// later saves append only the changes
+	s.Put(record("a", 5))
+	s.Delete("b")
+	s.Delete("missing")
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if n := lines(t, s.Path()); n != 6 {
+		t.Errorf("got %d lines, want 2 entries appended", n)
+	}
+
+	reopened, err := Open(s.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 1 {
+		t.Errorf("got %d reports, want 1", reopened.Len())
+	}
+	if rec, ok := reopened.Get("a"); !ok || rec.Report.Updated != 5 {
+		t.Errorf("got %+v, %v, want the updated report", rec, ok)
+	}
+	if cp := reopened.Checkpoint("e1"); cp != (Checkpoint{Updated: 2, LastSync: 3}) {
+		t.Errorf("got checkpoint %+v", cp)
+	}
+
+	// nothing pending, nothing written
+	if err := reopened.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if n := lines(t, s.Path()); n != 6 {
+		t.Errorf("got %d lines, want the file untouched", n)
+	}
+}
+
+func TestStoreCompaction(t *testing.T) {
+	s, done := tempStore(t)
+	defer done()
+
+	for i := 0; i <= compactMinEntries; i++ {
+		s.Put(record("a", int64(i)))
+		if err := s.Save(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := lines(t, s.Path()); n > compactMinEntries {
+		t.Errorf("got %d lines, want the superseded entries compacted away", n)
+	}
+
+	reopened, err := Open(s.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := reopened.Get("a"); rec.Report.Updated != compactMinEntries {
+		t.Errorf("got updated %d, want the last version", rec.Report.Updated)
+	}
+}
+
+func TestStoreLegacyFile(t *testing.T) {
+	s, done := tempStore(t)
+	defer done()
+
+	legacy := `{"version":1,"reports":{"a":{"report":{"id":"a","updated":7}}},"checkpoints":{"e1":{"updated":7,"lastSync":8}}}`
+	if err := ioutil.WriteFile(s.Path(), []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := Open(s.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec, ok := s.Get("a"); !ok || rec.Report.Updated != 7 || s.Checkpoint("e1").LastSync != 8 {
+		t.Fatalf("got %+v, %v, want the legacy contents", rec, ok)
+	}
+
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	data, _ := ioutil.ReadFile(s.Path())
+	if !strings.HasPrefix(string(data), `{"version":2}`+"\n") {
+		t.Errorf("got %q, want the file converted to the log layout", data)
+	}
+
+	if err := ioutil.WriteFile(s.Path(), []byte(`{"version":3}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(s.Path()); err == nil || !strings.Contains(err.Error(), "version 3") {
+		t.Errorf("got error %v, want a newer version error", err)
+	}
+}
+
+func TestStoreTornLine(t *testing.T) {
+	s, done := tempStore(t)
+	defer done()
+
+	s.Put(record("a", 1))
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.OpenFile(s.Path(), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.WriteString(`{"put":{"report":{"id":"b"`)
+	f.Close()
+
+	s, err = Open(s.Path())
+	if err != nil {
+		t.Fatalf("got error %v, want the torn final line ignored", err)
+	}
+	if s.Len() != 1 {
+		t.Errorf("got %d reports, want 1", s.Len())
+	}
+
+	if err := s.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(s.Path()); err != nil {
+		t.Errorf("got error %v, want the torn line dropped by the next save", err)
+	}
+
+	// a corrupt line before the end is an error
+	if err := ioutil.WriteFile(s.Path(), []byte(`{"version":2}`+"\nnot json\n{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(s.Path()); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("got error %v, want the corrupt line reported", err)
+	}
+}
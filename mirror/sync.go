@@ -0,0 +1,204 @@
+package mirror
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+const (
+	// DefaultOverlap is how far before the checkpoint an incremental sync starts, to catch reports
+	// whose updated time was assigned out of order
+	DefaultOverlap = 10 * time.Minute
+
+	// DefaultInitialWindow is how far back the first sync of an enclave reaches
+	DefaultInitialWindow = 30 * 24 * time.Hour
+)
+
+// Syncer copies the reports of selected enclaves into a Store
+type Syncer struct {
+	Client     *trustar.Client
+	Store      *Store
+	EnclaveIDs []string
+
+	// Overlap is how far before the checkpoint each incremental sync starts, defaults to DefaultOverlap
+	Overlap time.Duration
+
+	// InitialFrom is the start of the first sync of an enclave, defaults to DefaultInitialWindow ago
+	InitialFrom time.Time
+
+	// DetectDeletions lists every report of the enclave since InitialFrom on each sync and removes
+	// mirrored reports that are no longer returned. Unchanged reports are not fetched again, so this
+	// costs one listing per sync.
+	DetectDeletions bool
+
+	// SkipIndicators and SkipTags disable mirroring the indicators and tags of each report
+	SkipIndicators bool
+	SkipTags       bool
+}
+
+// SyncResult counts the changes made by a sync
+type SyncResult struct {
+	Added   int
+	Updated int
+	Deleted int
+}
+
+// Sync brings the store up to date with every configured enclave, saving the store and the
+// checkpoint of each enclave as soon as it is done
+func (s *Syncer) Sync() (SyncResult, error) {
+	var total SyncResult
+
+	if s.Client == nil || s.Store == nil {
+		return total, errors.New("a Client and Store are required to sync")
+	}
+
+	// reports seen in any enclave during a deletion sweep
+	seen := map[string]bool{}
+
+	for _, enclaveID := range s.EnclaveIDs {
+		res, err := s.syncEnclave(enclaveID, seen)
+		total.Added += res.Added
+		total.Updated += res.Updated
+		if err != nil {
+			return total, err
+		}
+	}
+
+	if s.DetectDeletions {
+		total.Deleted = s.deleteMissing(seen)
+		if err := s.Store.Save(); err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+func (s *Syncer) syncEnclave(enclaveID string, seen map[string]bool) (SyncResult, error) {
+	var res SyncResult
+
+	now := time.Now()
+	cp := s.Store.Checkpoint(enclaveID)
+
+	from := s.initialFrom(now)
+	if cp.Updated > 0 && !s.DetectDeletions {
+		overlap := s.Overlap
+		if overlap <= 0 {
+			overlap = DefaultOverlap
+		}
+		if start := cp.Updated - int64(overlap/time.Millisecond); start > trustar.TimeToMsEpoch(from) {
+			from, _ = trustar.MsEpochToTime(start)
+		}
+	}
+
+	v := url.Values{}
+	v.Set("enclaveIds", enclaveID)
+	v.Set("from", strconv.FormatInt(trustar.TimeToMsEpoch(from), 10))
+	v.Set("to", strconv.FormatInt(trustar.TimeToMsEpoch(now), 10))
+
+	err := s.Client.ForEachReport(v, func(r trustar.ReportDetails) error {
+		seen[r.ID] = true
+
+		if r.Updated > cp.Updated {
+			cp.Updated = r.Updated
+		}
+
+		existing, ok := s.Store.Get(r.ID)
+		if ok && existing.Report.Updated == r.Updated {
+			return nil
+		}
+
+		rec, err := s.fetch(r)
+		if err != nil {
+			return err
+		}
+		s.Store.Put(rec)
+
+		if ok {
+			res.Updated++
+		} else {
+			res.Added++
+		}
+		return nil
+	})
+	if err != nil {
+		// keep what was fetched so far, the checkpoint is only moved once the enclave is complete
+		s.Store.Save()
+		return res, err
+	}
+
+	cp.LastSync = trustar.TimeToMsEpoch(now)
+	s.Store.SetCheckpoint(enclaveID, cp)
+
+	return res, s.Store.Save()
+}
+
+// fetch builds the record of a report, fetching its body, indicators and tags
+func (s *Syncer) fetch(r trustar.ReportDetails) (Record, error) {
+	// the report listing may omit the body, so read the full details
+	details, err := s.Client.GetReportDetails(r.ID)
+	if err != nil {
+		return Record{}, err
+	}
+
+	rec := Record{Report: details, SyncedAt: trustar.TimeToMsEpoch(time.Now())}
+
+	if !s.SkipIndicators {
+		err = s.Client.ForEachReportIndicator(r.ID, url.Values{}, func(i trustar.Indicator) error {
+			rec.Indicators = append(rec.Indicators, i)
+			return nil
+		})
+		if err != nil {
+			return Record{}, err
+		}
+	}
+
+	if !s.SkipTags {
+		if rec.Tags, err = s.Client.GetReportTags(r.ID); err != nil {
+			return Record{}, err
+		}
+	}
+
+	return rec, nil
+}
+
+// deleteMissing removes mirrored reports of the synced enclaves that were not returned by the sweep
+func (s *Syncer) deleteMissing(seen map[string]bool) int {
+	synced := map[string]bool{}
+	for _, id := range s.EnclaveIDs {
+		synced[id] = true
+	}
+
+	from := trustar.TimeToMsEpoch(s.initialFrom(time.Now()))
+
+	var missing []string
+	s.Store.Each(func(rec Record) bool {
+		if seen[rec.Report.ID] || rec.Report.Updated < from {
+			return true
+		}
+		for _, e := range rec.Report.EnclaveIds {
+			if synced[e] {
+				missing = append(missing, rec.Report.ID)
+				break
+			}
+		}
+		return true
+	})
+
+	for _, id := range missing {
+		s.Store.Delete(id)
+	}
+
+	return len(missing)
+}
+
+func (s *Syncer) initialFrom(now time.Time) time.Time {
+	if !s.InitialFrom.IsZero() {
+		return s.InitialFrom
+	}
+	return now.Add(-DefaultInitialWindow)
+}
@@ -0,0 +1,149 @@
+package mirror
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// fakeAPI serves the report endpoints used by Syncer from an in-memory set of reports
+type fakeAPI struct {
+	mu      sync.Mutex
+	reports map[string]trustar.ReportDetails
+	fetched int      // GetReportDetails calls
+	froms   []string // from parameter of each listing
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	enc := json.NewEncoder(w)
+
+	switch {
+	case len(parts) == 1 && parts[0] == "reports":
+		q := r.URL.Query()
+		f.froms = append(f.froms, q.Get("from"))
+		from, _ := strconv.ParseInt(q.Get("from"), 10, 64)
+
+		var list []trustar.ReportDetails
+		for _, rep := range f.reports {
+			if rep.Updated >= from && rep.EnclaveIds[0] == q.Get("enclaveIds") {
+				rep.ReportBody = "" // the listing omits bodies
+				list = append(list, rep)
+			}
+		}
+		sort.Slice(list, func(i, j int) bool { return list[i].Updated > list[j].Updated })
+		enc.Encode(trustar.ReportResponse{Reports: list})
+	case len(parts) == 2:
+		rep, ok := f.reports[parts[1]]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		f.fetched++
+		enc.Encode(rep)
+	case len(parts) == 3 && parts[2] == "indicators":
+		enc.Encode(trustar.ReportIndicatorsResponse{Items: []trustar.Indicator{{Value: "ind-" + parts[1]}}})
+	case len(parts) == 3 && parts[2] == "tags":
+		enc.Encode([]trustar.IndicatorTag{{Name: "tag-" + parts[1]}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (f *fakeAPI) set(id string, updated int64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.reports[id] = trustar.ReportDetails{ID: id, EnclaveIds: []string{"e1"}, Updated: updated, ReportBody: "body of " + id}
+}
+
+func TestSync(t *testing.T) {
+	api := &fakeAPI{reports: map[string]trustar.ReportDetails{}}
+	api.set("a", 1000)
+	api.set("b", 2000)
+
+	srv := httptest.NewServer(api)
+	defer srv.Close()
+
+	c, err := trustar.NewClient("id", "secret", srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetAccessToken("token")
+
+	store, done := tempStore(t)
+	defer done()
+
+	syncer := &Syncer{Client: c, Store: store, EnclaveIDs: []string{"e1"}, InitialFrom: time.Unix(0, 0), Overlap: time.Millisecond}
+
+	res, err := syncer.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != (SyncResult{Added: 2}) {
+		t.Errorf("got %+v, want 2 added", res)
+	}
+
+	rec, ok := store.Get("a")
+	if !ok || rec.Report.ReportBody != "body of a" || len(rec.Indicators) != 1 || rec.Indicators[0].Value != "ind-a" || len(rec.Tags) != 1 || rec.SyncedAt == 0 {
+		t.Errorf("got %+v, want the full record", rec)
+	}
+	if cp := store.Checkpoint("e1"); cp.Updated != 2000 || cp.LastSync == 0 {
+		t.Errorf("got checkpoint %+v", cp)
+	}
+
+	// unchanged reports are not fetched again and the listing starts at the checkpoint less the overlap
+	api.set("a", 3000)
+	fetched := api.fetched
+	if res, err = syncer.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if res != (SyncResult{Updated: 1}) || api.fetched != fetched+1 {
+		t.Errorf("got %+v with %d fetches, want 1 updated report fetched", res, api.fetched-fetched)
+	}
+	if from := api.froms[len(api.froms)-1]; from != "1999" {
+		t.Errorf("got from %s, want 1999", from)
+	}
+
+	// the sync is persisted
+	reopened, err := Open(store.Path())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec, _ := reopened.Get("a"); rec.Report.Updated != 3000 || reopened.Checkpoint("e1").Updated != 3000 {
+		t.Errorf("got %+v, want the second sync saved", rec)
+	}
+
+	// deleted reports are only detected with a full listing
+	delete(api.reports, "b")
+	if res, _ = syncer.Sync(); res.Deleted != 0 || store.Len() != 2 {
+		t.Errorf("got %+v, want no deletions without DetectDeletions", res)
+	}
+	syncer.DetectDeletions = true
+	if res, err = syncer.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if res != (SyncResult{Deleted: 1}) || store.Len() != 1 {
+		t.Errorf("got %+v with %d reports, want b deleted", res, store.Len())
+	}
+	if from := api.froms[len(api.froms)-1]; from != "0" {
+		t.Errorf("got from %s, want the deletion sweep to list from InitialFrom", from)
+	}
+}
+
+func TestSyncRequiresClientAndStore(t *testing.T) {
+	if _, err := (&Syncer{}).Sync(); err == nil {
+		t.Error("want an error without a Client and Store")
+	}
+}
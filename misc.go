@@ -2,7 +2,6 @@ package trustar
 
 import (
 	"fmt"
-	"net/http"
 	"strings"
 )
 
@@ -14,7 +13,7 @@ func (c *Client) Ping() (string, error) {
 	var response strings.Builder
 
 	url := fmt.Sprintf("%s%s", c.APIBase, "ping")
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest("Ping", "GET", url, nil)
 
 	if err != nil {
 		return "", err
@@ -34,7 +33,7 @@ func (c *Client) Ping() (string, error) {
 func (c *Client) Version() (string, error) {
 	var response strings.Builder
 
-	req, err := http.NewRequest("GET", "https://api.trustar.co/api/version", nil)
+	req, err := c.newRequest("Version", "GET", "https://api.trustar.co/api/version", nil)
 
 	if err != nil {
 		return "", err
@@ -55,7 +54,7 @@ func (c *Client) RequestQuotas() (RequestQuotas, error) {
 	var quotas RequestQuotas
 
 	url := fmt.Sprintf("%s%s", c.APIBase, "request-quotas")
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest("RequestQuotas", "GET", url, nil)
 
 	if err != nil {
 		return quotas, err
@@ -0,0 +1,213 @@
+package trustar
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+)
+
+// DefaultPageSize is the page size requested by the ForEach helpers when the query does not set one
+const DefaultPageSize = 100
+
+// ForEachReport calls fn for every report matching the GetReports query, requesting further pages
+// until the results are exhausted or fn returns an error.
+//
+// GetReports pages backwards through time, so each following page is requested with the "to"
+// parameter moved to the updated time of the oldest report seen so far. When a whole page shares
+// one updated time the following pages are requested with pageNumber on the same "to", so reports
+// tied on that time are not skipped.
+func (c *Client) ForEachReport(v url.Values, fn func(ReportDetails) error) (err error) {
+	ctx, span := c.startSpan(context.Background(), "trustar.ForEachReport", queryAttributes(v)...)
+	defer func() { endSpan(span, nil, err) }()
+
+	q := copyValues(v)
+
+	// reports sharing the boundary timestamp are returned again on the next page
+	seen := map[string]bool{}
+	var boundary int64 = -1
+
+	// tie counts the pages requested with pageNumber on a "to" shared by a whole page of reports
+	tie := 0
+
+	for n := 0; ; n++ {
+		_, pageSpan := c.startSpan(ctx, "trustar.Page", Attr("trustar.page_number", n), Attr("trustar.to", q.Get("to")))
+		rr, err := c.GetReports(q)
+		pageSpan.SetAttributes(Attr("trustar.has_next", rr.HasNext))
+		endSpan(pageSpan, nil, err)
+		if err != nil {
+			return err
+		}
+
+		fresh := 0
+		for _, r := range rr.Reports {
+			if r.Updated == boundary && seen[r.ID] {
+				continue
+			}
+			if err := fn(r); err != nil {
+				return err
+			}
+			fresh++
+			if r.Updated != boundary {
+				boundary = r.Updated
+				seen = map[string]bool{}
+			}
+			seen[r.ID] = true
+		}
+
+		if !rr.HasNext || len(rr.Reports) == 0 {
+			return nil
+		}
+		if tie > 0 && fresh == 0 {
+			return fmt.Errorf("more than %d reports share the updated time %s and GetReports did not page through them", len(rr.Reports), q.Get("to"))
+		}
+
+		next := strconv.FormatInt(rr.Reports[len(rr.Reports)-1].Updated, 10)
+		if to := q.Get("to"); to != "" && to == next && allSeen(rr.Reports, boundary, seen) {
+			// the whole page shares a single timestamp, page through the tie before moving past it
+			tie++
+			q.Set("pageNumber", strconv.Itoa(tie))
+			continue
+		}
+
+		if tie > 0 {
+			tie = 0
+			q.Del("pageNumber")
+		}
+		q.Set("to", next)
+	}
+}
+
+// ForEachSearchReport calls fn for every report matching the SearchReports query
+func (c *Client) ForEachSearchReport(v url.Values, fn func(ReportDetails) error) error {
+	return c.forEachPage("ForEachSearchReport", v, func(q url.Values) (bool, error) {
+		rr, err := c.SearchReports(q)
+		if err != nil {
+			return false, err
+		}
+		for _, r := range rr.Reports {
+			if err := fn(r); err != nil {
+				return false, err
+			}
+		}
+		return rr.HasNext && len(rr.Reports) > 0, nil
+	})
+}
+
+// ForEachCorrelatedReport calls fn for every report matching the FindCorrelatedReports query
+func (c *Client) ForEachCorrelatedReport(v url.Values, fn func(ReportDetails) error) error {
+	return c.forEachPage("ForEachCorrelatedReport", v, func(q url.Values) (bool, error) {
+		crr, err := c.FindCorrelatedReports(q)
+		if err != nil {
+			return false, err
+		}
+		for _, r := range crr.Items {
+			if err := fn(r); err != nil {
+				return false, err
+			}
+		}
+		return crr.HasNext && len(crr.Items) > 0, nil
+	})
+}
+
+// ForEachIndicator calls fn for every indicator matching the SearchIndicators query
+func (c *Client) ForEachIndicator(v url.Values, fn func(Indicator) error) error {
+	return c.forEachPage("ForEachIndicator", v, func(q url.Values) (bool, error) {
+		sir, err := c.SearchIndicators(q)
+		if err != nil {
+			return false, err
+		}
+		return sir.HasNext && len(sir.Items) > 0, eachIndicator(sir.Items, fn)
+	})
+}
+
+// ForEachReportIndicator calls fn for every indicator contained in the specified report
+func (c *Client) ForEachReportIndicator(id string, v url.Values, fn func(Indicator) error, idType ...IDType) error {
+	return c.forEachPage("ForEachReportIndicator", v, func(q url.Values) (bool, error) {
+		rir, err := c.GetReportIndicators(id, q, idType...)
+		if err != nil {
+			return false, err
+		}
+		return rir.HasNext && len(rir.Items) > 0, eachIndicator(rir.Items, fn)
+	})
+}
+
+// ForEachRelatedIndicator calls fn for every indicator matching the FindRelatedIndicators query
+func (c *Client) ForEachRelatedIndicator(v url.Values, fn func(Indicator) error) error {
+	return c.forEachPage("ForEachRelatedIndicator", v, func(q url.Values) (bool, error) {
+		rir, err := c.FindRelatedIndicators(q)
+		if err != nil {
+			return false, err
+		}
+		return rir.HasNext && len(rir.Items) > 0, eachIndicator(rir.Items, fn)
+	})
+}
+
+// ForEachWhitelistIndicator calls fn for every indicator on the company whitelist
+func (c *Client) ForEachWhitelistIndicator(v url.Values, fn func(Indicator) error) error {
+	return c.forEachPage("ForEachWhitelistIndicator", v, func(q url.Values) (bool, error) {
+		wir, err := c.GetWhitelist(q)
+		if err != nil {
+			return false, err
+		}
+		return wir.HasNext && len(wir.Items) > 0, eachIndicator(wir.Items, fn)
+	})
+}
+
+// forEachPage calls page with an increasing pageNumber until it reports there are no more pages
+func (c *Client) forEachPage(name string, v url.Values, page func(url.Values) (bool, error)) (err error) {
+	ctx, span := c.startSpan(context.Background(), "trustar."+name, queryAttributes(v)...)
+	defer func() { endSpan(span, nil, err) }()
+
+	q := copyValues(v)
+	if q.Get("pageSize") == "" {
+		q.Set("pageSize", strconv.Itoa(DefaultPageSize))
+	}
+
+	n := 0
+	if p := q.Get("pageNumber"); p != "" {
+		var err error
+		if n, err = strconv.Atoi(p); err != nil {
+			return err
+		}
+	}
+
+	for ; ; n++ {
+		q.Set("pageNumber", strconv.Itoa(n))
+
+		_, pageSpan := c.startSpan(ctx, "trustar.Page", Attr("trustar.page_number", n))
+		hasNext, err := page(q)
+		pageSpan.SetAttributes(Attr("trustar.has_next", hasNext))
+		endSpan(pageSpan, nil, err)
+
+		if err != nil || !hasNext {
+			return err
+		}
+	}
+}
+
+func eachIndicator(items []Indicator, fn func(Indicator) error) error {
+	for _, i := range items {
+		if err := fn(i); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func allSeen(reports []ReportDetails, updated int64, seen map[string]bool) bool {
+	for _, r := range reports {
+		if r.Updated != updated || !seen[r.ID] {
+			return false
+		}
+	}
+	return true
+}
+
+func copyValues(v url.Values) url.Values {
+	q := url.Values{}
+	for k, vs := range v {
+		q[k] = append([]string(nil), vs...)
+	}
+	return q
+}
@@ -0,0 +1,245 @@
+package trustar
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// reportsServer serves GetReports like the API does: reports updated at or before "to",
+// newest first, split into pages of pageSize. If ignorePageNumber is set every request
+// returns the first page.
+func reportsServer(reports []ReportDetails, pageSize int, ignorePageNumber bool, queries *[]url.Values) http.HandlerFunc {
+	sorted := append([]ReportDetails(nil), reports...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Updated > sorted[j].Updated })
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if queries != nil {
+			*queries = append(*queries, q)
+		}
+
+		var match []ReportDetails
+		for _, rep := range sorted {
+			if to := q.Get("to"); to != "" {
+				if max, _ := strconv.ParseInt(to, 10, 64); rep.Updated > max {
+					continue
+				}
+			}
+			match = append(match, rep)
+		}
+
+		page := 0
+		if !ignorePageNumber {
+			page, _ = strconv.Atoi(q.Get("pageNumber"))
+		}
+
+		start := page * pageSize
+		if start > len(match) {
+			start = len(match)
+		}
+		end := start + pageSize
+		if end > len(match) {
+			end = len(match)
+		}
+
+		writeJSON(w, ReportResponse{Reports: match[start:end], HasNext: end < len(match), PageNumber: int64(page), PageSize: int64(pageSize)})
+	}
+}
+
+func reportIDs(reports []ReportDetails) []string {
+	ids := make([]string, len(reports))
+	for i, r := range reports {
+		ids[i] = r.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestForEachReport(t *testing.T) {
+	tests := []struct {
+		name    string
+		updated []int64
+	}{
+		{"distinct times", []int64{10, 9, 8, 7, 6, 5, 4}},
+		{"tie across a page boundary", []int64{10, 9, 8, 8, 8, 7, 6}},
+		{"tie longer than a page", []int64{10, 8, 8, 8, 8, 8, 8, 8, 5, 4}},
+		{"everything tied", []int64{8, 8, 8, 8, 8, 8, 8}},
+		{"single page", []int64{3, 2}},
+		{"empty", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reports []ReportDetails
+			for i, u := range tt.updated {
+				reports = append(reports, ReportDetails{ID: "r" + strconv.Itoa(i), Updated: u})
+			}
+
+			c, done := newTestClient(t, reportsServer(reports, 3, false, nil))
+			defer done()
+
+			var got []ReportDetails
+			err := c.ForEachReport(url.Values{}, func(r ReportDetails) error {
+				got = append(got, r)
+				return nil
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(reportIDs(got), reportIDs(reports)) {
+				t.Errorf("got reports %v, want %v", reportIDs(got), reportIDs(reports))
+			}
+			if len(got) != len(reports) {
+				t.Errorf("got %d reports, want each of the %d once", len(got), len(reports))
+			}
+		})
+	}
+}
+
+func TestForEachReportTiePaging(t *testing.T) {
+	var reports []ReportDetails
+	for i, u := range []int64{10, 8, 8, 8, 8, 8, 8, 8, 5, 4, 3} {
+		reports = append(reports, ReportDetails{ID: "r" + strconv.Itoa(i), Updated: u})
+	}
+
+	var queries []url.Values
+	c, done := newTestClient(t, reportsServer(reports, 3, false, &queries))
+	defer done()
+
+	if err := c.ForEachReport(url.Values{}, func(ReportDetails) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, q := range queries {
+		got = append(got, "to="+q.Get("to")+" page="+q.Get("pageNumber"))
+	}
+	want := []string{
+		"to= page=",
+		"to=8 page=",
+		"to=8 page=1",
+		"to=8 page=2",
+		"to=4 page=",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got requests\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestForEachReportTieNotPaged(t *testing.T) {
+	var reports []ReportDetails
+	for i := 0; i < 7; i++ {
+		reports = append(reports, ReportDetails{ID: "r" + strconv.Itoa(i), Updated: 8})
+	}
+
+	c, done := newTestClient(t, reportsServer(reports, 3, true, nil))
+	defer done()
+
+	n := 0
+	err := c.ForEachReport(url.Values{}, func(ReportDetails) error {
+		n++
+		return nil
+	})
+	if err == nil || !strings.Contains(err.Error(), "share the updated time 8") {
+		t.Fatalf("got error %v, want the tie to be reported", err)
+	}
+	if n != 3 {
+		t.Errorf("got %d reports before the error, want 3", n)
+	}
+}
+
+func TestForEachReportStopsOnError(t *testing.T) {
+	reports := []ReportDetails{{ID: "a", Updated: 3}, {ID: "b", Updated: 2}, {ID: "c", Updated: 1}}
+
+	c, done := newTestClient(t, reportsServer(reports, 1, false, nil))
+	defer done()
+
+	stop := errors.New("stop")
+	var got []string
+	err := c.ForEachReport(url.Values{}, func(r ReportDetails) error {
+		got = append(got, r.ID)
+		if r.ID == "b" {
+			return stop
+		}
+		return nil
+	})
+	if err != stop {
+		t.Fatalf("got error %v, want %v", err, stop)
+	}
+	if want := []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got reports %v, want %v", got, want)
+	}
+}
+
+func TestForEachIndicator(t *testing.T) {
+	var queries []url.Values
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		queries = append(queries, q)
+
+		page, _ := strconv.Atoi(q.Get("pageNumber"))
+		writeJSON(w, SearchIndicatorReponse{
+			Items:   []Indicator{{Value: "10.0.0." + strconv.Itoa(page)}},
+			HasNext: page < 2,
+		})
+	})
+	defer done()
+
+	var got []string
+	err := c.ForEachIndicator(url.Values{"searchTerm": {"10.0.0"}}, func(i Indicator) error {
+		got = append(got, i.Value)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"10.0.0.0", "10.0.0.1", "10.0.0.2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, q := range queries {
+		if q.Get("pageSize") != strconv.Itoa(DefaultPageSize) || q.Get("searchTerm") != "10.0.0" {
+			t.Errorf("got query %v, want the search term and the default page size", q)
+		}
+	}
+}
+
+func TestForEachPageStartsAtPageNumber(t *testing.T) {
+	var pages []string
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		pages = append(pages, r.URL.Query().Get("pageNumber"))
+		writeJSON(w, ReportResponse{Reports: []ReportDetails{{ID: "x"}}, HasNext: len(pages) < 2})
+	})
+	defer done()
+
+	err := c.ForEachSearchReport(url.Values{"pageNumber": {"4"}, "pageSize": {"10"}}, func(ReportDetails) error { return nil })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"4", "5"}; !reflect.DeepEqual(pages, want) {
+		t.Errorf("got pages %v, want %v", pages, want)
+	}
+
+	if err := c.ForEachSearchReport(url.Values{"pageNumber": {"x"}}, func(ReportDetails) error { return nil }); err == nil {
+		t.Error("want an error for a malformed pageNumber")
+	}
+}
+
+func TestForEachPageAPIError(t *testing.T) {
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "gone", http.StatusNotFound)
+	})
+	defer done()
+
+	err := c.ForEachReportIndicator("missing", url.Values{}, func(Indicator) error { return nil })
+	if !IsNotFound(err) {
+		t.Errorf("got error %v, want a 404 error response", err)
+	}
+}
@@ -0,0 +1,308 @@
+package trustar
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DefaultPoolConcurrency is the number of tenants a ClientPool calls at the same time
+const DefaultPoolConcurrency = 8
+
+// Tenant is one TruSTAR account in a ClientPool
+type Tenant struct {
+	Name     string
+	Client   *Client
+	Limiter  *QuotaLimiter
+	Enclaves *EnclaveRegistry
+}
+
+// authorize fetches an access token the first time the tenant is used
+func (t *Tenant) authorize() error {
+	// hold the Client lock as SendWithAuth does when it refreshes the token
+	t.Client.Lock()
+	defer t.Client.Unlock()
+
+	if t.Client.Token != nil {
+		return nil
+	}
+	_, err := t.Client.GetAccessToken()
+	return err
+}
+
+// PoolError holds the errors of the tenants that failed during a fan-out call
+type PoolError struct {
+	Errors map[string]error // keyed by tenant name
+}
+
+func (e *PoolError) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, len(names))
+	for i, name := range names {
+		msgs[i] = fmt.Sprintf("%s: %v", name, e.Errors[name])
+	}
+	return fmt.Sprintf("%d tenants failed: %s", len(names), strings.Join(msgs, "; "))
+}
+
+// ClientPool holds a Client per tenant, for services working across several TruSTAR accounts.
+// Each tenant has its own access token, QuotaLimiter and EnclaveRegistry. It is safe for concurrent use.
+type ClientPool struct {
+	mu      sync.RWMutex
+	tenants map[string]*Tenant
+
+	// Concurrency is the number of tenants called at the same time by fan-out calls, defaults to DefaultPoolConcurrency
+	Concurrency int
+}
+
+// NewClientPool returns an empty ClientPool
+func NewClientPool() *ClientPool {
+	return &ClientPool{
+		tenants:     make(map[string]*Tenant),
+		Concurrency: DefaultPoolConcurrency,
+	}
+}
+
+// Add registers a Client under a tenant name
+func (p *ClientPool) Add(name string, c *Client) (*Tenant, error) {
+	if name == "" || c == nil {
+		return nil, fmt.Errorf("a tenant name and Client are required")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if _, ok := p.tenants[name]; ok {
+		return nil, fmt.Errorf("tenant %q already exists", name)
+	}
+
+	t := &Tenant{
+		Name:     name,
+		Client:   c,
+		Limiter:  NewQuotaLimiter(c),
+		Enclaves: NewEnclaveRegistry(c),
+	}
+	p.tenants[name] = t
+	return t, nil
+}
+
+// Remove drops a tenant from the pool
+func (p *ClientPool) Remove(name string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.tenants, name)
+}
+
+// Tenant returns the named tenant
+func (p *ClientPool) Tenant(name string) (*Tenant, bool) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	t, ok := p.tenants[name]
+	return t, ok
+}
+
+// Names returns the tenant names, sorted
+func (p *ClientPool) Names() []string {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	names := make([]string, 0, len(p.tenants))
+	for name := range p.tenants {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Do calls fn for every tenant concurrently, after the tenant has an access token and a request is allowed by its QuotaLimiter.
+// fn may send further requests, which should call t.Limiter.Wait first.
+// If any tenant fails a *PoolError is returned.
+func (p *ClientPool) Do(fn func(t *Tenant) error) error {
+	return p.do(fn, true)
+}
+
+// do calls fn for every tenant concurrently. When wait is false fn claims its own requests from t.Limiter.
+func (p *ClientPool) do(fn func(t *Tenant) error, wait bool) error {
+	p.mu.RLock()
+	tenants := make([]*Tenant, 0, len(p.tenants))
+	for _, t := range p.tenants {
+		tenants = append(tenants, t)
+	}
+	concurrency := p.Concurrency
+	p.mu.RUnlock()
+
+	if concurrency <= 0 {
+		concurrency = DefaultPoolConcurrency
+	}
+
+	var (
+		mu   sync.Mutex
+		wg   sync.WaitGroup
+		errs = make(map[string]error)
+		sem  = make(chan struct{}, concurrency)
+	)
+
+	for _, t := range tenants {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(t *Tenant) {
+			defer wg.Done()
+			defer func() { <-sem }()
+
+			err := t.authorize()
+			if err == nil && wait {
+				err = t.Limiter.Wait(context.Background())
+			}
+			if err == nil {
+				err = fn(t)
+			}
+			if err != nil {
+				mu.Lock()
+				errs[t.Name] = err
+				mu.Unlock()
+			}
+		}(t)
+	}
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return &PoolError{Errors: errs}
+	}
+	return nil
+}
+
+// TenantReport is a report found in one tenant
+type TenantReport struct {
+	Tenant string `json:"tenant"`
+	ReportDetails
+}
+
+// TenantIndicator is an indicator found in one tenant
+type TenantIndicator struct {
+	Tenant string `json:"tenant"`
+	Indicator
+}
+
+// TenantIndicatorMetadata is the metadata of an indicator in one tenant
+type TenantIndicatorMetadata struct {
+	Tenant string `json:"tenant"`
+	IndicatorMetadata
+}
+
+// SearchReports runs SearchReports in every tenant and merges the first pages, newest first.
+// The results of the tenants that succeeded are returned along with a *PoolError for the rest.
+func (p *ClientPool) SearchReports(v url.Values) ([]TenantReport, error) {
+	return p.reports(func(t *Tenant) ([]ReportDetails, error) {
+		resp, err := t.Client.SearchReports(v)
+		return resp.Reports, err
+	})
+}
+
+// GetReports runs GetReports in every tenant and merges the first pages, newest first.
+// Enclave IDs differ between accounts, so v should not usually set enclaveIds.
+func (p *ClientPool) GetReports(v url.Values) ([]TenantReport, error) {
+	return p.reports(func(t *Tenant) ([]ReportDetails, error) {
+		resp, err := t.Client.GetReports(v)
+		return resp.Reports, err
+	})
+}
+
+// FindCorrelatedReports runs FindCorrelatedReports in every tenant and merges the first pages, newest first
+func (p *ClientPool) FindCorrelatedReports(v url.Values) ([]TenantReport, error) {
+	return p.reports(func(t *Tenant) ([]ReportDetails, error) {
+		resp, err := t.Client.FindCorrelatedReports(v)
+		return resp.Items, err
+	})
+}
+
+func (p *ClientPool) reports(fetch func(t *Tenant) ([]ReportDetails, error)) ([]TenantReport, error) {
+	var (
+		mu     sync.Mutex
+		merged []TenantReport
+	)
+
+	err := p.Do(func(t *Tenant) error {
+		items, err := fetch(t)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		for _, r := range items {
+			merged = append(merged, TenantReport{Tenant: t.Name, ReportDetails: r})
+		}
+		mu.Unlock()
+		return nil
+	})
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Updated != merged[j].Updated {
+			return merged[i].Updated > merged[j].Updated
+		}
+		return merged[i].Tenant < merged[j].Tenant
+	})
+	return merged, err
+}
+
+// SearchIndicators runs SearchIndicators in every tenant and merges the first pages, ordered by tenant
+func (p *ClientPool) SearchIndicators(v url.Values) ([]TenantIndicator, error) {
+	var (
+		mu     sync.Mutex
+		merged []TenantIndicator
+	)
+
+	err := p.Do(func(t *Tenant) error {
+		resp, err := t.Client.SearchIndicators(v)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		for _, ind := range resp.Items {
+			merged = append(merged, TenantIndicator{Tenant: t.Name, Indicator: ind})
+		}
+		mu.Unlock()
+		return nil
+	})
+
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Tenant < merged[j].Tenant })
+	return merged, err
+}
+
+// GetIndicatorMetadata looks the indicators up in every tenant with GetIndicatorMetadataBatch, using each tenant's QuotaLimiter.
+// The results are ordered by tenant.
+func (p *ClientPool) GetIndicatorMetadata(indicators []Indicator) ([]TenantIndicatorMetadata, error) {
+	var (
+		mu     sync.Mutex
+		merged []TenantIndicatorMetadata
+	)
+
+	// every chunk of the batch waits on the tenant's limiter itself
+	err := p.do(func(t *Tenant) error {
+		batch, err := t.Client.GetIndicatorMetadataBatch(indicators, BatchOptions{Limiter: t.Limiter})
+
+		mu.Lock()
+		for _, md := range batch.Metadata {
+			merged = append(merged, TenantIndicatorMetadata{Tenant: t.Name, IndicatorMetadata: md})
+		}
+		mu.Unlock()
+		return err
+	}, false)
+
+	sort.SliceStable(merged, func(i, j int) bool {
+		if merged[i].Tenant != merged[j].Tenant {
+			return merged[i].Tenant < merged[j].Tenant
+		}
+		return merged[i].Value < merged[j].Value
+	})
+	return merged, err
+}
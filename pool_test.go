@@ -0,0 +1,253 @@
+package trustar
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// tenantAPI serves reports, indicators, metadata and request quotas for one tenant, or fails every search
+type tenantAPI struct {
+	updated    int64 // the Updated time of the tenant's reports
+	fail       bool
+	quotaReads int32
+	requests   int32
+}
+
+func (a *tenantAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/request-quotas" {
+		atomic.AddInt32(&a.quotaReads, 1)
+		writeJSON(w, RequestQuotas{{MaxRequests: 10}})
+		return
+	}
+
+	atomic.AddInt32(&a.requests, 1)
+	if a.fail {
+		http.Error(w, "boom", http.StatusInternalServerError)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/reports/search", "/reports":
+		writeJSON(w, ReportResponse{Reports: []ReportDetails{{ID: "r1", Updated: a.updated}, {ID: "r2", Updated: a.updated - 10}}})
+	case "/reports/correlated":
+		writeJSON(w, CorrelatedReportResponse{Items: []ReportDetails{{ID: "c1", Updated: a.updated}}})
+	case "/indicators/search":
+		writeJSON(w, SearchIndicatorReponse{Items: []Indicator{{Value: "b.com"}, {Value: "a.com"}}})
+	case "/indicators/metadata":
+		writeJSON(w, IndicatorMetadataResponse{{Value: "b.com"}, {Value: "a.com"}})
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func newTestPool(t *testing.T, apis map[string]*tenantAPI) (*ClientPool, func()) {
+	t.Helper()
+
+	p := NewClientPool()
+	var closers []func()
+	for name, api := range apis {
+		c, done := newTestClient(t, api.ServeHTTP)
+		closers = append(closers, done)
+		if _, err := p.Add(name, c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return p, func() {
+		for _, done := range closers {
+			done()
+		}
+	}
+}
+
+func TestClientPoolTenants(t *testing.T) {
+	p, done := newTestPool(t, map[string]*tenantAPI{"b": {}, "a": {}})
+	defer done()
+
+	if got := p.Names(); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Errorf("got %v", got)
+	}
+
+	a, ok := p.Tenant("a")
+	if !ok || a.Name != "a" || a.Client == nil || a.Limiter == nil || a.Enclaves == nil {
+		t.Errorf("got %+v, %v", a, ok)
+	}
+
+	if _, err := p.Add("a", a.Client); err == nil || err.Error() != `tenant "a" already exists` {
+		t.Errorf("got %v, want a duplicate tenant error", err)
+	}
+	if _, err := p.Add("", a.Client); err == nil {
+		t.Error("want an error for an unnamed tenant")
+	}
+	if _, err := p.Add("c", nil); err == nil {
+		t.Error("want an error for a nil Client")
+	}
+
+	p.Remove("a")
+	if _, ok := p.Tenant("a"); ok || len(p.Names()) != 1 {
+		t.Errorf("got %v, want a removed", p.Names())
+	}
+}
+
+func TestClientPoolReports(t *testing.T) {
+	apis := map[string]*tenantAPI{
+		"acme":    {updated: 100},
+		"beta":    {updated: 100},
+		"gamma":   {updated: 200},
+		"failing": {fail: true},
+	}
+	p, done := newTestPool(t, apis)
+	defer done()
+
+	reports, err := p.SearchReports(url.Values{"searchTerm": {"x"}})
+
+	var got []string
+	for _, r := range reports {
+		got = append(got, r.Tenant+"/"+r.ID)
+	}
+	want := []string{"gamma/r1", "gamma/r2", "acme/r1", "beta/r1", "acme/r2", "beta/r2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want newest first, then by tenant: %v", got, want)
+	}
+
+	pe, ok := err.(*PoolError)
+	if !ok || len(pe.Errors) != 1 || pe.Errors["failing"] == nil {
+		t.Fatalf("got %v, want a *PoolError for the failing tenant", err)
+	}
+	if !strings.HasPrefix(err.Error(), "1 tenants failed: failing: GET ") {
+		t.Errorf("got %q", err.Error())
+	}
+
+	delete(apis, "failing")
+	p.Remove("failing")
+
+	if reports, err := p.GetReports(url.Values{}); err != nil || len(reports) != 6 {
+		t.Errorf("got %d reports, %v", len(reports), err)
+	}
+	if reports, err := p.FindCorrelatedReports(url.Values{"indicators": {"a"}}); err != nil || len(reports) != 3 || reports[0].Tenant != "gamma" {
+		t.Errorf("got %v, %v", reports, err)
+	}
+
+	// every fan-out call claims one request from each tenant's limiter
+	for name := range apis {
+		tenant, _ := p.Tenant(name)
+		if got := tenant.Limiter.Remaining(); got != 7 {
+			t.Errorf("%s: got %d remaining, want 7 after three calls", name, got)
+		}
+	}
+}
+
+func TestClientPoolIndicators(t *testing.T) {
+	apis := map[string]*tenantAPI{"b": {}, "a": {}}
+	p, done := newTestPool(t, apis)
+	defer done()
+
+	indicators, err := p.SearchIndicators(url.Values{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, ind := range indicators {
+		got = append(got, ind.Tenant+"/"+ind.Value)
+	}
+	if want := []string{"a/b.com", "a/a.com", "b/b.com", "b/a.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want tenant order with each tenant's order kept: %v", got, want)
+	}
+
+	metadata, err := p.GetIndicatorMetadata([]Indicator{{Value: "a.com"}, {Value: "b.com"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got = nil
+	for _, md := range metadata {
+		got = append(got, md.Tenant+"/"+md.Value)
+	}
+	if want := []string{"a/a.com", "a/b.com", "b/a.com", "b/b.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// the metadata lookup waits on the limiter once per chunk, not once more for the tenant
+	for name := range apis {
+		tenant, _ := p.Tenant(name)
+		if got := tenant.Limiter.Remaining(); got != 8 {
+			t.Errorf("%s: got %d remaining, want 8 after the search and one metadata chunk", name, got)
+		}
+	}
+}
+
+func TestClientPoolDo(t *testing.T) {
+	var tokens int32
+	c, err := NewClient("id", "secret", "https://example.invalid/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetHTTPClient(&http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"access_token":"token","expires_in":3600}`
+		if req.URL.Path == "/oauth/token" {
+			atomic.AddInt32(&tokens, 1)
+		} else {
+			body = `[{"maxRequests":10}]`
+		}
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{},
+			Body:       ioutil.NopCloser(strings.NewReader(body)),
+			Request:    req,
+		}, nil
+	})})
+
+	p := NewClientPool()
+	p.Add("solo", c)
+
+	for i := 0; i < 3; i++ {
+		if err := p.Do(func(t *Tenant) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := atomic.LoadInt32(&tokens); n != 1 {
+		t.Errorf("got %d token requests, want the token fetched on first use only", n)
+	}
+
+	failure := errors.New("failed")
+	err = p.Do(func(t *Tenant) error { return failure })
+	if pe, ok := err.(*PoolError); !ok || pe.Errors["solo"] != failure {
+		t.Errorf("got %v, want the tenant's error", err)
+	}
+}
+
+func TestClientPoolConcurrency(t *testing.T) {
+	apis := map[string]*tenantAPI{}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		apis[name] = &tenantAPI{}
+	}
+	p, done := newTestPool(t, apis)
+	defer done()
+	p.Concurrency = 2
+
+	var running, peak int32
+	err := p.Do(func(t *Tenant) error {
+		n := atomic.AddInt32(&running, 1)
+		for {
+			old := atomic.LoadInt32(&peak)
+			if n <= old || atomic.CompareAndSwapInt32(&peak, old, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := atomic.LoadInt32(&peak); got != 2 {
+		t.Errorf("got %d tenants at once, want 2", got)
+	}
+}
@@ -0,0 +1,157 @@
+package trustar
+
+import (
+	"context"
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// Remaining returns the number of requests that can still be sent before the most constrained quota is exhausted
+func (q RequestQuotas) Remaining() int64 {
+	if len(q) == 0 {
+		return math.MaxInt64
+	}
+
+	remaining := int64(math.MaxInt64)
+	for _, quota := range q {
+		if r := quota.MaxRequests - quota.UsedRequests; r < remaining {
+			remaining = r
+		}
+	}
+
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// NextReset returns the time the most constrained quota resets. The zero time is returned if there are no quotas.
+func (q RequestQuotas) NextReset() time.Time {
+	var (
+		reset     time.Time
+		remaining = int64(math.MaxInt64)
+	)
+
+	for _, quota := range q {
+		if r := quota.MaxRequests - quota.UsedRequests; r < remaining {
+			remaining = r
+			reset, _ = MsEpochToTime(quota.NextResetTime)
+		}
+	}
+
+	return reset
+}
+
+// QuotaLimiter keeps a batch of requests within the company's request quotas.
+// It is safe for concurrent use.
+type QuotaLimiter struct {
+	mu         sync.Mutex
+	client     *Client
+	budget     int64
+	resetAt    time.Time
+	refreshing chan struct{} // closed when the refresh in progress completes
+
+	// Reserve is the number of requests left untouched for other tools sharing the company quota
+	Reserve int64
+
+	// RefreshInterval is how often the quotas are re-read while requests remain, defaults to one minute
+	RefreshInterval time.Duration
+
+	// MaxWait is the longest Wait blocks for a quota reset before giving up, zero means no limit
+	MaxWait time.Duration
+}
+
+// ErrQuotaExhausted is returned by QuotaLimiter.Wait when the quota does not reset within MaxWait
+var ErrQuotaExhausted = errors.New("request quota exhausted")
+
+// NewQuotaLimiter returns a QuotaLimiter that reads the quotas of the given Client
+func NewQuotaLimiter(c *Client) *QuotaLimiter {
+	return &QuotaLimiter{client: c, RefreshInterval: time.Minute}
+}
+
+// Wait blocks until a request can be sent without exceeding the request quotas, then claims it.
+// It returns ctx.Err() if ctx is done first.
+func (l *QuotaLimiter) Wait(ctx context.Context) error {
+	start := time.Now()
+
+	for {
+		l.mu.Lock()
+
+		// only one waiter re-reads the quotas, the rest wait for it without holding the lock
+		if done := l.refreshing; done != nil {
+			l.mu.Unlock()
+			select {
+			case <-done:
+				continue
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+
+		if l.resetAt.IsZero() || !time.Now().Before(l.resetAt) {
+			done := make(chan struct{})
+			l.refreshing = done
+			l.mu.Unlock()
+
+			quotas, err := l.client.RequestQuotas()
+
+			l.mu.Lock()
+			l.refreshing = nil
+			close(done)
+			if err != nil {
+				l.mu.Unlock()
+				return err
+			}
+			l.apply(quotas)
+		}
+
+		if l.budget > 0 {
+			l.budget--
+			l.mu.Unlock()
+			return nil
+		}
+
+		wait := time.Until(l.resetAt)
+		l.mu.Unlock()
+
+		if l.MaxWait > 0 && time.Since(start)+wait > l.MaxWait {
+			return ErrQuotaExhausted
+		}
+
+		t := time.NewTimer(wait)
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+			t.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// Remaining returns the number of requests the limiter will allow before the quotas are re-read
+func (l *QuotaLimiter) Remaining() int64 {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.budget
+}
+
+// apply sets the budget from freshly read quotas, it must be called with l.mu held
+func (l *QuotaLimiter) apply(quotas RequestQuotas) {
+	interval := l.RefreshInterval
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	l.budget = quotas.Remaining() - l.Reserve
+	l.resetAt = time.Now().Add(interval)
+
+	if l.budget <= 0 {
+		l.budget = 0
+		if reset := quotas.NextReset(); reset.After(time.Now()) {
+			l.resetAt = reset
+		}
+	}
+}
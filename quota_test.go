@@ -0,0 +1,191 @@
+package trustar
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRequestQuotas(t *testing.T) {
+	soon := time.Now().Add(time.Minute).Truncate(time.Millisecond)
+	later := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+
+	tests := []struct {
+		name      string
+		quotas    RequestQuotas
+		remaining int64
+		reset     time.Time
+	}{
+		{name: "none", remaining: 1<<63 - 1},
+		{
+			name: "most constrained",
+			quotas: RequestQuotas{
+				{MaxRequests: 100, UsedRequests: 10, NextResetTime: TimeToMsEpoch(soon)},
+				{MaxRequests: 1000, UsedRequests: 995, NextResetTime: TimeToMsEpoch(later)},
+			},
+			remaining: 5,
+			reset:     later,
+		},
+		{
+			name:      "overused",
+			quotas:    RequestQuotas{{MaxRequests: 10, UsedRequests: 12, NextResetTime: TimeToMsEpoch(soon)}},
+			remaining: 0,
+			reset:     soon,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.quotas.Remaining(); got != tt.remaining {
+				t.Errorf("got remaining %d, want %d", got, tt.remaining)
+			}
+			if got := tt.quotas.NextReset(); !got.Equal(tt.reset) {
+				t.Errorf("got reset %v, want %v", got, tt.reset)
+			}
+		})
+	}
+}
+
+// quotaServer reports the given quotas, counting the requests for them
+func quotaServer(quotas RequestQuotas, reads *int32) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(reads, 1)
+		writeJSON(w, quotas)
+	}
+}
+
+func TestQuotaLimiterWait(t *testing.T) {
+	var reads int32
+	c, done := newTestClient(t, quotaServer(RequestQuotas{{MaxRequests: 10, UsedRequests: 5}}, &reads))
+	defer done()
+
+	l := NewQuotaLimiter(c)
+	l.Reserve = 2
+	l.RefreshInterval = time.Hour
+
+	for i := 0; i < 3; i++ {
+		if err := l.Wait(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := l.Remaining(); got != 0 {
+		t.Errorf("got %d remaining, want 0 after claiming the budget less the reserve", got)
+	}
+	if n := atomic.LoadInt32(&reads); n != 1 {
+		t.Errorf("got %d quota reads, want 1 within the refresh interval", n)
+	}
+}
+
+func TestQuotaLimiterExhausted(t *testing.T) {
+	var reads int32
+	reset := TimeToMsEpoch(time.Now().Add(time.Hour))
+	c, done := newTestClient(t, quotaServer(RequestQuotas{{MaxRequests: 10, UsedRequests: 10, NextResetTime: reset}}, &reads))
+	defer done()
+
+	l := NewQuotaLimiter(c)
+	l.MaxWait = time.Minute
+	if err := l.Wait(context.Background()); err != ErrQuotaExhausted {
+		t.Errorf("got error %v, want %v", err, ErrQuotaExhausted)
+	}
+
+	l = NewQuotaLimiter(c)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := l.Wait(ctx); err != context.DeadlineExceeded {
+		t.Errorf("got error %v, want %v", err, context.DeadlineExceeded)
+	}
+}
+
+func TestQuotaLimiterWaitsForReset(t *testing.T) {
+	var reads int32
+	// whole milliseconds, as NextResetTime cannot carry anything finer
+	reset := time.Now().Add(50 * time.Millisecond).Truncate(time.Millisecond)
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&reads, 1) == 1 {
+			writeJSON(w, RequestQuotas{{MaxRequests: 10, UsedRequests: 10, NextResetTime: TimeToMsEpoch(reset)}})
+			return
+		}
+		writeJSON(w, RequestQuotas{{MaxRequests: 10}})
+	})
+	defer done()
+
+	if err := NewQuotaLimiter(c).Wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if time.Now().Before(reset) {
+		t.Error("Wait returned before the quota reset")
+	}
+	if n := atomic.LoadInt32(&reads); n != 2 {
+		t.Errorf("got %d quota reads, want 2", n)
+	}
+}
+
+func TestQuotaLimiterRefreshUnlocked(t *testing.T) {
+	var reads int32
+	release := make(chan struct{})
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&reads, 1)
+		<-release
+		writeJSON(w, RequestQuotas{{MaxRequests: 10}})
+	})
+	defer done()
+
+	l := NewQuotaLimiter(c)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- l.Wait(context.Background())
+		}()
+	}
+
+	for atomic.LoadInt32(&reads) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// a waiter cancelled during another waiter's refresh returns without waiting for it
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled := make(chan error)
+	go func() { cancelled <- l.Wait(ctx) }()
+
+	time.Sleep(10 * time.Millisecond)
+	cancel()
+	select {
+	case err := <-cancelled:
+		if err != context.Canceled {
+			t.Errorf("got error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("cancelled Wait blocked on the refresh")
+	}
+
+	remaining := make(chan int64)
+	go func() { remaining <- l.Remaining() }()
+	select {
+	case <-remaining:
+	case <-time.After(time.Second):
+		t.Fatal("Remaining blocked on the refresh")
+	}
+
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	if n := atomic.LoadInt32(&reads); n != 1 {
+		t.Errorf("got %d quota reads, want a single refresh shared by the waiters", n)
+	}
+	if got := l.Remaining(); got != 6 {
+		t.Errorf("got %d remaining, want 6", got)
+	}
+}
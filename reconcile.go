@@ -0,0 +1,182 @@
+package trustar
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+const (
+	// DefaultMaxDeleteFraction is the largest share of the current whitelist ReconcileWhitelist deletes without MaxDeleteFraction being raised
+	DefaultMaxDeleteFraction = 0.2
+
+	// whitelistChunkSize is the number of values sent per WhitelistIndicators request
+	whitelistChunkSize = 1000
+)
+
+// ParseWhitelist reads a desired whitelist with one indicator value per line.
+// Blank lines and lines starting with # are ignored.
+func ParseWhitelist(r io.Reader) ([]string, error) {
+	var values []string
+	seen := make(map[string]bool)
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key := strings.ToLower(line)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		values = append(values, line)
+	}
+
+	return values, scanner.Err()
+}
+
+// WhitelistPlan lists the changes needed to make the company whitelist match a desired list
+type WhitelistPlan struct {
+	Add     []string    // values to whitelist
+	Delete  []Indicator // whitelist entries to remove
+	Current int         // number of entries currently whitelisted
+	Keep    int         // number of entries already matching the desired list
+}
+
+// Empty reports whether the whitelist already matches
+func (p *WhitelistPlan) Empty() bool {
+	return len(p.Add) == 0 && len(p.Delete) == 0
+}
+
+// WriteTo writes the plan as a diff, one "+ value" or "- TYPE value" line per change, followed by a summary line
+func (p *WhitelistPlan) WriteTo(w io.Writer) (int64, error) {
+	var b strings.Builder
+	for _, v := range p.Add {
+		fmt.Fprintf(&b, "+ %s\n", v)
+	}
+	for _, ind := range p.Delete {
+		fmt.Fprintf(&b, "- %s %s\n", ind.IndicatorType, ind.Value)
+	}
+	fmt.Fprintf(&b, "%d to add, %d to delete, %d unchanged\n", len(p.Add), len(p.Delete), p.Keep)
+
+	n, err := io.WriteString(w, b.String())
+	return int64(n), err
+}
+
+// PlanWhitelist compares the desired values with every page of the company whitelist.
+// Values are compared case-insensitively.
+func (c *Client) PlanWhitelist(desired []string) (*WhitelistPlan, error) {
+	want := make(map[string]string, len(desired))
+	for _, v := range desired {
+		v = strings.TrimSpace(v)
+		if v != "" {
+			want[strings.ToLower(v)] = v
+		}
+	}
+
+	plan := &WhitelistPlan{}
+	have := make(map[string]bool)
+	err := c.ForEachWhitelistIndicator(url.Values{}, func(ind Indicator) error {
+		key := strings.ToLower(ind.Value)
+		if have[key] {
+			return nil
+		}
+		have[key] = true
+		plan.Current++
+
+		if _, ok := want[key]; ok {
+			plan.Keep++
+		} else {
+			plan.Delete = append(plan.Delete, ind)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for key, v := range want {
+		if !have[key] {
+			plan.Add = append(plan.Add, v)
+		}
+	}
+
+	sort.Strings(plan.Add)
+	sort.Slice(plan.Delete, func(i, j int) bool { return plan.Delete[i].Value < plan.Delete[j].Value })
+
+	return plan, nil
+}
+
+// ReconcileOptions controls ReconcileWhitelist
+type ReconcileOptions struct {
+	// DryRun returns the plan without changing the whitelist
+	DryRun bool
+
+	// MaxDeleteFraction is the largest share of the current entries that may be deleted, DefaultMaxDeleteFraction if nil.
+	// Point it at 0 to allow no deletes, or at 1 to allow emptying the whitelist.
+	MaxDeleteFraction *float64
+
+	// Cache is updated with the changes when set, so it does not have to be reloaded
+	Cache *WhitelistCache
+}
+
+// ReconcileWhitelist makes the company whitelist match the desired values, sending only the
+// WhitelistIndicators and DeleteFromWhitelist calls needed. The plan is returned even when applying it fails.
+func (c *Client) ReconcileWhitelist(desired []string, opts ReconcileOptions) (*WhitelistPlan, error) {
+	plan, err := c.PlanWhitelist(desired)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := DefaultMaxDeleteFraction
+	if opts.MaxDeleteFraction != nil {
+		limit = *opts.MaxDeleteFraction
+	}
+	if limit < 0 {
+		return plan, fmt.Errorf("invalid MaxDeleteFraction %g, it must not be negative", limit)
+	}
+	if plan.Current > 0 && float64(len(plan.Delete)) > limit*float64(plan.Current) {
+		return plan, fmt.Errorf("refusing to delete %d of %d whitelist entries, more than the allowed %.0f%%", len(plan.Delete), plan.Current, limit*100)
+	}
+
+	if opts.DryRun {
+		return plan, nil
+	}
+
+	for start := 0; start < len(plan.Add); start += whitelistChunkSize {
+		end := start + whitelistChunkSize
+		if end > len(plan.Add) {
+			end = len(plan.Add)
+		}
+
+		if opts.Cache != nil {
+			err = opts.Cache.Add(plan.Add[start:end])
+		} else {
+			err = c.WhitelistIndicators(plan.Add[start:end])
+		}
+		if err != nil {
+			return plan, err
+		}
+	}
+
+	for _, ind := range plan.Delete {
+		if opts.Cache != nil {
+			err = opts.Cache.Delete(ind.IndicatorType, ind.Value)
+		} else {
+			v := url.Values{}
+			v.Set("indicatorType", ind.IndicatorType)
+			v.Set("value", ind.Value)
+			err = c.DeleteFromWhitelist(v)
+		}
+		if err != nil {
+			return plan, fmt.Errorf("deleting %s from the whitelist: %v", ind.Value, err)
+		}
+	}
+
+	return plan, nil
+}
@@ -0,0 +1,142 @@
+package trustar
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestParseWhitelist(t *testing.T) {
+	values, err := ParseWhitelist(strings.NewReader("# comment\na.com\n\n  B.com  \nb.COM\n10.0.0.0/8\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.com", "B.com", "10.0.0.0/8"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got %v, want %v", values, want)
+	}
+}
+
+func reconcileEntries() []Indicator {
+	return []Indicator{
+		{Value: "keep.com", IndicatorType: "DOMAIN"},
+		{Value: "KEEP2.com", IndicatorType: "DOMAIN"},
+		{Value: "old.com", IndicatorType: "DOMAIN"},
+		{Value: "1.1.1.1", IndicatorType: "IP"},
+		{Value: "keep3.com", IndicatorType: "DOMAIN"},
+	}
+}
+
+func TestPlanWhitelist(t *testing.T) {
+	srv := &whitelistServer{entries: reconcileEntries()}
+	c, done := newTestClient(t, srv.ServeHTTP)
+	defer done()
+
+	plan, err := c.PlanWhitelist([]string{"keep.com", "keep2.com", "keep3.com", "new.com", " ", "a.io"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := []string{"a.io", "new.com"}; !reflect.DeepEqual(plan.Add, want) {
+		t.Errorf("got add %v, want %v", plan.Add, want)
+	}
+	if len(plan.Delete) != 2 || plan.Delete[0].Value != "1.1.1.1" || plan.Delete[1].Value != "old.com" {
+		t.Errorf("got delete %v", plan.Delete)
+	}
+	if plan.Current != 5 || plan.Keep != 3 || plan.Empty() {
+		t.Errorf("got %+v", plan)
+	}
+
+	var b bytes.Buffer
+	if _, err := plan.WriteTo(&b); err != nil {
+		t.Fatal(err)
+	}
+	want := "+ a.io\n+ new.com\n- IP 1.1.1.1\n- DOMAIN old.com\n2 to add, 2 to delete, 3 unchanged\n"
+	if b.String() != want {
+		t.Errorf("got\n%s\nwant\n%s", b.String(), want)
+	}
+
+	if plan, _ := c.PlanWhitelist([]string{"keep.com", "keep2.com", "keep3.com", "old.com", "1.1.1.1"}); !plan.Empty() {
+		t.Errorf("got %+v, want an empty plan", plan)
+	}
+}
+
+// fraction returns a pointer for ReconcileOptions.MaxDeleteFraction
+func fraction(f float64) *float64 {
+	return &f
+}
+
+func TestReconcileWhitelist(t *testing.T) {
+	desired := []string{"keep.com", "keep2.com", "keep3.com", "new.com"}
+
+	tests := []struct {
+		name    string
+		opts    ReconcileOptions
+		err     string
+		changed bool
+	}{
+		{name: "delete guard", err: "refusing to delete 2 of 5 whitelist entries, more than the allowed 20%"},
+		{name: "no deletes", opts: ReconcileOptions{MaxDeleteFraction: fraction(0)}, err: "refusing to delete 2 of 5 whitelist entries, more than the allowed 0%"},
+		{name: "negative", opts: ReconcileOptions{MaxDeleteFraction: fraction(-1)}, err: "invalid MaxDeleteFraction -1, it must not be negative"},
+		{name: "dry run", opts: ReconcileOptions{DryRun: true, MaxDeleteFraction: fraction(1)}},
+		{name: "apply", opts: ReconcileOptions{MaxDeleteFraction: fraction(0.4)}, changed: true},
+		{name: "apply through a cache", opts: ReconcileOptions{MaxDeleteFraction: fraction(0.5)}, changed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := &whitelistServer{entries: reconcileEntries(), types: map[string]string{"new.com": "DOMAIN"}}
+			c, done := newTestClient(t, srv.ServeHTTP)
+			defer done()
+
+			if strings.HasSuffix(tt.name, "cache") {
+				tt.opts.Cache = NewWhitelistCache(c)
+				if err := tt.opts.Cache.Load(); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			plan, err := c.ReconcileWhitelist(desired, tt.opts)
+			if (err != nil || tt.err != "") && (err == nil || err.Error() != tt.err) {
+				t.Fatalf("got error %v, want %q", err, tt.err)
+			}
+			if plan == nil || len(plan.Add) != 1 || len(plan.Delete) != 2 {
+				t.Fatalf("got plan %+v, want it returned", plan)
+			}
+
+			var values []string
+			for _, ind := range srv.entries {
+				values = append(values, strings.ToLower(ind.Value))
+			}
+			sort.Strings(values)
+
+			want := []string{"1.1.1.1", "keep.com", "keep2.com", "keep3.com", "old.com"}
+			if tt.changed {
+				want = []string{"keep.com", "keep2.com", "keep3.com", "new.com"}
+			}
+			if !reflect.DeepEqual(values, want) {
+				t.Errorf("got whitelist %v, want %v", values, want)
+			}
+
+			if tt.changed {
+				if got := srv.deletes[0].Encode(); got != "indicatorType=IP&value=1.1.1.1" {
+					t.Errorf("got delete %s, want the entry's type sent", got)
+				}
+			}
+
+			if cache := tt.opts.Cache; cache != nil {
+				if ok, _ := cache.Contains("www.new.com"); !ok {
+					t.Error("got the cache without the added domain")
+				}
+				if ok, _ := cache.Contains("old.com"); ok {
+					t.Error("got the cache still holding a deleted entry")
+				}
+				// one load for the cache and one for the plan
+				if srv.loads != 2 {
+					t.Errorf("got %d loads, want the cache updated in place", srv.loads)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Package redact scrubs internal details such as host names, employee email addresses and
+// internal IP ranges from reports before they are shared.
+package redact
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// DefaultReplacement replaces redacted values. %s is replaced with the rule name.
+const DefaultReplacement = "[REDACTED:%s]"
+
+// Redaction records one redacted value. Value holds the original text, so reports should be kept as
+// private as the reports they describe.
+type Redaction struct {
+	Rule  string `json:"rule"`
+	Field string `json:"field"` // title or reportBody
+	Value string `json:"value"`
+	Start int    `json:"start"` // byte offset in the original field
+	End   int    `json:"end"`
+}
+
+// Report lists the redactions made to a submission
+type Report struct {
+	Redactions []Redaction `json:"redactions"`
+}
+
+// Count returns the number of redacted values
+func (r *Report) Count() int {
+	return len(r.Redactions)
+}
+
+// ByRule returns the number of redactions made by each rule
+func (r *Report) ByRule() map[string]int {
+	counts := make(map[string]int)
+	for _, red := range r.Redactions {
+		counts[red.Rule]++
+	}
+	return counts
+}
+
+// Engine applies a set of rules to report titles and bodies
+type Engine struct {
+	Rules []Rule
+
+	// Replacement replaces each redacted value, defaults to DefaultReplacement. A %s is replaced with the rule name.
+	Replacement string
+
+	// Distributions limits the hook to submissions with these distribution types, such as COMMUNITY. All submissions are redacted when empty.
+	Distributions []string
+
+	// OnRedact is called by the hook with each redacted submission and its report
+	OnRedact func(trustar.ReportSubmission, *Report)
+}
+
+// New returns an Engine applying the given rules
+func New(rules ...Rule) *Engine {
+	return &Engine{Rules: rules, Replacement: DefaultReplacement}
+}
+
+// Redact returns the submission with its title and body redacted, and a report of what was removed
+func (e *Engine) Redact(s trustar.ReportSubmission) (trustar.ReportSubmission, *Report, error) {
+	report := &Report{}
+
+	title, redactions, err := e.RedactText("title", s.Title)
+	if err != nil {
+		return s, nil, err
+	}
+	report.Redactions = append(report.Redactions, redactions...)
+
+	body, redactions, err := e.RedactText("reportBody", s.ReportBody)
+	if err != nil {
+		return s, nil, err
+	}
+	report.Redactions = append(report.Redactions, redactions...)
+
+	s.Title, s.ReportBody = title, body
+	return s, report, nil
+}
+
+// RedactText applies the rules to a text. Where matches of several rules overlap, the one starting first
+// is kept, and the longer one when they start at the same place; earlier rules win ties.
+func (e *Engine) RedactText(field, text string) (string, []Redaction, error) {
+	if text == "" {
+		return text, nil, nil
+	}
+
+	var found []Redaction
+	for _, rule := range e.Rules {
+		matches, err := rule.Find(text)
+		if err != nil {
+			return text, nil, err
+		}
+		for _, m := range matches {
+			if m.Start < 0 || m.End > len(text) || m.Start >= m.End {
+				continue
+			}
+			found = append(found, Redaction{Rule: rule.Name(), Field: field, Value: text[m.Start:m.End], Start: m.Start, End: m.End})
+		}
+	}
+
+	if len(found) == 0 {
+		return text, nil, nil
+	}
+
+	sort.SliceStable(found, func(i, j int) bool {
+		if found[i].Start != found[j].Start {
+			return found[i].Start < found[j].Start
+		}
+		return found[i].End > found[j].End
+	})
+
+	replacement := e.Replacement
+	if replacement == "" {
+		replacement = DefaultReplacement
+	}
+
+	var (
+		b    strings.Builder
+		kept []Redaction
+		cur  int
+	)
+	for _, red := range found {
+		if red.Start < cur {
+			continue
+		}
+		b.WriteString(text[cur:red.Start])
+		if strings.Contains(replacement, "%s") {
+			fmt.Fprintf(&b, replacement, red.Rule)
+		} else {
+			b.WriteString(replacement)
+		}
+		cur = red.End
+		kept = append(kept, red)
+	}
+	b.WriteString(text[cur:])
+
+	return b.String(), kept, nil
+}
+
+// Hook returns a ReportHook that redacts every report before it is sent
+func (e *Engine) Hook() trustar.ReportHook {
+	return func(s trustar.ReportSubmission) (trustar.ReportSubmission, error) {
+		if !e.applies(s) {
+			return s, nil
+		}
+
+		redacted, report, err := e.Redact(s)
+		if err != nil {
+			return s, fmt.Errorf("redacting report: %v", err)
+		}
+		if e.OnRedact != nil {
+			e.OnRedact(redacted, report)
+		}
+		return redacted, nil
+	}
+}
+
+// Install adds the Engine's hook to a Client, so SubmitReport and UpdateReport never send an unredacted report
+func (e *Engine) Install(c *trustar.Client) {
+	c.ReportHooks = append(c.ReportHooks, e.Hook())
+}
+
+func (e *Engine) applies(s trustar.ReportSubmission) bool {
+	if len(e.Distributions) == 0 {
+		return true
+	}
+	for _, d := range e.Distributions {
+		if strings.EqualFold(d, s.DistributionType) {
+			return true
+		}
+	}
+	return false
+}
+
+// Middleware returns a Client middleware that redacts the reports sent by SubmitReport and UpdateReport.
+// It does the same as Install for clients whose requests are built elsewhere, such as through a shared chain.
+func (e *Engine) Middleware() trustar.Middleware {
+	hook := e.Hook()
+
+	return func(next trustar.Handler) trustar.Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			switch trustar.Operation(req) {
+			case "SubmitReport", "UpdateReport":
+			default:
+				return next(req)
+			}
+			if req.Body == nil {
+				return next(req)
+			}
+
+			data, err := ioutil.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+
+			var s trustar.ReportSubmission
+			if err = json.Unmarshal(data, &s); err != nil {
+				return nil, fmt.Errorf("redacting report: %v", err)
+			}
+			if s, err = hook(s); err != nil {
+				return nil, err
+			}
+			if data, err = json.Marshal(s); err != nil {
+				return nil, err
+			}
+
+			req = req.Clone(req.Context())
+			req.Body = ioutil.NopCloser(bytes.NewReader(data))
+			req.GetBody = func() (io.ReadCloser, error) {
+				return ioutil.NopCloser(bytes.NewReader(data)), nil
+			}
+			req.ContentLength = int64(len(data))
+
+			return next(req)
+		}
+	}
+}
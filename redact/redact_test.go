@@ -0,0 +1,236 @@
+package redact
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+func testEngine() *Engine {
+	return New(
+		Hostnames("host", "corp.example.com"),
+		EmailDomains("email", "corp.example.com"),
+		MustCIDR("internal", "10.0.0.0/8"),
+	)
+}
+
+func TestRedactText(t *testing.T) {
+	tests := []struct {
+		name        string
+		replacement string
+		text        string
+		want        string
+		rules       []string
+	}{
+		{
+			name: "default replacement",
+			text: "web01.corp.example.com at 10.1.2.3",
+			want: "[REDACTED:host] at [REDACTED:internal]",
+		},
+		{
+			// the email match starts first, so it wins over the host name inside it
+			name: "overlap",
+			text: "mail jane@web.corp.example.com now",
+			want: "mail [REDACTED:email] now",
+		},
+		{
+			name:        "fixed replacement",
+			replacement: "***",
+			text:        "10.0.0.1,10.0.0.2",
+			want:        "***,***",
+		},
+		{
+			name: "nothing to redact",
+			text: "evil.com at 8.8.8.8",
+			want: "evil.com at 8.8.8.8",
+		},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := testEngine()
+			if tt.replacement != "" {
+				e.Replacement = tt.replacement
+			}
+			got, _, err := e.RedactText("reportBody", tt.text)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactTies(t *testing.T) {
+	// matches starting at the same place keep the longer one, then the earlier rule
+	e := &Engine{Rules: []Rule{
+		Literals("short", "secret"),
+		Literals("long", "secret plan"),
+		Literals("same", "secret"),
+	}}
+
+	got, redactions, err := e.RedactText("title", "the secret plan and a secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "the [REDACTED:long] and a [REDACTED:short]"; got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	want := []Redaction{
+		{Rule: "long", Field: "title", Value: "secret plan", Start: 4, End: 15},
+		{Rule: "short", Field: "title", Value: "secret", Start: 22, End: 28},
+	}
+	if !reflect.DeepEqual(redactions, want) {
+		t.Errorf("got %+v, want %+v", redactions, want)
+	}
+}
+
+func TestRedact(t *testing.T) {
+	s := trustar.ReportSubmission{
+		Title:            "Incident on web01.corp.example.com",
+		ReportBody:       "jane@corp.example.com saw 10.9.9.9 and 10.8.8.8",
+		DistributionType: "ENCLAVE",
+		EnclaveIds:       []string{"e1"},
+	}
+
+	redacted, report, err := testEngine().Redact(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if redacted.Title != "Incident on [REDACTED:host]" || redacted.ReportBody != "[REDACTED:email] saw [REDACTED:internal] and [REDACTED:internal]" {
+		t.Errorf("got %+v", redacted)
+	}
+	if redacted.EnclaveIds[0] != "e1" {
+		t.Errorf("got %+v, want the other fields kept", redacted)
+	}
+
+	if report.Count() != 4 {
+		t.Errorf("got %d redactions, want 4", report.Count())
+	}
+	if want := map[string]int{"host": 1, "email": 1, "internal": 2}; !reflect.DeepEqual(report.ByRule(), want) {
+		t.Errorf("got %v, want %v", report.ByRule(), want)
+	}
+	if r := report.Redactions[0]; r.Field != "title" || r.Value != "web01.corp.example.com" {
+		t.Errorf("got %+v", r)
+	}
+}
+
+// failingRule fails every search
+type failingRule struct{}
+
+func (failingRule) Name() string                 { return "failing" }
+func (failingRule) Find(string) ([]Match, error) { return nil, errors.New("lookup failed") }
+
+func TestHook(t *testing.T) {
+	e := testEngine()
+	e.Distributions = []string{"community"}
+
+	var reports []*Report
+	e.OnRedact = func(s trustar.ReportSubmission, r *Report) { reports = append(reports, r) }
+
+	hook := e.Hook()
+
+	s, err := hook(trustar.ReportSubmission{DistributionType: "COMMUNITY", ReportBody: "from 10.0.0.1"})
+	if err != nil || s.ReportBody != "from [REDACTED:internal]" {
+		t.Errorf("got %q, %v", s.ReportBody, err)
+	}
+	if len(reports) != 1 || reports[0].Count() != 1 {
+		t.Errorf("got reports %+v, want OnRedact called once", reports)
+	}
+
+	s, err = hook(trustar.ReportSubmission{DistributionType: "ENCLAVE", ReportBody: "from 10.0.0.1"})
+	if err != nil || s.ReportBody != "from 10.0.0.1" || len(reports) != 1 {
+		t.Errorf("got %q, %v, want distributions outside the list left alone", s.ReportBody, err)
+	}
+
+	failing := New(failingRule{}).Hook()
+	if _, err := failing(trustar.ReportSubmission{Title: "x"}); err == nil || err.Error() != "redacting report: lookup failed" {
+		t.Errorf("got %v", err)
+	}
+}
+
+// reportServer records the submissions it receives
+func reportServer(t *testing.T, received *[]trustar.ReportSubmission) (*trustar.Client, func()) {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var s trustar.ReportSubmission
+		if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+			t.Error(err)
+		}
+		*received = append(*received, s)
+		w.Write([]byte("guid"))
+	}))
+
+	c, err := trustar.NewClient("id", "secret", srv.URL+"/")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	c.SetAccessToken("token")
+	return c, srv.Close
+}
+
+func TestInstall(t *testing.T) {
+	var received []trustar.ReportSubmission
+	c, done := reportServer(t, &received)
+	defer done()
+
+	testEngine().Install(c)
+
+	report := trustar.ReportSubmission{Title: "t", ReportBody: "host web01.corp.example.com", DistributionType: "COMMUNITY"}
+	if _, err := c.SubmitReport(report); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateReport("guid", report); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, s := range received {
+		if s.ReportBody != "host [REDACTED:host]" {
+			t.Errorf("got body %q sent, want it redacted", s.ReportBody)
+		}
+	}
+	if len(received) != 2 {
+		t.Errorf("got %d requests, want 2", len(received))
+	}
+
+	c.ReportHooks = append(c.ReportHooks, New(failingRule{}).Hook())
+	if _, err := c.SubmitReport(report); err == nil || !strings.Contains(err.Error(), "lookup failed") {
+		t.Errorf("got %v, want the hook error", err)
+	}
+	if len(received) != 2 {
+		t.Error("got a report sent after its hook failed")
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var received []trustar.ReportSubmission
+	c, done := reportServer(t, &received)
+	defer done()
+
+	c.Use(testEngine().Middleware())
+
+	report := trustar.ReportSubmission{Title: "from 10.1.1.1", ReportBody: "b", DistributionType: "COMMUNITY"}
+	if _, err := c.SubmitReport(report); err != nil {
+		t.Fatal(err)
+	}
+	if len(received) != 1 || received[0].Title != "from [REDACTED:internal]" {
+		t.Errorf("got %+v, want the title redacted", received)
+	}
+
+	c.Use(New(failingRule{}).Middleware())
+	if _, err := c.SubmitReport(report); err == nil || !strings.Contains(err.Error(), "lookup failed") {
+		t.Errorf("got %v, want the hook error", err)
+	}
+}
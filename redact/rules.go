@@ -0,0 +1,195 @@
+package redact
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"sort"
+	"strings"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// Match is the byte range [Start, End) of a value to redact
+type Match struct {
+	Start, End int
+}
+
+// Rule finds the values to redact in a text
+type Rule interface {
+	Name() string
+	Find(text string) ([]Match, error)
+}
+
+// candidate patterns for values rules check one at a time
+var (
+	ipv4Pattern  = regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}(?:/\d{1,2})?\b`)
+	ipv6Pattern  = regexp.MustCompile(`(?i)\b[0-9a-f]{0,4}(?::[0-9a-f]{0,4}){2,7}(?:/\d{1,3})?`)
+	tokenPattern = regexp.MustCompile(`(?i)\b(?:[a-z][a-z0-9+.-]*://[^\s<>"']+|[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}|(?:[a-z0-9_-]+\.)+[a-z]{2,}|(?:\d{1,3}\.){3}\d{1,3})`)
+)
+
+// regexpRule redacts the matches of a regular expression
+type regexpRule struct {
+	name string
+	re   *regexp.Regexp
+}
+
+// Regexp returns a rule redacting the matches of a regular expression
+func Regexp(name, pattern string) (Rule, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("rule %s: %v", name, err)
+	}
+	return &regexpRule{name: name, re: re}, nil
+}
+
+// MustRegexp is like Regexp but panics if the pattern does not compile
+func MustRegexp(name, pattern string) Rule {
+	r, err := Regexp(name, pattern)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r *regexpRule) Name() string { return r.name }
+
+func (r *regexpRule) Find(text string) ([]Match, error) {
+	return toMatches(r.re.FindAllStringIndex(text, -1)), nil
+}
+
+// Literals returns a rule redacting the given values wherever they appear, ignoring case.
+// Longer values are preferred when values overlap.
+func Literals(name string, values ...string) Rule {
+	sorted := make([]string, 0, len(values))
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			sorted = append(sorted, regexp.QuoteMeta(v))
+		}
+	}
+	if len(sorted) == 0 {
+		return &regexpRule{name: name, re: regexp.MustCompile(`$^`)}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	return &regexpRule{name: name, re: regexp.MustCompile(`(?i)` + strings.Join(sorted, "|"))}
+}
+
+// EmailDomains returns a rule redacting email addresses at the given domains and their subdomains
+func EmailDomains(name string, domains ...string) Rule {
+	return &regexpRule{name: name, re: regexp.MustCompile(`(?i)\b[a-z0-9._%+-]+@(?:[a-z0-9-]+\.)*` + domainAlternation(domains) + `\b`)}
+}
+
+// Hostnames returns a rule redacting host names under the given domains, such as corp.example.com
+func Hostnames(name string, domains ...string) Rule {
+	return &regexpRule{name: name, re: regexp.MustCompile(`(?i)\b(?:[a-z0-9-]+\.)*` + domainAlternation(domains) + `\b`)}
+}
+
+func domainAlternation(domains []string) string {
+	quoted := make([]string, 0, len(domains))
+	for _, d := range domains {
+		if d = strings.Trim(strings.TrimSpace(d), "."); d != "" {
+			quoted = append(quoted, regexp.QuoteMeta(d))
+		}
+	}
+	if len(quoted) == 0 {
+		return `$^`
+	}
+	return `(?:` + strings.Join(quoted, "|") + `)`
+}
+
+// cidrRule redacts IP addresses inside a set of networks
+type cidrRule struct {
+	name     string
+	networks []*net.IPNet
+}
+
+// CIDR returns a rule redacting IP addresses and CIDR blocks that fall inside the given networks
+func CIDR(name string, cidrs ...string) (Rule, error) {
+	r := &cidrRule{name: name}
+	for _, c := range cidrs {
+		_, network, err := net.ParseCIDR(strings.TrimSpace(c))
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %v", name, err)
+		}
+		r.networks = append(r.networks, network)
+	}
+	return r, nil
+}
+
+// MustCIDR is like CIDR but panics if a network does not parse
+func MustCIDR(name string, cidrs ...string) Rule {
+	r, err := CIDR(name, cidrs...)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+func (r *cidrRule) Name() string { return r.name }
+
+func (r *cidrRule) Find(text string) ([]Match, error) {
+	var matches []Match
+	for _, re := range []*regexp.Regexp{ipv4Pattern, ipv6Pattern} {
+		for _, loc := range re.FindAllStringIndex(text, -1) {
+			if ip := parseIP(text[loc[0]:loc[1]]); ip != nil && r.contains(ip) {
+				matches = append(matches, Match{loc[0], loc[1]})
+			}
+		}
+	}
+	return matches, nil
+}
+
+func (r *cidrRule) contains(ip net.IP) bool {
+	for _, network := range r.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseIP parses an IP address, or the network address of a CIDR block
+func parseIP(s string) net.IP {
+	if ip, _, err := net.ParseCIDR(s); err == nil {
+		return ip
+	}
+	return net.ParseIP(s)
+}
+
+// whitelistRule redacts values covered by the company whitelist
+type whitelistRule struct {
+	name  string
+	cache *trustar.WhitelistCache
+}
+
+// Whitelist returns a rule redacting IP addresses, domains, URLs and email addresses covered by the company whitelist,
+// for companies that whitelist their own infrastructure
+func Whitelist(name string, cache *trustar.WhitelistCache) Rule {
+	return &whitelistRule{name: name, cache: cache}
+}
+
+func (r *whitelistRule) Name() string { return r.name }
+
+func (r *whitelistRule) Find(text string) ([]Match, error) {
+	var matches []Match
+	for _, loc := range tokenPattern.FindAllStringIndex(text, -1) {
+		value := strings.TrimRight(text[loc[0]:loc[1]], ".,;:)")
+		ok, err := r.cache.Contains(value)
+		if err != nil {
+			return nil, fmt.Errorf("rule %s: %v", r.name, err)
+		}
+		if ok {
+			matches = append(matches, Match{loc[0], loc[0] + len(value)})
+		}
+	}
+	return matches, nil
+}
+
+func toMatches(locs [][]int) []Match {
+	matches := make([]Match, len(locs))
+	for i, loc := range locs {
+		matches[i] = Match{loc[0], loc[1]}
+	}
+	return matches
+}
@@ -0,0 +1,142 @@
+package redact
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// found returns the text of each match
+func found(t *testing.T, r Rule, text string) []string {
+	t.Helper()
+
+	matches, err := r.Find(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var values []string
+	for _, m := range matches {
+		values = append(values, text[m.Start:m.End])
+	}
+	return values
+}
+
+func TestRules(t *testing.T) {
+	tests := []struct {
+		name string
+		rule Rule
+		text string
+		want []string
+	}{
+		{
+			name: "regexp",
+			rule: MustRegexp("ticket", `INC-\d+`),
+			text: "see INC-42 and INC-7",
+			want: []string{"INC-42", "INC-7"},
+		},
+		{
+			name: "literals prefer longer values",
+			rule: Literals("names", "Acme", "acme corp", " ", "Bob"),
+			text: "ACME Corp hired bob from Acme",
+			want: []string{"ACME Corp", "bob", "Acme"},
+		},
+		{
+			name: "no literals",
+			rule: Literals("none", "", " "),
+			text: "anything",
+		},
+		{
+			name: "email domains",
+			rule: EmailDomains("email", "example.com", ".corp.net."),
+			text: "mail jane.doe@example.com, ops@mail.corp.net or eve@example.org",
+			want: []string{"jane.doe@example.com", "ops@mail.corp.net"},
+		},
+		{
+			name: "hostnames",
+			rule: Hostnames("host", "corp.example.com"),
+			text: "web01.corp.example.com talked to corp.example.com and evilcorp.example.net",
+			want: []string{"web01.corp.example.com", "corp.example.com"},
+		},
+		{
+			name: "no hostnames",
+			rule: Hostnames("host", " "),
+			text: "web01.corp.example.com",
+		},
+		{
+			name: "ipv4 networks",
+			rule: MustCIDR("internal", "10.0.0.0/8", "192.168.0.0/16"),
+			text: "10.1.2.3 beaconed to 8.8.8.8 via 192.168.4.0/24, not 999.1.1.1",
+			want: []string{"10.1.2.3", "192.168.4.0/24"},
+		},
+		{
+			name: "ipv6 networks",
+			rule: MustCIDR("internal", "fd00::/8"),
+			text: "fd12:3456::1 and 2001:db8::1",
+			want: []string{"fd12:3456::1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := found(t, tt.rule, tt.text); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRuleErrors(t *testing.T) {
+	if _, err := Regexp("bad", "("); err == nil || !strings.HasPrefix(err.Error(), "rule bad: ") {
+		t.Errorf("got %v, want a compile error naming the rule", err)
+	}
+	if _, err := CIDR("bad", "10.0.0.0/33"); err == nil {
+		t.Error("want an error for an invalid network")
+	}
+
+	for _, fn := range []func(){
+		func() { MustRegexp("bad", "(") },
+		func() { MustCIDR("bad", "nope") },
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Error("want a panic")
+				}
+			}()
+			fn()
+		}()
+	}
+}
+
+func TestWhitelistRule(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/whitelist" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"items":[{"value":"corp.example.com","indicatorType":"DOMAIN"},{"value":"10.0.0.0/8","indicatorType":"CIDR_BLOCK"}]}`))
+	}))
+	defer srv.Close()
+
+	c, err := trustar.NewClient("id", "secret", srv.URL+"/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetAccessToken("token")
+
+	rule := Whitelist("whitelist", trustar.NewWhitelistCache(c))
+	got := found(t, rule, "Seen at https://vpn.corp.example.com/login, admin@corp.example.com and 10.2.3.4; evil.com and 8.8.8.8.")
+	if want := []string{"https://vpn.corp.example.com/login", "admin@corp.example.com", "10.2.3.4"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	broken := trustar.NewWhitelistCache(c)
+	c.APIBase = srv.URL + "/missing/"
+	if _, err := Whitelist("whitelist", broken).Find("evil.com"); err == nil {
+		t.Error("want the whitelist load error")
+	}
+}
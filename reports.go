@@ -3,9 +3,9 @@ package trustar
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io/ioutil"
-	"net/http"
 	"net/url"
 	"strings"
 )
@@ -19,7 +19,7 @@ func (c *Client) GetReports(v url.Values) (ReportResponse, error) {
 	var rr ReportResponse
 
 	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("reports?%s", v.Encode()))
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest("GetReports", "GET", url, nil)
 
 	if err != nil {
 		return rr, err
@@ -32,14 +32,14 @@ func (c *Client) GetReports(v url.Values) (ReportResponse, error) {
 	return rr, nil
 }
 
-// GetReportIndicators Returns a paginated list of all indicators contained in a specified report.
+// GetReportIndicators Returns a paginated list of all indicators contained in a specified report. Pass IDTypeExternal to address the report by its external tracking ID.
 //
 // Endpoint: GET /1.3/reports/{id}/indicators
-func (c *Client) GetReportIndicators(id string, v url.Values) (ReportIndicatorsResponse, error) {
+func (c *Client) GetReportIndicators(id string, v url.Values, idType ...IDType) (ReportIndicatorsResponse, error) {
 	var rir ReportIndicatorsResponse
 
-	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("reports/%s/indicators?%s", id, v.Encode()))
-	req, err := http.NewRequest("GET", url, nil)
+	url := c.reportURL(id, "/indicators", v, idType)
+	req, err := c.newRequest("GetReportIndicators", "GET", url, nil)
 
 	if err != nil {
 		return rir, err
@@ -59,7 +59,7 @@ func (c *Client) FindCorrelatedReports(v url.Values) (CorrelatedReportResponse,
 	var crr CorrelatedReportResponse
 
 	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("reports/correlated?%s", v.Encode()))
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest("FindCorrelatedReports", "GET", url, nil)
 
 	if err != nil {
 		return crr, err
@@ -73,20 +73,27 @@ func (c *Client) FindCorrelatedReports(v url.Values) (CorrelatedReportResponse,
 }
 
 // SubmitReport Submit a new incident report, and receive the ID it has been assigned in TruSTAR’s system.
+// The report passes through c.ReportHooks before it is sent.
 //
 // Endpoint: POST /1.3/reports
 func (c *Client) SubmitReport(report ReportSubmission) (string, error) {
 
 	var guid strings.Builder
 
+	report, err := c.applyReportHooks(report)
+	if err != nil {
+		return "", err
+	}
+
 	i, _ := json.Marshal(report)
 
 	url := fmt.Sprintf("%s%s", c.APIBase, "reports")
-	req, err := http.NewRequest("POST", url, bytes.NewReader(i))
+	req, err := c.newRequest("SubmitReport", "POST", url, bytes.NewReader(i))
 
 	if err != nil {
 		return "", err
 	}
+	req = withEnclaves(req, report.EnclaveIds)
 
 	if err = c.SendWithAuth(req, &guid); err != nil {
 		return "", err
@@ -95,32 +102,38 @@ func (c *Client) SubmitReport(report ReportSubmission) (string, error) {
 	return guid.String(), nil
 }
 
-// UpdateReport Update the report with the specified Trustar report ID.
+// UpdateReport Update the report with the specified Trustar report ID. Pass IDTypeExternal to address the report by its external tracking ID.
 //
 // Endpoint: PUT /1.3/reports/{ID}
-func (c *Client) UpdateReport(id string, report ReportSubmission) error {
+func (c *Client) UpdateReport(id string, report ReportSubmission, idType ...IDType) error {
+
+	report, err := c.applyReportHooks(report)
+	if err != nil {
+		return err
+	}
 
 	i, _ := json.Marshal(report)
 
-	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("reports/%s", id))
-	req, err := http.NewRequest("PUT", url, bytes.NewReader(i))
+	url := c.reportURL(id, "", nil, idType)
+	req, err := c.newRequest("UpdateReport", "PUT", url, bytes.NewReader(i))
 
 	if err != nil {
 		return err
 	}
+	req = withEnclaves(req, report.EnclaveIds)
 
 	return c.SendWithAuth(req, ioutil.Discard)
 }
 
-// GetReportDetails Gets the details for a report for the specified Trustar report id
+// GetReportDetails Gets the details for a report for the specified Trustar report id. Pass IDTypeExternal to address the report by its external tracking ID.
 //
 // Endpoint: GET /1.3/reports/{ID}
-func (c *Client) GetReportDetails(id string) (ReportDetails, error) {
+func (c *Client) GetReportDetails(id string, idType ...IDType) (ReportDetails, error) {
 
 	var rd ReportDetails
 
-	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("reports/%s", id))
-	req, err := http.NewRequest("GET", url, nil)
+	url := c.reportURL(id, "", nil, idType)
+	req, err := c.newRequest("GetReportDetails", "GET", url, nil)
 
 	if err != nil {
 		return rd, err
@@ -133,13 +146,13 @@ func (c *Client) GetReportDetails(id string) (ReportDetails, error) {
 	return rd, nil
 }
 
-// DeleteReport Delete a report with the specified Trustar report ID.
+// DeleteReport Delete a report with the specified Trustar report ID. Pass IDTypeExternal to address the report by its external tracking ID.
 //
 // Endpoint: DELETE /1.3/reports/{ID}
-func (c *Client) DeleteReport(id string) error {
+func (c *Client) DeleteReport(id string, idType ...IDType) error {
 
-	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("reports/%s", id))
-	req, err := http.NewRequest("DELETE", url, nil)
+	url := c.reportURL(id, "", nil, idType)
+	req, err := c.newRequest("DeleteReport", "DELETE", url, nil)
 
 	if err != nil {
 		return err
@@ -155,7 +168,7 @@ func (c *Client) SearchReports(v url.Values) (ReportResponse, error) {
 	var rr ReportResponse
 
 	url := fmt.Sprintf("%s%s", c.APIBase, fmt.Sprintf("reports/search?%s", v.Encode()))
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := c.newRequest("SearchReports", "GET", url, nil)
 
 	if err != nil {
 		return rr, err
@@ -167,3 +180,56 @@ func (c *Client) SearchReports(v url.Values) (ReportResponse, error) {
 
 	return rr, nil
 }
+
+// UpsertReport Creates the report, or updates the existing report with the same ExternalTrackingID.
+// It returns the TruSTAR report ID and whether a new report was created.
+//
+// Endpoints: GET /1.3/reports/{ID}?idType=external, then PUT /1.3/reports/{ID} or POST /1.3/reports
+func (c *Client) UpsertReport(report ReportSubmission) (string, bool, error) {
+	if report.ExternalTrackingID == "" {
+		return "", false, errors.New("ExternalTrackingID is required to upsert a report")
+	}
+
+	existing, err := c.GetReportDetails(report.ExternalTrackingID, IDTypeExternal)
+	if err != nil {
+		if !IsNotFound(err) {
+			return "", false, err
+		}
+
+		id, err := c.SubmitReport(report)
+		return id, err == nil, err
+	}
+
+	if err = c.UpdateReport(existing.ID, report); err != nil {
+		return "", false, err
+	}
+
+	return existing.ID, false, nil
+}
+
+// reportURL builds the URL of an endpoint for a single report. The ID is escaped as a path segment
+// since external IDs are user-defined and may contain any character.
+func (c *Client) reportURL(id, suffix string, v url.Values, idType []IDType) string {
+	q := copyValues(v)
+	if len(idType) > 0 && idType[0] != "" && idType[0] != IDTypeInternal {
+		q.Set("idType", string(idType[0]))
+	}
+
+	u := fmt.Sprintf("%sreports/%s%s", c.APIBase, url.PathEscape(id), suffix)
+	if len(q) > 0 {
+		u += "?" + q.Encode()
+	}
+
+	return u
+}
+
+// applyReportHooks runs c.ReportHooks over a report before it is sent
+func (c *Client) applyReportHooks(report ReportSubmission) (ReportSubmission, error) {
+	var err error
+	for _, hook := range c.ReportHooks {
+		if report, err = hook(report); err != nil {
+			return report, err
+		}
+	}
+	return report, nil
+}
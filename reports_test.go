@@ -0,0 +1,175 @@
+package trustar
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+// upsertServer serves the report endpoints with one existing report, recording each request as "METHOD URI"
+func upsertServer(t *testing.T, existing ReportDetails, calls *[]string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		*calls = append(*calls, r.Method+" "+r.URL.RequestURI())
+
+		switch {
+		case r.Method == "GET" && r.URL.Query().Get("idType") == "external":
+			if r.URL.Path != "/reports/"+existing.ExternalID {
+				http.Error(w, "no such report", http.StatusNotFound)
+				return
+			}
+			writeJSON(w, existing)
+		case r.Method == "POST":
+			var s ReportSubmission
+			if err := json.NewDecoder(r.Body).Decode(&s); err != nil {
+				t.Error(err)
+			}
+			w.Write([]byte("new-guid"))
+		case r.Method == "PUT":
+			w.Write([]byte("{}"))
+		default:
+			http.Error(w, "unexpected request", http.StatusBadRequest)
+		}
+	}
+}
+
+func TestUpsertReport(t *testing.T) {
+	existing := ReportDetails{ID: "guid-1", ExternalID: "ext-1"}
+
+	tests := []struct {
+		name    string
+		extID   string
+		id      string
+		created bool
+		calls   []string
+	}{
+		{
+			name:  "update",
+			extID: "ext-1",
+			id:    "guid-1",
+			calls: []string{"GET /reports/ext-1?idType=external", "PUT /reports/guid-1"},
+		},
+		{
+			name:    "create",
+			extID:   "ext-2",
+			id:      "new-guid",
+			created: true,
+			calls:   []string{"GET /reports/ext-2?idType=external", "POST /reports"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			c, done := newTestClient(t, upsertServer(t, existing, &calls))
+			defer done()
+
+			id, created, err := c.UpsertReport(ReportSubmission{Title: "t", ReportBody: "b", ExternalTrackingID: tt.extID})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if id != tt.id || created != tt.created {
+				t.Errorf("got %q, %v, want %q, %v", id, created, tt.id, tt.created)
+			}
+			if len(calls) != len(tt.calls) {
+				t.Fatalf("got requests %q, want %q", calls, tt.calls)
+			}
+			for i := range calls {
+				if calls[i] != tt.calls[i] {
+					t.Errorf("got request %q, want %q", calls[i], tt.calls[i])
+				}
+			}
+		})
+	}
+}
+
+func TestUpsertReportErrors(t *testing.T) {
+	var calls []string
+	c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls = append(calls, r.Method)
+		http.Error(w, "boom", http.StatusInternalServerError)
+	})
+	defer done()
+
+	if _, _, err := c.UpsertReport(ReportSubmission{Title: "t"}); err == nil {
+		t.Error("want an error without an ExternalTrackingID")
+	}
+	if len(calls) != 0 {
+		t.Errorf("got requests %v, want none without an ExternalTrackingID", calls)
+	}
+
+	// a failed lookup must not be mistaken for a missing report
+	if _, created, err := c.UpsertReport(ReportSubmission{Title: "t", ExternalTrackingID: "x"}); err == nil || created {
+		t.Errorf("got created %v, error %v, want the lookup error", created, err)
+	}
+	if len(calls) != 1 || calls[0] != "GET" {
+		t.Errorf("got requests %v, want only the lookup", calls)
+	}
+}
+
+func TestReportIDAddressing(t *testing.T) {
+	const id = "INC 42/7?x#y"
+
+	tests := []struct {
+		name string
+		call func(c *Client) error
+		want string
+	}{
+		{
+			name: "details by internal ID",
+			call: func(c *Client) error { _, err := c.GetReportDetails("guid-1"); return err },
+			want: "GET /reports/guid-1",
+		},
+		{
+			name: "explicit internal ID type is not sent",
+			call: func(c *Client) error { _, err := c.GetReportDetails("guid-1", IDTypeInternal); return err },
+			want: "GET /reports/guid-1",
+		},
+		{
+			name: "details by external ID",
+			call: func(c *Client) error { _, err := c.GetReportDetails(id, IDTypeExternal); return err },
+			want: "GET /reports/INC%2042%2F7%3Fx%23y?idType=external",
+		},
+		{
+			name: "update by external ID",
+			call: func(c *Client) error { return c.UpdateReport(id, ReportSubmission{}, IDTypeExternal) },
+			want: "PUT /reports/INC%2042%2F7%3Fx%23y?idType=external",
+		},
+		{
+			name: "delete by external ID",
+			call: func(c *Client) error { return c.DeleteReport(id, IDTypeExternal) },
+			want: "DELETE /reports/INC%2042%2F7%3Fx%23y?idType=external",
+		},
+		{
+			name: "indicators by external ID",
+			call: func(c *Client) error {
+				_, err := c.GetReportIndicators(id, url.Values{"pageSize": {"5"}}, IDTypeExternal)
+				return err
+			},
+			want: "GET /reports/INC%2042%2F7%3Fx%23y/indicators?idType=external&pageSize=5",
+		},
+		{
+			name: "indicators by internal ID",
+			call: func(c *Client) error { _, err := c.GetReportIndicators("guid-1", nil); return err },
+			want: "GET /reports/guid-1/indicators",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			c, done := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+				got = r.Method + " " + r.RequestURI
+				w.Write([]byte("{}"))
+			})
+			defer done()
+
+			if err := tt.call(c); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("got request %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
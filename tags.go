@@ -0,0 +1,50 @@
+package trustar
+
+import (
+	"net/url"
+	"strings"
+)
+
+// GetReportTags Returns the tags that have been applied to a report. Pass IDTypeExternal to address the report by its external tracking ID.
+//
+// Endpoint: GET /1.3/reports/{id}/tags
+func (c *Client) GetReportTags(id string, idType ...IDType) ([]IndicatorTag, error) {
+	var tags []IndicatorTag
+
+	url := c.reportURL(id, "/tags", nil, idType)
+	req, err := c.newRequest("GetReportTags", "GET", url, nil)
+
+	if err != nil {
+		return tags, err
+	}
+
+	if err = c.SendWithAuth(req, &tags); err != nil {
+		return tags, err
+	}
+
+	return tags, nil
+}
+
+// AddReportTag Adds a tag to a report in the given enclave and returns the ID of the tag. Pass IDTypeExternal to address the report by its external tracking ID.
+//
+// Endpoint: POST /1.3/reports/{id}/tags
+func (c *Client) AddReportTag(id, name, enclaveID string, idType ...IDType) (string, error) {
+	var guid strings.Builder
+
+	v := url.Values{}
+	v.Set("tagName", name)
+	v.Set("enclaveId", enclaveID)
+
+	url := c.reportURL(id, "/tags", v, idType)
+	req, err := c.newRequest("AddReportTag", "POST", url, nil)
+
+	if err != nil {
+		return "", err
+	}
+
+	if err = c.SendWithAuth(req, &guid); err != nil {
+		return "", err
+	}
+
+	return strings.Trim(guid.String(), "\" \n"), nil
+}
@@ -0,0 +1,151 @@
+// Package tracetest provides an in-memory trustar.Tracer for tests.
+package tracetest
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// SpanData is a snapshot of a recorded span
+type SpanData struct {
+	ID                int
+	ParentID          int // zero for root spans
+	Name              string
+	Attributes        map[string]interface{}
+	Status            trustar.SpanStatus
+	StatusDescription string
+	Errors            []error
+	Start             time.Time
+	End               time.Time
+}
+
+// Recorder is a trustar.Tracer that keeps every ended span in memory. It is safe for concurrent use.
+type Recorder struct {
+	mu     sync.Mutex
+	nextID int
+	ended  []SpanData
+}
+
+// NewRecorder returns an empty Recorder
+func NewRecorder() *Recorder {
+	return &Recorder{}
+}
+
+type spanKey struct{}
+
+// Start implements trustar.Tracer
+func (r *Recorder) Start(ctx context.Context, name string, attrs ...trustar.Attribute) (context.Context, trustar.Span) {
+	r.mu.Lock()
+	r.nextID++
+	id := r.nextID
+	r.mu.Unlock()
+
+	s := &span{
+		recorder: r,
+		data: SpanData{
+			ID:         id,
+			Name:       name,
+			Attributes: make(map[string]interface{}),
+			Start:      time.Now(),
+		},
+	}
+	if parent, ok := ctx.Value(spanKey{}).(*span); ok {
+		s.data.ParentID = parent.data.ID
+	}
+	s.SetAttributes(attrs...)
+
+	return context.WithValue(ctx, spanKey{}, s), s
+}
+
+// Spans returns the ended spans in the order they ended
+func (r *Recorder) Spans() []SpanData {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]SpanData(nil), r.ended...)
+}
+
+// Named returns the ended spans with the given name
+func (r *Recorder) Named(name string) []SpanData {
+	var spans []SpanData
+	for _, s := range r.Spans() {
+		if s.Name == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// Children returns the ended spans whose parent is the given span
+func (r *Recorder) Children(parent SpanData) []SpanData {
+	var spans []SpanData
+	for _, s := range r.Spans() {
+		if s.ParentID == parent.ID {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+// Reset discards the recorded spans
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.ended = nil
+}
+
+// span records into its Recorder when it ends
+type span struct {
+	mu       sync.Mutex
+	recorder *Recorder
+	data     SpanData
+	ended    bool
+}
+
+func (s *span) SetAttributes(attrs ...trustar.Attribute) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, a := range attrs {
+		s.data.Attributes[a.Key] = a.Value
+	}
+}
+
+func (s *span) RecordError(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Errors = append(s.data.Errors, err)
+}
+
+func (s *span) SetStatus(status trustar.SpanStatus, description string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.data.Status, s.data.StatusDescription = status, description
+}
+
+func (s *span) End() {
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.data.End = time.Now()
+
+	data := s.data
+	data.Attributes = make(map[string]interface{}, len(s.data.Attributes))
+	for k, v := range s.data.Attributes {
+		data.Attributes[k] = v
+	}
+	s.mu.Unlock()
+
+	s.recorder.mu.Lock()
+	s.recorder.ended = append(s.recorder.ended, data)
+	s.recorder.mu.Unlock()
+}
@@ -0,0 +1,140 @@
+package trustar
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Attribute is a key/value pair recorded on a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// Attr returns an Attribute
+func Attr(key string, value interface{}) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanStatus is the outcome of a span
+type SpanStatus int
+
+// Span statuses, matching the OpenTelemetry status codes
+const (
+	SpanStatusUnset SpanStatus = iota
+	SpanStatusError
+	SpanStatusOK
+)
+
+// Span is a timed operation within a trace. Its methods mirror the OpenTelemetry span API, so an
+// OpenTelemetry tracer can be adapted with a thin wrapper.
+type Span interface {
+	SetAttributes(attrs ...Attribute)
+	RecordError(err error)
+	SetStatus(status SpanStatus, description string)
+	End()
+}
+
+// Tracer starts spans. The returned context carries the new span, so spans started from it become its children.
+//
+// The Client starts a span for every API call ("trustar.<Operation>"), every access token refresh
+// ("trustar.TokenRefresh"), every attempt made by the Retry middleware ("trustar.Attempt"),
+// and for the ForEach helpers ("trustar.<Helper>" with a "trustar.Page" child per page).
+// The SDK methods take no context, so API call spans are trace roots; retry attempts are children of their call.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span)
+}
+
+type tracerKey struct{}
+
+type enclavesKey struct{}
+
+// withEnclaves returns a copy of req carrying the enclaves of a request body, for the span of the call
+func withEnclaves(req *http.Request, enclaveIDs []string) *http.Request {
+	if len(enclaveIDs) == 0 {
+		return req
+	}
+	ids := append([]string(nil), enclaveIDs...)
+	return req.WithContext(context.WithValue(req.Context(), enclavesKey{}, ids))
+}
+
+// startSpan starts a span with the Client's Tracer, or a no-op span if there is none
+func (c *Client) startSpan(ctx context.Context, name string, attrs ...Attribute) (context.Context, Span) {
+	if c.Tracer == nil {
+		return ctx, noopSpan{}
+	}
+	ctx, span := c.Tracer.Start(ctx, name, attrs...)
+	return context.WithValue(ctx, tracerKey{}, c.Tracer), span
+}
+
+// startChildSpan starts a span with the Tracer of the call req belongs to, or a no-op span if the call is not traced
+func startChildSpan(req *http.Request, name string, attrs ...Attribute) (*http.Request, Span) {
+	tracer, ok := req.Context().Value(tracerKey{}).(Tracer)
+	if !ok {
+		return req, noopSpan{}
+	}
+	ctx, span := tracer.Start(req.Context(), name, attrs...)
+	return req.WithContext(ctx), span
+}
+
+// endSpan sets the span status from the outcome of an HTTP exchange and ends it
+func endSpan(span Span, resp *http.Response, err error) {
+	if resp != nil {
+		span.SetAttributes(Attr("http.status_code", resp.StatusCode))
+	}
+
+	switch {
+	case err != nil:
+		span.RecordError(err)
+		span.SetStatus(SpanStatusError, err.Error())
+	case resp != nil && resp.StatusCode >= 400:
+		span.SetStatus(SpanStatusError, resp.Status)
+	default:
+		span.SetStatus(SpanStatusOK, "")
+	}
+	span.End()
+}
+
+// requestAttributes describes an API request for its span
+func requestAttributes(req *http.Request) []Attribute {
+	attrs := []Attribute{
+		Attr("trustar.operation", Operation(req)),
+		Attr("http.method", req.Method),
+		Attr("http.url", req.URL.String()),
+	}
+	attrs = append(attrs, queryAttributes(req.URL.Query())...)
+
+	// writes carry their enclaves in the body rather than the query
+	if ids, ok := req.Context().Value(enclavesKey{}).([]string); ok {
+		attrs = append(attrs, Attr("trustar.enclave_ids", ids))
+	}
+	return attrs
+}
+
+// queryAttributes describes the enclaves and page of a query
+func queryAttributes(q map[string][]string) []Attribute {
+	var attrs []Attribute
+	if ids := strings.Join(q["enclaveIds"], ","); ids != "" {
+		attrs = append(attrs, Attr("trustar.enclave_ids", strings.Split(ids, ",")))
+	}
+	for _, p := range []struct{ param, key string }{
+		{"pageNumber", "trustar.page_number"},
+		{"pageSize", "trustar.page_size"},
+	} {
+		if vs := q[p.param]; len(vs) > 0 {
+			if n, err := strconv.Atoi(vs[0]); err == nil {
+				attrs = append(attrs, Attr(p.key, n))
+			}
+		}
+	}
+	return attrs
+}
+
+type noopSpan struct{}
+
+func (noopSpan) SetAttributes(...Attribute)   {}
+func (noopSpan) RecordError(error)            {}
+func (noopSpan) SetStatus(SpanStatus, string) {}
+func (noopSpan) End()                         {}
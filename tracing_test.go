@@ -0,0 +1,262 @@
+package trustar_test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+	"github.com/jakewarren/trustar-golang/tracetest"
+)
+
+// handlerTransport serves every request, including the token request, with h
+type handlerTransport struct {
+	h http.Handler
+}
+
+func (t handlerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	t.h.ServeHTTP(rec, req)
+	return rec.Result(), nil
+}
+
+// tracedClient returns a Client recording its spans, sending every request to h
+func tracedClient(t *testing.T, h http.HandlerFunc) (*trustar.Client, *tracetest.Recorder) {
+	t.Helper()
+
+	c, err := trustar.NewClient("id", "secret", "http://api.test/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetHTTPClient(&http.Client{Transport: handlerTransport{h}})
+	c.SetAccessToken("token")
+
+	rec := tracetest.NewRecorder()
+	c.Tracer = rec
+	return c, rec
+}
+
+func reply(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// one returns the only span with the given name
+func one(t *testing.T, rec *tracetest.Recorder, name string) tracetest.SpanData {
+	t.Helper()
+
+	spans := rec.Named(name)
+	if len(spans) != 1 {
+		t.Fatalf("got %d %s spans, want 1: %+v", len(spans), name, rec.Spans())
+	}
+	return spans[0]
+}
+
+func TestTraceCall(t *testing.T) {
+	c, rec := tracedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/reports/missing" {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		reply(w, trustar.ReportResponse{})
+	})
+
+	v := url.Values{"enclaveIds": {"a,b"}, "pageSize": {"10"}, "pageNumber": {"2"}}
+	if _, err := c.GetReports(v); err != nil {
+		t.Fatal(err)
+	}
+
+	span := one(t, rec, "trustar.GetReports")
+	want := map[string]interface{}{
+		"trustar.operation":   "GetReports",
+		"http.method":         "GET",
+		"http.url":            "http://api.test/reports?" + v.Encode(),
+		"http.status_code":    200,
+		"trustar.enclave_ids": []string{"a", "b"},
+		"trustar.page_number": 2,
+		"trustar.page_size":   10,
+	}
+	if !reflect.DeepEqual(span.Attributes, want) {
+		t.Errorf("got attributes %v\nwant %v", span.Attributes, want)
+	}
+	if span.ParentID != 0 || span.Status != trustar.SpanStatusOK || span.End.Before(span.Start) {
+		t.Errorf("got %+v, want an OK root span", span)
+	}
+
+	// an error status marks the span failed without recording an error
+	rec.Reset()
+	c.GetReportDetails("missing")
+	span = one(t, rec, "trustar.GetReportDetails")
+	if span.Status != trustar.SpanStatusError || span.StatusDescription != "404 Not Found" || len(span.Errors) != 0 {
+		t.Errorf("got %+v, want a 404 error status", span)
+	}
+	if span.Attributes["http.status_code"] != 404 {
+		t.Errorf("got status code %v", span.Attributes["http.status_code"])
+	}
+}
+
+func TestTraceWriteEnclaves(t *testing.T) {
+	c, rec := tracedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "POST" && r.URL.Path == "/reports" {
+			w.Write([]byte("r1"))
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+
+	report := trustar.ReportSubmission{Title: "t", ReportBody: "b", DistributionType: "ENCLAVE", EnclaveIds: []string{"e1", "e2"}}
+	if _, err := c.SubmitReport(report); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.UpdateReport("r1", report); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.SubmitIndicators(trustar.IndicatorSubmission{EnclaveIDS: []string{"e3"}, Content: []trustar.IndicatorContent{{Value: "evil.com"}}}); err != nil {
+		t.Fatal(err)
+	}
+
+	// the enclaves travel in the request body, not the query
+	for name, want := range map[string][]string{
+		"trustar.SubmitReport":     {"e1", "e2"},
+		"trustar.UpdateReport":     {"e1", "e2"},
+		"trustar.SubmitIndicators": {"e3"},
+	} {
+		span := one(t, rec, name)
+		if got := span.Attributes["trustar.enclave_ids"]; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got enclave IDs %v, want %v", name, got, want)
+		}
+	}
+
+	// a COMMUNITY report has no enclaves to record
+	rec.Reset()
+	c.SubmitReport(trustar.ReportSubmission{Title: "t", ReportBody: "b", DistributionType: "COMMUNITY"})
+	if _, ok := one(t, rec, "trustar.SubmitReport").Attributes["trustar.enclave_ids"]; ok {
+		t.Error("got enclave IDs on a COMMUNITY submission")
+	}
+}
+
+func TestTraceTransportError(t *testing.T) {
+	c, rec := tracedClient(t, nil)
+	refused := errors.New("refused")
+	c.Use(func(next trustar.Handler) trustar.Handler {
+		return func(req *http.Request) (*http.Response, error) {
+			return nil, refused
+		}
+	})
+
+	c.GetEnclaves()
+
+	span := one(t, rec, "trustar.GetEnclaves")
+	if span.Status != trustar.SpanStatusError || len(span.Errors) != 1 || span.StatusDescription != "refused" {
+		t.Errorf("got %+v, want the error recorded", span)
+	}
+	if _, ok := span.Attributes["http.status_code"]; ok {
+		t.Error("got a status code without a response")
+	}
+}
+
+func TestTraceRetryAttempts(t *testing.T) {
+	calls := 0
+	c, rec := tracedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		reply(w, []trustar.Enclave{})
+	})
+	c.Use(trustar.Retry(trustar.RetryPolicy{InitialBackoff: time.Millisecond}))
+
+	if _, err := c.GetEnclaves(); err != nil {
+		t.Fatal(err)
+	}
+
+	call := one(t, rec, "trustar.GetEnclaves")
+	attempts := rec.Children(call)
+	if len(attempts) != 3 {
+		t.Fatalf("got %d attempt spans, want 3", len(attempts))
+	}
+	for i, a := range attempts {
+		wantStatus := trustar.SpanStatusError
+		if i == 2 {
+			wantStatus = trustar.SpanStatusOK
+		}
+		if a.Name != "trustar.Attempt" || a.Attributes["trustar.retry.attempt"] != i+1 || a.Status != wantStatus {
+			t.Errorf("attempt %d: got %+v", i+1, a)
+		}
+	}
+}
+
+func TestTraceTokenRefresh(t *testing.T) {
+	c, rec := tracedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/oauth/token" {
+			reply(w, map[string]interface{}{"access_token": "fresh", "expires_in": 30})
+			return
+		}
+		reply(w, []trustar.Enclave{})
+	})
+
+	// a token expiring within RequestNewTokenBeforeExpiresIn is refreshed before every call
+	if _, err := c.GetAccessToken(); err != nil {
+		t.Fatal(err)
+	}
+	rec.Reset()
+
+	if _, err := c.GetEnclaves(); err != nil {
+		t.Fatal(err)
+	}
+
+	refresh := one(t, rec, "trustar.TokenRefresh")
+	if refresh.Attributes["trustar.operation"] != "GetEnclaves" || refresh.Status != trustar.SpanStatusOK {
+		t.Errorf("got %+v", refresh)
+	}
+	token := one(t, rec, "trustar.GetAccessToken")
+	if token.Attributes["http.url"] != "https://api.trustar.co/oauth/token" {
+		t.Errorf("got %+v", token)
+	}
+	one(t, rec, "trustar.GetEnclaves")
+}
+
+func TestTraceForEach(t *testing.T) {
+	c, rec := tracedClient(t, func(w http.ResponseWriter, r *http.Request) {
+		page := r.URL.Query().Get("pageNumber")
+		reply(w, trustar.WhitelistIndicatorsResponse{
+			HasNext: page == "0",
+			Items:   []trustar.Indicator{{Value: "x" + page + ".com"}},
+		})
+	})
+
+	if err := c.ForEachWhitelistIndicator(url.Values{"enclaveIds": {"e1"}}, func(trustar.Indicator) error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	helper := one(t, rec, "trustar.ForEachWhitelistIndicator")
+	if helper.ParentID != 0 || helper.Status != trustar.SpanStatusOK || !reflect.DeepEqual(helper.Attributes["trustar.enclave_ids"], []string{"e1"}) {
+		t.Errorf("got %+v", helper)
+	}
+
+	pages := rec.Children(helper)
+	if len(pages) != 2 {
+		t.Fatalf("got %d page spans, want 2", len(pages))
+	}
+	for i, p := range pages {
+		if p.Name != "trustar.Page" || p.Attributes["trustar.page_number"] != i || p.Attributes["trustar.has_next"] != (i == 0) {
+			t.Errorf("page %d: got %+v", i, p)
+		}
+	}
+
+	// the SDK methods take no context, so the page requests are trace roots
+	for _, call := range rec.Named("trustar.GetWhitelist") {
+		if call.ParentID != 0 {
+			t.Errorf("got %+v, want a root span", call)
+		}
+	}
+	if n := len(rec.Named("trustar.GetWhitelist")); n != 2 {
+		t.Errorf("got %d GetWhitelist spans, want 2", n)
+	}
+}
@@ -15,11 +15,20 @@ const (
 
 	// RequestNewTokenBeforeExpiresIn is used by SendWithAuth and try to get new Token when it's about to expire
 	RequestNewTokenBeforeExpiresIn = time.Duration(60) * time.Second
+
+	// IDTypeInternal identifies a report by the GUID TruSTAR assigned to it
+	IDTypeInternal IDType = "internal"
+
+	// IDTypeExternal identifies a report by the user-defined external tracking ID it was submitted with
+	IDTypeExternal IDType = "external"
 )
 
 type (
 	expirationTime int64
 
+	// IDType selects how a report ID is interpreted by the report endpoints
+	IDType string
+
 	// TokenResponse is for API response for the /oauth2/token endpoint
 	TokenResponse struct {
 		Token     string         `json:"access_token"`
@@ -34,12 +43,22 @@ type (
 		Client         *http.Client
 		ClientID       string
 		Secret         string
+		Credentials    CredentialsProvider // If set, used instead of ClientID and Secret when requesting access tokens
 		APIBase        string
-		Log            io.Writer // If user set log file name all requests will be logged there
+		Log            io.Writer // If user set log file name all requests will be logged there, set it with SetLog
 		Token          *TokenResponse
+		ReportHooks    []ReportHook // Applied in order to every report before SubmitReport and UpdateReport send it
+		Middleware     []Middleware // Wraps every HTTP request, the first entry is the outermost
+		Tracer         Tracer       // If set, spans are started for API calls, token refreshes, retries and pages
+		DryRun         io.Writer    // If set, write requests are written here instead of being sent, see SetDryRun
 		tokenExpiresAt time.Time
+		dryRunMu       sync.Mutex
+		dryRunSeq      int
 	}
 
+	// ReportHook inspects or rewrites a report before it is sent. Returning an error stops the request.
+	ReportHook func(ReportSubmission) (ReportSubmission, error)
+
 	// ErrorResponse holds the response if an error occurs
 	ErrorResponse struct {
 		Response *http.Response
@@ -103,7 +122,10 @@ type (
 	}
 
 	// RequestQuotas represents the current status of the company’s request quotas.
-	RequestQuotas []struct {
+	RequestQuotas []RequestQuota
+
+	// RequestQuota is a maximum number of requests that a company can send to the API during a given time window.
+	RequestQuota struct {
 		GUID          string `json:"guid"`
 		LastResetTime int64  `json:"lastResetTime"`
 		MaxRequests   int64  `json:"maxRequests"`
@@ -122,7 +144,10 @@ type (
 	}
 
 	// IndicatorMetadataResponse is a metadata object containing the metadata for the requested indicator(s).
-	IndicatorMetadataResponse []struct {
+	IndicatorMetadataResponse []IndicatorMetadata
+
+	// IndicatorMetadata contains the metadata for a single indicator
+	IndicatorMetadata struct {
 		EnclaveIds    []string       `json:"enclaveIds"`    // the enclaves (of those the user has access to) that the indicator has appeared in a report or indicator submission to
 		FirstSeen     int64          `json:"firstSeen"`     // the time (in milliseconds since epoch) that the indicator first appeared in a report or indicator submission to any enclaves the user has access to
 		GUID          string         `json:"guid"`          // unique id of the indicator
@@ -217,3 +242,15 @@ func (r *ErrorResponse) Error() string {
 	defer r.Response.Body.Close()
 	return fmt.Sprintf("%v %v: %d %s", r.Response.Request.Method, r.Response.Request.URL, r.Response.StatusCode, respBody)
 }
+
+// IsNotFound reports whether err is an API error response with a 404 status
+func IsNotFound(err error) bool {
+	errResp, ok := err.(*ErrorResponse)
+	return ok && errResp.Response != nil && errResp.Response.StatusCode == http.StatusNotFound
+}
+
+// IsRateLimited reports whether err is an API error response with a 429 status
+func IsRateLimited(err error) bool {
+	errResp, ok := err.(*ErrorResponse)
+	return ok && errResp.Response != nil && errResp.Response.StatusCode == http.StatusTooManyRequests
+}
@@ -0,0 +1,72 @@
+package trustar
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Validate checks a report submission for the mistakes the API would reject
+func (r ReportSubmission) Validate() error {
+	var problems []string
+
+	if strings.TrimSpace(r.Title) == "" {
+		problems = append(problems, "title is required")
+	}
+	if strings.TrimSpace(r.ReportBody) == "" {
+		problems = append(problems, "reportBody is required")
+	}
+
+	switch r.DistributionType {
+	case "ENCLAVE":
+		if len(r.EnclaveIds) == 0 {
+			problems = append(problems, "ENCLAVE distribution requires at least one enclave ID")
+		}
+	case "COMMUNITY":
+	case "":
+		problems = append(problems, "distributionType is required")
+	default:
+		problems = append(problems, fmt.Sprintf("distributionType must be ENCLAVE or COMMUNITY, not %q", r.DistributionType))
+	}
+
+	if len(r.ExternalURL) > 500 {
+		problems = append(problems, "externalUrl is limited to 500 characters")
+	}
+	if r.TimeBegan != "" {
+		if _, err := time.Parse(time.RFC3339, r.TimeBegan); err != nil {
+			problems = append(problems, fmt.Sprintf("timeBegan %q is not an ISO-8601 time with timezone", r.TimeBegan))
+		}
+	}
+
+	return validationError("report", problems)
+}
+
+// Validate checks an indicator submission for the mistakes the API would reject
+func (s IndicatorSubmission) Validate() error {
+	var problems []string
+
+	if len(s.EnclaveIDS) == 0 {
+		problems = append(problems, "at least one enclave ID is required")
+	}
+	if len(s.Content) == 0 {
+		problems = append(problems, "at least one indicator is required")
+	}
+	for i, c := range s.Content {
+		if strings.TrimSpace(c.Value) == "" {
+			problems = append(problems, fmt.Sprintf("indicator %d has no value", i+1))
+		}
+		if c.FirstSeen > 0 && c.LastSeen > 0 && c.FirstSeen > c.LastSeen {
+			problems = append(problems, fmt.Sprintf("indicator %s has firstSeen after lastSeen", c.Value))
+		}
+	}
+
+	return validationError("indicator submission", problems)
+}
+
+func validationError(what string, problems []string) error {
+	if len(problems) == 0 {
+		return nil
+	}
+	return errors.New("invalid " + what + ": " + strings.Join(problems, "; "))
+}
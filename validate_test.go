@@ -0,0 +1,89 @@
+package trustar
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReportSubmissionValidate(t *testing.T) {
+	valid := ReportSubmission{Title: "t", ReportBody: "b", DistributionType: "ENCLAVE", EnclaveIds: []string{"e1"}}
+
+	tests := []struct {
+		name   string
+		modify func(r *ReportSubmission)
+		want   string
+	}{
+		{name: "valid", modify: func(r *ReportSubmission) {}},
+		{name: "community", modify: func(r *ReportSubmission) { r.DistributionType, r.EnclaveIds = "COMMUNITY", nil }},
+		{name: "time began", modify: func(r *ReportSubmission) { r.TimeBegan = "2016-09-22T11:38:35+00:00" }},
+		{name: "blank title", modify: func(r *ReportSubmission) { r.Title = "  " }, want: "invalid report: title is required"},
+		{name: "no body", modify: func(r *ReportSubmission) { r.ReportBody = "" }, want: "invalid report: reportBody is required"},
+		{name: "no enclaves", modify: func(r *ReportSubmission) { r.EnclaveIds = nil }, want: "invalid report: ENCLAVE distribution requires at least one enclave ID"},
+		{name: "no distribution", modify: func(r *ReportSubmission) { r.DistributionType = "" }, want: "invalid report: distributionType is required"},
+		{name: "bad distribution", modify: func(r *ReportSubmission) { r.DistributionType = "enclave" }, want: `invalid report: distributionType must be ENCLAVE or COMMUNITY, not "enclave"`},
+		{name: "long url", modify: func(r *ReportSubmission) { r.ExternalURL = strings.Repeat("x", 501) }, want: "invalid report: externalUrl is limited to 500 characters"},
+		{name: "no timezone", modify: func(r *ReportSubmission) { r.TimeBegan = "2016-09-22T11:38:35" }, want: `invalid report: timeBegan "2016-09-22T11:38:35" is not an ISO-8601 time with timezone`},
+		{
+			name:   "several problems",
+			modify: func(r *ReportSubmission) { r.Title, r.ReportBody = "", "" },
+			want:   "invalid report: title is required; reportBody is required",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := valid
+			tt.modify(&r)
+			err := r.Validate()
+			if tt.want == "" && err != nil {
+				t.Errorf("got error %v", err)
+			}
+			if tt.want != "" && (err == nil || err.Error() != tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestIndicatorSubmissionValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		s    IndicatorSubmission
+		want string
+	}{
+		{
+			name: "valid",
+			s:    IndicatorSubmission{EnclaveIDS: []string{"e1"}, Content: []IndicatorContent{{Value: "evil.com", FirstSeen: 1, LastSeen: 2}}},
+		},
+		{
+			name: "empty",
+			want: "invalid indicator submission: at least one enclave ID is required; at least one indicator is required",
+		},
+		{
+			name: "blank value",
+			s:    IndicatorSubmission{EnclaveIDS: []string{"e1"}, Content: []IndicatorContent{{Value: "evil.com"}, {Value: " "}}},
+			want: "invalid indicator submission: indicator 2 has no value",
+		},
+		{
+			name: "seen backwards",
+			s:    IndicatorSubmission{EnclaveIDS: []string{"e1"}, Content: []IndicatorContent{{Value: "evil.com", FirstSeen: 2, LastSeen: 1}}},
+			want: "invalid indicator submission: indicator evil.com has firstSeen after lastSeen",
+		},
+		{
+			name: "only first seen",
+			s:    IndicatorSubmission{EnclaveIDS: []string{"e1"}, Content: []IndicatorContent{{Value: "evil.com", FirstSeen: 2}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.s.Validate()
+			if tt.want == "" && err != nil {
+				t.Errorf("got error %v", err)
+			}
+			if tt.want != "" && (err == nil || err.Error() != tt.want) {
+				t.Errorf("got error %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
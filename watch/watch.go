@@ -0,0 +1,441 @@
+// Package watch polls enclaves for new and updated reports.
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+	"github.com/jakewarren/trustar-golang/internal/atomicfile"
+)
+
+const (
+	// DefaultInterval is the time between polls
+	DefaultInterval = 5 * time.Minute
+
+	// DefaultOverlap is how far each poll window reaches back before the high-water mark, to catch
+	// reports whose updated time was assigned out of order
+	DefaultOverlap = 15 * time.Minute
+
+	// DefaultMaxBackoff caps the delay between polls after errors
+	DefaultMaxBackoff = time.Hour
+
+	// DefaultMinRemaining is the number of quota requests below which polling pauses until the quota resets
+	DefaultMinRemaining = 25
+)
+
+// EventType distinguishes new reports from updated ones
+type EventType int
+
+const (
+	// NewReport is emitted the first time a report is seen
+	NewReport EventType = iota
+	// UpdatedReport is emitted when a known report has a later updated time
+	UpdatedReport
+)
+
+func (t EventType) String() string {
+	if t == NewReport {
+		return "NewReport"
+	}
+	return "UpdatedReport"
+}
+
+// Event is emitted for each new or updated report
+type Event struct {
+	Type      EventType
+	EnclaveID string // the watched enclave the report was found in
+	Report    trustar.ReportDetails
+}
+
+// Watcher polls enclaves on an interval and emits an Event for every new or updated report.
+// Each poll overlaps the previous one, and reports are de-duplicated by ID and updated time,
+// so a report is emitted once per update. The high-water mark of each enclave only moves past
+// an event once it has been delivered, and is persisted to StatePath after every poll and when
+// Run is cancelled, so restarts neither miss nor replay reports.
+type Watcher struct {
+	Client     *trustar.Client
+	EnclaveIDs []string
+
+	Interval   time.Duration // time between polls, defaults to DefaultInterval
+	Overlap    time.Duration // how far each poll reaches back before the high-water mark, defaults to DefaultOverlap
+	MaxBackoff time.Duration // longest delay between polls after errors, defaults to DefaultMaxBackoff
+
+	// InitialLookback is how far back the first poll of an enclave reaches. Later polls never reach
+	// further back than that, so by default only reports updated after the watcher first starts are emitted.
+	InitialLookback time.Duration
+
+	// MinRemaining pauses polling until the quotas reset when fewer requests remain. The quotas are
+	// checked with RequestQuotas before each poll. Defaults to DefaultMinRemaining; set it to a negative
+	// value to disable the quota check.
+	MinRemaining int64
+
+	// StatePath is the file the high-water marks are persisted to. State is kept in memory only if empty.
+	StatePath string
+
+	// Handler receives events. If nil, events are sent on the channel returned by Events.
+	Handler func(Event)
+
+	// ErrorHandler receives poll errors, which are otherwise retried silently with backoff
+	ErrorHandler func(error)
+
+	mu      sync.Mutex
+	state   *state
+	events  chan Event
+	started bool
+}
+
+// state is the persisted position of each enclave
+type state struct {
+	Enclaves map[string]*enclaveState `json:"enclaves"`
+}
+
+type enclaveState struct {
+	HighWater int64            `json:"highWater"`       // the latest updated time delivered, in milliseconds since epoch
+	Floor     int64            `json:"floor,omitempty"` // the earliest updated time polled, set when the enclave is first watched
+	Seen      map[string]int64 `json:"seen"`            // updated time of the reports inside the overlap window, by report ID
+}
+
+// New returns a Watcher for the given enclaves
+func New(c *trustar.Client, enclaveIDs ...string) *Watcher {
+	return &Watcher{Client: c, EnclaveIDs: enclaveIDs}
+}
+
+// Events returns the channel events are delivered on when no Handler is set.
+// The channel is closed when Run returns.
+func (w *Watcher) Events() <-chan Event {
+	return w.channel()
+}
+
+func (w *Watcher) channel() chan Event {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.events == nil {
+		w.events = make(chan Event, 64)
+	}
+	return w.events
+}
+
+// Run polls until ctx is cancelled. Poll errors are passed to ErrorHandler and retried with
+// exponential backoff. It returns an error only if the persisted state cannot be loaded, or
+// cannot be saved once ctx is cancelled.
+//
+// Run can only be called once, as it closes the Events channel when it returns. Create a new
+// Watcher with the same StatePath to resume watching.
+func (w *Watcher) Run(ctx context.Context) error {
+	w.mu.Lock()
+	started := w.started
+	w.started = true
+	w.mu.Unlock()
+	if started {
+		return errors.New("watch: Run can only be called once")
+	}
+
+	defer w.closeEvents()
+
+	if err := w.loadState(); err != nil {
+		return err
+	}
+
+	failures := 0
+	for {
+		delay := w.interval()
+
+		// wait for the quotas to reset rather than spend the last requests on a poll
+		if wait := w.quotaDelay(); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return w.saveState()
+			case <-time.After(wait):
+			}
+		}
+
+		// events from the enclaves polled before an error are still delivered and checkpointed
+		events, err := w.poll()
+		delivered := w.deliver(ctx, events)
+		w.prune()
+		if serr := w.saveState(); !delivered {
+			// the events that were not delivered are polled again after a restart
+			return serr
+		} else if err == nil {
+			err = serr
+		}
+
+		if err == nil {
+			failures = 0
+		} else {
+			failures++
+			if w.ErrorHandler != nil {
+				w.ErrorHandler(err)
+			}
+			delay = w.backoff(failures)
+		}
+
+		select {
+		case <-ctx.Done():
+			return w.saveState()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// Poll checks every enclave once and returns the new and updated reports, oldest first.
+// The high-water marks move forward in memory; call Run, or SaveState after handling the events, to persist them.
+func (w *Watcher) Poll() ([]Event, error) {
+	if err := w.loadState(); err != nil {
+		return nil, err
+	}
+
+	events, err := w.poll()
+	for _, e := range events {
+		w.commit(e)
+	}
+	w.prune()
+
+	return events, err
+}
+
+// poll checks every enclave once without moving the high-water marks
+func (w *Watcher) poll() ([]Event, error) {
+	if err := w.loadState(); err != nil {
+		return nil, err
+	}
+
+	var all []Event
+	for _, enclaveID := range w.EnclaveIDs {
+		events, err := w.pollEnclave(enclaveID)
+		if err != nil {
+			return all, err
+		}
+		all = append(all, events...)
+	}
+
+	return all, nil
+}
+
+// SaveState persists the high-water marks to StatePath
+func (w *Watcher) SaveState() error {
+	return w.saveState()
+}
+
+func (w *Watcher) pollEnclave(enclaveID string) ([]Event, error) {
+	now := time.Now()
+
+	w.mu.Lock()
+	es, ok := w.state.Enclaves[enclaveID]
+	if !ok {
+		start := trustar.TimeToMsEpoch(now.Add(-w.InitialLookback))
+		es = &enclaveState{HighWater: start, Floor: start, Seen: map[string]int64{}}
+		w.state.Enclaves[enclaveID] = es
+	}
+	from := es.HighWater - int64(w.overlap()/time.Millisecond)
+	if from < es.Floor {
+		from = es.Floor
+	}
+	w.mu.Unlock()
+
+	v := url.Values{}
+	v.Set("enclaveIds", enclaveID)
+	v.Set("from", strconv.FormatInt(from, 10))
+	v.Set("to", strconv.FormatInt(trustar.TimeToMsEpoch(now), 10))
+
+	var reports []trustar.ReportDetails
+	err := w.Client.ForEachReport(v, func(r trustar.ReportDetails) error {
+		reports = append(reports, r)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(reports, func(i, j int) bool { return reports[i].Updated < reports[j].Updated })
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	var events []Event
+	for _, r := range reports {
+		prev, seen := es.Seen[r.ID]
+		if seen && prev >= r.Updated {
+			continue
+		}
+
+		e := Event{Type: UpdatedReport, EnclaveID: enclaveID, Report: r}
+		if !seen && r.Created >= from {
+			e.Type = NewReport
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// commit moves the high-water mark of the event's enclave past its report
+func (w *Watcher) commit(e Event) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	es := w.state.Enclaves[e.EnclaveID]
+	es.Seen[e.Report.ID] = e.Report.Updated
+	if e.Report.Updated > es.HighWater {
+		es.HighWater = e.Report.Updated
+	}
+}
+
+// prune forgets the reports that can no longer be returned by the next overlap window
+func (w *Watcher) prune() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, es := range w.state.Enclaves {
+		cutoff := es.HighWater - int64(w.overlap()/time.Millisecond)
+		for id, updated := range es.Seen {
+			if updated < cutoff {
+				delete(es.Seen, id)
+			}
+		}
+	}
+}
+
+// deliver hands the events to the Handler or channel, committing each one as it is delivered.
+// It returns false if ctx was cancelled first.
+func (w *Watcher) deliver(ctx context.Context, events []Event) bool {
+	for _, e := range events {
+		if w.Handler != nil {
+			w.Handler(e)
+			w.commit(e)
+			continue
+		}
+
+		select {
+		case w.channel() <- e:
+			w.commit(e)
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	return true
+}
+
+func (w *Watcher) closeEvents() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.events != nil {
+		close(w.events)
+	}
+}
+
+// quotaDelay returns how long to wait for the quotas to reset when few requests remain
+func (w *Watcher) quotaDelay() time.Duration {
+	min := w.MinRemaining
+	if min < 0 {
+		return 0
+	}
+	if min == 0 {
+		min = DefaultMinRemaining
+	}
+
+	quotas, err := w.Client.RequestQuotas()
+	if err != nil || quotas.Remaining() >= min {
+		return 0
+	}
+
+	return time.Until(quotas.NextReset())
+}
+
+func (w *Watcher) backoff(failures int) time.Duration {
+	max := w.MaxBackoff
+	if max <= 0 {
+		max = DefaultMaxBackoff
+	}
+
+	delay := w.interval()
+	for i := 1; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+func (w *Watcher) interval() time.Duration {
+	if w.Interval <= 0 {
+		return DefaultInterval
+	}
+	return w.Interval
+}
+
+func (w *Watcher) overlap() time.Duration {
+	if w.Overlap <= 0 {
+		return DefaultOverlap
+	}
+	return w.Overlap
+}
+
+func (w *Watcher) loadState() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.state != nil {
+		return nil
+	}
+
+	// the state is only kept once it loads, so a failed load is retried rather than replaced by an empty state
+	st := &state{Enclaves: map[string]*enclaveState{}}
+	if w.StatePath != "" {
+		data, err := ioutil.ReadFile(w.StatePath)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if err == nil {
+			if err = json.Unmarshal(data, st); err != nil {
+				return err
+			}
+		}
+	}
+
+	if st.Enclaves == nil {
+		st.Enclaves = map[string]*enclaveState{}
+	}
+	for _, es := range st.Enclaves {
+		if es.Seen == nil {
+			es.Seen = map[string]int64{}
+		}
+	}
+	w.state = st
+
+	return nil
+}
+
+// saveState writes the state to StatePath atomically
+func (w *Watcher) saveState() error {
+	if w.StatePath == "" {
+		return nil
+	}
+
+	w.mu.Lock()
+	if w.state == nil {
+		w.mu.Unlock()
+		return errors.New("watcher state has not been loaded")
+	}
+	data, err := json.Marshal(w.state)
+	w.mu.Unlock()
+
+	if err != nil {
+		return err
+	}
+
+	return atomicfile.Write(w.StatePath, data)
+}
@@ -0,0 +1,383 @@
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	trustar "github.com/jakewarren/trustar-golang"
+)
+
+// fakeAPI serves GetReports from an in-memory set of reports
+type fakeAPI struct {
+	mu          sync.Mutex
+	reports     map[string]trustar.ReportDetails
+	froms       []int64
+	status      int                   // when set, GetReports fails with this status
+	quotas      trustar.RequestQuotas // served by RequestQuotas, plenty remaining if nil
+	quotaChecks int
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *trustar.Client, func()) {
+	api := &fakeAPI{reports: map[string]trustar.ReportDetails{}}
+	srv := httptest.NewServer(api)
+
+	c, err := trustar.NewClient("id", "secret", srv.URL+"/")
+	if err != nil {
+		srv.Close()
+		t.Fatal(err)
+	}
+	c.SetAccessToken("token")
+
+	return api, c, srv.Close
+}
+
+func (f *fakeAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.URL.Path == "/request-quotas" {
+		f.quotaChecks++
+		quotas := f.quotas
+		if quotas == nil {
+			quotas = trustar.RequestQuotas{{MaxRequests: 100}}
+		}
+		json.NewEncoder(w).Encode(quotas)
+		return
+	}
+	if f.status != 0 {
+		http.Error(w, "failed", f.status)
+		return
+	}
+
+	q := r.URL.Query()
+	from, _ := strconv.ParseInt(q.Get("from"), 10, 64)
+	to, _ := strconv.ParseInt(q.Get("to"), 10, 64)
+	f.froms = append(f.froms, from)
+
+	var list []trustar.ReportDetails
+	for _, rep := range f.reports {
+		if rep.Updated >= from && rep.Updated <= to && rep.EnclaveIds[0] == q.Get("enclaveIds") {
+			list = append(list, rep)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Updated > list[j].Updated })
+	json.NewEncoder(w).Encode(trustar.ReportResponse{Reports: list})
+}
+
+// put adds or updates a report, created and updated the given time ago
+func (f *fakeAPI) put(id string, created, updated time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	now := time.Now()
+	f.reports[id] = trustar.ReportDetails{
+		ID:         id,
+		EnclaveIds: []string{"e1"},
+		Created:    trustar.TimeToMsEpoch(now.Add(-created)),
+		Updated:    trustar.TimeToMsEpoch(now.Add(-updated)),
+	}
+}
+
+func summary(events []Event) []string {
+	var out []string
+	for _, e := range events {
+		out = append(out, e.Type.String()+" "+e.Report.ID)
+	}
+	return out
+}
+
+func TestPoll(t *testing.T) {
+	api, c, done := newFakeAPI(t)
+	defer done()
+
+	api.put("old", 3*time.Hour, 3*time.Hour)
+	api.put("a", 30*time.Minute, 30*time.Minute)
+	api.put("b", 20*time.Minute, 20*time.Minute)
+
+	w := New(c, "e1")
+	w.InitialLookback = time.Hour
+
+	events, err := w.Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary(events), []string{"NewReport a", "NewReport b"}; !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+
+	// nothing new, and the overlap does not reach back before the initial lookback
+	if events, _ = w.Poll(); len(events) != 0 {
+		t.Errorf("got %v, want no events", summary(events))
+	}
+	floor := trustar.TimeToMsEpoch(time.Now().Add(-time.Hour)) - 1000
+	if from := api.froms[len(api.froms)-1]; from < floor {
+		t.Errorf("got from %d, want no earlier than the initial lookback", from)
+	}
+
+	api.put("a", 30*time.Minute, time.Minute)
+	api.put("c", 30*time.Second, 30*time.Second)
+	events, _ = w.Poll()
+	if got, want := summary(events), []string{"UpdatedReport a", "NewReport c"}; !equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestRunPersistsState(t *testing.T) {
+	api, c, done := newFakeAPI(t)
+	defer done()
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	api.put("a", time.Minute, time.Minute)
+
+	run := func() []string {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		var got []string
+		w := New(c, "e1")
+		w.InitialLookback = time.Hour
+		w.StatePath = filepath.Join(dir, "state.json")
+		w.Interval = time.Hour
+		w.Handler = func(e Event) {
+			got = append(got, e.Type.String()+" "+e.Report.ID)
+			cancel()
+		}
+
+		// the handler cancels after the first event, otherwise stop after the first poll
+		go func() {
+			time.Sleep(200 * time.Millisecond)
+			cancel()
+		}()
+		if err := w.Run(ctx); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	if got := run(); !equal(got, []string{"NewReport a"}) {
+		t.Errorf("got %v on the first run", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "state.json")); err != nil {
+		t.Fatalf("got %v, want the state saved", err)
+	}
+
+	// a restart neither replays nor misses reports
+	api.put("b", time.Second, time.Second)
+	if got := run(); !equal(got, []string{"NewReport b"}) {
+		t.Errorf("got %v after a restart, want only the new report", got)
+	}
+}
+
+func TestRunUndeliveredEvents(t *testing.T) {
+	api, c, done := newFakeAPI(t)
+	defer done()
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// more reports than the event channel buffers
+	for i := 0; i < 70; i++ {
+		age := time.Duration(100-i) * time.Second
+		api.put("r"+strconv.Itoa(i), age, age)
+	}
+
+	newWatcher := func() *Watcher {
+		w := New(c, "e1")
+		w.InitialLookback = time.Hour
+		w.StatePath = filepath.Join(dir, "state.json")
+		return w
+	}
+
+	// nothing reads the channel, so the watcher is cancelled with events still to deliver
+	ctx, cancel := context.WithCancel(context.Background())
+	w := newWatcher()
+	events := w.Events()
+	errc := make(chan error)
+	go func() { errc <- w.Run(ctx) }()
+
+	time.Sleep(100 * time.Millisecond)
+	cancel()
+	if err := <-errc; err != nil {
+		t.Fatal(err)
+	}
+
+	delivered := 0
+	for e := range events {
+		if e.Report.ID != "r"+strconv.Itoa(delivered) {
+			t.Errorf("got %s, want r%d", e.Report.ID, delivered)
+		}
+		delivered++
+	}
+	if delivered == 0 || delivered == 70 {
+		t.Fatalf("got %d events delivered, want the channel to fill up", delivered)
+	}
+
+	// the undelivered events are polled again after a restart
+	got, err := newWatcher().Poll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 70-delivered || got[0].Report.ID != "r"+strconv.Itoa(delivered) {
+		t.Errorf("got %d events starting at %s, want the %d undelivered", len(got), got[0].Report.ID, 70-delivered)
+	}
+}
+
+func TestRunQuotaCheck(t *testing.T) {
+	reset := 300 * time.Millisecond
+
+	tests := []struct {
+		name         string
+		quotas       trustar.RequestQuotas
+		minRemaining int64
+		checks       bool
+		wait         time.Duration
+	}{
+		{name: "plenty remaining", checks: true},
+		{
+			name:   "few remaining",
+			quotas: trustar.RequestQuotas{{MaxRequests: 100, UsedRequests: 90}},
+			checks: true,
+			wait:   reset,
+		},
+		{
+			name:         "disabled",
+			quotas:       trustar.RequestQuotas{{MaxRequests: 100, UsedRequests: 100}},
+			minRemaining: -1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api, c, done := newFakeAPI(t)
+			defer done()
+
+			start := time.Now()
+			for i := range tt.quotas {
+				tt.quotas[i].NextResetTime = trustar.TimeToMsEpoch(start.Add(reset))
+			}
+			api.quotas = tt.quotas
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			w := New(c, "e1")
+			w.MinRemaining = tt.minRemaining
+			errc := make(chan error)
+			go func() { errc <- w.Run(ctx) }()
+
+			// the quotas are checked before the first poll, which waits for them to reset if few remain
+			var polled time.Duration
+			for polled == 0 && time.Since(start) < 5*time.Second {
+				time.Sleep(5 * time.Millisecond)
+				api.mu.Lock()
+				if len(api.froms) > 0 {
+					polled = time.Since(start)
+				}
+				api.mu.Unlock()
+			}
+			cancel()
+			if err := <-errc; err != nil {
+				t.Fatal(err)
+			}
+
+			if polled == 0 {
+				t.Fatal("the enclave was never polled")
+			}
+			if polled < tt.wait {
+				t.Errorf("polled after %v, want the quota reset %v awaited", polled, tt.wait)
+			}
+			if tt.wait == 0 && polled >= reset {
+				t.Errorf("polled after %v, want no wait", polled)
+			}
+			if (api.quotaChecks > 0) != tt.checks {
+				t.Errorf("got %d quota checks, want checks %v", api.quotaChecks, tt.checks)
+			}
+		})
+	}
+}
+
+func TestRunOnce(t *testing.T) {
+	_, c, done := newFakeAPI(t)
+	defer done()
+
+	dir, err := ioutil.TempDir("", "watch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "state.json")
+	if err := ioutil.WriteFile(path, []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	w := New(c, "e1")
+	w.StatePath = path
+	events := w.Events()
+
+	if err := w.Run(context.Background()); err == nil {
+		t.Fatal("got no error loading a corrupt state file")
+	}
+	if _, ok := <-events; ok {
+		t.Error("got the events channel open after Run returned")
+	}
+
+	// a second call fails instead of closing the channel again
+	if err := w.Run(context.Background()); err == nil || err.Error() != "watch: Run can only be called once" {
+		t.Errorf("got %v, want the second Run refused", err)
+	}
+
+	// a failed load is not replaced by an empty state, so it is retried once the file is fixed
+	if _, err := w.Poll(); err == nil {
+		t.Error("got no error polling with a corrupt state file")
+	}
+	if err := ioutil.WriteFile(path, []byte(`{"enclaves":{"e1":{"highWater":5,"floor":5}}}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Poll(); err != nil {
+		t.Fatal(err)
+	}
+	if hw := w.state.Enclaves["e1"].HighWater; hw != 5 {
+		t.Errorf("got high-water mark %d, want the persisted 5", hw)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	w := &Watcher{Interval: time.Minute, MaxBackoff: 10 * time.Minute}
+
+	for failures, want := range map[int]time.Duration{1: time.Minute, 2: 2 * time.Minute, 4: 8 * time.Minute, 5: 10 * time.Minute, 50: 10 * time.Minute} {
+		if got := w.backoff(failures); got != want {
+			t.Errorf("backoff(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,336 @@
+package trustar
+
+import (
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultWhitelistTTL is how long a WhitelistCache serves the whitelist before reloading it
+const DefaultWhitelistTTL = 15 * time.Minute
+
+// WhitelistCache keeps a local copy of the company whitelist so indicators can be checked without a request.
+// Besides exact values, whitelisted CIDR blocks match the IP addresses they contain and whitelisted
+// domains match their subdomains, including the hosts of URLs and email addresses.
+// It is safe for concurrent use.
+type WhitelistCache struct {
+	mu       sync.RWMutex
+	client   *Client
+	entries  map[string]Indicator // keyed by lowercased value
+	networks map[string]*net.IPNet
+	domains  map[string]map[string]bool // domain -> keys of the entries matching its subdomains, such as x.com and *.x.com
+	loadedAt time.Time
+
+	// TTL is how long the whitelist is served before it is reloaded on the next lookup, defaults to DefaultWhitelistTTL
+	TTL time.Duration
+}
+
+// NewWhitelistCache returns a WhitelistCache for the given Client. The whitelist is loaded on first use.
+func NewWhitelistCache(c *Client) *WhitelistCache {
+	return &WhitelistCache{client: c, TTL: DefaultWhitelistTTL}
+}
+
+// Load fetches every page of the whitelist, replacing the cached copy
+func (w *WhitelistCache) Load() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.load()
+}
+
+func (w *WhitelistCache) load() error {
+	var items []Indicator
+	err := w.client.ForEachWhitelistIndicator(url.Values{}, func(ind Indicator) error {
+		items = append(items, ind)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	w.entries = make(map[string]Indicator, len(items))
+	w.networks = make(map[string]*net.IPNet)
+	w.domains = make(map[string]map[string]bool)
+	for _, ind := range items {
+		w.add(ind, true)
+	}
+	w.loadedAt = time.Now()
+
+	return nil
+}
+
+// ensure reloads the whitelist if it has never been loaded or the TTL has passed
+func (w *WhitelistCache) ensure() error {
+	ttl := w.TTL
+	if ttl <= 0 {
+		ttl = DefaultWhitelistTTL
+	}
+
+	w.mu.RLock()
+	fresh := w.entries != nil && time.Since(w.loadedAt) < ttl
+	w.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	// another caller may have reloaded while we waited for the lock
+	if w.entries != nil && time.Since(w.loadedAt) < ttl {
+		return nil
+	}
+	return w.load()
+}
+
+// add caches an entry. Entries typed DOMAIN match their subdomains; untyped hostnames do too when guessDomain is set.
+func (w *WhitelistCache) add(ind Indicator, guessDomain bool) {
+	key := strings.ToLower(strings.TrimSpace(ind.Value))
+	if key == "" {
+		return
+	}
+	w.entries[key] = ind
+
+	if _, network, err := net.ParseCIDR(key); err == nil {
+		w.networks[key] = network
+		return
+	}
+
+	t := strings.ToUpper(ind.IndicatorType)
+	if (t == "DOMAIN" || (t == "" && guessDomain)) && isHostname(key) {
+		domain := strings.TrimPrefix(key, "*.")
+		if w.domains[domain] == nil {
+			w.domains[domain] = map[string]bool{}
+		}
+		w.domains[domain][key] = true
+	}
+}
+
+func (w *WhitelistCache) remove(value string) {
+	key := strings.ToLower(strings.TrimSpace(value))
+	delete(w.entries, key)
+	delete(w.networks, key)
+
+	domain := strings.TrimPrefix(key, "*.")
+	if keys := w.domains[domain]; keys[key] {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(w.domains, domain)
+		}
+	}
+}
+
+// Match returns the whitelist entry that covers the value, if any
+func (w *WhitelistCache) Match(value string) (Indicator, bool, error) {
+	if err := w.ensure(); err != nil {
+		return Indicator{}, false, err
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	ind, ok := w.match(value)
+	return ind, ok, nil
+}
+
+func (w *WhitelistCache) match(value string) (Indicator, bool) {
+	key := strings.ToLower(strings.TrimSpace(value))
+	if ind, ok := w.entries[key]; ok {
+		return ind, true
+	}
+
+	if ip := net.ParseIP(key); ip != nil {
+		for k, network := range w.networks {
+			if network.Contains(ip) {
+				return w.entries[k], true
+			}
+		}
+		return Indicator{}, false
+	}
+
+	host := hostOf(key)
+	if host == "" {
+		return Indicator{}, false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ind, ok := w.entries[host]; ok {
+			return ind, true
+		}
+		for k, network := range w.networks {
+			if network.Contains(ip) {
+				return w.entries[k], true
+			}
+		}
+		return Indicator{}, false
+	}
+
+	// walk up the labels: a.b.example.com, b.example.com, example.com, com
+	for h := host; h != ""; {
+		for _, k := range []string{h, "*." + h} {
+			if w.domains[h][k] {
+				return w.entries[k], true
+			}
+		}
+		i := strings.IndexByte(h, '.')
+		if i < 0 {
+			break
+		}
+		h = h[i+1:]
+	}
+
+	return Indicator{}, false
+}
+
+// Contains reports whether the value is covered by the whitelist
+func (w *WhitelistCache) Contains(value string) (bool, error) {
+	_, ok, err := w.Match(value)
+	return ok, err
+}
+
+// Filter returns the indicators that are not covered by the whitelist
+func (w *WhitelistCache) Filter(indicators []Indicator) ([]Indicator, error) {
+	if err := w.ensure(); err != nil {
+		return nil, err
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	kept := make([]Indicator, 0, len(indicators))
+	for _, ind := range indicators {
+		if _, ok := w.match(ind.Value); !ok {
+			kept = append(kept, ind)
+		}
+	}
+	return kept, nil
+}
+
+// FilterValues returns the values that are not covered by the whitelist
+func (w *WhitelistCache) FilterValues(values []string) ([]string, error) {
+	if err := w.ensure(); err != nil {
+		return nil, err
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	kept := make([]string, 0, len(values))
+	for _, v := range values {
+		if _, ok := w.match(v); !ok {
+			kept = append(kept, v)
+		}
+	}
+	return kept, nil
+}
+
+// Entries returns the cached whitelist
+func (w *WhitelistCache) Entries() ([]Indicator, error) {
+	if err := w.ensure(); err != nil {
+		return nil, err
+	}
+
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+
+	entries := make([]Indicator, 0, len(w.entries))
+	for _, ind := range w.entries {
+		entries = append(entries, ind)
+	}
+	return entries, nil
+}
+
+// Add whitelists the values with WhitelistIndicators and adds them to the cache, typed as the API
+// returned them. Values the API returns no type for only match exactly until the next Load.
+func (w *WhitelistCache) Add(values []string) error {
+	added, err := w.client.whitelistIndicators(values)
+	if err != nil {
+		return err
+	}
+
+	types := make(map[string]string, len(added))
+	for _, ind := range added {
+		types[strings.ToLower(strings.TrimSpace(ind.Value))] = ind.IndicatorType
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.entries != nil {
+		for _, v := range values {
+			key := strings.ToLower(strings.TrimSpace(v))
+			if _, ok := w.entries[key]; ok {
+				continue
+			}
+			w.add(Indicator{Value: v, IndicatorType: types[key]}, false)
+		}
+	}
+	return nil
+}
+
+// Delete removes a value from the whitelist with DeleteFromWhitelist and from the cache.
+// If indicatorType is empty the type of the cached entry is used.
+func (w *WhitelistCache) Delete(indicatorType, value string) error {
+	if indicatorType == "" {
+		if err := w.ensure(); err != nil {
+			return err
+		}
+		w.mu.RLock()
+		indicatorType = w.entries[strings.ToLower(strings.TrimSpace(value))].IndicatorType
+		w.mu.RUnlock()
+	}
+
+	v := url.Values{}
+	v.Set("indicatorType", indicatorType)
+	v.Set("value", value)
+	if err := w.client.DeleteFromWhitelist(v); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.entries != nil {
+		w.remove(value)
+	}
+	return nil
+}
+
+// hostOf returns the host of a URL, the domain of an email address, or the value itself
+func hostOf(value string) string {
+	host := value
+	if strings.Contains(value, "://") {
+		u, err := url.Parse(value)
+		if err != nil {
+			return ""
+		}
+		host = u.Hostname()
+	} else if i := strings.LastIndexByte(value, '@'); i >= 0 {
+		host = value[i+1:]
+	} else if i := strings.IndexAny(value, "/?#"); i >= 0 {
+		// scheme-less URL such as example.com/path
+		host = value[:i]
+	}
+
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(host, ".")
+}
+
+// isHostname reports whether the value looks like a domain name
+func isHostname(value string) bool {
+	value = strings.TrimPrefix(value, "*.")
+	if !strings.Contains(value, ".") || net.ParseIP(value) != nil {
+		return false
+	}
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.', r == '_':
+		default:
+			return false
+		}
+	}
+	return true
+}
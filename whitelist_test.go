@@ -0,0 +1,236 @@
+package trustar
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+// whitelistServer keeps a whitelist, serving it in pages of two. Added values are typed from types.
+type whitelistServer struct {
+	mu      sync.Mutex
+	entries []Indicator
+	types   map[string]string
+	loads   int
+	deletes []url.Values
+}
+
+func (s *whitelistServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch r.Method {
+	case "GET":
+		page, _ := strconv.Atoi(r.URL.Query().Get("pageNumber"))
+		if page == 0 {
+			s.loads++
+		}
+		resp := WhitelistIndicatorsResponse{PageNumber: int64(page)}
+		for i := page * 2; i < len(s.entries) && i < page*2+2; i++ {
+			resp.Items = append(resp.Items, s.entries[i])
+		}
+		resp.HasNext = page*2+2 < len(s.entries)
+		writeJSON(w, resp)
+	case "POST":
+		var values []string
+		json.NewDecoder(r.Body).Decode(&values)
+		var added []Indicator
+		for _, v := range values {
+			ind := Indicator{Value: v, IndicatorType: s.types[v]}
+			s.entries = append(s.entries, ind)
+			if ind.IndicatorType != "" {
+				added = append(added, ind)
+			}
+		}
+		writeJSON(w, added)
+	case "DELETE":
+		q := r.URL.Query()
+		s.deletes = append(s.deletes, q)
+		for i, ind := range s.entries {
+			if ind.Value == q.Get("value") {
+				s.entries = append(s.entries[:i], s.entries[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+func newWhitelistCache(t *testing.T, entries ...Indicator) (*WhitelistCache, *whitelistServer, func()) {
+	t.Helper()
+
+	srv := &whitelistServer{entries: entries, types: map[string]string{}}
+	c, done := newTestClient(t, srv.ServeHTTP)
+	return NewWhitelistCache(c), srv, done
+}
+
+func TestWhitelistMatch(t *testing.T) {
+	w, _, done := newWhitelistCache(t,
+		Indicator{Value: "Example.com", IndicatorType: "DOMAIN"},
+		Indicator{Value: "*.wild.org", IndicatorType: "DOMAIN"},
+		Indicator{Value: "10.0.0.0/8", IndicatorType: "CIDR_BLOCK"},
+		Indicator{Value: "192.168.1.1", IndicatorType: "IP"},
+		Indicator{Value: "bad@evil.com", IndicatorType: "EMAIL_ADDRESS"},
+		Indicator{Value: "untyped.net"},
+		Indicator{Value: "host.example.org", IndicatorType: "URL"},
+	)
+	defer done()
+
+	tests := []struct {
+		value string
+		entry string // the matching entry, empty if none
+	}{
+		{"example.com", "Example.com"},
+		{" EXAMPLE.com ", "Example.com"},
+		{"a.b.example.com", "Example.com"},
+		{"notexample.com", ""},
+		{"example.com.evil.net", ""},
+		{"https://www.example.com:8443/path", "Example.com"},
+		{"example.com/path?x=1", "Example.com"},
+		{"user@mail.example.com", "Example.com"},
+		{"sub.wild.org", "*.wild.org"},
+		{"deep.sub.wild.org", "*.wild.org"},
+		{"10.1.2.3", "10.0.0.0/8"},
+		{"http://10.9.9.9/x", "10.0.0.0/8"},
+		{"11.0.0.1", ""},
+		{"192.168.1.1", "192.168.1.1"},
+		{"http://192.168.1.1:8080/", "192.168.1.1"},
+		{"192.168.1.2", ""},
+		{"bad@evil.com", "bad@evil.com"},
+		{"other@evil.com", ""},
+		{"www.untyped.net", "untyped.net"}, // untyped hostnames from the API are treated as domains
+		{"sub.host.example.org", ""},       // entries typed as something other than DOMAIN match exactly
+		{"com", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		ind, ok, err := w.Match(tt.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ok != (tt.entry != "") || ind.Value != tt.entry {
+			t.Errorf("Match(%q) = %q, %v, want %q", tt.value, ind.Value, ok, tt.entry)
+		}
+	}
+
+	kept, err := w.FilterValues([]string{"example.com", "safe.io", "10.2.3.4"})
+	if err != nil || !reflect.DeepEqual(kept, []string{"safe.io"}) {
+		t.Errorf("got %v, %v, want [safe.io]", kept, err)
+	}
+	inds, err := w.Filter([]Indicator{{Value: "safe.io"}, {Value: "www.example.com"}})
+	if err != nil || len(inds) != 1 || inds[0].Value != "safe.io" {
+		t.Errorf("got %v, %v, want [safe.io]", inds, err)
+	}
+	if entries, err := w.Entries(); err != nil || len(entries) != 7 {
+		t.Errorf("got %d entries, %v, want 7", len(entries), err)
+	}
+}
+
+func TestWhitelistTTL(t *testing.T) {
+	w, srv, done := newWhitelistCache(t, Indicator{Value: "a.com", IndicatorType: "DOMAIN"})
+	defer done()
+
+	for i := 0; i < 3; i++ {
+		if ok, err := w.Contains("a.com"); err != nil || !ok {
+			t.Fatalf("got %v, %v", ok, err)
+		}
+	}
+	if srv.loads != 1 {
+		t.Errorf("got %d loads, want 1 within the TTL", srv.loads)
+	}
+
+	srv.entries = nil
+	w.TTL = time.Nanosecond
+	if ok, _ := w.Contains("a.com"); ok || srv.loads != 2 {
+		t.Errorf("got %v after %d loads, want the expired whitelist reloaded", ok, srv.loads)
+	}
+
+	if err := w.Load(); err != nil || srv.loads != 3 {
+		t.Errorf("got %v after %d loads, want Load to fetch", err, srv.loads)
+	}
+}
+
+func TestWhitelistAdd(t *testing.T) {
+	w, srv, done := newWhitelistCache(t, Indicator{Value: "old.com", IndicatorType: "URL"})
+	defer done()
+	srv.types["new.com"] = "DOMAIN"
+	srv.types["old.com"] = "DOMAIN"
+
+	if err := w.Load(); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Add([]string{"new.com", "plain.org", "old.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		value string
+		match bool
+	}{
+		{"www.new.com", true},    // typed DOMAIN by the API response
+		{"plain.org", true},      // untyped in the response, matched exactly
+		{"www.plain.org", false}, // and not as a domain
+		{"www.old.com", false},   // an existing entry keeps its type
+	}
+	for _, tt := range tests {
+		if ok, _ := w.Contains(tt.value); ok != tt.match {
+			t.Errorf("Contains(%q) = %v, want %v", tt.value, ok, tt.match)
+		}
+	}
+	if srv.loads != 1 {
+		t.Errorf("got %d loads, want Add to update the cache without reloading", srv.loads)
+	}
+}
+
+func TestWhitelistDelete(t *testing.T) {
+	w, srv, done := newWhitelistCache(t,
+		Indicator{Value: "x.com", IndicatorType: "DOMAIN"},
+		Indicator{Value: "*.x.com", IndicatorType: "DOMAIN"},
+		Indicator{Value: "10.0.0.0/8", IndicatorType: "CIDR_BLOCK"},
+	)
+	defer done()
+
+	if err := w.Delete("", "x.com"); err != nil {
+		t.Fatal(err)
+	}
+	if got := srv.deletes[0].Encode(); got != "indicatorType=DOMAIN&value=x.com" {
+		t.Errorf("got delete %s, want the cached type", got)
+	}
+
+	// *.x.com still covers the subdomains
+	if ind, ok, _ := w.Match("www.x.com"); !ok || ind.Value != "*.x.com" {
+		t.Errorf("got %q, %v, want *.x.com to still match", ind.Value, ok)
+	}
+
+	if err := w.Delete("DOMAIN", "*.x.com"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := w.Contains("www.x.com"); ok {
+		t.Error("got a match after deleting both entries")
+	}
+
+	if err := w.Delete("CIDR_BLOCK", "10.0.0.0/8"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := w.Contains("10.1.1.1"); ok {
+		t.Error("got a match after deleting the CIDR block")
+	}
+
+	var values []string
+	for _, q := range srv.deletes {
+		values = append(values, q.Get("value"))
+	}
+	sort.Strings(values)
+	if want := []string{"*.x.com", "10.0.0.0/8", "x.com"}; !reflect.DeepEqual(values, want) {
+		t.Errorf("got deletes %v, want %v", values, want)
+	}
+	if srv.loads != 1 {
+		t.Errorf("got %d loads, want 1", srv.loads)
+	}
+}